@@ -73,3 +73,13 @@ func (es emailService) SendVerifyEmail(subject, recipient string, data interface
 func (es emailService) SendTFAEmail(subject, recipient string, data interface{}) error {
 	return es.SendTemplateEmail(subject, recipient, es.tmpltr.TFATemplate, data)
 }
+
+// SendMagicLinkEmail sends emails with passwordless login link.
+func (es emailService) SendMagicLinkEmail(subject, recipient string, data interface{}) error {
+	return es.SendTemplateEmail(subject, recipient, es.tmpltr.MagicLinkTemplate, data)
+}
+
+// SendAccountLockedEmail sends emails about account lockout after failed login attempts.
+func (es emailService) SendAccountLockedEmail(subject, recipient string, data interface{}) error {
+	return es.SendTemplateEmail(subject, recipient, es.tmpltr.AccountLockedTemplate, data)
+}
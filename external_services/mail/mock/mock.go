@@ -57,3 +57,13 @@ func (es emailService) SendVerifyEmail(subject, recipient string, data interface
 func (es emailService) SendTFAEmail(subject, recipient string, data interface{}) error {
 	return nil
 }
+
+// SendMagicLinkEmail returns nil error.
+func (es emailService) SendMagicLinkEmail(subject, recipient string, data interface{}) error {
+	return nil
+}
+
+// SendAccountLockedEmail returns nil error.
+func (es emailService) SendAccountLockedEmail(subject, recipient string, data interface{}) error {
+	return nil
+}
@@ -126,6 +126,16 @@ func (es *EmailService) SendTFAEmail(subject, recipient string, data interface{}
 	return es.SendTemplateEmail(subject, recipient, es.tmpltr.TFATemplate, data)
 }
 
+// SendMagicLinkEmail sends emails with passwordless login link.
+func (es *EmailService) SendMagicLinkEmail(subject, recipient string, data interface{}) error {
+	return es.SendTemplateEmail(subject, recipient, es.tmpltr.MagicLinkTemplate, data)
+}
+
+// SendAccountLockedEmail sends emails about account lockout after failed login attempts.
+func (es *EmailService) SendAccountLockedEmail(subject, recipient string, data interface{}) error {
+	return es.SendTemplateEmail(subject, recipient, es.tmpltr.AccountLockedTemplate, data)
+}
+
 func logAWSError(err error) {
 	if err == nil {
 		return
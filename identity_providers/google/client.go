@@ -0,0 +1,105 @@
+package google
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/madappgang/identifo/identity_providers/oidc"
+	"github.com/madappgang/identifo/model"
+)
+
+// CertsURL is where Google publishes public keys for ID tokens, in JWKS format.
+const CertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// Google ID tokens are issued by one of these issuers.
+var validIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+var (
+	// ErrEmptyClientID is when the app has no Google client ID to check tokens against.
+	ErrEmptyClientID = errors.New("Google client ID is empty")
+	// ErrInvalidAudience is when ID token is issued for another client.
+	ErrInvalidAudience = errors.New("Google ID token is issued for another client")
+	// ErrInvalidIssuer is when ID token is not issued by Google.
+	ErrInvalidIssuer = errors.New("Google ID token has invalid issuer")
+)
+
+// defaultKeySet is shared between clients, so Google public keys are fetched once for all requests.
+var defaultKeySet = oidc.NewKeySet(CertsURL, &http.Client{Timeout: 15 * time.Second})
+
+// NewClient creates new client for verifying Google ID tokens issued for the app.
+func NewClient(googleInfo *model.GoogleInfo) *Client {
+	return &Client{
+		ClientID: googleInfo.ClientID,
+		Keys:     defaultKeySet,
+	}
+}
+
+// Client verifies Google ID tokens offline, against Google public keys.
+type Client struct {
+	ClientID string
+	Keys     *oidc.KeySet
+}
+
+// User is what we can get about the user from Google ID token.
+type User struct {
+	ID    string
+	Email string
+}
+
+type idTokenClaims struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	jwt.StandardClaims
+}
+
+// MyProfile verifies ID token and returns the user it is issued for.
+// Email is returned only if Google has verified it.
+func (c *Client) MyProfile(idToken string) (User, error) {
+	var user User
+	if c.ClientID == "" {
+		return user, ErrEmptyClientID
+	}
+
+	claims := idTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return c.Keys.Key(kid)
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if !claims.VerifyAudience(c.ClientID, true) {
+		return user, ErrInvalidAudience
+	}
+	if !validIssuer(claims.Issuer) {
+		return user, ErrInvalidIssuer
+	}
+	if !claims.VerifyExpiresAt(jwt.TimeFunc().Unix(), true) {
+		return user, fmt.Errorf("Google ID token has no expiration time")
+	}
+
+	user.ID = claims.Subject
+	if user.ID == "" {
+		return user, fmt.Errorf("ID token has empty subject")
+	}
+	if claims.EmailVerified {
+		user.Email = claims.Email
+	}
+	return user, nil
+}
+
+func validIssuer(iss string) bool {
+	for _, vi := range validIssuers {
+		if iss == vi {
+			return true
+		}
+	}
+	return false
+}
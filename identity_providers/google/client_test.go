@@ -0,0 +1,169 @@
+package google_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/madappgang/identifo/identity_providers/google"
+	"github.com/madappgang/identifo/identity_providers/oidc"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	testClientID = "test-client.apps.googleusercontent.com"
+	testKeyID    = "test-key"
+)
+
+// stubJWKS serves the public key the way Google does, counting requests.
+func stubJWKS(t *testing.T, key *rsa.PublicKey, requests *int32) *httptest.Server {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": testKeyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Unable to marshal JWKS: %v", err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+}
+
+func idToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Unable to sign token: %v", err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now().Unix()
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testClientID,
+		"sub":            "110169484474386276334",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"iat":            now,
+		"exp":            now + 3600,
+	}
+}
+
+func TestMyProfile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	var requests int32
+	srv := stubJWKS(t, &key.PublicKey, &requests)
+	defer srv.Close()
+
+	c := google.NewClient(&model.GoogleInfo{ClientID: testClientID})
+	c.Keys = oidc.NewKeySet(srv.URL, srv.Client())
+
+	with := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", idToken(t, key, testKeyID, validClaims()), false},
+		{"issuer without scheme", idToken(t, key, testKeyID, with("iss", "accounts.google.com")), false},
+		{"wrong audience", idToken(t, key, testKeyID, with("aud", "another-client")), true},
+		{"wrong issuer", idToken(t, key, testKeyID, with("iss", "https://evil.example.com")), true},
+		{"expired", idToken(t, key, testKeyID, with("exp", time.Now().Add(-time.Minute).Unix())), true},
+		{"no expiration", idToken(t, key, testKeyID, with("exp", nil)), true},
+		{"empty subject", idToken(t, key, testKeyID, with("sub", nil)), true},
+		{"unknown key", idToken(t, key, "unknown-key", validClaims()), true},
+		{"wrong signature", idToken(t, otherKey, testKeyID, validClaims()), true},
+		{"malformed", "not.a.token", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := c.MyProfile(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MyProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (user.ID != "110169484474386276334" || user.Email != "user@example.com") {
+				t.Errorf("MyProfile() = %+v, unexpected user", user)
+			}
+		})
+	}
+
+	// Keys are cached, and unknown key does not cause refetch right after keys are fetched.
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+}
+
+func TestMyProfileUnverifiedEmail(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	var requests int32
+	srv := stubJWKS(t, &key.PublicKey, &requests)
+	defer srv.Close()
+
+	c := google.NewClient(&model.GoogleInfo{ClientID: testClientID})
+	c.Keys = oidc.NewKeySet(srv.URL, srv.Client())
+
+	claims := validClaims()
+	claims["email_verified"] = false
+
+	user, err := c.MyProfile(idToken(t, key, testKeyID, claims))
+	if err != nil {
+		t.Fatalf("MyProfile() error = %v", err)
+	}
+	if user.Email != "" {
+		t.Errorf("MyProfile() returned unverified email %q", user.Email)
+	}
+}
+
+func TestMyProfileEmptyClientID(t *testing.T) {
+	c := google.NewClient(&model.GoogleInfo{})
+	if _, err := c.MyProfile("token"); err != google.ErrEmptyClientID {
+		t.Errorf("MyProfile() error = %v, want %v", err, google.ErrEmptyClientID)
+	}
+}
@@ -0,0 +1,220 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/madappgang/identifo/model"
+)
+
+// discoveryPath is where providers publish their metadata, relative to the issuer URL.
+const discoveryPath = "/.well-known/openid-configuration"
+
+var (
+	// ErrEmptyClientID is when the provider has no client ID to check tokens against.
+	ErrEmptyClientID = errors.New("OpenID Connect client ID is empty")
+	// ErrInvalidAudience is when ID token is issued for another client.
+	ErrInvalidAudience = errors.New("ID token is issued for another client")
+	// ErrInvalidIssuer is when ID token is issued by another provider.
+	ErrInvalidIssuer = errors.New("ID token has invalid issuer")
+	// ErrNoExpiration is when ID token has no expiration time.
+	ErrNoExpiration = errors.New("ID token has no expiration time")
+)
+
+// keySets caches provider keys by issuer, so discovery and keys are shared between requests.
+var (
+	keySetsMu sync.Mutex
+	keySets   = make(map[string]*KeySet)
+)
+
+// NewClient creates new client for verifying ID tokens the provider issues for the app.
+func NewClient(provider model.OIDCProvider) *Client {
+	return &Client{
+		Issuer:       provider.Issuer,
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		HTTPClient:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Client verifies ID tokens of the OpenID Connect provider offline, against the keys published by the provider.
+// Provider metadata is found with OpenID Connect Discovery.
+type Client struct {
+	Issuer   string
+	ClientID string
+	// ClientSecret is used for ID tokens signed with HMAC, if the provider does so.
+	ClientSecret string
+	HTTPClient   *http.Client
+}
+
+// User is what we can get about the user from ID token.
+type User struct {
+	ID    string
+	Email string
+}
+
+// MyProfile verifies ID token and returns the user it is issued for.
+// Email is returned only if the provider has verified it.
+func (c *Client) MyProfile(idToken string) (User, error) {
+	var user User
+	if c.ClientID == "" {
+		return user, ErrEmptyClientID
+	}
+
+	claims := idTokenClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, &claims, c.key); err != nil {
+		return user, err
+	}
+
+	if claims.Issuer != c.Issuer {
+		return user, ErrInvalidIssuer
+	}
+	// If there are several audiences, the token must be issued to the client, as an authorized party.
+	if !claims.Audience.contains(c.ClientID) {
+		return user, ErrInvalidAudience
+	}
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != c.ClientID {
+		return user, ErrInvalidAudience
+	}
+
+	user.ID = claims.Subject
+	if user.ID == "" {
+		return user, fmt.Errorf("ID token has empty subject")
+	}
+	if claims.emailVerified() {
+		user.Email = claims.Email
+	}
+	return user, nil
+}
+
+// key returns the key to verify the token signature with.
+func (c *Client) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		ks, err := c.keySet()
+		if err != nil {
+			return nil, err
+		}
+		kid, _ := token.Header["kid"].(string)
+		return ks.Key(kid)
+	case *jwt.SigningMethodHMAC:
+		if c.ClientSecret == "" {
+			return nil, fmt.Errorf("ID token is signed with client secret, but the secret is not set")
+		}
+		return []byte(c.ClientSecret), nil
+	}
+	return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+}
+
+// keySet discovers where provider keys are published, if it has not been done yet.
+func (c *Client) keySet() (*KeySet, error) {
+	keySetsMu.Lock()
+	ks, ok := keySets[c.Issuer]
+	keySetsMu.Unlock()
+	if ok {
+		return ks, nil
+	}
+
+	resp, err := c.HTTPClient.Get(strings.TrimSuffix(c.Issuer, "/") + discoveryPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Discovery response error, status: %d", resp.StatusCode)
+	}
+
+	var metadata struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	// Issuer in metadata must be exactly the same, see OpenID Connect Discovery 1.0, section 4.3.
+	if metadata.Issuer != c.Issuer {
+		return nil, fmt.Errorf("Discovered issuer %s does not match %s", metadata.Issuer, c.Issuer)
+	}
+	if metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("Provider %s does not publish its keys", c.Issuer)
+	}
+
+	keySetsMu.Lock()
+	defer keySetsMu.Unlock()
+	if ks, ok = keySets[c.Issuer]; !ok {
+		ks = NewKeySet(metadata.JWKSURI, c.HTTPClient)
+		keySets[c.Issuer] = ks
+	}
+	return ks, nil
+}
+
+// audience is "aud" claim, which is either a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = audience(multiple)
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+type idTokenClaims struct {
+	Issuer          string      `json:"iss"`
+	Subject         string      `json:"sub"`
+	Audience        audience    `json:"aud"`
+	AuthorizedParty string      `json:"azp,omitempty"`
+	ExpiresAt       int64       `json:"exp"`
+	IssuedAt        int64       `json:"iat,omitempty"`
+	NotBefore       int64       `json:"nbf,omitempty"`
+	Email           string      `json:"email,omitempty"`
+	EmailVerified   interface{} `json:"email_verified,omitempty"`
+}
+
+// Valid implements jwt.Claims interface, checks time based claims.
+func (c idTokenClaims) Valid() error {
+	now := jwt.TimeFunc().Unix()
+	if c.ExpiresAt == 0 {
+		return ErrNoExpiration
+	}
+	if now > c.ExpiresAt {
+		return fmt.Errorf("ID token is expired")
+	}
+	if c.NotBefore != 0 && now < c.NotBefore {
+		return fmt.Errorf("ID token is not valid yet")
+	}
+	return nil
+}
+
+// emailVerified reads "email_verified" claim, some providers send it as a string.
+func (c idTokenClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeysLifespan is used when the provider does not tell how long to cache the keys.
+	defaultKeysLifespan = time.Hour
+	// minRefreshInterval limits refetching keys on unknown key IDs.
+	minRefreshInterval = time.Minute
+)
+
+// ErrUnknownKey is when the token is signed with the key the provider does not publish.
+var ErrUnknownKey = errors.New("ID token is signed with unknown key")
+
+// KeySet is a set of provider public keys in JWKS format, cached for as long as the provider allows.
+type KeySet struct {
+	url        string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+// NewKeySet creates key set fetched from the JWKS URL.
+func NewKeySet(url string, httpClient *http.Client) *KeySet {
+	return &KeySet{url: url, httpClient: httpClient}
+}
+
+// Key returns public key by its ID, fetching keys if they have expired.
+// Keys are also refetched when the key is unknown, as the provider may have rotated them already.
+// RSA keys are returned as *rsa.PublicKey, elliptic curve keys as *ecdsa.PublicKey.
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	key, ok := ks.keys[kid]
+	if ok && now.Before(ks.expiresAt) {
+		return key, nil
+	}
+	if !ok && now.Sub(ks.fetchedAt) < minRefreshInterval && now.Before(ks.expiresAt) {
+		return nil, ErrUnknownKey
+	}
+
+	if err := ks.fetch(now); err != nil {
+		return nil, err
+	}
+	if key, ok = ks.keys[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (ks *KeySet) fetch(now time.Time) error {
+	resp, err := ks.httpClient.Get(ks.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Keys response error, status: %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		// Skip encryption keys and key types we do not support.
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			key, err = k.ecdsaKey()
+		default:
+			continue
+		}
+		// Malformed keys are skipped, tokens signed with them are rejected as signed with unknown key.
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	ks.keys = keys
+	ks.fetchedAt = now
+	ks.expiresAt = now.Add(maxAge(resp.Header.Get("Cache-Control")))
+	return nil
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("Key %s has invalid exponent", k.Kid)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func (k jwk) ecdsaKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("Key %s has unsupported curve %s", k.Kid, k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("Key %s is not on curve %s", k.Kid, k.Crv)
+	}
+	return key, nil
+}
+
+// maxAge reads max-age directive of Cache-Control header.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultKeysLifespan
+}
@@ -0,0 +1,152 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// canonicalize serializes the element with Exclusive XML Canonicalization without comments,
+// see https://www.w3.org/TR/xml-exc-c14n/.
+// The excluded element is omitted with its subtree, this is how enveloped signature transform is applied.
+// Prefixes from InclusiveNamespaces PrefixList are rendered as in inclusive canonicalization, "#default" stands for the default namespace.
+func canonicalize(e *element, exclude *element, inclusivePrefixes []string) []byte {
+	inclusive := make(map[string]bool, len(inclusivePrefixes))
+	for _, p := range inclusivePrefixes {
+		if p == "#default" {
+			p = ""
+		}
+		inclusive[p] = true
+	}
+
+	var buf bytes.Buffer
+	c := canonicalizer{buf: &buf, exclude: exclude, inclusive: inclusive}
+	c.element(e, map[string]string{})
+	return buf.Bytes()
+}
+
+type canonicalizer struct {
+	buf       *bytes.Buffer
+	exclude   *element
+	inclusive map[string]bool
+}
+
+type canonicalAttr struct {
+	namespace string
+	name      string
+	value     string
+}
+
+// element writes the element, rendered are namespace declarations already in effect in the output.
+func (c *canonicalizer) element(e *element, rendered map[string]string) {
+	// Namespaces visibly utilized by the element and its attributes, plus the inclusive ones in scope.
+	utilized := map[string]bool{e.prefix: true}
+	var attrs []canonicalAttr
+	for _, a := range e.attrs {
+		if isNamespaceDecl(a) {
+			continue
+		}
+		ns := ""
+		if a.Name.Space != "" {
+			ns = e.namespace(a.Name.Space)
+			utilized[a.Name.Space] = true
+		}
+		attrs = append(attrs, canonicalAttr{namespace: ns, name: qualifiedName(a.Name.Space, a.Name.Local), value: a.Value})
+	}
+	for p := range c.inclusive {
+		if p == "" || e.namespace(p) != "" {
+			utilized[p] = true
+		}
+	}
+	delete(utilized, "xml")
+
+	var prefixes []string
+	scope := rendered
+	for p := range utilized {
+		uri := e.namespace(p)
+		if current, ok := rendered[p]; (ok && current == uri) || (!ok && uri == "") {
+			continue
+		}
+		if p != "" && uri == "" {
+			continue // Undeclared prefix, can't be rendered.
+		}
+		if len(prefixes) == 0 {
+			scope = make(map[string]string, len(rendered)+1)
+			for k, v := range rendered {
+				scope[k] = v
+			}
+		}
+		scope[p] = uri
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes) // Default namespace is the empty prefix, so it goes first.
+
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return localName(attrs[i].name) < localName(attrs[j].name)
+	})
+
+	name := qualifiedName(e.prefix, e.local)
+	c.buf.WriteString("<" + name)
+	for _, p := range prefixes {
+		if p == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(` xmlns:` + p + `="`)
+		}
+		c.buf.WriteString(escapeAttr(scope[p]) + `"`)
+	}
+	for _, a := range attrs {
+		c.buf.WriteString(" " + a.name + `="` + escapeAttr(a.value) + `"`)
+	}
+	c.buf.WriteString(">")
+
+	for _, child := range e.children {
+		switch ch := child.(type) {
+		case *element:
+			if ch != c.exclude {
+				c.element(ch, scope)
+			}
+		case string:
+			c.buf.WriteString(escapeText(ch))
+		}
+	}
+	c.buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+func localName(qname string) string {
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
+
+// escape escapes the string for use in XML documents we build, both in text and in attribute values.
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,194 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// Register hash functions used by supported algorithms.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	dsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+
+	excC14NAlgorithm       = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSigAlgorithm  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA256Algorithm     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	rsaSHA512Algorithm     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	ecdsaSHA256Algorithm   = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	sha256DigestAlgorithm  = "http://www.w3.org/2001/04/xmlenc#sha256"
+	sha512DigestAlgorithm  = "http://www.w3.org/2001/04/xmlenc#sha512"
+	inclusiveNamespacesTag = "InclusiveNamespaces"
+)
+
+var (
+	// ErrNotSigned is when the element has no signature.
+	ErrNotSigned = errors.New("saml: element is not signed")
+	// ErrInvalidSignature is when the signature does not match the element, or the element is not signed with the identity provider keys.
+	ErrInvalidSignature = errors.New("saml: invalid signature")
+)
+
+// Signatures with SHA-1 are not accepted, identity providers are expected to use SHA-256 at least.
+var signatureHashes = map[string]crypto.Hash{
+	rsaSHA256Algorithm:   crypto.SHA256,
+	rsaSHA512Algorithm:   crypto.SHA512,
+	ecdsaSHA256Algorithm: crypto.SHA256,
+}
+
+var digestHashes = map[string]crypto.Hash{
+	sha256DigestAlgorithm: crypto.SHA256,
+	sha512DigestAlgorithm: crypto.SHA512,
+}
+
+// verifySignature verifies enveloped signature of the element against the certificates from identity provider metadata.
+// Key info in the signature itself is ignored, so only the keys the app trusts are used.
+// The signature must reference the element itself, so the caller should read data only from the verified element.
+func verifySignature(e *element, certs []*x509.Certificate) error {
+	signatures := e.childElements(dsigNamespace, "Signature")
+	if len(signatures) == 0 {
+		return ErrNotSigned
+	}
+	if len(signatures) > 1 {
+		return ErrInvalidSignature
+	}
+	sig := signatures[0]
+
+	signedInfo := sig.child(dsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return ErrInvalidSignature
+	}
+
+	c14nMethod := signedInfo.child(dsigNamespace, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.attr("Algorithm") != excC14NAlgorithm {
+		return fmt.Errorf("saml: unsupported canonicalization method")
+	}
+	sigMethod := signedInfo.child(dsigNamespace, "SignatureMethod")
+	if sigMethod == nil {
+		return ErrInvalidSignature
+	}
+	sigHash, ok := signatureHashes[sigMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("saml: unsupported signature method %s", sigMethod.attr("Algorithm"))
+	}
+
+	ref := signedInfo.child(dsigNamespace, "Reference")
+	if ref == nil {
+		return ErrInvalidSignature
+	}
+	if id := e.attr("ID"); id == "" || ref.attr("URI") != "#"+id {
+		return ErrInvalidSignature
+	}
+	prefixes, err := referenceTransforms(ref)
+	if err != nil {
+		return err
+	}
+
+	digestMethod := ref.child(dsigNamespace, "DigestMethod")
+	if digestMethod == nil {
+		return ErrInvalidSignature
+	}
+	digestHash, ok := digestHashes[digestMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("saml: unsupported digest method %s", digestMethod.attr("Algorithm"))
+	}
+	digestValue := ref.child(dsigNamespace, "DigestValue")
+	if digestValue == nil {
+		return ErrInvalidSignature
+	}
+	expectedDigest, err := decodeBase64(digestValue.text())
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	h := digestHash.New()
+	h.Write(canonicalize(e, sig, prefixes))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expectedDigest) != 1 {
+		return ErrInvalidSignature
+	}
+
+	sigValue := sig.child(dsigNamespace, "SignatureValue")
+	if sigValue == nil {
+		return ErrInvalidSignature
+	}
+	signature, err := decodeBase64(sigValue.text())
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	h = sigHash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusiveNamespaces(c14nMethod)))
+	hashed := h.Sum(nil)
+
+	for _, cert := range certs {
+		if verifyWithKey(cert.PublicKey, sigHash, hashed, signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// referenceTransforms checks the reference is transformed the way SAML signatures are,
+// and returns inclusive namespace prefixes for the canonicalization.
+func referenceTransforms(ref *element) ([]string, error) {
+	transforms := ref.child(dsigNamespace, "Transforms")
+	if transforms == nil {
+		return nil, fmt.Errorf("saml: signature reference has no transforms")
+	}
+
+	var prefixes []string
+	canonicalized := false
+	for _, t := range transforms.childElements(dsigNamespace, "Transform") {
+		switch t.attr("Algorithm") {
+		case envelopedSigAlgorithm:
+		case excC14NAlgorithm:
+			canonicalized = true
+			prefixes = inclusiveNamespaces(t)
+		default:
+			return nil, fmt.Errorf("saml: unsupported transform %s", t.attr("Algorithm"))
+		}
+	}
+	if !canonicalized {
+		return nil, fmt.Errorf("saml: signature reference is not canonicalized with exclusive canonicalization")
+	}
+	return prefixes, nil
+}
+
+// inclusiveNamespaces reads InclusiveNamespaces PrefixList of canonicalization method or transform.
+func inclusiveNamespaces(method *element) []string {
+	in := method.child(excC14NAlgorithm, inclusiveNamespacesTag)
+	if in == nil {
+		return nil
+	}
+	return strings.Fields(in.attr("PrefixList"))
+}
+
+func verifyWithKey(key crypto.PublicKey, hash crypto.Hash, hashed, signature []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, hash, hashed, signature) == nil
+	case *ecdsa.PublicKey:
+		// XML Signature encodes ECDSA signatures as concatenated r and s, not in ASN.1.
+		if len(signature) == 0 || len(signature)%2 != 0 {
+			return false
+		}
+		half := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+		return ecdsa.Verify(k, hashed, r, s)
+	}
+	return false
+}
+
+// decodeBase64 decodes base64 values that may be split into lines.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
@@ -0,0 +1,126 @@
+package saml
+
+import (
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	metadataNamespace = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	redirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	postBinding     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// ErrNoIdentityProvider is when metadata does not describe an identity provider.
+var ErrNoIdentityProvider = errors.New("saml: metadata has no identity provider descriptor")
+
+// IdentityProvider is what service provider needs to know about the identity provider, read from its metadata.
+type IdentityProvider struct {
+	EntityID string
+	// SSOURL is where authentication requests are sent with HTTP-Redirect binding.
+	SSOURL string
+	// Certificates are used to verify identity provider signatures.
+	Certificates []*x509.Certificate
+}
+
+type entityDescriptor struct {
+	EntityID string          `xml:"entityID,attr"`
+	IDPSSO   []idpDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type idpDescriptor struct {
+	KeyDescriptors []struct {
+		Use          string   `xml:"use,attr"`
+		Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SSOServices []struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+// ParseMetadata reads identity provider metadata.
+// Metadata comes from the app settings and is trusted as is, so its own signature is not checked.
+// If it is an EntitiesDescriptor, the first entity with identity provider descriptor is used.
+func ParseMetadata(data []byte) (*IdentityProvider, error) {
+	var doc struct {
+		XMLName xml.Name
+		entityDescriptor
+		Entities []entityDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("saml: invalid metadata: %s", err)
+	}
+	if doc.XMLName.Space != metadataNamespace {
+		return nil, ErrNoIdentityProvider
+	}
+
+	var entities []entityDescriptor
+	switch doc.XMLName.Local {
+	case "EntityDescriptor":
+		entities = []entityDescriptor{doc.entityDescriptor}
+	case "EntitiesDescriptor":
+		entities = doc.Entities
+	}
+
+	for _, entity := range entities {
+		if len(entity.IDPSSO) == 0 {
+			continue
+		}
+		return newIdentityProvider(entity.EntityID, entity.IDPSSO[0])
+	}
+	return nil, ErrNoIdentityProvider
+}
+
+func newIdentityProvider(entityID string, d idpDescriptor) (*IdentityProvider, error) {
+	idp := &IdentityProvider{EntityID: entityID}
+	if idp.EntityID == "" {
+		return nil, fmt.Errorf("saml: identity provider has no entity ID")
+	}
+
+	for _, s := range d.SSOServices {
+		if s.Binding == redirectBinding {
+			idp.SSOURL = s.Location
+			break
+		}
+	}
+
+	for _, kd := range d.KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, c := range kd.Certificates {
+			der, err := decodeBase64(c)
+			if err != nil {
+				return nil, fmt.Errorf("saml: invalid identity provider certificate: %s", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("saml: invalid identity provider certificate: %s", err)
+			}
+			idp.Certificates = append(idp.Certificates, cert)
+		}
+	}
+	if len(idp.Certificates) == 0 {
+		return nil, fmt.Errorf("saml: identity provider has no signing certificates")
+	}
+	return idp, nil
+}
+
+// Metadata returns service provider metadata to register Identifo with the identity provider.
+// Identifo does not sign authentication requests, and wants assertions signed.
+func (sp *ServiceProvider) Metadata() []byte {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<md:EntityDescriptor xmlns:md="` + metadataNamespace + `" entityID="` + escape(sp.EntityID) + `">`)
+	sb.WriteString(`<md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="` + protocolNamespace + `">`)
+	sb.WriteString(`<md:NameIDFormat>` + persistentNameIDFormat + `</md:NameIDFormat>`)
+	sb.WriteString(`<md:AssertionConsumerService Binding="` + postBinding + `" Location="` + escape(sp.ACSURL) + `" index="0" isDefault="true"/>`)
+	sb.WriteString(`</md:SPSSODescriptor>`)
+	sb.WriteString(`</md:EntityDescriptor>`)
+	return []byte(sb.String())
+}
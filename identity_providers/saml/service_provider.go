@@ -0,0 +1,306 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+
+	successStatus          = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerMethod           = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	persistentNameIDFormat = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	// EmailNameIDFormat is the format of NameID that is user's email.
+	EmailNameIDFormat = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+
+	// maxClockSkew is how much identity provider clock may differ from ours.
+	maxClockSkew = 3 * time.Minute
+)
+
+var (
+	// ErrNoSSOURL is when identity provider does not accept authentication requests with HTTP-Redirect binding.
+	ErrNoSSOURL = errors.New("saml: identity provider has no single sign-on service with HTTP-Redirect binding")
+	// ErrEncryptedAssertion is when identity provider encrypts assertions, this is not supported.
+	ErrEncryptedAssertion = errors.New("saml: encrypted assertions are not supported")
+	// ErrExpired is when the assertion is not valid at the moment.
+	ErrExpired = errors.New("saml: assertion is expired or not yet valid")
+)
+
+// ServiceProvider is Identifo acting as SAML 2.0 service provider for the app.
+type ServiceProvider struct {
+	// EntityID identifies Identifo to the identity provider, by convention it is the URL of service provider metadata.
+	EntityID string
+	// ACSURL is Assertion Consumer Service URL, where identity provider posts its responses.
+	ACSURL string
+	IdP    *IdentityProvider
+}
+
+// Assertion is what service provider learns about the user from the verified assertion.
+type Assertion struct {
+	ID string
+	// InResponseTo is ID of authentication request, empty for login initiated at the identity provider.
+	InResponseTo string
+	NameID       string
+	NameIDFormat string
+	// NotOnOrAfter is when the assertion expires, it has to be remembered until then to prevent its replay.
+	NotOnOrAfter time.Time
+	Attributes   map[string][]string
+}
+
+// Attribute returns the first value of the attribute.
+func (a *Assertion) Attribute(name string) string {
+	if values := a.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// NewRequestID generates unique ID for authentication request.
+// XML IDs can't start with a digit, so it has a prefix.
+func NewRequestID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "id-" + hex.EncodeToString(b), nil
+}
+
+// AuthnRequestURL returns URL to redirect the user to, to authenticate at the identity provider with HTTP-Redirect binding.
+func (sp *ServiceProvider) AuthnRequestURL(requestID, relayState string) (string, error) {
+	if sp.IdP.SSOURL == "" {
+		return "", ErrNoSSOURL
+	}
+	u, err := url.Parse(sp.IdP.SSOURL)
+	if err != nil {
+		return "", err
+	}
+
+	request := `<samlp:AuthnRequest xmlns:samlp="` + protocolNamespace + `" xmlns:saml="` + assertionNamespace + `"` +
+		` ID="` + escape(requestID) + `" Version="2.0" IssueInstant="` + time.Now().UTC().Format(time.RFC3339) + `"` +
+		` Destination="` + escape(sp.IdP.SSOURL) + `" AssertionConsumerServiceURL="` + escape(sp.ACSURL) + `"` +
+		` ProtocolBinding="` + postBinding + `">` +
+		`<saml:Issuer>` + escape(sp.EntityID) + `</saml:Issuer>` +
+		`<samlp:NameIDPolicy AllowCreate="true"/>` +
+		`</samlp:AuthnRequest>`
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err = fw.Write([]byte(request)); err != nil {
+		return "", err
+	}
+	if err = fw.Close(); err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		q.Set("RelayState", relayState)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ParseResponse verifies base64 encoded response the identity provider has posted to ACS URL, and returns the assertion from it.
+// Either the assertion or the whole response must be signed by the identity provider.
+// The caller is responsible to check InResponseTo against requests it has sent, and to prevent the assertion replay.
+func (sp *ServiceProvider) ParseResponse(samlResponse string, now time.Time) (*Assertion, error) {
+	data, err := decodeBase64(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid response encoding: %s", err)
+	}
+	response, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if !response.is(protocolNamespace, "Response") || response.attr("Version") != "2.0" {
+		return nil, fmt.Errorf("saml: not a SAML 2.0 response")
+	}
+
+	if dest := response.attr("Destination"); dest != "" && dest != sp.ACSURL {
+		return nil, fmt.Errorf("saml: response is sent to %s", dest)
+	}
+	if issuer := response.child(assertionNamespace, "Issuer"); issuer != nil && issuer.text() != sp.IdP.EntityID {
+		return nil, fmt.Errorf("saml: response is issued by unknown identity provider %s", issuer.text())
+	}
+	if err = checkStatus(response); err != nil {
+		return nil, err
+	}
+
+	if len(response.childElements(assertionNamespace, "EncryptedAssertion")) > 0 {
+		return nil, ErrEncryptedAssertion
+	}
+	assertion := response.child(assertionNamespace, "Assertion")
+	if assertion == nil {
+		return nil, fmt.Errorf("saml: response must have exactly one assertion")
+	}
+
+	// Signature is verified on the exact elements the data is read from, to prevent signature wrapping.
+	assertionErr := verifySignature(assertion, sp.IdP.Certificates)
+	responseErr := verifySignature(response, sp.IdP.Certificates)
+	if (assertionErr != nil && assertionErr != ErrNotSigned) || (responseErr != nil && responseErr != ErrNotSigned) {
+		return nil, ErrInvalidSignature
+	}
+	if assertionErr == ErrNotSigned && responseErr == ErrNotSigned {
+		return nil, ErrNotSigned
+	}
+
+	res, err := sp.parseAssertion(assertion, now)
+	if err != nil {
+		return nil, err
+	}
+	if irt := response.attr("InResponseTo"); irt != "" && irt != res.InResponseTo {
+		return nil, fmt.Errorf("saml: response and assertion are sent in response to different requests")
+	}
+	return res, nil
+}
+
+func checkStatus(response *element) error {
+	status := response.child(protocolNamespace, "Status")
+	if status == nil {
+		return fmt.Errorf("saml: response has no status")
+	}
+	code := status.child(protocolNamespace, "StatusCode")
+	if code == nil {
+		return fmt.Errorf("saml: response has no status code")
+	}
+	if code.attr("Value") != successStatus {
+		msg := ""
+		if m := status.child(protocolNamespace, "StatusMessage"); m != nil {
+			msg = m.text()
+		}
+		return fmt.Errorf("saml: identity provider returned %s %s", code.attr("Value"), msg)
+	}
+	return nil
+}
+
+func (sp *ServiceProvider) parseAssertion(assertion *element, now time.Time) (*Assertion, error) {
+	res := &Assertion{ID: assertion.attr("ID"), Attributes: make(map[string][]string)}
+	if res.ID == "" || assertion.attr("Version") != "2.0" {
+		return nil, fmt.Errorf("saml: invalid assertion")
+	}
+	if issuer := assertion.child(assertionNamespace, "Issuer"); issuer == nil || issuer.text() != sp.IdP.EntityID {
+		return nil, fmt.Errorf("saml: assertion is issued by unknown identity provider")
+	}
+
+	if err := sp.checkConditions(assertion.child(assertionNamespace, "Conditions"), now); err != nil {
+		return nil, err
+	}
+	if err := sp.parseSubject(assertion.child(assertionNamespace, "Subject"), now, res); err != nil {
+		return nil, err
+	}
+
+	for _, statement := range assertion.childElements(assertionNamespace, "AttributeStatement") {
+		for _, attr := range statement.childElements(assertionNamespace, "Attribute") {
+			name := attr.attr("Name")
+			for _, value := range attr.childElements(assertionNamespace, "AttributeValue") {
+				res.Attributes[name] = append(res.Attributes[name], value.text())
+			}
+		}
+	}
+	return res, nil
+}
+
+// checkConditions checks the assertion is valid now, and is intended for this service provider.
+func (sp *ServiceProvider) checkConditions(conditions *element, now time.Time) error {
+	if conditions == nil {
+		return fmt.Errorf("saml: assertion has no conditions")
+	}
+	if err := checkTime(conditions, now); err != nil {
+		return err
+	}
+
+	restrictions := conditions.childElements(assertionNamespace, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return fmt.Errorf("saml: assertion has no audience restriction")
+	}
+	// Each restriction must be satisfied.
+	for _, r := range restrictions {
+		found := false
+		for _, aud := range r.childElements(assertionNamespace, "Audience") {
+			if aud.text() == sp.EntityID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("saml: assertion is intended for another service provider")
+		}
+	}
+	return nil
+}
+
+// parseSubject reads NameID, and checks there is bearer subject confirmation for this service provider.
+func (sp *ServiceProvider) parseSubject(subject *element, now time.Time, res *Assertion) error {
+	if subject == nil {
+		return fmt.Errorf("saml: assertion has no subject")
+	}
+	nameID := subject.child(assertionNamespace, "NameID")
+	if nameID == nil || nameID.text() == "" {
+		return fmt.Errorf("saml: assertion has no NameID")
+	}
+	res.NameID = nameID.text()
+	res.NameIDFormat = nameID.attr("Format")
+
+	for _, sc := range subject.childElements(assertionNamespace, "SubjectConfirmation") {
+		if sc.attr("Method") != bearerMethod {
+			continue
+		}
+		data := sc.child(assertionNamespace, "SubjectConfirmationData")
+		if data == nil || data.attr("Recipient") != sp.ACSURL || data.attr("NotOnOrAfter") == "" {
+			continue
+		}
+		if checkTime(data, now) != nil {
+			continue
+		}
+		notOnOrAfter, _ := parseTime(data.attr("NotOnOrAfter"))
+		res.InResponseTo = data.attr("InResponseTo")
+		res.NotOnOrAfter = notOnOrAfter
+		return nil
+	}
+	return fmt.Errorf("saml: assertion has no valid bearer subject confirmation")
+}
+
+// checkTime checks NotBefore and NotOnOrAfter attributes of the element, if they are present.
+func checkTime(e *element, now time.Time) error {
+	if nb := e.attr("NotBefore"); nb != "" {
+		t, err := parseTime(nb)
+		if err != nil {
+			return err
+		}
+		if now.Add(maxClockSkew).Before(t) {
+			return ErrExpired
+		}
+	}
+	if noa := e.attr("NotOnOrAfter"); noa != "" {
+		t, err := parseTime(noa)
+		if err != nil {
+			return err
+		}
+		if !now.Add(-maxClockSkew).Before(t) {
+			return ErrExpired
+		}
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+	if err != nil {
+		return t, fmt.Errorf("saml: invalid time %s", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,323 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIdPEntityID = "https://idp.example.com/metadata"
+	testEntityID    = "https://identifo.example.com/saml/app1/metadata"
+	testACSURL      = "https://identifo.example.com/saml/app1/acs"
+	testRequestID   = "id-request"
+)
+
+type testIdP struct {
+	key      *rsa.PrivateKey
+	metadata string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unable to create certificate: %v", err)
+	}
+
+	metadata := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="` + testIdPEntityID + `">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+` + base64.StdEncoding.EncodeToString(der) + `
+      </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso?tenant=1"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+	return &testIdP{key: key, metadata: metadata}
+}
+
+type testResponse struct {
+	inResponseTo string
+	audience     string
+	recipient    string
+	nameID       string
+	notOnOrAfter time.Time
+	signResponse bool
+}
+
+func validResponse() testResponse {
+	return testResponse{
+		inResponseTo: testRequestID,
+		audience:     testEntityID,
+		recipient:    testACSURL,
+		nameID:       "user-1",
+		notOnOrAfter: time.Now().Add(5 * time.Minute),
+	}
+}
+
+func (r testResponse) xml() string {
+	irt := ""
+	if r.inResponseTo != "" {
+		irt = ` InResponseTo="` + r.inResponseTo + `"`
+	}
+	noa := r.notOnOrAfter.UTC().Format(time.RFC3339)
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_response" Version="2.0" Destination="` + testACSURL + `"` + irt + `>
+  <saml:Issuer>` + testIdPEntityID + `</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" ID="_assertion" Version="2.0" IssueInstant="` + time.Now().UTC().Format(time.RFC3339) + `">
+    <saml:Issuer>` + testIdPEntityID + `</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:2.0:nameid-format:persistent">` + r.nameID + `</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData NotOnOrAfter="` + noa + `" Recipient="` + r.recipient + `"` + irt + `/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="` + time.Now().Add(-time.Minute).UTC().Format(time.RFC3339) + `" NotOnOrAfter="` + noa + `">
+      <saml:AudienceRestriction><saml:Audience>` + r.audience + `</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="email"><saml:AttributeValue xsi:type="xs:string">user@example.com</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`
+}
+
+// sign inserts enveloped signature into the element with the ID, right after its Issuer.
+func sign(t *testing.T, doc, id string, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	root, err := parseXML([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unable to parse document: %v", err)
+	}
+	e := findByID(root, id)
+	if e == nil {
+		t.Fatalf("No element with ID %s", id)
+	}
+	digest := sha256.Sum256(canonicalize(e, nil, nil))
+
+	signature := `<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>` +
+		`</ds:Transforms><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue>` +
+		`</ds:Reference></ds:SignedInfo><ds:SignatureValue>SIGNATURE</ds:SignatureValue></ds:Signature>`
+
+	// Issuer of the element is the first one after its ID.
+	start := strings.Index(doc, `ID="`+id+`"`)
+	end := start + strings.Index(doc[start:], "</saml:Issuer>") + len("</saml:Issuer>")
+	doc = doc[:end] + signature + doc[end:]
+
+	root, err = parseXML([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unable to parse signed document: %v", err)
+	}
+	signedInfo := findByID(root, id).child(dsigNamespace, "Signature").child(dsigNamespace, "SignedInfo")
+	hashed := sha256.Sum256(canonicalize(signedInfo, nil, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("Unable to sign: %v", err)
+	}
+	return strings.Replace(doc, "SIGNATURE", base64.StdEncoding.EncodeToString(value), 1)
+}
+
+func findByID(e *element, id string) *element {
+	if e.attr("ID") == id {
+		return e
+	}
+	for _, c := range e.children {
+		if ce, ok := c.(*element); ok {
+			if found := findByID(ce, id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := newTestIdP(t)
+	otherIdP := newTestIdP(t)
+
+	meta, err := ParseMetadata([]byte(idp.metadata))
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+	sp := &ServiceProvider{EntityID: testEntityID, ACSURL: testACSURL, IdP: meta}
+
+	signed := func(r testResponse) string {
+		if r.signResponse {
+			return sign(t, r.xml(), "_response", idp.key)
+		}
+		return sign(t, r.xml(), "_assertion", idp.key)
+	}
+	with := func(change func(r *testResponse)) testResponse {
+		r := validResponse()
+		change(&r)
+		return r
+	}
+
+	valid := signed(validResponse())
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"signed response", signed(with(func(r *testResponse) { r.signResponse = true })), false},
+		{"not signed", validResponse().xml(), true},
+		{"signed with other key", sign(t, validResponse().xml(), "_assertion", otherIdP.key), true},
+		{"tampered after signing", strings.Replace(valid, ">user-1<", ">admin<", 1), true},
+		{"wrong audience", signed(with(func(r *testResponse) { r.audience = "https://other.example.com" })), true},
+		{"wrong recipient", signed(with(func(r *testResponse) { r.recipient = "https://other.example.com/acs" })), true},
+		{"expired", signed(with(func(r *testResponse) { r.notOnOrAfter = time.Now().Add(-10 * time.Minute) })), true},
+		{"wrapped assertion", strings.Replace(valid, "<samlp:Status>",
+			`<saml:Assertion ID="_evil" Version="2.0"><saml:Issuer>`+testIdPEntityID+`</saml:Issuer></saml:Assertion><samlp:Status>`, 1), true},
+		{"duplicate ID", strings.Replace(valid, "<samlp:Status>", `<samlp:Extensions ID="_assertion"/><samlp:Status>`, 1), true},
+		{"doctype", `<!DOCTYPE x [<!ENTITY e "x">]>` + valid, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(tt.doc)), time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if a.ID != "_assertion" || a.NameID != "user-1" || a.InResponseTo != testRequestID || a.Attribute("email") != "user@example.com" {
+				t.Errorf("ParseResponse() = %+v, unexpected assertion", a)
+			}
+		})
+	}
+}
+
+// Fixtures in testdata are signed by libxmlsec1, the library behind xmlsec1, with the key of the certificate in idp-metadata.xml.
+// Unlike the responses above, they do not depend on canonicalize of this package to be signed.
+// Assertion signature uses InclusiveNamespaces for the xs prefix declared on the response only, as some identity providers do.
+// Both the assertion and the response are signed in response-signed.xml, the latter with RSA-SHA512.
+func TestParseResponseFixtures(t *testing.T) {
+	readFixture := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("Unable to read fixture: %v", err)
+		}
+		return string(data)
+	}
+
+	meta, err := ParseMetadata([]byte(readFixture("idp-metadata.xml")))
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+	sp := &ServiceProvider{EntityID: testEntityID, ACSURL: testACSURL, IdP: meta}
+	now := time.Date(2026, time.October, 18, 12, 1, 0, 0, time.UTC)
+
+	otherMeta, err := ParseMetadata([]byte(newTestIdP(t).metadata))
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+	otherSP := &ServiceProvider{EntityID: testEntityID, ACSURL: testACSURL, IdP: otherMeta}
+
+	for _, name := range []string{"response-assertion-signed.xml", "response-signed.xml"} {
+		doc := readFixture(name)
+		t.Run(name, func(t *testing.T) {
+			a, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(doc)), now)
+			if err != nil {
+				t.Fatalf("ParseResponse() error = %v", err)
+			}
+			if a.ID != "_assertion" || a.NameID != "user-1" || a.InResponseTo != testRequestID ||
+				a.Attribute("email") != "user@example.com" || a.Attribute("displayName") != `Tom & Jerry <"R&D">` {
+				t.Errorf("ParseResponse() = %+v, unexpected assertion", a)
+			}
+
+			invalid := map[string]string{
+				"tampered name ID":  strings.Replace(doc, ">user-1<", ">admin<", 1),
+				"tampered escaping": strings.Replace(doc, "Tom &amp; Jerry", "Tom &amp;amp; Jerry", 1),
+				"tampered comment":  strings.Replace(doc, "<!-- Comments are not signed. -->", "<saml:Extensions/>", 1),
+				"dropped namespace": strings.Replace(doc, ` xmlns:xs="http://www.w3.org/2001/XMLSchema"`, ` xmlns:xs="urn:other"`, 1),
+			}
+			for change, doc := range invalid {
+				if _, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(doc)), now); err == nil {
+					t.Errorf("ParseResponse() accepts response with %s", change)
+				}
+			}
+
+			if _, err := otherSP.ParseResponse(base64.StdEncoding.EncodeToString([]byte(doc)), now); err != ErrInvalidSignature {
+				t.Errorf("ParseResponse() with another identity provider error = %v, want %v", err, ErrInvalidSignature)
+			}
+			if _, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(doc)), now.Add(10*time.Minute)); err == nil {
+				t.Error("ParseResponse() accepts expired response")
+			}
+		})
+	}
+
+	// Comments are not signed, so they can be changed freely.
+	doc := strings.Replace(readFixture("response-assertion-signed.xml"), "Comments are not signed.", "Anything", 1)
+	if _, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(doc)), now); err != nil {
+		t.Errorf("ParseResponse() with changed comment error = %v", err)
+	}
+}
+
+func TestParseResponseIdPInitiated(t *testing.T) {
+	idp := newTestIdP(t)
+	meta, err := ParseMetadata([]byte(idp.metadata))
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+	sp := &ServiceProvider{EntityID: testEntityID, ACSURL: testACSURL, IdP: meta}
+
+	r := validResponse()
+	r.inResponseTo = ""
+	a, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(sign(t, r.xml(), "_assertion", idp.key))), time.Now())
+	if err != nil {
+		t.Fatalf("ParseResponse() error = %v", err)
+	}
+	if a.InResponseTo != "" {
+		t.Errorf("ParseResponse() InResponseTo = %q, want empty", a.InResponseTo)
+	}
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	meta, err := ParseMetadata([]byte(newTestIdP(t).metadata))
+	if err != nil {
+		t.Fatalf("ParseMetadata() error = %v", err)
+	}
+	sp := &ServiceProvider{EntityID: testEntityID, ACSURL: testACSURL, IdP: meta}
+
+	u, err := sp.AuthnRequestURL(testRequestID, "https://app.example.com/callback")
+	if err != nil {
+		t.Fatalf("AuthnRequestURL() error = %v", err)
+	}
+	for _, part := range []string{"https://idp.example.com/sso?", "tenant=1", "SAMLRequest=", "RelayState=https%3A%2F%2Fapp.example.com%2Fcallback"} {
+		if !strings.Contains(u, part) {
+			t.Errorf("AuthnRequestURL() = %s, does not contain %s", u, part)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrInvalidXML is returned for malformed documents, and for documents with constructs SAML messages never have.
+var ErrInvalidXML = errors.New("saml: invalid XML document")
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// element is an XML element as written in the document.
+// Namespace prefixes are kept as is, as signatures are computed over the canonical form that preserves them.
+type element struct {
+	prefix   string
+	local    string
+	attrs    []xml.Attr
+	children []interface{} // *element or string with character data.
+	parent   *element
+}
+
+// parseXML parses the document into the tree of elements.
+// Document type declarations are rejected, comments and processing instructions are dropped,
+// as exclusive canonicalization without comments omits them anyway.
+// Duplicate ID attributes are rejected to make signature wrapping harder.
+func parseXML(data []byte) (*element, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+
+	var root, current *element
+	ids := make(map[string]bool)
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidXML
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil && current == nil {
+				return nil, ErrInvalidXML // Several root elements.
+			}
+			e := &element{prefix: t.Name.Space, local: t.Name.Local, parent: current}
+			for _, a := range t.Attr {
+				e.attrs = append(e.attrs, xml.Attr{Name: a.Name, Value: a.Value})
+				if a.Name.Space == "" && a.Name.Local == "ID" {
+					if ids[a.Value] {
+						return nil, ErrInvalidXML
+					}
+					ids[a.Value] = true
+				}
+			}
+			if current == nil {
+				root = e
+			} else {
+				current.children = append(current.children, e)
+			}
+			current = e
+		case xml.EndElement:
+			if current == nil || current.prefix != t.Name.Space || current.local != t.Name.Local {
+				return nil, ErrInvalidXML
+			}
+			current = current.parent
+		case xml.CharData:
+			if current == nil {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, ErrInvalidXML
+				}
+				continue
+			}
+			current.children = append(current.children, string(t))
+		case xml.Directive:
+			return nil, ErrInvalidXML
+		}
+	}
+
+	if root == nil || current != nil {
+		return nil, ErrInvalidXML
+	}
+	return root, nil
+}
+
+// namespace returns the namespace URI the prefix is bound to in the scope of the element.
+func (e *element) namespace(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespace
+	}
+	for el := e; el != nil; el = el.parent {
+		for _, a := range el.attrs {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") ||
+				(prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// is tells if the element has the namespace and the local name.
+func (e *element) is(namespace, local string) bool {
+	return e.local == local && e.namespace(e.prefix) == namespace
+}
+
+// attr returns the value of the attribute without namespace.
+func (e *element) attr(name string) string {
+	for _, a := range e.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// childElements returns child elements with the namespace and the local name.
+func (e *element) childElements(namespace, local string) []*element {
+	var res []*element
+	for _, c := range e.children {
+		if ce, ok := c.(*element); ok && ce.is(namespace, local) {
+			res = append(res, ce)
+		}
+	}
+	return res
+}
+
+// child returns the only child element with the namespace and the local name, or nil if there is none or several.
+func (e *element) child(namespace, local string) *element {
+	if children := e.childElements(namespace, local); len(children) == 1 {
+		return children[0]
+	}
+	return nil
+}
+
+// text returns character data of the element, with surrounding whitespace trimmed.
+func (e *element) text() string {
+	var sb strings.Builder
+	for _, c := range e.children {
+		if s, ok := c.(string); ok {
+			sb.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// isNamespaceDecl tells if the attribute declares a namespace.
+func isNamespaceDecl(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns")
+}
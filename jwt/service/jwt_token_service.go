@@ -1,11 +1,14 @@
 package service
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -30,8 +33,16 @@ var (
 	TokenLifespan = int64(604800) // int64(1*7*24*60*60)
 	// InviteTokenLifespan is an invite token expiration time, one hour.
 	InviteTokenLifespan = int64(3600) // int64(1*60*60)
+	// EmailVerificationTokenLifespan is an email verification token expiration time, one day.
+	EmailVerificationTokenLifespan = int64(86400) // int64(24*60*60)
+	// MagicLinkTokenLifespan is a magic link token expiration time, fifteen minutes.
+	MagicLinkTokenLifespan = int64(900) // int64(15*60)
 	// RefreshTokenLifespan is a default expiration time for refresh tokens, one year.
 	RefreshTokenLifespan = int64(31536000) // int(365*24*60*60)
+	// ImpersonationTokenLifespan is an impersonation token expiration time, fifteen minutes.
+	ImpersonationTokenLifespan = int64(900) // int64(15*60)
+	// LogoutTokenLifespan is a lifespan of back-channel logout tokens, they are delivered right away.
+	LogoutTokenLifespan = int64(120) // int64(2*60)
 )
 
 const (
@@ -39,6 +50,14 @@ const (
 	PayloadName = "name"
 	// PayloadTFAuthorized is a JWT token payload "tfa_authorized".
 	PayloadTFAuthorized = "tfa_authorized"
+	// PayloadRefreshTokenFamily is a JWT token payload "family".
+	// All refresh tokens that replace each other during rotation belong to the same family.
+	PayloadRefreshTokenFamily = "family"
+	// PayloadTrustedDevice is a JWT token payload "trusted_device", the ID of the device the token is issued for.
+	PayloadTrustedDevice = "trusted_device"
+	// PayloadWebSessionApps is a web cookie token payload "apps", space-separated IDs of the apps
+	// that have got tokens through the web session.
+	PayloadWebSessionApps = "apps"
 )
 
 // NewJWTokenService returns new JWT token service.
@@ -107,6 +126,18 @@ func (ts *JWTokenService) Algorithm() string {
 	}
 }
 
+// signingMethod returns method the tokens are signed with.
+func (ts *JWTokenService) signingMethod() (jwt.SigningMethod, error) {
+	switch ts.algorithm {
+	case ijwt.TokenSignatureAlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case ijwt.TokenSignatureAlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, ijwt.ErrWrongSignatureAlgorithm
+	}
+}
+
 // PublicKey returns public key.
 func (ts *JWTokenService) PublicKey() interface{} {
 	return ts.publicKey
@@ -198,14 +229,164 @@ func (ts *JWTokenService) NewAccessToken(u model.User, scopes []string, app mode
 		},
 	}
 
-	var sm jwt.SigningMethod
-	switch ts.algorithm {
-	case ijwt.TokenSignatureAlgorithmES256:
-		sm = jwt.SigningMethodES256
-	case ijwt.TokenSignatureAlgorithmRS256:
-		sm = jwt.SigningMethodRS256
-	default:
-		return nil, ijwt.ErrWrongSignatureAlgorithm
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
+// NewImpersonationToken creates new access token for the user, issued to someone acting on behalf of the user, like support admin.
+// The actor is kept in "act" claim, so resource servers can tell impersonation apart.
+// Such tokens are short-lived and never come with refresh tokens.
+func (ts *JWTokenService) NewImpersonationToken(u model.User, scopes []string, app model.AppData, actor string) (ijwt.Token, error) {
+	if !app.Active() {
+		return nil, ErrInvalidApp
+	}
+
+	if !u.Active() {
+		return nil, ErrInvalidUser
+	}
+
+	if len(actor) == 0 {
+		return nil, ErrCreatingToken
+	}
+
+	payload := make(map[string]string)
+	if contains(app.TokenPayload(), PayloadName) {
+		payload[PayloadName] = u.Username()
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	lifespan := app.TokenLifespan()
+	if lifespan == 0 || lifespan > ImpersonationTokenLifespan {
+		lifespan = ImpersonationTokenLifespan
+	}
+
+	claims := ijwt.Claims{
+		Scopes:  strings.Join(scopes, " "),
+		Payload: payload,
+		Type:    AccessTokenType,
+		Actor:   &ijwt.Actor{Subject: actor},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: (now + lifespan),
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
+// NewAppAccessToken creates new access token for the app itself, with app ID as a subject.
+// Such tokens are used by backend services which do not act on behalf of a user.
+func (ts *JWTokenService) NewAppAccessToken(app model.AppData, scopes []string) (ijwt.Token, error) {
+	if !app.Active() {
+		return nil, ErrInvalidApp
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	lifespan := app.TokenLifespan()
+	if lifespan == 0 {
+		lifespan = TokenLifespan
+	}
+
+	claims := ijwt.Claims{
+		Scopes:  strings.Join(scopes, " "),
+		Payload: make(map[string]string),
+		Type:    AccessTokenType,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: (now + lifespan),
+			Issuer:    ts.issuer,
+			Subject:   app.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
+// NewIDToken creates new OpenID Connect ID token for user.
+// accessToken is the access token issued along with the ID token, it is used to compute "at_hash" claim.
+func (ts *JWTokenService) NewIDToken(u model.User, scopes []string, app model.AppData, nonce string, authTime int64, accessToken string) (ijwt.Token, error) {
+	if !app.Active() {
+		return nil, ErrInvalidApp
+	}
+
+	if !u.Active() {
+		return nil, ErrInvalidUser
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	lifespan := app.TokenLifespan()
+	if lifespan == 0 {
+		lifespan = TokenLifespan
+	}
+
+	idClaims := ijwt.IDTokenClaims{
+		Nonce:    nonce,
+		AuthTime: authTime,
+	}
+	if len(accessToken) > 0 {
+		// Both ES256 and RS256 use SHA-256, so we take the left-most 128 bits of the hash.
+		// https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken
+		h := sha256.Sum256([]byte(accessToken))
+		idClaims.AccessTokenHash = base64.RawURLEncoding.EncodeToString(h[:len(h)/2])
+	}
+	if contains(scopes, EmailScope) {
+		idClaims.Email = u.Email()
+	}
+	if contains(scopes, PhoneScope) {
+		idClaims.PhoneNumber = u.Phone()
+	}
+	if contains(scopes, ProfileScope) {
+		idClaims.PreferredUsername = u.Username()
+	}
+
+	claims := ijwt.Claims{
+		Type:          IDTokenType,
+		IDTokenClaims: idClaims,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: (now + lifespan),
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
 	}
 
 	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
@@ -215,8 +396,64 @@ func (ts *JWTokenService) NewAccessToken(u model.User, scopes []string, app mode
 	return &ijwt.JWToken{JWT: token, New: true}, nil
 }
 
-// NewRefreshToken creates new refresh token.
-func (ts *JWTokenService) NewRefreshToken(u model.User, scopes []string, app model.AppData) (ijwt.Token, error) {
+// NewRefreshToken creates new refresh token, which starts new token family and the user session on the client.
+func (ts *JWTokenService) NewRefreshToken(u model.User, scopes []string, app model.AppData, client model.ClientInfo) (ijwt.Token, error) {
+	family, err := newRefreshTokenFamily()
+	if err != nil {
+		return nil, ErrCreatingToken
+	}
+	session := model.UserSession{ID: family, CreatedAt: ijwt.TimeFunc().Unix()}
+	session.Touch(client)
+	return ts.newRefreshToken(u, scopes, app, session)
+}
+
+// RotateRefreshToken issues the new refresh token of the same family to replace the provided one.
+// The new token continues the session of the old one.
+// It's up to the caller to delete the old token from the token storage.
+func (ts *JWTokenService) RotateRefreshToken(refreshToken ijwt.Token, client model.ClientInfo) (ijwt.Token, error) {
+	rt, ok := refreshToken.(*ijwt.JWToken)
+	if !ok || rt == nil {
+		return nil, ijwt.ErrTokenInvalid
+	}
+
+	if err := rt.Validate(); err != nil {
+		return nil, err
+	}
+
+	claims, ok := rt.JWT.Claims.(*ijwt.Claims)
+	if !ok || claims == nil {
+		return nil, ijwt.ErrTokenInvalid
+	}
+
+	app, err := ts.appStorage.AppByID(claims.Audience)
+	if err != nil || app == nil {
+		return nil, ErrInvalidApp
+	}
+
+	user, err := ts.userStorage.UserByID(claims.Subject)
+	if err != nil || user == nil {
+		return nil, ErrInvalidUser
+	}
+
+	// Tokens issued before the families were introduced start the new one.
+	family := claims.Payload[PayloadRefreshTokenFamily]
+	if len(family) == 0 {
+		if family, err = newRefreshTokenFamily(); err != nil {
+			return nil, ErrCreatingToken
+		}
+	}
+
+	// Tokens saved before sessions were introduced start the new one.
+	session, err := ts.tokenStorage.TokenSession(rt.JWT.Raw)
+	if err != nil || session.ID != family {
+		session = model.UserSession{ID: family, CreatedAt: ijwt.TimeFunc().Unix()}
+	}
+	session.Touch(client)
+
+	return ts.newRefreshToken(user, strings.Split(claims.Scopes, " "), app, session)
+}
+
+func (ts *JWTokenService) newRefreshToken(u model.User, scopes []string, app model.AppData, session model.UserSession) (ijwt.Token, error) {
 	if !app.Active() || !app.Offline() {
 		return nil, ErrInvalidApp
 
@@ -234,6 +471,7 @@ func (ts *JWTokenService) NewRefreshToken(u model.User, scopes []string, app mod
 	if contains(app.TokenPayload(), PayloadName) {
 		payload[PayloadName] = u.Username()
 	}
+	payload[PayloadRefreshTokenFamily] = session.ID
 	now := ijwt.TimeFunc().Unix()
 
 	lifespan := app.RefreshTokenLifespan()
@@ -254,14 +492,9 @@ func (ts *JWTokenService) NewRefreshToken(u model.User, scopes []string, app mod
 		},
 	}
 
-	var sm jwt.SigningMethod
-	switch ts.algorithm {
-	case ijwt.TokenSignatureAlgorithmES256:
-		sm = jwt.SigningMethodES256
-	case ijwt.TokenSignatureAlgorithmRS256:
-		sm = jwt.SigningMethodRS256
-	default:
-		return nil, ijwt.ErrWrongSignatureAlgorithm
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
 	}
 
 	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
@@ -275,7 +508,10 @@ func (ts *JWTokenService) NewRefreshToken(u model.User, scopes []string, app mod
 		return nil, ErrSavingToken
 	}
 
-	if err := ts.tokenStorage.SaveToken(tokenString); err != nil {
+	session.UserID = u.ID()
+	session.AppID = app.ID()
+	session.ExpiresAt = claims.ExpiresAt
+	if err := ts.tokenStorage.SaveToken(tokenString, session); err != nil {
 		return nil, ErrSavingToken
 	}
 	return t, nil
@@ -307,20 +543,73 @@ func (ts *JWTokenService) RefreshAccessToken(refreshToken ijwt.Token) (ijwt.Toke
 		return nil, ErrInvalidUser
 	}
 
-	token, err := ts.NewAccessToken(user, strings.Split(claims.Scopes, " "), app, false)
-	if err != nil {
-		return nil, err
+	return ts.NewAccessToken(user, strings.Split(claims.Scopes, " "), app, false)
+}
+
+// ExchangeAccessToken issues new access token for the subject of provided access token, to be used with another app on behalf of the user.
+// The actor is added on top of the "act" chain of the subject token, and the new token does not outlive the subject token.
+func (ts *JWTokenService) ExchangeAccessToken(subjectToken ijwt.Token, u model.User, scopes []string, app model.AppData, actor string) (ijwt.Token, error) {
+	st, ok := subjectToken.(*ijwt.JWToken)
+	if !ok || st == nil {
+		return nil, ijwt.ErrTokenInvalid
+	}
+	subjectClaims, ok := st.JWT.Claims.(*ijwt.Claims)
+	if !ok || subjectClaims == nil || subjectClaims.Subject != u.ID() {
+		return nil, ijwt.ErrTokenInvalid
+	}
+
+	if !app.Active() {
+		return nil, ErrInvalidApp
+	}
+
+	if !u.Active() {
+		return nil, ErrInvalidUser
+	}
+
+	if len(actor) == 0 {
+		return nil, ErrCreatingToken
 	}
 
-	tokenString, err := ts.String(token)
+	payload := make(map[string]string)
+	if contains(app.TokenPayload(), PayloadName) {
+		payload[PayloadName] = u.Username()
+	}
+
+	now := ijwt.TimeFunc().Unix()
+
+	lifespan := app.TokenLifespan()
+	if lifespan == 0 {
+		lifespan = TokenLifespan
+	}
+	expiresAt := now + lifespan
+	if subjectClaims.ExpiresAt < expiresAt {
+		expiresAt = subjectClaims.ExpiresAt
+	}
+
+	claims := ijwt.Claims{
+		Scopes:  strings.Join(scopes, " "),
+		Payload: payload,
+		Type:    AccessTokenType,
+		Actor:   &ijwt.Actor{Subject: actor, Actor: subjectClaims.Actor},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiresAt,
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
 	if err != nil {
-		return nil, ErrSavingToken
+		return nil, err
 	}
 
-	if err := ts.tokenStorage.SaveToken(tokenString); err != nil {
-		return nil, ErrSavingToken
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
 	}
-	return token, nil
+	return &ijwt.JWToken{JWT: token, New: true}, nil
 }
 
 // NewInviteToken creates new invite token.
@@ -344,14 +633,9 @@ func (ts *JWTokenService) NewInviteToken() (ijwt.Token, error) {
 		},
 	}
 
-	var sm jwt.SigningMethod
-	switch ts.algorithm {
-	case ijwt.TokenSignatureAlgorithmES256:
-		sm = jwt.SigningMethodES256
-	case ijwt.TokenSignatureAlgorithmRS256:
-		sm = jwt.SigningMethodRS256
-	default:
-		return nil, ijwt.ErrWrongSignatureAlgorithm
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
 	}
 
 	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
@@ -378,14 +662,120 @@ func (ts *JWTokenService) NewResetToken(userID string) (ijwt.Token, error) {
 		},
 	}
 
-	var sm jwt.SigningMethod
-	switch ts.algorithm {
-	case ijwt.TokenSignatureAlgorithmES256:
-		sm = jwt.SigningMethodES256
-	case ijwt.TokenSignatureAlgorithmRS256:
-		sm = jwt.SigningMethodRS256
-	default:
-		return nil, ijwt.ErrWrongSignatureAlgorithm
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
+// NewEmailVerificationToken creates new email verification token.
+// The token is bound to the current user's email, so it stops working once the email is changed.
+func (ts *JWTokenService) NewEmailVerificationToken(u model.User) (ijwt.Token, error) {
+	if len(u.Email()) == 0 {
+		return nil, ErrInvalidUser
+	}
+	now := ijwt.TimeFunc().Unix()
+
+	claims := ijwt.Claims{
+		Type: EmailVerificationTokenType,
+		IDTokenClaims: ijwt.IDTokenClaims{
+			Email: u.Email(),
+		},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: (now + EmailVerificationTokenLifespan),
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  "identifo",
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
+// NewMagicLinkToken creates new token for passwordless login link.
+// The token is issued for the specific app and carries the requested scopes.
+// Like email verification token, it is bound to the user's email the link is sent to.
+func (ts *JWTokenService) NewMagicLinkToken(u model.User, app model.AppData, scopes []string) (ijwt.Token, error) {
+	if !app.Active() {
+		return nil, ErrInvalidApp
+	}
+	if !u.Active() || len(u.Email()) == 0 {
+		return nil, ErrInvalidUser
+	}
+	now := ijwt.TimeFunc().Unix()
+
+	claims := ijwt.Claims{
+		Scopes: strings.Join(scopes, " "),
+		Type:   MagicLinkTokenType,
+		IDTokenClaims: ijwt.IDTokenClaims{
+			Email: u.Email(),
+		},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: (now + MagicLinkTokenLifespan),
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
+// NewTrustedDeviceToken creates new token for the device the user has chosen to skip the second factor on.
+// The token expires along with the device, and stops working as soon as the device is revoked.
+func (ts *JWTokenService) NewTrustedDeviceToken(u model.User, app model.AppData, device model.TrustedDevice) (ijwt.Token, error) {
+	if !app.Active() {
+		return nil, ErrInvalidApp
+	}
+	if !u.Active() {
+		return nil, ErrInvalidUser
+	}
+	now := ijwt.TimeFunc().Unix()
+
+	claims := ijwt.Claims{
+		Payload: map[string]string{PayloadTrustedDevice: device.ID},
+		Type:    TrustedDeviceTokenType,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: device.ExpiresAt,
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
 	}
 
 	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
@@ -404,25 +794,66 @@ func (ts *JWTokenService) NewWebCookieToken(u model.User) (ijwt.Token, error) {
 	now := ijwt.TimeFunc().Unix()
 	lifespan := ts.resetTokenLifespan
 
+	return ts.newWebCookieToken(u.ID(), now, now+lifespan, nil)
+}
+
+// JoinWebCookieToken returns web cookie token of the same web session, which also lists the app among those
+// that have got tokens through the session. The token is returned as is if the app is already listed.
+func (ts *JWTokenService) JoinWebCookieToken(token ijwt.Token, appID string) (ijwt.Token, error) {
+	wt, ok := token.(*ijwt.JWToken)
+	if !ok || wt == nil {
+		return nil, ijwt.ErrTokenInvalid
+	}
+	if err := wt.Validate(); err != nil {
+		return nil, err
+	}
+	// Parsed tokens hold pointer to claims, and new ones hold claims by value.
+	var claims ijwt.Claims
+	switch c := wt.JWT.Claims.(type) {
+	case *ijwt.Claims:
+		if c == nil {
+			return nil, ijwt.ErrTokenInvalid
+		}
+		claims = *c
+	case ijwt.Claims:
+		claims = c
+	default:
+		return nil, ijwt.ErrTokenInvalid
+	}
+	if claims.Type != WebCookieTokenType {
+		return nil, ijwt.ErrTokenInvalid
+	}
+
+	apps := strings.Fields(claims.Payload[PayloadWebSessionApps])
+	if contains(apps, appID) {
+		return token, nil
+	}
+	return ts.newWebCookieToken(claims.Subject, claims.IssuedAt, claims.ExpiresAt, append(apps, appID))
+}
+
+// WebSessionApps returns IDs of the apps that have got tokens through the web session of the web cookie token.
+func WebSessionApps(token ijwt.Token) []string {
+	return strings.Fields(token.Payload()[PayloadWebSessionApps])
+}
+
+func (ts *JWTokenService) newWebCookieToken(userID string, issuedAt, expiresAt int64, apps []string) (ijwt.Token, error) {
 	claims := ijwt.Claims{
 		Type: WebCookieTokenType,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: (now + lifespan),
+			ExpiresAt: expiresAt,
 			Issuer:    ts.issuer,
-			Subject:   u.ID(),
+			Subject:   userID,
 			Audience:  "identifo",
-			IssuedAt:  now,
+			IssuedAt:  issuedAt,
 		},
 	}
+	if len(apps) > 0 {
+		claims.Payload = map[string]string{PayloadWebSessionApps: strings.Join(apps, " ")}
+	}
 
-	var sm jwt.SigningMethod
-	switch ts.algorithm {
-	case ijwt.TokenSignatureAlgorithmES256:
-		sm = jwt.SigningMethodES256
-	case ijwt.TokenSignatureAlgorithmRS256:
-		sm = jwt.SigningMethodRS256
-	default:
-		return nil, ijwt.ErrWrongSignatureAlgorithm
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
 	}
 
 	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
@@ -433,6 +864,42 @@ func (ts *JWTokenService) NewWebCookieToken(u model.User) (ijwt.Token, error) {
 	return &ijwt.JWToken{JWT: token, New: true}, nil
 }
 
+// NewLogoutToken creates back-channel logout token, which tells the app that the user's web session has ended.
+// Additional info: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken.
+func (ts *JWTokenService) NewLogoutToken(u model.User, app model.AppData) (ijwt.Token, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return nil, ErrCreatingToken
+	}
+	now := ijwt.TimeFunc().Unix()
+
+	claims := ijwt.Claims{
+		Type:   LogoutTokenType,
+		Events: map[string]struct{}{BackChannelLogoutEvent: {}},
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			ExpiresAt: (now + LogoutTokenLifespan),
+			Issuer:    ts.issuer,
+			Subject:   u.ID(),
+			Audience:  app.ID(),
+			IssuedAt:  now,
+		},
+	}
+
+	sm, err := ts.signingMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	token := ijwt.NewTokenWithClaims(sm, ts.KeyID(), claims)
+	if token == nil {
+		return nil, ErrCreatingToken
+	}
+	// Explicit type keeps logout tokens from being taken for ID tokens.
+	token.Header["typ"] = "logout+jwt"
+	return &ijwt.JWToken{JWT: token, New: true}, nil
+}
+
 // String returns string representation of a token.
 func (ts *JWTokenService) String(t ijwt.Token) (string, error) {
 	token, ok := t.(*ijwt.JWToken)
@@ -446,11 +913,19 @@ func (ts *JWTokenService) String(t ijwt.Token) (string, error) {
 	if !token.New && !token.JWT.Valid {
 		return "", ijwt.ErrTokenInvalid
 	}
+	// ECDSA signatures are not deterministic, so we sign each new token only once
+	// to let token storage find the same string we give out.
+	if token.New && len(token.JWT.Raw) > 0 {
+		return token.JWT.Raw, nil
+	}
 
 	str, err := token.JWT.SignedString(ts.privateKey)
 	if err != nil {
 		return "", err
 	}
+	if token.New {
+		token.JWT.Raw = str
+	}
 	return str, nil
 }
 
@@ -478,3 +953,17 @@ func contains(s []string, e string) bool {
 	}
 	return false
 }
+
+// newRefreshTokenFamily generates random refresh token family identifier.
+func newRefreshTokenFamily() (string, error) {
+	return newTokenID()
+}
+
+// newTokenID generates random unique token identifier.
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
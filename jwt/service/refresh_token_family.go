@@ -0,0 +1,26 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	ijwt "github.com/madappgang/identifo/jwt"
+	"github.com/madappgang/identifo/model"
+)
+
+// CheckRefreshTokenFamily makes sure that the refresh token has not been replaced during rotation.
+// Replaced token is presented again only if it has leaked, so the whole token family gets revoked.
+// The family is the user session, so revoking it removes the tokens of the session from the token storage.
+func CheckRefreshTokenFamily(token ijwt.Token, tokenString string, app model.AppData, tokenStorage model.TokenStorage, logger *log.Logger) error {
+	if tokenStorage.HasToken(tokenString) {
+		return nil
+	}
+
+	if family := token.Payload()[PayloadRefreshTokenFamily]; len(family) > 0 {
+		if _, err := tokenStorage.DeleteUserSession(token.UserID(), family); err != nil && err != model.ErrorNotFound {
+			logger.Println("Cannot revoke refresh token family:", err)
+		}
+	}
+	logger.Printf("Refresh token reuse detected, token family of user %s for app %s is revoked", token.UserID(), app.ID())
+	return errors.New("Refresh token has already been used")
+}
@@ -8,6 +8,14 @@ import (
 const (
 	// OfflineScope is a scope value to request refresh token.
 	OfflineScope = "offline"
+	// OpenIDScope is a scope value to request OpenID Connect ID token.
+	OpenIDScope = "openid"
+	// EmailScope is a scope value to request user's email in ID token and user info.
+	EmailScope = "email"
+	// PhoneScope is a scope value to request user's phone number in ID token and user info.
+	PhoneScope = "phone"
+	// ProfileScope is a scope value to request user's profile claims in ID token and user info.
+	ProfileScope = "profile"
 	// RefrestTokenType is a refresh token type value.
 	RefrestTokenType = "refresh"
 	// InviteTokenType is an invite token type value.
@@ -18,16 +26,38 @@ const (
 	ResetTokenType = "reset"
 	// WebCookieTokenType is a web-cookie token type value.
 	WebCookieTokenType = "web-cookie"
+	// EmailVerificationTokenType is an email verification token type value.
+	EmailVerificationTokenType = "email-verification"
+	// MagicLinkTokenType is a passwordless login link token type value.
+	MagicLinkTokenType = "magic-link"
+	// TrustedDeviceTokenType is a token type value of the device the second factor is skipped on.
+	TrustedDeviceTokenType = "trusted-device"
+	// IDTokenType is an OpenID Connect ID token type value.
+	IDTokenType = "id"
+	// LogoutTokenType is an OpenID Connect back-channel logout token type value.
+	LogoutTokenType = "logout"
+	// BackChannelLogoutEvent is the event logout tokens inform about.
+	BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
 )
 
 // TokenService is an abstract token manager.
 type TokenService interface {
 	NewAccessToken(u model.User, scopes []string, app model.AppData, requireTFA bool) (ijwt.Token, error)
-	NewRefreshToken(u model.User, scopes []string, app model.AppData) (ijwt.Token, error)
+	NewRefreshToken(u model.User, scopes []string, app model.AppData, client model.ClientInfo) (ijwt.Token, error)
+	NewAppAccessToken(app model.AppData, scopes []string) (ijwt.Token, error)
+	NewImpersonationToken(u model.User, scopes []string, app model.AppData, actor string) (ijwt.Token, error)
+	NewIDToken(u model.User, scopes []string, app model.AppData, nonce string, authTime int64, accessToken string) (ijwt.Token, error)
 	RefreshAccessToken(token ijwt.Token) (ijwt.Token, error)
+	ExchangeAccessToken(subjectToken ijwt.Token, u model.User, scopes []string, app model.AppData, actor string) (ijwt.Token, error)
+	RotateRefreshToken(token ijwt.Token, client model.ClientInfo) (ijwt.Token, error)
 	NewInviteToken() (ijwt.Token, error)
 	NewResetToken(userID string) (ijwt.Token, error)
+	NewEmailVerificationToken(u model.User) (ijwt.Token, error)
+	NewMagicLinkToken(u model.User, app model.AppData, scopes []string) (ijwt.Token, error)
+	NewTrustedDeviceToken(u model.User, app model.AppData, device model.TrustedDevice) (ijwt.Token, error)
 	NewWebCookieToken(u model.User) (ijwt.Token, error)
+	JoinWebCookieToken(token ijwt.Token, appID string) (ijwt.Token, error)
+	NewLogoutToken(u model.User, app model.AppData) (ijwt.Token, error)
 	Parse(string) (ijwt.Token, error)
 	String(ijwt.Token) (string, error)
 	Issuer() string
@@ -8,6 +8,8 @@ type Token interface {
 	UserID() string
 	Type() string
 	Payload() map[string]string
+	IssuedAt() int64
+	Actor() *Actor
 }
 
 // NewTokenWithClaims generates new JWT token with claims and keyID.
@@ -67,14 +69,55 @@ func (t *JWToken) Type() string {
 	return claims.Type
 }
 
+// IssuedAt returns the time when the token was issued, as Unix time.
+func (t *JWToken) IssuedAt() int64 {
+	claims, ok := t.JWT.Claims.(*Claims)
+	if !ok {
+		return 0
+	}
+	return claims.IssuedAt
+}
+
+// Actor returns the party acting on behalf of the subject, or nil if the subject uses the token.
+func (t *JWToken) Actor() *Actor {
+	claims, ok := t.JWT.Claims.(*Claims)
+	if !ok {
+		return nil
+	}
+	return claims.Actor
+}
+
 // Claims is an extended claims structure.
 type Claims struct {
 	Payload map[string]string `json:"payload,omitempty"`
 	Scopes  string            `json:"scopes,omitempty"`
 	Type    string            `json:"type,omitempty"`
 	KeyID   string            `json:"kid,omitempty"` // optional keyID
+	Actor   *Actor            `json:"act,omitempty"`
+	// Events are security events the token informs about, used by logout tokens only.
+	Events map[string]struct{} `json:"events,omitempty"`
+	IDTokenClaims
 	jwt.StandardClaims
 }
 
+// Actor is the party acting on behalf of the token subject, like an admin impersonating the user.
+// Additional info: https://tools.ietf.org/html/rfc8693#section-4.1.
+type Actor struct {
+	Subject string `json:"sub"`
+	// Actor is the one who acted before, if the token has been passed along the chain.
+	Actor *Actor `json:"act,omitempty"`
+}
+
+// IDTokenClaims are OpenID Connect claims, used by ID tokens only.
+// Additional info: https://openid.net/specs/openid-connect-core-1_0.html#IDToken.
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time,omitempty"`
+	AccessTokenHash   string `json:"at_hash,omitempty"`
+	Email             string `json:"email,omitempty"`
+	PhoneNumber       string `json:"phone_number,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
 // Full example of how to use JWT tokens:
 // https://github.com/dgrijalva/jwt-go/blob/master/cmd/jwt/app.go
@@ -95,37 +95,7 @@ func TestNewTokenService(t *testing.T) {
 }
 
 func TestParseString(t *testing.T) {
-	us, err := mem.NewUserStorage()
-	if err != nil {
-		t.Fatalf("Unable to create user storage %v", err)
-	}
-	tstor, err := mem.NewTokenStorage()
-	if err != nil {
-		t.Fatalf("Unable to create token storage %v", err)
-	}
-	as, err := mem.NewAppStorage()
-	if err != nil {
-		t.Fatalf("Unable to create app storage %v", err)
-	}
-	configStorage, err := configStorageFile.NewConfigurationStorage(model.ConfigurationStorageSettings{
-		Type: model.ConfigurationStorageTypeFile,
-		KeyStorage: model.KeyStorageSettings{
-			Type: model.KeyStorageTypeLocal,
-		},
-	})
-	if err != nil {
-		t.Fatalf("Unable to init configuration storage. %v", err)
-	}
-
-	keys, err := configStorage.LoadKeys(ijwt.TokenSignatureAlgorithmES256)
-	if err != nil {
-		t.Fatalf("Cannot load keys = %s", err)
-	}
-
-	ts, err := jwtService.NewJWTokenService(keys, testIssuer, tstor, as, us)
-	if err != nil {
-		t.Fatalf("Unable to create service %v", err)
-	}
+	ts, _, _ := testTokenService(t, testUserStorage(t))
 	token, err := ts.Parse(tokenStringExample)
 	if err != nil {
 		t.Fatalf("Unable to parse token. %v", err)
@@ -151,36 +121,7 @@ func TestParseString(t *testing.T) {
 }
 
 func TestTokenToString(t *testing.T) {
-	us, err := mem.NewUserStorage()
-	if err != nil {
-		t.Errorf("Unable to create user storage %v", err)
-	}
-	tstor, err := mem.NewTokenStorage()
-	if err != nil {
-		t.Errorf("Unable to create token storage %v", err)
-	}
-	as, err := mem.NewAppStorage()
-	if err != nil {
-		t.Errorf("Unable to create app storage %v", err)
-	}
-	configStorage, err := configStorageFile.NewConfigurationStorage(model.ConfigurationStorageSettings{
-		Type: model.ConfigurationStorageTypeFile,
-		KeyStorage: model.KeyStorageSettings{
-			Type: model.KeyStorageTypeLocal,
-		},
-	})
-	if err != nil {
-		t.Fatalf("Unable to init configuration storage. %v", err)
-	}
-
-	keys, err := configStorage.LoadKeys(ijwt.TokenSignatureAlgorithmES256)
-	if err != nil {
-		t.Fatalf("Cannot load keys = %s", err)
-	}
-	ts, err := jwtService.NewJWTokenService(keys, testIssuer, tstor, as, us)
-	if err != nil {
-		t.Errorf("Unable to create service %v", err)
-	}
+	ts, _, _ := testTokenService(t, testUserStorage(t))
 	token, err := ts.Parse(tokenStringExample)
 	if err != nil {
 		t.Errorf("Unable to parse token %v", err)
@@ -218,41 +159,9 @@ func TestTokenToString(t *testing.T) {
 }
 
 func TestNewToken(t *testing.T) {
-	us, err := mem.NewUserStorage()
-	if err != nil {
-		t.Errorf("Unable to create user storage %v", err)
-	}
-	tstor, err := mem.NewTokenStorage()
-	if err != nil {
-		t.Errorf("Unable to create token storage %v", err)
-	}
-	as, err := mem.NewAppStorage()
-	if err != nil {
-		t.Errorf("Unable to create app storage %v", err)
-	}
-	configStorage, err := configStorageFile.NewConfigurationStorage(model.ConfigurationStorageSettings{
-		Type: model.ConfigurationStorageTypeFile,
-		KeyStorage: model.KeyStorageSettings{
-			Type: model.KeyStorageTypeLocal,
-		},
-	})
-	if err != nil {
-		t.Fatalf("Unable to init configuration storage. %v", err)
-	}
-	keys, err := configStorage.LoadKeys(ijwt.TokenSignatureAlgorithmES256)
-	if err != nil {
-		t.Fatalf("Cannot load keys = %s", err)
-	}
-	ts, err := jwtService.NewJWTokenService(keys, testIssuer, tstor, as, us)
-	if err != nil {
-		t.Errorf("Unable to create service %v", err)
-	}
-	ustg, _ := mem.NewUserStorage()
-	user, _ := ustg.UserByNamePassword("username", "password")
-	//generate random user until we get active user
-	for !user.Active() {
-		user, _ = ustg.UserByNamePassword("username", "password")
-	}
+	us := testUserStorage(t)
+	ts, _, _ := testTokenService(t, us)
+	user := activeUser(us)
 	scopes := []string{"scope1", "scope2"}
 	tokenPayload := []string{"name"}
 	app := mem.MakeAppData("123456", "1", true, "testName", "testDescriprion", scopes, true, []string{}, 0, 0, 0, tokenPayload, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
@@ -286,3 +195,307 @@ func TestNewToken(t *testing.T) {
 		t.Errorf("Audience = %+v, want %+v", claims2.Audience, app.ID())
 	}
 }
+
+func TestNewImpersonationToken(t *testing.T) {
+	us := testUserStorage(t)
+	ts, _, _ := testTokenService(t, us)
+	user := activeUser(us)
+	scopes := []string{"scope1"}
+	app := mem.MakeAppData("123456", "1", true, "testName", "testDescriprion", scopes, true, []string{}, 0, 0, 0, []string{}, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+
+	if _, err := ts.NewImpersonationToken(user, scopes, &app, ""); err == nil {
+		t.Errorf("Impersonation token must name the actor")
+	}
+
+	token, err := ts.NewImpersonationToken(user, scopes, &app, "admin@example.com")
+	if err != nil {
+		t.Fatalf("Unable to create token %v", err)
+	}
+	tokenString, err := ts.String(token)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	parsed, err := ts.Parse(tokenString)
+	if err != nil {
+		t.Fatalf("Unable to parse token %v", err)
+	}
+	claims, _ := parsed.(*ijwt.JWToken).JWT.Claims.(*ijwt.Claims)
+	if claims.Subject != user.ID() || claims.Type != jwtService.AccessTokenType {
+		t.Errorf("Subject = %v, type = %v, want access token of user %v", claims.Subject, claims.Type, user.ID())
+	}
+	if claims.Actor == nil || claims.Actor.Subject != "admin@example.com" {
+		t.Errorf("Actor = %+v, want admin@example.com", claims.Actor)
+	}
+	if lifespan := claims.ExpiresAt - claims.IssuedAt; lifespan > jwtService.ImpersonationTokenLifespan {
+		t.Errorf("Lifespan = %v, want at most %v", lifespan, jwtService.ImpersonationTokenLifespan)
+	}
+}
+
+func TestNewLogoutToken(t *testing.T) {
+	us := testUserStorage(t)
+	ts, _, _ := testTokenService(t, us)
+	user, _ := us.UserByNamePassword("username", "password")
+	app := mem.MakeAppData("123456", "1", true, "testName", "testDescriprion", []string{}, true, []string{}, 0, 0, 0, []string{}, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+
+	token, err := ts.NewLogoutToken(user, &app)
+	if err != nil {
+		t.Fatalf("Unable to create token %v", err)
+	}
+	tokenString, err := ts.String(token)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	parsed, err := ts.Parse(tokenString)
+	if err != nil {
+		t.Fatalf("Unable to parse token %v", err)
+	}
+	jwtToken := parsed.(*ijwt.JWToken).JWT
+	if typ := jwtToken.Header["typ"]; typ != "logout+jwt" {
+		t.Errorf("Header typ = %v, want logout+jwt", typ)
+	}
+	claims, _ := jwtToken.Claims.(*ijwt.Claims)
+	if claims.Subject != user.ID() || claims.Audience != app.ID() || claims.Type != jwtService.LogoutTokenType {
+		t.Errorf("Subject = %v, audience = %v, type = %v, want logout token of user %v for app %v", claims.Subject, claims.Audience, claims.Type, user.ID(), app.ID())
+	}
+	if _, ok := claims.Events[jwtService.BackChannelLogoutEvent]; !ok || len(claims.Id) == 0 {
+		t.Errorf("Events = %v, jti = %v, want back-channel logout event with jti", claims.Events, claims.Id)
+	}
+	// Logout tokens must not be usable as ID tokens, so they have no nonce.
+	if len(claims.Nonce) > 0 {
+		t.Errorf("Logout token must not contain nonce")
+	}
+}
+
+func TestJoinWebCookieToken(t *testing.T) {
+	us := testUserStorage(t)
+	ts, _, _ := testTokenService(t, us)
+	user := activeUser(us)
+
+	token, err := ts.NewWebCookieToken(user)
+	if err != nil {
+		t.Fatalf("Unable to create token %v", err)
+	}
+	if apps := jwtService.WebSessionApps(token); len(apps) > 0 {
+		t.Errorf("New web session apps = %v, want none", apps)
+	}
+
+	joined, err := ts.JoinWebCookieToken(token, "app1")
+	if err != nil {
+		t.Fatalf("Unable to join app to token %v", err)
+	}
+	joined, err = ts.JoinWebCookieToken(joined, "app2")
+	if err != nil {
+		t.Fatalf("Unable to join app to token %v", err)
+	}
+	rejoined, err := ts.JoinWebCookieToken(joined, "app1")
+	if err != nil {
+		t.Fatalf("Unable to join app to token %v", err)
+	}
+	if rejoined != joined {
+		t.Errorf("Joining listed app must return the same token")
+	}
+
+	tokenString, err := ts.String(joined)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	parsed, err := ts.Parse(tokenString)
+	if err != nil {
+		t.Fatalf("Unable to parse token %v", err)
+	}
+	if apps := jwtService.WebSessionApps(parsed); !reflect.DeepEqual(apps, []string{"app1", "app2"}) {
+		t.Errorf("Web session apps = %v, want [app1 app2]", apps)
+	}
+	// The session keeps its user and lifetime.
+	issuedAt := token.(*ijwt.JWToken).JWT.Claims.(ijwt.Claims).IssuedAt
+	if parsed.UserID() != user.ID() || parsed.IssuedAt() != issuedAt || parsed.Type() != jwtService.WebCookieTokenType {
+		t.Errorf("Joined token user = %v, iat = %v, type = %v, want %v, %v, %v", parsed.UserID(), parsed.IssuedAt(), parsed.Type(), user.ID(), issuedAt, jwtService.WebCookieTokenType)
+	}
+
+	// Only web cookie tokens carry web sessions.
+	app := mem.MakeAppData("123456", "1", true, "testName", "testDescriprion", []string{}, true, []string{}, 0, 0, 0, []string{}, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+	access, err := ts.NewAccessToken(user, []string{}, &app, false)
+	if err != nil {
+		t.Fatalf("Unable to create token %v", err)
+	}
+	if _, err = ts.JoinWebCookieToken(access, "app1"); err == nil {
+		t.Errorf("Access token must not be joined to web session")
+	}
+}
+
+func TestExchangeAccessToken(t *testing.T) {
+	us := testUserStorage(t)
+	ts, _, _ := testTokenService(t, us)
+	user := activeUser(us)
+	scopes := []string{"scope1"}
+	gateway := mem.MakeAppData("gateway", "1", true, "gateway", "", scopes, true, []string{}, 0, 0, 0, []string{}, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+	service := mem.MakeAppData("service", "2", true, "service", "", scopes, true, []string{}, 0, 0, 0, []string{}, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+
+	// Parsed tokens are exchanged, the same way the token endpoint does it.
+	subject, err := ts.NewImpersonationToken(user, scopes, &gateway, "admin@example.com")
+	if err != nil {
+		t.Fatalf("Unable to create token %v", err)
+	}
+	subjectString, err := ts.String(subject)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	if subject, err = ts.Parse(subjectString); err != nil {
+		t.Fatalf("Unable to parse token %v", err)
+	}
+
+	other, _ := us.UserByNamePassword("username", "password")
+	if _, err = ts.ExchangeAccessToken(subject, other, scopes, &service, gateway.ID()); err == nil {
+		t.Errorf("Token must not be exchanged for another user")
+	}
+
+	token, err := ts.ExchangeAccessToken(subject, user, scopes, &service, gateway.ID())
+	if err != nil {
+		t.Fatalf("Unable to exchange token %v", err)
+	}
+	tokenString, err := ts.String(token)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	parsed, err := ts.Parse(tokenString)
+	if err != nil {
+		t.Fatalf("Unable to parse token %v", err)
+	}
+	claims, _ := parsed.(*ijwt.JWToken).JWT.Claims.(*ijwt.Claims)
+	subjectClaims, _ := subject.(*ijwt.JWToken).JWT.Claims.(*ijwt.Claims)
+	if claims.Subject != user.ID() || claims.Audience != service.ID() {
+		t.Errorf("Subject = %v, audience = %v, want %v and %v", claims.Subject, claims.Audience, user.ID(), service.ID())
+	}
+	if claims.Actor == nil || claims.Actor.Subject != gateway.ID() || claims.Actor.Actor == nil || claims.Actor.Actor.Subject != "admin@example.com" {
+		t.Errorf("Actor = %+v, want gateway acting after admin", claims.Actor)
+	}
+	if claims.ExpiresAt > subjectClaims.ExpiresAt {
+		t.Errorf("ExpiresAt = %v, must not outlive subject token expiring at %v", claims.ExpiresAt, subjectClaims.ExpiresAt)
+	}
+}
+
+func TestRefreshTokenSession(t *testing.T) {
+	us := testUserStorage(t)
+	user := activeUser(us)
+	ts, tstor, as := testTokenService(t, sameUserStorage{UserStorage: us, user: user})
+	scopes := []string{jwtService.OfflineScope}
+	app := mem.MakeAppData("123456", "1", true, "testName", "", scopes, true, []string{}, 0, 0, 0, []string{}, true, true, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+
+	if _, err := as.CreateApp(&app); err != nil {
+		t.Fatalf("Unable to create app %v", err)
+	}
+
+	token, err := ts.NewRefreshToken(user, scopes, &app, model.ClientInfo{DeviceName: "Phone", IP: "10.0.0.1", UserAgent: "app/1.0"})
+	if err != nil {
+		t.Fatalf("Unable to create token %v", err)
+	}
+	tokenString, err := ts.String(token)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	parsed, err := ts.Parse(tokenString)
+	if err != nil {
+		t.Fatalf("Unable to parse token %v", err)
+	}
+	session, err := tstor.TokenSession(tokenString)
+	if err != nil {
+		t.Fatalf("Unable to get token session %v", err)
+	}
+	if session.ID != parsed.Payload()[jwtService.PayloadRefreshTokenFamily] || session.UserID != user.ID() || session.AppID != app.ID() {
+		t.Errorf("Session = %+v, want token family for user %v in app %v", session, user.ID(), app.ID())
+	}
+	if session.DeviceName != "Phone" || session.IP != "10.0.0.1" || session.UserAgent != "app/1.0" {
+		t.Errorf("Session = %+v, want client info saved", session)
+	}
+
+	// Rotated token continues the session, the device name sticks.
+	rotated, err := ts.RotateRefreshToken(parsed, model.ClientInfo{IP: "10.0.0.2", UserAgent: "app/1.1"})
+	if err != nil {
+		t.Fatalf("Unable to rotate token %v", err)
+	}
+	rotatedString, err := ts.String(rotated)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	rotatedSession, err := tstor.TokenSession(rotatedString)
+	if err != nil {
+		t.Fatalf("Unable to get token session %v", err)
+	}
+	if rotatedSession.ID != session.ID || rotatedSession.CreatedAt != session.CreatedAt || rotatedSession.DeviceName != "Phone" || rotatedSession.IP != "10.0.0.2" {
+		t.Errorf("Rotated session = %+v, want continuation of %+v", rotatedSession, session)
+	}
+
+	if err = tstor.DeleteToken(tokenString); err != nil {
+		t.Fatalf("Unable to delete token %v", err)
+	}
+	sessions, err := tstor.UserSessions(user.ID())
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Sessions = %+v, err = %v, want the only session", sessions, err)
+	}
+	tokens, err := tstor.DeleteUserSession(user.ID(), session.ID)
+	if err != nil || len(tokens) != 1 || tokens[0] != rotatedString {
+		t.Errorf("Deleted tokens = %v, err = %v, want the rotated token", tokens, err)
+	}
+	if tstor.HasToken(rotatedString) {
+		t.Errorf("Token of deleted session must be removed from the storage")
+	}
+}
+
+// sameUserStorage always returns the same user, while the in-memory storage makes up a new one every time.
+type sameUserStorage struct {
+	model.UserStorage
+	user model.User
+}
+
+func (s sameUserStorage) UserByID(string) (model.User, error) { return s.user, nil }
+
+func testUserStorage(t *testing.T) model.UserStorage {
+	t.Helper()
+
+	us, err := mem.NewUserStorage()
+	if err != nil {
+		t.Fatalf("Unable to create user storage %v", err)
+	}
+	return us
+}
+
+// activeUser returns active user, as the in-memory storage makes up random users, and some of them are inactive.
+func activeUser(us model.UserStorage) model.User {
+	user, _ := us.UserByNamePassword("username", "password")
+	for !user.Active() {
+		user, _ = us.UserByNamePassword("username", "password")
+	}
+	return user
+}
+
+// testTokenService creates token service with the test keys, backed with in-memory token and app storages.
+func testTokenService(t *testing.T, us model.UserStorage) (jwtService.TokenService, model.TokenStorage, model.AppStorage) {
+	t.Helper()
+
+	tstor, err := mem.NewTokenStorage()
+	if err != nil {
+		t.Fatalf("Unable to create token storage %v", err)
+	}
+	as, err := mem.NewAppStorage()
+	if err != nil {
+		t.Fatalf("Unable to create app storage %v", err)
+	}
+	configStorage, err := configStorageFile.NewConfigurationStorage(model.ConfigurationStorageSettings{
+		Type: model.ConfigurationStorageTypeFile,
+		KeyStorage: model.KeyStorageSettings{
+			Type: model.KeyStorageTypeLocal,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to init configuration storage. %v", err)
+	}
+	keys, err := configStorage.LoadKeys(ijwt.TokenSignatureAlgorithmES256)
+	if err != nil {
+		t.Fatalf("Cannot load keys = %s", err)
+	}
+	ts, err := jwtService.NewJWTokenService(keys, testIssuer, tstor, as, us)
+	if err != nil {
+		t.Fatalf("Unable to create service %v", err)
+	}
+	return ts, tstor, as
+}
@@ -37,12 +37,26 @@ type AppData interface {
 	InviteTokenLifespan() int64
 	// RefreshTokenLifespan is a refreshToken lifespan in seconds, if 0 - default one is used.
 	RefreshTokenLifespan() int64
+	// RefreshTokenRotation indicates whether the refresh token gets replaced with the new one on every use.
+	// Presenting already replaced token again revokes the whole token family, as it is likely to be stolen.
+	RefreshTokenRotation() bool
+	// TrustedDeviceLifespan is a maximum age in seconds of the devices users choose to skip the second factor on.
+	// If it's 0, devices cannot be remembered.
+	TrustedDeviceLifespan() int64
+	// TokenExchangeAudiences are IDs of the apps this app may exchange user access tokens for, to call them on behalf of the user.
+	// If it's empty, the app cannot exchange tokens.
+	TokenExchangeAudiences() []string
+	// BackChannelLogoutURI is where the app gets logout tokens when the user's web session ends.
+	// If it's empty, the app is not notified.
+	BackChannelLogoutURI() string
 	// Payload is a list of fields that are included in token. If it's empty, there are no fields in payload.
 	TokenPayload() []string
 	Sanitize()
 	TFAStatus() TFAStatus
 	DebugTFACode() string
 	RegistrationForbidden() bool
+	// EmailVerificationRequired indicates whether users have to verify their email before they can log in.
+	EmailVerificationRequired() bool
 	AnonymousRegistrationAllowed() bool
 	AuthzWay() AuthorizationWay
 	AuthzModel() string
@@ -51,6 +65,11 @@ type AppData interface {
 	RolesBlacklist() []string
 	NewUserDefaultRole() string
 	AppleInfo() *AppleInfo
+	GoogleInfo() *GoogleInfo
+	// OIDCProviders are generic OpenID Connect identity providers users can log in with.
+	OIDCProviders() []OIDCProvider
+	// SAMLInfo is the app's SAML 2.0 identity provider, nil if SAML login is not enabled for the app.
+	SAMLInfo() *SAMLInfo
 	SetSecret(secret string)
 }
 
@@ -66,6 +85,8 @@ const (
 	IOS AppType = "ios"
 	// Desktop is a desktop app.
 	Desktop AppType = "desktop"
+	// Service is a backend service which acts on its own behalf, not on behalf of a user.
+	Service AppType = "service"
 )
 
 // AuthorizationWay is a way of authorization supported by the application.
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// AuthorizationCodeLifespan is a lifespan of the OAuth 2.0 authorization code.
+// RFC 6749 recommends a maximum lifetime of 10 minutes.
+const AuthorizationCodeLifespan = 10 * time.Minute
+
+// AuthorizationCodeStorage stores short-lived OAuth 2.0 authorization codes.
+type AuthorizationCodeStorage interface {
+	SaveAuthorizationCode(code AuthorizationCode) error
+	// ConsumeAuthorizationCode returns the code and removes it from the storage, so each code can be exchanged only once.
+	ConsumeAuthorizationCode(code string) (AuthorizationCode, error)
+	Close()
+}
+
+// AuthorizationCode is an authorization code issued by the authorization endpoint,
+// with everything we need to know to exchange it for tokens.
+type AuthorizationCode struct {
+	Code                string   `json:"code" bson:"code"`
+	AppID               string   `json:"app_id" bson:"app_id"`
+	UserID              string   `json:"user_id" bson:"user_id"`
+	RedirectURI         string   `json:"redirect_uri" bson:"redirect_uri"`
+	Scopes              []string `json:"scopes,omitempty" bson:"scopes,omitempty"`
+	CodeChallenge       string   `json:"code_challenge,omitempty" bson:"code_challenge,omitempty"`
+	CodeChallengeMethod string   `json:"code_challenge_method,omitempty" bson:"code_challenge_method,omitempty"`
+	Nonce               string   `json:"nonce,omitempty" bson:"nonce,omitempty"`
+	AuthTime            int64    `json:"auth_time,omitempty" bson:"auth_time,omitempty"`
+	ExpiresAt           int64    `json:"expires_at" bson:"expires_at"`
+}
+
+// Expired checks whether the code has expired.
+func (ac AuthorizationCode) Expired() bool {
+	return time.Now().Unix() > ac.ExpiresAt
+}
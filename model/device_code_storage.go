@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+const (
+	// DeviceCodeLifespan is a lifespan of the device code and its user code.
+	DeviceCodeLifespan = 10 * time.Minute
+	// DeviceCodePollingInterval is a minimum amount of time in seconds the device should wait between polling requests.
+	DeviceCodePollingInterval = 5
+)
+
+// DeviceCodeStorage stores device codes issued for OAuth 2.0 device authorization grant.
+type DeviceCodeStorage interface {
+	// SaveDeviceCode creates new device code or replaces the existing one.
+	SaveDeviceCode(code DeviceCode) error
+	DeviceCodeByDeviceCode(deviceCode string) (DeviceCode, error)
+	DeviceCodeByUserCode(userCode string) (DeviceCode, error)
+	// UpdateDeviceCodePolling updates only the polling state of the device code,
+	// so that polling does not undo the user's approval made at the same time.
+	UpdateDeviceCodePolling(deviceCode string, lastPolledAt, interval int64) error
+	// ConsumeDeviceCode removes the approved device code and returns it, so that tokens are issued for it only once.
+	// Codes that are not approved are left alone, and ErrorNotFound is returned for them.
+	ConsumeDeviceCode(deviceCode string) (DeviceCode, error)
+	DeleteDeviceCode(deviceCode string) error
+	Close()
+}
+
+// DeviceCodeStatus is a status of the device authorization request.
+type DeviceCodeStatus string
+
+const (
+	// DeviceCodeStatusPending is when the user has not approved or denied the request yet.
+	DeviceCodeStatusPending DeviceCodeStatus = "pending"
+	// DeviceCodeStatusApproved is when the user has approved the request.
+	DeviceCodeStatusApproved DeviceCodeStatus = "approved"
+	// DeviceCodeStatusDenied is when the user has denied the request.
+	DeviceCodeStatusDenied DeviceCodeStatus = "denied"
+)
+
+// DeviceCode is a device authorization request, see https://tools.ietf.org/html/rfc8628.
+// The device polls the token endpoint with DeviceCode, while the user enters UserCode on the verification page.
+type DeviceCode struct {
+	DeviceCode   string           `json:"device_code" bson:"device_code"`
+	UserCode     string           `json:"user_code" bson:"user_code"`
+	AppID        string           `json:"app_id" bson:"app_id"`
+	Scopes       []string         `json:"scopes,omitempty" bson:"scopes,omitempty"`
+	Status       DeviceCodeStatus `json:"status" bson:"status"`
+	UserID       string           `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	AuthTime     int64            `json:"auth_time,omitempty" bson:"auth_time,omitempty"`
+	Interval     int64            `json:"interval" bson:"interval"`
+	LastPolledAt int64            `json:"last_polled_at,omitempty" bson:"last_polled_at,omitempty"`
+	ExpiresAt    int64            `json:"expires_at" bson:"expires_at"`
+}
+
+// Expired checks whether the device code has expired.
+func (dc DeviceCode) Expired() bool {
+	return time.Now().Unix() > dc.ExpiresAt
+}
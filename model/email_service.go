@@ -15,6 +15,8 @@ type EmailService interface {
 	SendWelcomeEmail(subject, recipient string, data interface{}) error
 	SendVerifyEmail(subject, recipient string, data interface{}) error
 	SendTFAEmail(subject, recipient string, data interface{}) error
+	SendMagicLinkEmail(subject, recipient string, data interface{}) error
+	SendAccountLockedEmail(subject, recipient string, data interface{}) error
 
 	Templater() *EmailTemplater
 }
@@ -26,6 +28,8 @@ type EmailTemplater struct {
 	InviteTemplate        *template.Template
 	VerifyTemplate        *template.Template
 	TFATemplate           *template.Template
+	MagicLinkTemplate     *template.Template
+	AccountLockedTemplate *template.Template
 }
 
 // NewEmailTemplater creates new email templater.
@@ -48,5 +52,11 @@ func NewEmailTemplater(staticFilesStorage StaticFilesStorage) (*EmailTemplater,
 	if et.WelcomeTemplate, err = staticFilesStorage.ParseTemplate(StaticPagesNames.WelcomeEmail); err != nil {
 		return nil, err
 	}
+	if et.MagicLinkTemplate, err = staticFilesStorage.ParseTemplate(StaticPagesNames.MagicLinkEmail); err != nil {
+		return nil, err
+	}
+	if et.AccountLockedTemplate, err = staticFilesStorage.ParseTemplate(StaticPagesNames.AccountLockedEmail); err != nil {
+		return nil, err
+	}
 	return &et, nil
 }
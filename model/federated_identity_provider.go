@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 // FederatedIdentityProvider is an external federated identity provider type.
 // If you are missing the provider you need, please feel free to add it here.
 type FederatedIdentityProvider string
@@ -13,6 +15,8 @@ var (
 	TwitterIDProvider FederatedIdentityProvider = "TWITTER"
 	// AppleIDProvider is an Apple ID provider.
 	AppleIDProvider FederatedIdentityProvider = "APPLE"
+	// LDAPIDProvider links LDAP directory users to their local records. It is not valid for federated login.
+	LDAPIDProvider FederatedIdentityProvider = "LDAP"
 )
 
 // IsValid has to be called everywhere input happens, otherwise you risk to operate on bad data - no guarantees.
@@ -24,8 +28,69 @@ func (fid FederatedIdentityProvider) IsValid() bool {
 	return false
 }
 
+// FederatedIdentity is the user's account at federated identity provider.
+type FederatedIdentity struct {
+	Provider FederatedIdentityProvider `json:"provider"`
+	ID       string                    `json:"id"`
+}
+
+// FederatedIDString returns federated ID the way user storages keep it.
+func FederatedIDString(provider FederatedIdentityProvider, id string) string {
+	return string(provider) + ":" + id
+}
+
+// FederatedIdentitiesFromStrings parses federated IDs kept in user storages.
+func FederatedIdentitiesFromStrings(fids []string) []FederatedIdentity {
+	identities := make([]FederatedIdentity, 0, len(fids))
+	for _, fid := range fids {
+		parts := strings.SplitN(fid, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		identities = append(identities, FederatedIdentity{Provider: FederatedIdentityProvider(parts[0]), ID: parts[1]})
+	}
+	return identities
+}
+
 // AppleInfo represents the information needed for Sign In with Apple.
 type AppleInfo struct {
 	ClientID     string `json:"client_id,omitempty" bson:"client_id,omitempty"`
 	ClientSecret string `json:"client_secret,omitempty" bson:"client_secret,omitempty"`
 }
+
+// GoogleInfo represents the information needed for Sign In with Google.
+// ClientID is the OAuth client ID the Google ID tokens are issued for.
+type GoogleInfo struct {
+	ClientID string `json:"client_id,omitempty" bson:"client_id,omitempty"`
+}
+
+// OIDCProvider is a generic OpenID Connect identity provider configured for the app.
+// Key identifies the provider in federated login requests and in users' federated IDs,
+// so it should refer to the same identity provider in all apps it is configured for.
+type OIDCProvider struct {
+	Key          string `json:"key" bson:"key"`
+	Issuer       string `json:"issuer" bson:"issuer"`
+	ClientID     string `json:"client_id" bson:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty" bson:"client_secret,omitempty"`
+}
+
+// FederatedIdentityProvider returns the provider type users' federated IDs are stored with.
+func (p OIDCProvider) FederatedIdentityProvider() FederatedIdentityProvider {
+	return FederatedIdentityProvider(strings.ToUpper(p.Key))
+}
+
+// SAMLInfo represents the information needed for login with the app's SAML 2.0 identity provider.
+// Key identifies the identity provider in users' federated IDs, NameID of the assertion is the user ID there.
+// IdPMetadata is the identity provider's metadata XML, its signing certificates are the only ones trusted.
+// EmailAttribute is the assertion attribute with user's email, if not set, NameID of email format is used.
+type SAMLInfo struct {
+	Key                 string `json:"key" bson:"key"`
+	IdPMetadata         string `json:"idp_metadata" bson:"idp_metadata"`
+	IdPInitiatedAllowed bool   `json:"idp_initiated_allowed,omitempty" bson:"idp_initiated_allowed,omitempty"`
+	EmailAttribute      string `json:"email_attribute,omitempty" bson:"email_attribute,omitempty"`
+}
+
+// FederatedIdentityProvider returns the provider type users' federated IDs are stored with.
+func (s SAMLInfo) FederatedIdentityProvider() FederatedIdentityProvider {
+	return FederatedIdentityProvider(strings.ToUpper(s.Key))
+}
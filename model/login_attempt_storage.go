@@ -0,0 +1,43 @@
+package model
+
+import "strings"
+
+// LoginAttemptStorage keeps failed login attempts, so that users and source IPs can be locked out for a while.
+type LoginAttemptStorage interface {
+	// AddFailedLoginAttempt counts one more failed attempt for the key and returns the updated record.
+	// Attempts failed before resetBefore are forgotten, and counting starts over.
+	AddFailedLoginAttempt(key string, resetBefore int64) (LoginAttempts, error)
+	// LoginAttempts returns failed attempts for the key, empty record if there are none.
+	LoginAttempts(key string) (LoginAttempts, error)
+	// ResetLoginAttempts forgets failed attempts for the key.
+	ResetLoginAttempts(key string) error
+	Close()
+}
+
+// LoginAttempts are failed login attempts in a row, for the user or the source IP.
+type LoginAttempts struct {
+	Key          string `json:"key" bson:"_id"`
+	Failures     int    `json:"failures" bson:"failures"`
+	LastFailedAt int64  `json:"last_failed_at,omitempty" bson:"last_failed_at,omitempty"`
+}
+
+// UserLoginAttemptsKey is a key to count failed login attempts of the user.
+func UserLoginAttemptsKey(userID string) string {
+	return "user:" + userID
+}
+
+// NameLoginAttemptsKey is a key to count failed login attempts with the username nobody has.
+// Such attempts are limited the same way as the ones of existing users, so that lockouts do not tell which users exist.
+func NameLoginAttemptsKey(name string) string {
+	return "name:" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// EmailCodeRequestsKey is a key to count login codes sent to the email, so that they are not requested too often.
+func EmailCodeRequestsKey(email string) string {
+	return "email_code:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPLoginAttemptsKey is a key to count failed login attempts from the IP address.
+func IPLoginAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
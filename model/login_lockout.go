@@ -0,0 +1,247 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Default lockout settings.
+const (
+	DefaultLockoutMaxAttempts      = 5
+	DefaultLockoutMaxAttemptsPerIP = 50
+	DefaultLockoutLockDuration     = int64(60)    // one minute
+	DefaultLockoutMaxLockDuration  = int64(3600)  // one hour
+	DefaultLockoutResetAfter       = int64(86400) // one day
+)
+
+// LoginLockout locks users and source IPs out for a while after repeated failed login attempts.
+// Password, phone code and TFA code failures are counted together. Nil lockout locks nobody out.
+type LoginLockout struct {
+	settings       LockoutSettings
+	trustedProxies []*net.IPNet
+	storage        LoginAttemptStorage
+	emailService   EmailService
+}
+
+// LoginLockStatus is the state of the lockout for the user or the source IP.
+type LoginLockStatus struct {
+	LoginAttempts
+	Locked      bool  `json:"locked"`
+	LockedUntil int64 `json:"locked_until,omitempty"`
+}
+
+// NewLoginLockout creates login lockout, unset settings get defaults. Disabled lockout is nil.
+func NewLoginLockout(settings LockoutSettings, storage LoginAttemptStorage, emailService EmailService) (*LoginLockout, error) {
+	if settings.Disabled || storage == nil {
+		return nil, nil
+	}
+
+	trustedProxies := make([]*net.IPNet, 0, len(settings.TrustedProxies))
+	for _, proxy := range settings.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %s: %s", proxy, err)
+		}
+		trustedProxies = append(trustedProxies, ipNet)
+	}
+
+	if settings.MaxAttempts <= 0 {
+		settings.MaxAttempts = DefaultLockoutMaxAttempts
+	}
+	if settings.MaxAttemptsPerIP <= 0 {
+		settings.MaxAttemptsPerIP = DefaultLockoutMaxAttemptsPerIP
+	}
+	if settings.LockDuration <= 0 {
+		settings.LockDuration = DefaultLockoutLockDuration
+	}
+	if settings.MaxLockDuration < settings.LockDuration {
+		settings.MaxLockDuration = DefaultLockoutMaxLockDuration
+		if settings.MaxLockDuration < settings.LockDuration {
+			settings.MaxLockDuration = settings.LockDuration
+		}
+	}
+	if settings.ResetAfter <= 0 {
+		settings.ResetAfter = DefaultLockoutResetAfter
+	}
+	return &LoginLockout{settings: settings, trustedProxies: trustedProxies, storage: storage, emailService: emailService}, nil
+}
+
+// ClientIP returns the address the request comes from, to count failed attempts per source IP.
+// X-Forwarded-For is set by the client, so it is honoured only for requests through trusted proxies,
+// and the address is the last one there which has not been added by them.
+func (ll *LoginLockout) ClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !ll.isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		ip = addr
+		if !ll.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return ip
+}
+
+func (ll *LoginLockout) isTrustedProxy(addr string) bool {
+	if ll == nil {
+		return false
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range ll.trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// LockedFor returns how much longer login is locked for the user or the source IP, zero if it is not.
+// Empty keys are not checked.
+func (ll *LoginLockout) LockedFor(userKey, ipKey string) time.Duration {
+	if ll == nil {
+		return 0
+	}
+
+	var until int64
+	if userKey != "" {
+		until = ll.Status(userKey).LockedUntil
+	}
+	if ipKey != "" {
+		if ipUntil := ll.Status(ipKey).LockedUntil; ipUntil > until {
+			until = ipUntil
+		}
+	}
+
+	left := until - time.Now().Unix()
+	if left <= 0 {
+		return 0
+	}
+	return time.Duration(left) * time.Second
+}
+
+// Fail counts failed login attempt of the user from the source IP. Empty keys are not counted.
+// It returns true when the user has just been locked out, so that they could be notified.
+func (ll *LoginLockout) Fail(userKey, ipKey string) bool {
+	if ll == nil {
+		return false
+	}
+
+	resetBefore := time.Now().Unix() - ll.settings.ResetAfter
+	if ipKey != "" {
+		if _, err := ll.storage.AddFailedLoginAttempt(ipKey, resetBefore); err != nil {
+			log.Println("Cannot count failed login attempt:", err)
+		}
+	}
+	if userKey == "" {
+		return false
+	}
+
+	attempts, err := ll.storage.AddFailedLoginAttempt(userKey, resetBefore)
+	if err != nil {
+		log.Println("Cannot count failed login attempt:", err)
+		return false
+	}
+	return attempts.Failures == ll.settings.MaxAttempts
+}
+
+// Succeed forgets failed login attempts of the user.
+// Attempts from the source IP are kept, so that login to one account does not help to guess passwords of others.
+func (ll *LoginLockout) Succeed(userKey string) {
+	if ll == nil || userKey == "" {
+		return
+	}
+	if err := ll.storage.ResetLoginAttempts(userKey); err != nil {
+		log.Println("Cannot reset failed login attempts:", err)
+	}
+}
+
+// Status returns failed attempts for the key and the lock they have caused.
+func (ll *LoginLockout) Status(key string) LoginLockStatus {
+	status := LoginLockStatus{LoginAttempts: LoginAttempts{Key: key}}
+	if ll == nil {
+		return status
+	}
+
+	attempts, err := ll.storage.LoginAttempts(key)
+	if err != nil {
+		log.Println("Cannot get failed login attempts:", err)
+		return status
+	}
+	status.LoginAttempts = attempts
+
+	maxAttempts := ll.settings.MaxAttempts
+	if strings.HasPrefix(key, IPLoginAttemptsKey("")) {
+		maxAttempts = ll.settings.MaxAttemptsPerIP
+	}
+	if attempts.Failures < maxAttempts {
+		return status
+	}
+
+	// Every failure after the lock doubles it.
+	lock := ll.settings.LockDuration
+	for i := maxAttempts; i < attempts.Failures && lock < ll.settings.MaxLockDuration; i++ {
+		lock *= 2
+	}
+	if lock > ll.settings.MaxLockDuration {
+		lock = ll.settings.MaxLockDuration
+	}
+	status.LockedUntil = attempts.LastFailedAt + lock
+	status.Locked = status.LockedUntil > time.Now().Unix()
+	return status
+}
+
+// Clear removes the lock and failed attempts for the key.
+func (ll *LoginLockout) Clear(key string) error {
+	if ll == nil {
+		return nil
+	}
+	return ll.storage.ResetLoginAttempts(key)
+}
+
+// NotifyLockedOut emails the user that their account has been locked out after failed login attempts.
+// The lock is told for the key the user has been locked out by.
+// Email is sent in the background, so that response time does not tell if the user exists.
+func (ll *LoginLockout) NotifyLockedOut(user User, userKey string) {
+	if ll == nil || ll.emailService == nil || user == nil || user.Email() == "" {
+		return
+	}
+	lockedFor := ll.LockedFor(userKey, "")
+	if lockedFor == 0 {
+		return
+	}
+
+	data := struct {
+		LockDuration time.Duration
+	}{
+		LockDuration: lockedFor,
+	}
+	go func() {
+		if err := ll.emailService.SendAccountLockedEmail("Your account is locked", user.Email(), data); err != nil {
+			log.Println("Cannot send account locked email:", err)
+		}
+	}()
+}
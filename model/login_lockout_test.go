@@ -0,0 +1,45 @@
+package model
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeLoginAttemptStorage struct{ LoginAttemptStorage }
+
+func TestLoginLockoutClientIP(t *testing.T) {
+	ll, err := NewLoginLockout(LockoutSettings{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}}, fakeLoginAttemptStorage{}, nil)
+	if err != nil {
+		t.Fatalf("NewLoginLockout() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct request", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"spoofed header from untrusted client", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:443", "198.51.100.1", "198.51.100.1"},
+		{"spoofed header through trusted proxy", "10.1.2.3:443", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:443", "198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"trusted proxy without header", "192.168.1.1:443", "", "192.168.1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/auth/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ll.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err = NewLoginLockout(LockoutSettings{TrustedProxies: []string{"proxy.local"}}, fakeLoginAttemptStorage{}, nil); err == nil {
+		t.Error("NewLoginLockout() accepts invalid trusted proxy")
+	}
+}
@@ -47,6 +47,8 @@ type DatabaseSettings struct {
 	Endpoint string       `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
 	Region   string       `yaml:"region,omitempty" json:"region,omitempty"`
 	Path     string       `yaml:"path,omitempty" json:"path,omitempty"`
+	// LDAP holds directory settings, for DBTypeLDAP only.
+	LDAP *LDAPSettings `yaml:"ldap,omitempty" json:"ldap,omitempty"`
 }
 
 // DatabaseType is a type of database.
@@ -61,8 +63,40 @@ const (
 	DBTypeDynamoDB DatabaseType = "dynamodb"
 	// DBTypeFake is for in-memory storage.
 	DBTypeFake DatabaseType = "fake"
+	// DBTypeLDAP is for LDAP directory, like Active Directory. Only user storage can be backed with it.
+	DBTypeLDAP DatabaseType = "ldap"
 )
 
+// LDAPSettings are settings of LDAP directory users log in with. Directory address is DatabaseSettings.Endpoint,
+// ldap:// or ldaps:// URL. Plain ldap:// connections must either be upgraded to TLS with StartTLS,
+// or be explicitly allowed to stay unencrypted with AllowUnencrypted, as passwords are sent over them.
+// Users are searched under BaseDN with UserFilter, where {username} is replaced with the login name,
+// by the service account BindDN, or anonymously if it is not set. Then the password is checked by binding as the user.
+// Directory groups from GroupAttribute are mapped to access roles by GroupRoles, the first matching group wins.
+// Directory is used for credentials only, local records of the users, with TFA settings and the like,
+// are kept in ShadowStorage. Users are looked up with UserFilter whenever their local records are, so accounts
+// it excludes, like disabled Active Directory ones, are not found on refresh or passwordless logins either.
+type LDAPSettings struct {
+	BindDN            string           `yaml:"bindDN,omitempty" json:"bind_dn,omitempty"`
+	BindPassword      string           `yaml:"bindPassword,omitempty" json:"bind_password,omitempty"`
+	BaseDN            string           `yaml:"baseDN,omitempty" json:"base_dn,omitempty"`
+	UserFilter        string           `yaml:"userFilter,omitempty" json:"user_filter,omitempty"`
+	UsernameAttribute string           `yaml:"usernameAttribute,omitempty" json:"username_attribute,omitempty"`
+	EmailAttribute    string           `yaml:"emailAttribute,omitempty" json:"email_attribute,omitempty"`
+	GroupAttribute    string           `yaml:"groupAttribute,omitempty" json:"group_attribute,omitempty"`
+	GroupRoles        []LDAPGroupRole  `yaml:"groupRoles,omitempty" json:"group_roles,omitempty"`
+	DefaultRole       string           `yaml:"defaultRole,omitempty" json:"default_role,omitempty"`
+	StartTLS          bool             `yaml:"startTLS,omitempty" json:"start_tls,omitempty"`
+	AllowUnencrypted  bool             `yaml:"allowUnencrypted,omitempty" json:"allow_unencrypted,omitempty"`
+	ShadowStorage     DatabaseSettings `yaml:"shadowStorage,omitempty" json:"shadow_storage,omitempty"`
+}
+
+// LDAPGroupRole maps directory group to access role. Group is either group DN or its common name.
+type LDAPGroupRole struct {
+	Group string `yaml:"group" json:"group"`
+	Role  string `yaml:"role" json:"role"`
+}
+
 // StaticFilesStorageSettings are settings for static files storage.
 type StaticFilesStorageSettings struct {
 	Type             StaticFilesStorageType `yaml:"type,omitempty" json:"type,omitempty"`
@@ -203,8 +237,25 @@ const (
 
 // LoginSettings are settings of login.
 type LoginSettings struct {
-	LoginWith LoginWith `yaml:"loginWith,omitempty" json:"login_with,omitempty"`
-	TFAType   TFAType   `yaml:"tfaType,omitempty" json:"tfa_type,omitempty"`
+	LoginWith LoginWith       `yaml:"loginWith,omitempty" json:"login_with,omitempty"`
+	TFAType   TFAType         `yaml:"tfaType,omitempty" json:"tfa_type,omitempty"`
+	Lockout   LockoutSettings `yaml:"lockout,omitempty" json:"lockout,omitempty"`
+}
+
+// LockoutSettings are settings of temporary lockout after repeated failed login attempts.
+// The user is locked out after MaxAttempts failures in a row, and the source IP after MaxAttemptsPerIP,
+// for LockDuration seconds. Each failure after that doubles the lock, up to MaxLockDuration.
+// Failures are forgotten after successful login, or ResetAfter seconds after the last one.
+// X-Forwarded-For is honoured only for requests from TrustedProxies, which are IP addresses or CIDR ranges.
+// Zero values mean defaults.
+type LockoutSettings struct {
+	Disabled         bool     `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	MaxAttempts      int      `yaml:"maxAttempts,omitempty" json:"max_attempts,omitempty"`
+	MaxAttemptsPerIP int      `yaml:"maxAttemptsPerIP,omitempty" json:"max_attempts_per_ip,omitempty"`
+	LockDuration     int64    `yaml:"lockDuration,omitempty" json:"lock_duration,omitempty"`
+	MaxLockDuration  int64    `yaml:"maxLockDuration,omitempty" json:"max_lock_duration,omitempty"`
+	ResetAfter       int64    `yaml:"resetAfter,omitempty" json:"reset_after,omitempty"`
+	TrustedProxies   []string `yaml:"trustedProxies,omitempty" json:"trusted_proxies,omitempty"`
 }
 
 // LoginWith is a type for configuring supported login ways.
@@ -212,6 +263,9 @@ type LoginWith struct {
 	Username  bool `yaml:"username" json:"username,omitempty"`
 	Phone     bool `yaml:"phone" json:"phone,omitempty"`
 	Federated bool `yaml:"federated" json:"federated,omitempty"`
+	MagicLink bool `yaml:"magicLink" json:"magic_link,omitempty"`
+	EmailCode bool `yaml:"emailCode" json:"email_code,omitempty"`
+	Webauthn  bool `yaml:"webauthn" json:"webauthn,omitempty"`
 }
 
 // TFAType is a type of two-factor authentication for apps that support it.
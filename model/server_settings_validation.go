@@ -66,6 +66,11 @@ func (ss *StorageSettings) Validate() error {
 	if err := ss.UserStorage.Validate(); err != nil {
 		return fmt.Errorf("UserStorage: %s", err)
 	}
+	for _, dbType := range []DatabaseType{ss.AppStorage.Type, ss.TokenStorage.Type, ss.TokenBlacklist.Type, ss.VerificationCodeStorage.Type} {
+		if dbType == DBTypeLDAP {
+			return fmt.Errorf("Only UserStorage can be of type %s", DBTypeLDAP)
+		}
+	}
 	if err := ss.TokenStorage.Validate(); err != nil {
 		return fmt.Errorf("TokenStorage: %s", err)
 	}
@@ -106,6 +111,22 @@ func (dbs *DatabaseSettings) Validate() error {
 		if len(dbs.Name) == 0 {
 			return fmt.Errorf("Empty database name")
 		}
+	case DBTypeLDAP:
+		if _, err := url.ParseRequestURI(dbs.Endpoint); err != nil {
+			return fmt.Errorf("Invalid endpoint. %s", err)
+		}
+		if dbs.LDAP == nil {
+			return fmt.Errorf("Empty LDAP settings")
+		}
+		if len(dbs.LDAP.BaseDN) == 0 {
+			return fmt.Errorf("Empty LDAP base DN")
+		}
+		if dbs.LDAP.ShadowStorage.Type == DBTypeLDAP {
+			return fmt.Errorf("LDAP shadow storage can't be of type %s", DBTypeLDAP)
+		}
+		if err := dbs.LDAP.ShadowStorage.Validate(); err != nil {
+			return fmt.Errorf("LDAP shadow storage: %s", err)
+		}
 	default:
 		return fmt.Errorf("%s. Unknown type", subject)
 	}
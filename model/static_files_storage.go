@@ -45,46 +45,58 @@ const (
 
 // StaticPagesNames are the names of html pages.
 var StaticPagesNames = StaticPages{
-	DisableTFA:            "disable-tfa.html",
-	DisableTFASuccess:     "disable-tfa-success.html",
-	ForgotPassword:        "forgot-password.html",
-	ForgotPasswordSuccess: "forgot-password-success.html",
-	InviteEmail:           "invite-email.html",
-	Login:                 "login.html",
-	Misconfiguration:      "misconfiguration.html",
-	Registration:          "registration.html",
-	ResetPassword:         "reset-password.html",
-	ResetPasswordEmail:    "reset-password-email.html",
-	ResetPasswordSuccess:  "reset-password-success.html",
-	ResetTFA:              "reset-tfa.html",
-	ResetTFASuccess:       "reset-tfa-success.html",
-	TFAEmail:              "tfa-email.html",
-	TokenError:            "token-error.html",
-	VerifyEmail:           "verify-email.html",
-	WebMessage:            "web-message.html",
-	WelcomeEmail:          "welcome-email.html",
+	AccountLockedEmail:      "account-locked-email.html",
+	Device:                  "device.html",
+	DisableTFA:              "disable-tfa.html",
+	DisableTFASuccess:       "disable-tfa-success.html",
+	EmailVerificationResult: "verification-result.html",
+	ForgotPassword:          "forgot-password.html",
+	ForgotPasswordSuccess:   "forgot-password-success.html",
+	InviteEmail:             "invite-email.html",
+	Login:                   "login.html",
+	Logout:                  "logout.html",
+	MagicLink:               "magic-link.html",
+	MagicLinkEmail:          "magic-link-email.html",
+	Misconfiguration:        "misconfiguration.html",
+	Registration:            "registration.html",
+	ResetPassword:           "reset-password.html",
+	ResetPasswordEmail:      "reset-password-email.html",
+	ResetPasswordSuccess:    "reset-password-success.html",
+	ResetTFA:                "reset-tfa.html",
+	ResetTFASuccess:         "reset-tfa-success.html",
+	TFAEmail:                "tfa-email.html",
+	TokenError:              "token-error.html",
+	VerifyEmail:             "verify-email.html",
+	WebMessage:              "web-message.html",
+	WelcomeEmail:            "welcome-email.html",
 }
 
 // StaticPages holds together all paths to static pages.
 type StaticPages struct {
-	DisableTFA            string
-	DisableTFASuccess     string
-	ForgotPassword        string
-	ForgotPasswordSuccess string
-	InviteEmail           string
-	Login                 string
-	Misconfiguration      string
-	Registration          string
-	ResetPassword         string
-	ResetPasswordEmail    string
-	ResetPasswordSuccess  string
-	ResetTFA              string
-	ResetTFASuccess       string
-	TFAEmail              string
-	TokenError            string
-	VerifyEmail           string
-	WebMessage            string
-	WelcomeEmail          string
+	AccountLockedEmail      string
+	Device                  string
+	DisableTFA              string
+	DisableTFASuccess       string
+	EmailVerificationResult string
+	ForgotPassword          string
+	ForgotPasswordSuccess   string
+	InviteEmail             string
+	Login                   string
+	Logout                  string
+	MagicLink               string
+	MagicLinkEmail          string
+	Misconfiguration        string
+	Registration            string
+	ResetPassword           string
+	ResetPasswordEmail      string
+	ResetPasswordSuccess    string
+	ResetTFA                string
+	ResetTFASuccess         string
+	TFAEmail                string
+	TokenError              string
+	VerifyEmail             string
+	WebMessage              string
+	WelcomeEmail            string
 }
 
 // AppleFiles holds together static files needed for supporting Apple services.
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const (
+	// RecoveryCodesCount is a number of recovery codes issued at once.
+	RecoveryCodesCount = 10
+	// recoveryCodeLength is a length of the recovery code without separators, 80 random bits in base32.
+	recoveryCodeLength = 16
+	// recoveryCodeGroupLength is a length of the dash-separated groups the code is shown in.
+	recoveryCodeGroupLength = 4
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes generates a fresh set of recovery codes.
+// Codes are returned to be shown to the user, and their hashes are saved in TFAInfo.
+func (tfa *TFAInfo) NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodesCount)
+	hashes := make([]string, RecoveryCodesCount)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)
+		groups := make([]string, 0, recoveryCodeLength/recoveryCodeGroupLength)
+		for j := 0; j < len(code); j += recoveryCodeGroupLength {
+			groups = append(groups, code[j:j+recoveryCodeGroupLength])
+		}
+		codes[i] = strings.Join(groups, "-")
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	tfa.RecoveryCodes = hashes
+	tfa.RecoveryCodesIssued = true
+	return codes, nil
+}
+
+// RecoveryCodeHash returns the hash the recovery code is kept under, false if the code is malformed.
+// Codes are accepted regardless of case and separators.
+func RecoveryCodeHash(code string) (string, bool) {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != recoveryCodeLength {
+		return "", false
+	}
+	return hashRecoveryCode(code), true
+}
+
+// HasRecoveryCode tells if the recovery code with the hash has not been used yet.
+// Codes are consumed by the user storage, so that each one is accepted only once even by concurrent requests.
+func (tfa *TFAInfo) HasRecoveryCode(hash string) bool {
+	for _, h := range tfa.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTrustedDevice creates the new device for the app, so the second factor is skipped on it until maxAge seconds pass.
+// The device is saved with UserStorage.AddTrustedDevice.
+func NewTrustedDevice(appID, name string, maxAge int64) (TrustedDevice, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return TrustedDevice{}, err
+	}
+	now := time.Now().Unix()
+
+	return TrustedDevice{
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		AppID:     appID,
+		Name:      name,
+		CreatedAt: now,
+		ExpiresAt: now + maxAge,
+	}, nil
+}
+
+// AddTrustedDevice remembers the device. Expired devices are forgotten along the way.
+func (tfa *TFAInfo) AddTrustedDevice(device TrustedDevice) {
+	now := time.Now().Unix()
+
+	devices := make([]TrustedDevice, 0, len(tfa.TrustedDevices)+1)
+	for _, d := range tfa.TrustedDevices {
+		if d.ExpiresAt > now {
+			devices = append(devices, d)
+		}
+	}
+	tfa.TrustedDevices = append(devices, device)
+}
+
+// IsDeviceTrusted tells if the device is trusted for the app, and is remembered no longer than maxAge seconds ago.
+// Max age is checked on every use, so lowering it applies to the devices remembered before.
+func (tfa TFAInfo) IsDeviceTrusted(id, appID string, maxAge int64) bool {
+	now := time.Now().Unix()
+	for _, d := range tfa.TrustedDevices {
+		if d.ID == id {
+			return d.AppID == appID && d.ExpiresAt > now && now-d.CreatedAt <= maxAge
+		}
+	}
+	return false
+}
+
+// RevokeTrustedDevice forgets the device, so the second factor is asked on it again.
+// Returns false if there is no such device.
+func (tfa *TFAInfo) RevokeTrustedDevice(id string) bool {
+	for i, d := range tfa.TrustedDevices {
+		if d.ID == id {
+			tfa.TrustedDevices = append(tfa.TrustedDevices[:i:i], tfa.TrustedDevices[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ForgetTrustedDevices makes the second factor required again on all devices of the user, e.g. on logout from everywhere.
+func ForgetTrustedDevices(us UserStorage, userID string) error {
+	user, err := us.UserByID(userID)
+	if err != nil {
+		return err
+	}
+	tfaInfo := user.TFAInfo()
+	if len(tfaInfo.TrustedDevices) == 0 {
+		return nil
+	}
+	tfaInfo.TrustedDevices = nil
+	user.SetTFAInfo(tfaInfo)
+	_, err = us.UpdateUser(userID, user)
+	return err
+}
+
+// Sanitize removes secrets, leaving only the number of recovery codes left.
+func (tfa *TFAInfo) Sanitize() {
+	tfa.Secret = ""
+	tfa.RecoveryCodesLeft = len(tfa.RecoveryCodes)
+	tfa.RecoveryCodes = nil
+}
+
+// Recovery codes carry 80 random bits, which cannot be brute-forced even with a fast hash,
+// so the hash keeps them from leaking along with the user record.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	tfa := TFAInfo{}
+	codes, err := tfa.NewRecoveryCodes()
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != RecoveryCodesCount || len(tfa.RecoveryCodes) != RecoveryCodesCount || !tfa.RecoveryCodesIssued {
+		t.Fatalf("NewRecoveryCodes() = %d codes, %d hashes, issued %v", len(codes), len(tfa.RecoveryCodes), tfa.RecoveryCodesIssued)
+	}
+
+	// 16 base32 characters carry 80 random bits.
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("Recovery code %q does not match %v", code, format)
+		}
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"as shown", codes[0], true},
+		{"upper case without separators", strings.ToUpper(strings.Replace(codes[1], "-", "", -1)), true},
+		{"with spaces", strings.Replace(codes[2], "-", " ", -1), true},
+		{"too short", codes[3][:len(codes[3])-1], false},
+		{"another code", "aaaa-aaaa-aaaa-aaaa", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, ok := RecoveryCodeHash(tt.code)
+			if got := ok && tfa.HasRecoveryCode(hash); got != tt.want {
+				t.Errorf("Recovery code %q accepted = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,98 @@
 package model
 
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
 // TokenStorage is a storage for issued refresh tokens.
+// Every token is saved along with the session it belongs to, so users can see where they are logged in.
 type TokenStorage interface {
-	SaveToken(token string) error
+	SaveToken(token string, session UserSession) error
 	HasToken(token string) bool
 	DeleteToken(token string) error
+	// TokenSession returns the session of the token, ErrorNotFound if there is no such token.
+	TokenSession(token string) (UserSession, error)
+	// TouchToken records that the token has just been used by the client.
+	TouchToken(token string, client ClientInfo) error
+	// UserSessions returns unexpired sessions of the user.
+	UserSessions(userID string) ([]UserSession, error)
+	// DeleteUserSession removes all tokens of the user's session and returns them, so they could be blacklisted.
+	// ErrorNotFound is returned if the user has no such session.
+	DeleteUserSession(userID, sessionID string) ([]string, error)
+	// RevokeUserTokens removes all refresh tokens of the user, and revokes all tokens issued to the user before now.
+	RevokeUserTokens(userID string) error
+	// UserTokensRevokedAt returns the time tokens issued to the user before are revoked, zero if they never were.
+	UserTokensRevokedAt(userID string) int64
 	Close()
 }
 
+// TokenRevokedForUser tells if the token has been issued before the user's tokens were revoked.
+// Tokens issued within the same second as revocation are still valid, so the user can log in again right away.
+func TokenRevokedForUser(ts TokenStorage, userID string, issuedAt int64) bool {
+	revokedAt := ts.UserTokensRevokedAt(userID)
+	return revokedAt > 0 && issuedAt < revokedAt
+}
+
+// UserSession is where and when the user has logged in, it lasts as long as the refresh token does.
+// Session ID is the refresh token family, so the session survives refresh token rotation.
+type UserSession struct {
+	ID         string `bson:"session_id" json:"id"`
+	UserID     string `bson:"user_id" json:"user_id"`
+	AppID      string `bson:"app_id" json:"app_id"`
+	DeviceName string `bson:"device_name,omitempty" json:"device_name,omitempty"`
+	IP         string `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent  string `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	CreatedAt  int64  `bson:"created_at" json:"created_at"`
+	LastUsedAt int64  `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt  int64  `bson:"expires_at" json:"expires_at"`
+}
+
+// Expired tells if the session refresh token has expired.
+func (s UserSession) Expired() bool {
+	return s.ExpiresAt > 0 && time.Now().Unix() >= s.ExpiresAt
+}
+
+// Touch records the client has just used the session.
+func (s *UserSession) Touch(client ClientInfo) {
+	if len(client.DeviceName) > 0 {
+		s.DeviceName = client.DeviceName
+	}
+	s.IP = client.IP
+	s.UserAgent = client.UserAgent
+	s.LastUsedAt = time.Now().Unix()
+}
+
+// DeviceNameHeaderKey is a header the client names its device with.
+const DeviceNameHeaderKey = "X-Identifo-Device-Name"
+
+// ClientInfo describes the device the request comes from.
+// It is only shown to the user, so nothing here should be trusted.
+type ClientInfo struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// ClientInfoFromRequest collects client info from the request headers.
+func ClientInfoFromRequest(r *http.Request) ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	// The first address is the client one, if the server is behind proxy.
+	if forwarded := r.Header.Get("X-Forwarded-For"); len(forwarded) > 0 {
+		ip = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return ClientInfo{
+		DeviceName: r.Header.Get(DeviceNameHeaderKey),
+		IP:         ip,
+		UserAgent:  r.UserAgent(),
+	}
+}
+
 // TokenBlacklist is a storage for blacklisted tokens.
 type TokenBlacklist interface {
 	IsBlacklisted(token string) bool
@@ -21,6 +21,7 @@ type UserStorage interface {
 	AddUserByPhone(phone, role string) (User, error)
 	UserByID(id string) (User, error)
 	UserByEmail(email string) (User, error)
+	AddUserByEmail(email, role string) (User, error)
 	IDByName(name string) (string, error)
 	AttachDeviceToken(id, token string) error
 	DetachDeviceToken(token string) error
@@ -29,7 +30,15 @@ type UserStorage interface {
 	UserExists(name string) bool
 	UserByFederatedID(provider FederatedIdentityProvider, id string) (User, error)
 	AddUserWithFederatedID(provider FederatedIdentityProvider, id, role string) (User, error)
+	LinkFederatedID(userID string, provider FederatedIdentityProvider, id string) error
+	UnlinkFederatedID(userID string, provider FederatedIdentityProvider, id string) error
 	UpdateUser(userID string, newUser User) (User, error)
+	// ConsumeRecoveryCode removes TFA recovery code hash from the user, if it is still there, or returns ErrorNotFound.
+	ConsumeRecoveryCode(userID, codeHash string) error
+	// SetRecoveryCodes replaces TFA recovery code hashes of the user, and marks recovery codes as issued.
+	SetRecoveryCodes(userID string, codeHashes []string) error
+	// AddTrustedDevice remembers the device in TFA info of the user, and forgets expired devices.
+	AddTrustedDevice(userID string, device TrustedDevice) error
 	ResetPassword(id, password string) error
 	DeleteUser(id string) error
 	FetchUsers(search string, skip, limit int) ([]User, int, error)
@@ -50,13 +59,20 @@ type User interface {
 	SetUsername(string)
 	Email() string
 	SetEmail(string)
+	EmailVerified() bool
+	SetEmailVerified(bool)
 	Phone() string
 	TFAInfo() TFAInfo
 	SetTFAInfo(TFAInfo)
+	WebauthnCredentials() []WebauthnCredential
+	SetWebauthnCredentials([]WebauthnCredential)
+	FederatedIdentities() []FederatedIdentity
 	PasswordHash() string
 	Active() bool
 	AccessRole() string
+	SetAccessRole(string)
 	Sanitize()
+	IsAnonymous() bool
 	Deanonimize()
 }
 
@@ -64,4 +80,33 @@ type User interface {
 type TFAInfo struct {
 	IsEnabled bool   `bson:"is_enabled" json:"is_enabled"`
 	Secret    string `bson:"secret" json:"-"`
+	// RecoveryCodes are hashes of the one-time recovery codes not used yet.
+	RecoveryCodes []string `bson:"recovery_codes,omitempty" json:"recovery_codes,omitempty"`
+	// RecoveryCodesIssued is set when the enrollment is completed and recovery codes are given to the user.
+	RecoveryCodesIssued bool `bson:"recovery_codes_issued,omitempty" json:"recovery_codes_issued,omitempty"`
+	// RecoveryCodesLeft is filled by Sanitize in place of the code hashes.
+	RecoveryCodesLeft int `bson:"-" json:"recovery_codes_left"`
+	// TrustedDevices are devices the second factor is not asked on.
+	TrustedDevices []TrustedDevice `bson:"trusted_devices,omitempty" json:"trusted_devices,omitempty"`
+}
+
+// TrustedDevice is a device the user has chosen to be remembered on, to skip the second factor for the app.
+type TrustedDevice struct {
+	ID        string `bson:"id" json:"id"`
+	AppID     string `bson:"app_id" json:"app_id"`
+	Name      string `bson:"name,omitempty" json:"name,omitempty"`
+	CreatedAt int64  `bson:"created_at" json:"created_at"`
+	ExpiresAt int64  `bson:"expires_at" json:"expires_at"`
+}
+
+// WebauthnCredential is a WebAuthn public key credential (passkey) registered by the user.
+type WebauthnCredential struct {
+	// ID is a base64url encoded credential ID.
+	ID string `bson:"id" json:"id"`
+	// PublicKey is a COSE encoded credential public key.
+	PublicKey  []byte `bson:"public_key" json:"public_key"`
+	SignCount  uint32 `bson:"sign_count" json:"sign_count"`
+	Name       string `bson:"name,omitempty" json:"name,omitempty"`
+	CreatedAt  int64  `bson:"created_at" json:"created_at"`
+	LastUsedAt int64  `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
 }
@@ -1,8 +1,8 @@
 package model
 
-// VerificationCodeStorage stores verification codes linked to the phone number.
+// VerificationCodeStorage stores verification codes linked to the identifier, e.g. phone number or email.
 type VerificationCodeStorage interface {
-	IsVerificationCodeFound(phone, code string) (bool, error)
-	CreateVerificationCode(phone, code string) error
+	IsVerificationCodeFound(identifier, code string) (bool, error)
+	CreateVerificationCode(identifier, code string) error
 	Close()
 }
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// WebauthnChallengeLifespan is a lifespan of the WebAuthn ceremony challenge.
+// It leaves some time beyond the timeout the browser is given for the ceremony.
+const WebauthnChallengeLifespan = 5 * time.Minute
+
+// WebauthnChallengeStorage stores short-lived challenges of WebAuthn ceremonies.
+type WebauthnChallengeStorage interface {
+	SaveWebauthnChallenge(challenge WebauthnChallenge) error
+	// ConsumeWebauthnChallenge returns the challenge and removes it from the storage, so each challenge can be answered only once.
+	ConsumeWebauthnChallenge(challenge string) (WebauthnChallenge, error)
+	Close()
+}
+
+// WebauthnCeremony is a kind of WebAuthn ceremony the challenge is issued for.
+type WebauthnCeremony string
+
+const (
+	// WebauthnCeremonyRegistration is when the logged in user adds new passkey.
+	WebauthnCeremonyRegistration WebauthnCeremony = "registration"
+	// WebauthnCeremonyLogin is when the user logs in with the passkey.
+	WebauthnCeremonyLogin WebauthnCeremony = "login"
+)
+
+// WebauthnChallenge is a challenge issued for WebAuthn ceremony.
+type WebauthnChallenge struct {
+	Challenge string           `json:"challenge" bson:"challenge"`
+	Ceremony  WebauthnCeremony `json:"ceremony" bson:"ceremony"`
+	// UserID is the user the registration challenge is issued to.
+	UserID    string `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	ExpiresAt int64  `json:"expires_at" bson:"expires_at"`
+}
+
+// NewWebauthnChallenge makes a challenge record which expires after WebauthnChallengeLifespan.
+func NewWebauthnChallenge(challenge string, ceremony WebauthnCeremony, userID string) WebauthnChallenge {
+	return WebauthnChallenge{
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(WebauthnChallengeLifespan).Unix(),
+	}
+}
+
+// Expired checks whether the challenge has expired.
+func (wc WebauthnChallenge) Expired() bool {
+	return time.Now().Unix() > wc.ExpiresAt
+}
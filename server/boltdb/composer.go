@@ -9,24 +9,32 @@ import (
 // NewComposer creates new database composer with BoltDB support.
 func NewComposer(settings model.ServerSettings) (*DatabaseComposer, error) {
 	c := DatabaseComposer{
-		settings:                   settings,
-		newAppStorage:              boltdb.NewAppStorage,
-		newUserStorage:             boltdb.NewUserStorage,
-		newTokenStorage:            boltdb.NewTokenStorage,
-		newTokenBlacklist:          boltdb.NewTokenBlacklist,
-		newVerificationCodeStorage: boltdb.NewVerificationCodeStorage,
+		settings:                    settings,
+		newAppStorage:               boltdb.NewAppStorage,
+		newUserStorage:              boltdb.NewUserStorage,
+		newTokenStorage:             boltdb.NewTokenStorage,
+		newTokenBlacklist:           boltdb.NewTokenBlacklist,
+		newVerificationCodeStorage:  boltdb.NewVerificationCodeStorage,
+		newAuthorizationCodeStorage: boltdb.NewAuthorizationCodeStorage,
+		newDeviceCodeStorage:        boltdb.NewDeviceCodeStorage,
+		newWebauthnChallengeStorage: boltdb.NewWebauthnChallengeStorage,
+		newLoginAttemptStorage:      boltdb.NewLoginAttemptStorage,
 	}
 	return &c, nil
 }
 
 // DatabaseComposer composes BoltDB services.
 type DatabaseComposer struct {
-	settings                   model.ServerSettings
-	newAppStorage              func(*bolt.DB) (model.AppStorage, error)
-	newUserStorage             func(*bolt.DB) (model.UserStorage, error)
-	newTokenStorage            func(*bolt.DB) (model.TokenStorage, error)
-	newTokenBlacklist          func(*bolt.DB) (model.TokenBlacklist, error)
-	newVerificationCodeStorage func(*bolt.DB) (model.VerificationCodeStorage, error)
+	settings                    model.ServerSettings
+	newAppStorage               func(*bolt.DB) (model.AppStorage, error)
+	newUserStorage              func(*bolt.DB) (model.UserStorage, error)
+	newTokenStorage             func(*bolt.DB) (model.TokenStorage, error)
+	newTokenBlacklist           func(*bolt.DB) (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func(*bolt.DB) (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func(*bolt.DB) (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func(*bolt.DB) (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func(*bolt.DB) (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func(*bolt.DB) (model.LoginAttemptStorage, error)
 }
 
 // Compose composes all services with BoltDB support.
@@ -36,40 +44,64 @@ func (dc *DatabaseComposer) Compose() (
 	model.TokenStorage,
 	model.TokenBlacklist,
 	model.VerificationCodeStorage,
+	model.AuthorizationCodeStorage,
+	model.DeviceCodeStorage,
+	model.WebauthnChallengeStorage,
+	model.LoginAttemptStorage,
 	error,
 ) {
 	// We assume that all BoltDB-backed storages share the same filepath, so we can pick any of them.
 	db, err := boltdb.InitDB(dc.settings.Storage.AppStorage.Path)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	appStorage, err := dc.newAppStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	userStorage, err := dc.newUserStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenStorage, err := dc.newTokenStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenBlacklist, err := dc.newTokenBlacklist(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	verificationCodeStorage, err := dc.newVerificationCodeStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
-	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, nil
+	authorizationCodeStorage, err := dc.newAuthorizationCodeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	deviceCodeStorage, err := dc.newDeviceCodeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	webauthnChallengeStorage, err := dc.newWebauthnChallengeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	loginAttemptStorage, err := dc.newLoginAttemptStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, authorizationCodeStorage, deviceCodeStorage, webauthnChallengeStorage, loginAttemptStorage, nil
 }
 
 // NewPartialComposer returns new partial composer with BoltDB support.
@@ -90,6 +122,10 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 	if settings.TokenStorage.Type == model.DBTypeBoltDB {
 		pc.newTokenStorage = boltdb.NewTokenStorage
+		pc.newAuthorizationCodeStorage = boltdb.NewAuthorizationCodeStorage
+		pc.newDeviceCodeStorage = boltdb.NewDeviceCodeStorage
+		pc.newWebauthnChallengeStorage = boltdb.NewWebauthnChallengeStorage
+		pc.newLoginAttemptStorage = boltdb.NewLoginAttemptStorage
 		dbPath = settings.TokenStorage.Path
 	}
 
@@ -119,12 +155,16 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 // PartialDatabaseComposer composes only BoltDB-supporting services.
 type PartialDatabaseComposer struct {
-	db                         *bolt.DB
-	newAppStorage              func(*bolt.DB) (model.AppStorage, error)
-	newUserStorage             func(*bolt.DB) (model.UserStorage, error)
-	newTokenStorage            func(*bolt.DB) (model.TokenStorage, error)
-	newTokenBlacklist          func(*bolt.DB) (model.TokenBlacklist, error)
-	newVerificationCodeStorage func(*bolt.DB) (model.VerificationCodeStorage, error)
+	db                          *bolt.DB
+	newAppStorage               func(*bolt.DB) (model.AppStorage, error)
+	newUserStorage              func(*bolt.DB) (model.UserStorage, error)
+	newTokenStorage             func(*bolt.DB) (model.TokenStorage, error)
+	newTokenBlacklist           func(*bolt.DB) (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func(*bolt.DB) (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func(*bolt.DB) (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func(*bolt.DB) (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func(*bolt.DB) (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func(*bolt.DB) (model.LoginAttemptStorage, error)
 }
 
 // AppStorageComposer returns app storage composer.
@@ -176,3 +216,43 @@ func (pc *PartialDatabaseComposer) VerificationCodeStorageComposer() func() (mod
 	}
 	return nil
 }
+
+// AuthorizationCodeStorageComposer returns authorization code storage composer.
+func (pc *PartialDatabaseComposer) AuthorizationCodeStorageComposer() func() (model.AuthorizationCodeStorage, error) {
+	if pc.newAuthorizationCodeStorage != nil {
+		return func() (model.AuthorizationCodeStorage, error) {
+			return pc.newAuthorizationCodeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// DeviceCodeStorageComposer returns device code storage composer.
+func (pc *PartialDatabaseComposer) DeviceCodeStorageComposer() func() (model.DeviceCodeStorage, error) {
+	if pc.newDeviceCodeStorage != nil {
+		return func() (model.DeviceCodeStorage, error) {
+			return pc.newDeviceCodeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// WebauthnChallengeStorageComposer returns WebAuthn challenge storage composer.
+func (pc *PartialDatabaseComposer) WebauthnChallengeStorageComposer() func() (model.WebauthnChallengeStorage, error) {
+	if pc.newWebauthnChallengeStorage != nil {
+		return func() (model.WebauthnChallengeStorage, error) {
+			return pc.newWebauthnChallengeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// LoginAttemptStorageComposer returns login attempt storage composer.
+func (pc *PartialDatabaseComposer) LoginAttemptStorageComposer() func() (model.LoginAttemptStorage, error) {
+	if pc.newLoginAttemptStorage != nil {
+		return func() (model.LoginAttemptStorage, error) {
+			return pc.newLoginAttemptStorage(pc.db)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"fmt"
+
 	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/storage/ldap"
 )
 
 // DatabaseComposer inits database stack.
@@ -12,6 +15,10 @@ type DatabaseComposer interface {
 		model.TokenStorage,
 		model.TokenBlacklist,
 		model.VerificationCodeStorage,
+		model.AuthorizationCodeStorage,
+		model.DeviceCodeStorage,
+		model.WebauthnChallengeStorage,
+		model.LoginAttemptStorage,
 		error,
 	)
 }
@@ -23,16 +30,24 @@ type PartialDatabaseComposer interface {
 	TokenStorageComposer() func() (model.TokenStorage, error)
 	TokenBlacklistComposer() func() (model.TokenBlacklist, error)
 	VerificationCodeStorageComposer() func() (model.VerificationCodeStorage, error)
+	AuthorizationCodeStorageComposer() func() (model.AuthorizationCodeStorage, error)
+	DeviceCodeStorageComposer() func() (model.DeviceCodeStorage, error)
+	WebauthnChallengeStorageComposer() func() (model.WebauthnChallengeStorage, error)
+	LoginAttemptStorageComposer() func() (model.LoginAttemptStorage, error)
 }
 
 // Composer is a service composer which is agnostic to particular database implementations.
 type Composer struct {
-	settings                   model.ServerSettings
-	newAppStorage              func() (model.AppStorage, error)
-	newUserStorage             func() (model.UserStorage, error)
-	newTokenStorage            func() (model.TokenStorage, error)
-	newTokenBlacklist          func() (model.TokenBlacklist, error)
-	newVerificationCodeStorage func() (model.VerificationCodeStorage, error)
+	settings                    model.ServerSettings
+	newAppStorage               func() (model.AppStorage, error)
+	newUserStorage              func() (model.UserStorage, error)
+	newTokenStorage             func() (model.TokenStorage, error)
+	newTokenBlacklist           func() (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func() (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func() (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func() (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func() (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func() (model.LoginAttemptStorage, error)
 }
 
 // Compose composes all services.
@@ -42,34 +57,58 @@ func (c *Composer) Compose() (
 	model.TokenStorage,
 	model.TokenBlacklist,
 	model.VerificationCodeStorage,
+	model.AuthorizationCodeStorage,
+	model.DeviceCodeStorage,
+	model.WebauthnChallengeStorage,
+	model.LoginAttemptStorage,
 	error,
 ) {
 	appStorage, err := c.newAppStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	userStorage, err := c.newUserStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenStorage, err := c.newTokenStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenBlacklist, err := c.newTokenBlacklist()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	verificationCodeStorage, err := c.newVerificationCodeStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	authorizationCodeStorage, err := c.newAuthorizationCodeStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	deviceCodeStorage, err := c.newDeviceCodeStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	webauthnChallengeStorage, err := c.newWebauthnChallengeStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	loginAttemptStorage, err := c.newLoginAttemptStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
-	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, nil
+	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, authorizationCodeStorage, deviceCodeStorage, webauthnChallengeStorage, loginAttemptStorage, nil
 }
 
 // NewComposer returns new database composer based on passed server settings.
@@ -92,6 +131,18 @@ func NewComposer(settings model.ServerSettings, partialComposers []PartialDataba
 		if pc.VerificationCodeStorageComposer() != nil {
 			c.newVerificationCodeStorage = pc.VerificationCodeStorageComposer()
 		}
+		if pc.AuthorizationCodeStorageComposer() != nil {
+			c.newAuthorizationCodeStorage = pc.AuthorizationCodeStorageComposer()
+		}
+		if pc.DeviceCodeStorageComposer() != nil {
+			c.newDeviceCodeStorage = pc.DeviceCodeStorageComposer()
+		}
+		if pc.WebauthnChallengeStorageComposer() != nil {
+			c.newWebauthnChallengeStorage = pc.WebauthnChallengeStorageComposer()
+		}
+		if pc.LoginAttemptStorageComposer() != nil {
+			c.newLoginAttemptStorage = pc.LoginAttemptStorageComposer()
+		}
 	}
 
 	for _, option := range options {
@@ -101,3 +152,22 @@ func NewComposer(settings model.ServerSettings, partialComposers []PartialDataba
 	}
 	return c, nil
 }
+
+// LDAPUserStorage is an option that backs user storage with LDAP directory.
+// User storage composed by the partial composers becomes the shadow storage, it must be of LDAP shadow storage type.
+func LDAPUserStorage(settings model.DatabaseSettings) func(*Composer) error {
+	return func(c *Composer) error {
+		newShadowStorage := c.newUserStorage
+		if newShadowStorage == nil {
+			return fmt.Errorf("No shadow storage for LDAP user storage")
+		}
+		c.newUserStorage = func() (model.UserStorage, error) {
+			shadow, err := newShadowStorage()
+			if err != nil {
+				return nil, err
+			}
+			return ldap.NewUserStorage(settings, shadow)
+		}
+		return nil
+	}
+}
@@ -8,24 +8,32 @@ import (
 // NewComposer creates new database composer.
 func NewComposer(settings model.ServerSettings) (*DatabaseComposer, error) {
 	c := DatabaseComposer{
-		settings:                   settings,
-		newAppStorage:              dynamodb.NewAppStorage,
-		newUserStorage:             dynamodb.NewUserStorage,
-		newTokenStorage:            dynamodb.NewTokenStorage,
-		newTokenBlacklist:          dynamodb.NewTokenBlacklist,
-		newVerificationCodeStorage: dynamodb.NewVerificationCodeStorage,
+		settings:                    settings,
+		newAppStorage:               dynamodb.NewAppStorage,
+		newUserStorage:              dynamodb.NewUserStorage,
+		newTokenStorage:             dynamodb.NewTokenStorage,
+		newTokenBlacklist:           dynamodb.NewTokenBlacklist,
+		newVerificationCodeStorage:  dynamodb.NewVerificationCodeStorage,
+		newAuthorizationCodeStorage: dynamodb.NewAuthorizationCodeStorage,
+		newDeviceCodeStorage:        dynamodb.NewDeviceCodeStorage,
+		newWebauthnChallengeStorage: dynamodb.NewWebauthnChallengeStorage,
+		newLoginAttemptStorage:      dynamodb.NewLoginAttemptStorage,
 	}
 	return &c, nil
 }
 
 // DatabaseComposer composes DynamoDB services.
 type DatabaseComposer struct {
-	settings                   model.ServerSettings
-	newAppStorage              func(*dynamodb.DB) (model.AppStorage, error)
-	newUserStorage             func(*dynamodb.DB) (model.UserStorage, error)
-	newTokenStorage            func(*dynamodb.DB) (model.TokenStorage, error)
-	newTokenBlacklist          func(*dynamodb.DB) (model.TokenBlacklist, error)
-	newVerificationCodeStorage func(*dynamodb.DB) (model.VerificationCodeStorage, error)
+	settings                    model.ServerSettings
+	newAppStorage               func(*dynamodb.DB) (model.AppStorage, error)
+	newUserStorage              func(*dynamodb.DB) (model.UserStorage, error)
+	newTokenStorage             func(*dynamodb.DB) (model.TokenStorage, error)
+	newTokenBlacklist           func(*dynamodb.DB) (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func(*dynamodb.DB) (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func(*dynamodb.DB) (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func(*dynamodb.DB) (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func(*dynamodb.DB) (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func(*dynamodb.DB) (model.LoginAttemptStorage, error)
 }
 
 // Compose composes all services with DynamoDB support.
@@ -35,40 +43,64 @@ func (dc *DatabaseComposer) Compose() (
 	model.TokenStorage,
 	model.TokenBlacklist,
 	model.VerificationCodeStorage,
+	model.AuthorizationCodeStorage,
+	model.DeviceCodeStorage,
+	model.WebauthnChallengeStorage,
+	model.LoginAttemptStorage,
 	error,
 ) {
 	// We assume that all DynamoDB-backed storages share the same endpoint and region, so we can pick any of them.
 	db, err := dynamodb.NewDB(dc.settings.Storage.AppStorage.Endpoint, dc.settings.Storage.AppStorage.Region)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	appStorage, err := dc.newAppStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	userStorage, err := dc.newUserStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenStorage, err := dc.newTokenStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenBlacklist, err := dc.newTokenBlacklist(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	verificationCodeStorage, err := dc.newVerificationCodeStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
-	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, nil
+	authorizationCodeStorage, err := dc.newAuthorizationCodeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	deviceCodeStorage, err := dc.newDeviceCodeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	webauthnChallengeStorage, err := dc.newWebauthnChallengeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	loginAttemptStorage, err := dc.newLoginAttemptStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, authorizationCodeStorage, deviceCodeStorage, webauthnChallengeStorage, loginAttemptStorage, nil
 }
 
 // NewPartialComposer returns new partial composer with DynamoDB support.
@@ -91,6 +123,10 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 	if settings.TokenStorage.Type == model.DBTypeDynamoDB {
 		pc.newTokenStorage = dynamodb.NewTokenStorage
+		pc.newAuthorizationCodeStorage = dynamodb.NewAuthorizationCodeStorage
+		pc.newDeviceCodeStorage = dynamodb.NewDeviceCodeStorage
+		pc.newWebauthnChallengeStorage = dynamodb.NewWebauthnChallengeStorage
+		pc.newLoginAttemptStorage = dynamodb.NewLoginAttemptStorage
 		dbEndpoint = settings.TokenStorage.Endpoint
 		dbRegion = settings.TokenStorage.Region
 	}
@@ -123,12 +159,16 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 // PartialDatabaseComposer composes only DynamoDB-supporting services.
 type PartialDatabaseComposer struct {
-	db                         *dynamodb.DB
-	newAppStorage              func(*dynamodb.DB) (model.AppStorage, error)
-	newUserStorage             func(*dynamodb.DB) (model.UserStorage, error)
-	newTokenStorage            func(*dynamodb.DB) (model.TokenStorage, error)
-	newTokenBlacklist          func(*dynamodb.DB) (model.TokenBlacklist, error)
-	newVerificationCodeStorage func(*dynamodb.DB) (model.VerificationCodeStorage, error)
+	db                          *dynamodb.DB
+	newAppStorage               func(*dynamodb.DB) (model.AppStorage, error)
+	newUserStorage              func(*dynamodb.DB) (model.UserStorage, error)
+	newTokenStorage             func(*dynamodb.DB) (model.TokenStorage, error)
+	newTokenBlacklist           func(*dynamodb.DB) (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func(*dynamodb.DB) (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func(*dynamodb.DB) (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func(*dynamodb.DB) (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func(*dynamodb.DB) (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func(*dynamodb.DB) (model.LoginAttemptStorage, error)
 }
 
 // AppStorageComposer returns app storage composer.
@@ -180,3 +220,43 @@ func (pc *PartialDatabaseComposer) VerificationCodeStorageComposer() func() (mod
 	}
 	return nil
 }
+
+// AuthorizationCodeStorageComposer returns authorization code storage composer.
+func (pc *PartialDatabaseComposer) AuthorizationCodeStorageComposer() func() (model.AuthorizationCodeStorage, error) {
+	if pc.newAuthorizationCodeStorage != nil {
+		return func() (model.AuthorizationCodeStorage, error) {
+			return pc.newAuthorizationCodeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// DeviceCodeStorageComposer returns device code storage composer.
+func (pc *PartialDatabaseComposer) DeviceCodeStorageComposer() func() (model.DeviceCodeStorage, error) {
+	if pc.newDeviceCodeStorage != nil {
+		return func() (model.DeviceCodeStorage, error) {
+			return pc.newDeviceCodeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// WebauthnChallengeStorageComposer returns WebAuthn challenge storage composer.
+func (pc *PartialDatabaseComposer) WebauthnChallengeStorageComposer() func() (model.WebauthnChallengeStorage, error) {
+	if pc.newWebauthnChallengeStorage != nil {
+		return func() (model.WebauthnChallengeStorage, error) {
+			return pc.newWebauthnChallengeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// LoginAttemptStorageComposer returns login attempt storage composer.
+func (pc *PartialDatabaseComposer) LoginAttemptStorageComposer() func() (model.LoginAttemptStorage, error) {
+	if pc.newLoginAttemptStorage != nil {
+		return func() (model.LoginAttemptStorage, error) {
+			return pc.newLoginAttemptStorage(pc.db)
+		}
+	}
+	return nil
+}
@@ -8,24 +8,32 @@ import (
 // NewComposer creates new database composer with in-memory storage support.
 func NewComposer(settings model.ServerSettings) (*DatabaseComposer, error) {
 	c := DatabaseComposer{
-		settings:                   settings,
-		newAppStorage:              mem.NewAppStorage,
-		newUserStorage:             mem.NewUserStorage,
-		newTokenStorage:            mem.NewTokenStorage,
-		newTokenBlacklist:          mem.NewTokenBlacklist,
-		newVerificationCodeStorage: mem.NewVerificationCodeStorage,
+		settings:                    settings,
+		newAppStorage:               mem.NewAppStorage,
+		newUserStorage:              mem.NewUserStorage,
+		newTokenStorage:             mem.NewTokenStorage,
+		newTokenBlacklist:           mem.NewTokenBlacklist,
+		newVerificationCodeStorage:  mem.NewVerificationCodeStorage,
+		newAuthorizationCodeStorage: mem.NewAuthorizationCodeStorage,
+		newDeviceCodeStorage:        mem.NewDeviceCodeStorage,
+		newWebauthnChallengeStorage: mem.NewWebauthnChallengeStorage,
+		newLoginAttemptStorage:      mem.NewLoginAttemptStorage,
 	}
 	return &c, nil
 }
 
 // DatabaseComposer composes in-memory services.
 type DatabaseComposer struct {
-	settings                   model.ServerSettings
-	newAppStorage              func() (model.AppStorage, error)
-	newUserStorage             func() (model.UserStorage, error)
-	newTokenStorage            func() (model.TokenStorage, error)
-	newTokenBlacklist          func() (model.TokenBlacklist, error)
-	newVerificationCodeStorage func() (model.VerificationCodeStorage, error)
+	settings                    model.ServerSettings
+	newAppStorage               func() (model.AppStorage, error)
+	newUserStorage              func() (model.UserStorage, error)
+	newTokenStorage             func() (model.TokenStorage, error)
+	newTokenBlacklist           func() (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func() (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func() (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func() (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func() (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func() (model.LoginAttemptStorage, error)
 }
 
 // Compose composes all services with in-memory storage support.
@@ -35,34 +43,58 @@ func (dc *DatabaseComposer) Compose() (
 	model.TokenStorage,
 	model.TokenBlacklist,
 	model.VerificationCodeStorage,
+	model.AuthorizationCodeStorage,
+	model.DeviceCodeStorage,
+	model.WebauthnChallengeStorage,
+	model.LoginAttemptStorage,
 	error,
 ) {
 	appStorage, err := dc.newAppStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	userStorage, err := dc.newUserStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenStorage, err := dc.newTokenStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenBlacklist, err := dc.newTokenBlacklist()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	verificationCodeStorage, err := dc.newVerificationCodeStorage()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
-	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, nil
+	authorizationCodeStorage, err := dc.newAuthorizationCodeStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	deviceCodeStorage, err := dc.newDeviceCodeStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	webauthnChallengeStorage, err := dc.newWebauthnChallengeStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	loginAttemptStorage, err := dc.newLoginAttemptStorage()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, authorizationCodeStorage, deviceCodeStorage, webauthnChallengeStorage, loginAttemptStorage, nil
 }
 
 // NewPartialComposer returns new partial composer with in-memory storage support.
@@ -79,6 +111,10 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 	if settings.TokenStorage.Type == model.DBTypeFake {
 		pc.newTokenStorage = mem.NewTokenStorage
+		pc.newAuthorizationCodeStorage = mem.NewAuthorizationCodeStorage
+		pc.newDeviceCodeStorage = mem.NewDeviceCodeStorage
+		pc.newWebauthnChallengeStorage = mem.NewWebauthnChallengeStorage
+		pc.newLoginAttemptStorage = mem.NewLoginAttemptStorage
 	}
 
 	if settings.TokenBlacklist.Type == model.DBTypeFake {
@@ -99,11 +135,15 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 // PartialDatabaseComposer composes only those services that support in-memory storage.
 type PartialDatabaseComposer struct {
-	newAppStorage              func() (model.AppStorage, error)
-	newUserStorage             func() (model.UserStorage, error)
-	newTokenStorage            func() (model.TokenStorage, error)
-	newTokenBlacklist          func() (model.TokenBlacklist, error)
-	newVerificationCodeStorage func() (model.VerificationCodeStorage, error)
+	newAppStorage               func() (model.AppStorage, error)
+	newUserStorage              func() (model.UserStorage, error)
+	newTokenStorage             func() (model.TokenStorage, error)
+	newTokenBlacklist           func() (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func() (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func() (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func() (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func() (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func() (model.LoginAttemptStorage, error)
 }
 
 // AppStorageComposer returns app storage composer.
@@ -155,3 +195,43 @@ func (pc *PartialDatabaseComposer) VerificationCodeStorageComposer() func() (mod
 	}
 	return nil
 }
+
+// AuthorizationCodeStorageComposer returns authorization code storage composer.
+func (pc *PartialDatabaseComposer) AuthorizationCodeStorageComposer() func() (model.AuthorizationCodeStorage, error) {
+	if pc.newAuthorizationCodeStorage != nil {
+		return func() (model.AuthorizationCodeStorage, error) {
+			return pc.newAuthorizationCodeStorage()
+		}
+	}
+	return nil
+}
+
+// DeviceCodeStorageComposer returns device code storage composer.
+func (pc *PartialDatabaseComposer) DeviceCodeStorageComposer() func() (model.DeviceCodeStorage, error) {
+	if pc.newDeviceCodeStorage != nil {
+		return func() (model.DeviceCodeStorage, error) {
+			return pc.newDeviceCodeStorage()
+		}
+	}
+	return nil
+}
+
+// WebauthnChallengeStorageComposer returns WebAuthn challenge storage composer.
+func (pc *PartialDatabaseComposer) WebauthnChallengeStorageComposer() func() (model.WebauthnChallengeStorage, error) {
+	if pc.newWebauthnChallengeStorage != nil {
+		return func() (model.WebauthnChallengeStorage, error) {
+			return pc.newWebauthnChallengeStorage()
+		}
+	}
+	return nil
+}
+
+// LoginAttemptStorageComposer returns login attempt storage composer.
+func (pc *PartialDatabaseComposer) LoginAttemptStorageComposer() func() (model.LoginAttemptStorage, error) {
+	if pc.newLoginAttemptStorage != nil {
+		return func() (model.LoginAttemptStorage, error) {
+			return pc.newLoginAttemptStorage()
+		}
+	}
+	return nil
+}
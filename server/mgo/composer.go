@@ -8,24 +8,32 @@ import (
 // NewComposer creates new database composer.
 func NewComposer(settings model.ServerSettings) (*DatabaseComposer, error) {
 	c := DatabaseComposer{
-		settings:                   settings,
-		newAppStorage:              mongo.NewAppStorage,
-		newUserStorage:             mongo.NewUserStorage,
-		newTokenStorage:            mongo.NewTokenStorage,
-		newTokenBlacklist:          mongo.NewTokenBlacklist,
-		newVerificationCodeStorage: mongo.NewVerificationCodeStorage,
+		settings:                    settings,
+		newAppStorage:               mongo.NewAppStorage,
+		newUserStorage:              mongo.NewUserStorage,
+		newTokenStorage:             mongo.NewTokenStorage,
+		newTokenBlacklist:           mongo.NewTokenBlacklist,
+		newVerificationCodeStorage:  mongo.NewVerificationCodeStorage,
+		newAuthorizationCodeStorage: mongo.NewAuthorizationCodeStorage,
+		newDeviceCodeStorage:        mongo.NewDeviceCodeStorage,
+		newWebauthnChallengeStorage: mongo.NewWebauthnChallengeStorage,
+		newLoginAttemptStorage:      mongo.NewLoginAttemptStorage,
 	}
 	return &c, nil
 }
 
 // DatabaseComposer composes MongoDB services.
 type DatabaseComposer struct {
-	settings                   model.ServerSettings
-	newAppStorage              func(*mongo.DB) (model.AppStorage, error)
-	newUserStorage             func(*mongo.DB) (model.UserStorage, error)
-	newTokenStorage            func(*mongo.DB) (model.TokenStorage, error)
-	newTokenBlacklist          func(*mongo.DB) (model.TokenBlacklist, error)
-	newVerificationCodeStorage func(*mongo.DB) (model.VerificationCodeStorage, error)
+	settings                    model.ServerSettings
+	newAppStorage               func(*mongo.DB) (model.AppStorage, error)
+	newUserStorage              func(*mongo.DB) (model.UserStorage, error)
+	newTokenStorage             func(*mongo.DB) (model.TokenStorage, error)
+	newTokenBlacklist           func(*mongo.DB) (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func(*mongo.DB) (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func(*mongo.DB) (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func(*mongo.DB) (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func(*mongo.DB) (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func(*mongo.DB) (model.LoginAttemptStorage, error)
 }
 
 // Compose composes all services with MongoDB support.
@@ -35,40 +43,64 @@ func (dc *DatabaseComposer) Compose() (
 	model.TokenStorage,
 	model.TokenBlacklist,
 	model.VerificationCodeStorage,
+	model.AuthorizationCodeStorage,
+	model.DeviceCodeStorage,
+	model.WebauthnChallengeStorage,
+	model.LoginAttemptStorage,
 	error,
 ) {
 	// We assume that all MongoDB-backed storages share the same database name and connection string, so we can pick any of them.
 	db, err := mongo.NewDB(dc.settings.Storage.AppStorage.Endpoint, dc.settings.Storage.AppStorage.Name)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	appStorage, err := dc.newAppStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	userStorage, err := dc.newUserStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenStorage, err := dc.newTokenStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	tokenBlacklist, err := dc.newTokenBlacklist(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
 	verificationCodeStorage, err := dc.newVerificationCodeStorage(db)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
-	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, nil
+	authorizationCodeStorage, err := dc.newAuthorizationCodeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	deviceCodeStorage, err := dc.newDeviceCodeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	webauthnChallengeStorage, err := dc.newWebauthnChallengeStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	loginAttemptStorage, err := dc.newLoginAttemptStorage(db)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+
+	return appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, authorizationCodeStorage, deviceCodeStorage, webauthnChallengeStorage, loginAttemptStorage, nil
 }
 
 // NewPartialComposer returns new partial composer with MongoDB support.
@@ -91,6 +123,10 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 	if settings.TokenStorage.Type == model.DBTypeMongoDB {
 		pc.newTokenStorage = mongo.NewTokenStorage
+		pc.newAuthorizationCodeStorage = mongo.NewAuthorizationCodeStorage
+		pc.newDeviceCodeStorage = mongo.NewDeviceCodeStorage
+		pc.newWebauthnChallengeStorage = mongo.NewWebauthnChallengeStorage
+		pc.newLoginAttemptStorage = mongo.NewLoginAttemptStorage
 		dbEndpoint = settings.TokenStorage.Endpoint
 		dbName = settings.TokenStorage.Name
 	}
@@ -123,12 +159,16 @@ func NewPartialComposer(settings model.StorageSettings, options ...func(*Partial
 
 // PartialDatabaseComposer composes only MongoDB-supporting services.
 type PartialDatabaseComposer struct {
-	db                         *mongo.DB
-	newAppStorage              func(*mongo.DB) (model.AppStorage, error)
-	newUserStorage             func(*mongo.DB) (model.UserStorage, error)
-	newTokenStorage            func(*mongo.DB) (model.TokenStorage, error)
-	newTokenBlacklist          func(*mongo.DB) (model.TokenBlacklist, error)
-	newVerificationCodeStorage func(*mongo.DB) (model.VerificationCodeStorage, error)
+	db                          *mongo.DB
+	newAppStorage               func(*mongo.DB) (model.AppStorage, error)
+	newUserStorage              func(*mongo.DB) (model.UserStorage, error)
+	newTokenStorage             func(*mongo.DB) (model.TokenStorage, error)
+	newTokenBlacklist           func(*mongo.DB) (model.TokenBlacklist, error)
+	newVerificationCodeStorage  func(*mongo.DB) (model.VerificationCodeStorage, error)
+	newAuthorizationCodeStorage func(*mongo.DB) (model.AuthorizationCodeStorage, error)
+	newDeviceCodeStorage        func(*mongo.DB) (model.DeviceCodeStorage, error)
+	newWebauthnChallengeStorage func(*mongo.DB) (model.WebauthnChallengeStorage, error)
+	newLoginAttemptStorage      func(*mongo.DB) (model.LoginAttemptStorage, error)
 }
 
 // AppStorageComposer returns app storage composer.
@@ -180,3 +220,43 @@ func (pc *PartialDatabaseComposer) VerificationCodeStorageComposer() func() (mod
 	}
 	return nil
 }
+
+// AuthorizationCodeStorageComposer returns authorization code storage composer.
+func (pc *PartialDatabaseComposer) AuthorizationCodeStorageComposer() func() (model.AuthorizationCodeStorage, error) {
+	if pc.newAuthorizationCodeStorage != nil {
+		return func() (model.AuthorizationCodeStorage, error) {
+			return pc.newAuthorizationCodeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// DeviceCodeStorageComposer returns device code storage composer.
+func (pc *PartialDatabaseComposer) DeviceCodeStorageComposer() func() (model.DeviceCodeStorage, error) {
+	if pc.newDeviceCodeStorage != nil {
+		return func() (model.DeviceCodeStorage, error) {
+			return pc.newDeviceCodeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// WebauthnChallengeStorageComposer returns WebAuthn challenge storage composer.
+func (pc *PartialDatabaseComposer) WebauthnChallengeStorageComposer() func() (model.WebauthnChallengeStorage, error) {
+	if pc.newWebauthnChallengeStorage != nil {
+		return func() (model.WebauthnChallengeStorage, error) {
+			return pc.newWebauthnChallengeStorage(pc.db)
+		}
+	}
+	return nil
+}
+
+// LoginAttemptStorageComposer returns login attempt storage composer.
+func (pc *PartialDatabaseComposer) LoginAttemptStorageComposer() func() (model.LoginAttemptStorage, error) {
+	if pc.newLoginAttemptStorage != nil {
+		return func() (model.LoginAttemptStorage, error) {
+			return pc.newLoginAttemptStorage(pc.db)
+		}
+	}
+	return nil
+}
@@ -44,7 +44,7 @@ func NewServer(settings model.ServerSettings, db DatabaseComposer, configuration
 		}
 	}
 
-	appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, err := db.Compose()
+	appStorage, userStorage, tokenStorage, tokenBlacklist, verificationCodeStorage, authorizationCodeStorage, deviceCodeStorage, webauthnChallengeStorage, loginAttemptStorage, err := db.Compose()
 	if err != nil {
 		return nil, err
 	}
@@ -60,13 +60,17 @@ func NewServer(settings model.ServerSettings, db DatabaseComposer, configuration
 	}
 
 	s := Server{
-		appStorage:              appStorage,
-		userStorage:             userStorage,
-		tokenStorage:            tokenStorage,
-		tokenBlacklist:          tokenBlacklist,
-		verificationCodeStorage: verificationCodeStorage,
-		configurationStorage:    configurationStorage,
-		staticFilesStorage:      staticFilesStorage,
+		appStorage:               appStorage,
+		userStorage:              userStorage,
+		tokenStorage:             tokenStorage,
+		tokenBlacklist:           tokenBlacklist,
+		verificationCodeStorage:  verificationCodeStorage,
+		authorizationCodeStorage: authorizationCodeStorage,
+		deviceCodeStorage:        deviceCodeStorage,
+		webauthnChallengeStorage: webauthnChallengeStorage,
+		loginAttemptStorage:      loginAttemptStorage,
+		configurationStorage:     configurationStorage,
+		staticFilesStorage:       staticFilesStorage,
 	}
 
 	sessionStorage, err := initSessionStorage(settings.SessionStorage)
@@ -85,6 +89,11 @@ func NewServer(settings model.ServerSettings, db DatabaseComposer, configuration
 		return nil, err
 	}
 
+	loginLockout, err := model.NewLoginLockout(settings.Login.Lockout, loginAttemptStorage, ms)
+	if err != nil {
+		return nil, err
+	}
+
 	// env variable can rewrite host option
 	hostName := os.Getenv("HOST_NAME")
 	if len(hostName) == 0 {
@@ -92,33 +101,41 @@ func NewServer(settings model.ServerSettings, db DatabaseComposer, configuration
 	}
 
 	routerSettings := web.RouterSetting{
-		AppStorage:              appStorage,
-		UserStorage:             userStorage,
-		TokenStorage:            tokenStorage,
-		VerificationCodeStorage: verificationCodeStorage,
-		TokenService:            tokenService,
-		TokenBlacklist:          tokenBlacklist,
-		SessionService:          sessionService,
-		SessionStorage:          sessionStorage,
-		ConfigurationStorage:    configurationStorage,
-		StaticFilesStorage:      staticFilesStorage,
-		ServeAdminPanel:         settings.StaticFilesStorage.ServeAdminPanel,
-		SMSService:              sms,
-		EmailService:            ms,
+		AppStorage:               appStorage,
+		UserStorage:              userStorage,
+		TokenStorage:             tokenStorage,
+		VerificationCodeStorage:  verificationCodeStorage,
+		AuthorizationCodeStorage: authorizationCodeStorage,
+		DeviceCodeStorage:        deviceCodeStorage,
+		WebauthnChallengeStorage: webauthnChallengeStorage,
+		TokenService:             tokenService,
+		TokenBlacklist:           tokenBlacklist,
+		SessionService:           sessionService,
+		SessionStorage:           sessionStorage,
+		ConfigurationStorage:     configurationStorage,
+		StaticFilesStorage:       staticFilesStorage,
+		ServeAdminPanel:          settings.StaticFilesStorage.ServeAdminPanel,
+		SMSService:               sms,
+		EmailService:             ms,
 		WebRouterSettings: []func(*html.Router) error{
 			html.HostOption(hostName),
+			html.SupportedLoginWaysOption(settings.Login.LoginWith),
+			html.TFATypeOption(settings.Login.TFAType),
+			html.LoginLockoutOption(loginLockout),
 			html.CorsOption(cors),
 		},
 		APIRouterSettings: []func(*api.Router) error{
 			api.HostOption(hostName),
 			api.SupportedLoginWaysOption(settings.Login.LoginWith),
 			api.TFATypeOption(settings.Login.TFAType),
+			api.LoginLockoutOption(loginLockout),
 			api.CorsOption(cors),
 		},
 		AdminRouterSettings: []func(*admin.Router) error{
 			admin.HostOption(hostName),
 			admin.ServerConfigPathOption(settings.StaticFilesStorage.ServerConfigPath),
 			admin.ServerSettingsOption(&settings),
+			admin.LoginLockoutOption(loginLockout),
 			admin.CorsOption(cors),
 		},
 	}
@@ -139,14 +156,18 @@ func NewServer(settings model.ServerSettings, db DatabaseComposer, configuration
 
 // Server is a server.
 type Server struct {
-	MainRouter              *web.Router
-	appStorage              model.AppStorage
-	userStorage             model.UserStorage
-	configurationStorage    model.ConfigurationStorage
-	tokenStorage            model.TokenStorage
-	tokenBlacklist          model.TokenBlacklist
-	staticFilesStorage      model.StaticFilesStorage
-	verificationCodeStorage model.VerificationCodeStorage
+	MainRouter               *web.Router
+	appStorage               model.AppStorage
+	userStorage              model.UserStorage
+	configurationStorage     model.ConfigurationStorage
+	tokenStorage             model.TokenStorage
+	tokenBlacklist           model.TokenBlacklist
+	staticFilesStorage       model.StaticFilesStorage
+	verificationCodeStorage  model.VerificationCodeStorage
+	authorizationCodeStorage model.AuthorizationCodeStorage
+	deviceCodeStorage        model.DeviceCodeStorage
+	webauthnChallengeStorage model.WebauthnChallengeStorage
+	loginAttemptStorage      model.LoginAttemptStorage
 }
 
 // Router returns server's main router.
@@ -183,6 +204,26 @@ func (s *Server) VerificationCodeStorage() model.VerificationCodeStorage {
 	return s.verificationCodeStorage
 }
 
+// AuthorizationCodeStorage returns server's authorization code storage.
+func (s *Server) AuthorizationCodeStorage() model.AuthorizationCodeStorage {
+	return s.authorizationCodeStorage
+}
+
+// DeviceCodeStorage returns server's device code storage.
+func (s *Server) DeviceCodeStorage() model.DeviceCodeStorage {
+	return s.deviceCodeStorage
+}
+
+// WebauthnChallengeStorage returns server's WebAuthn challenge storage.
+func (s *Server) WebauthnChallengeStorage() model.WebauthnChallengeStorage {
+	return s.webauthnChallengeStorage
+}
+
+// LoginAttemptStorage returns server's login attempt storage.
+func (s *Server) LoginAttemptStorage() model.LoginAttemptStorage {
+	return s.loginAttemptStorage
+}
+
 // ConfigurationStorage returns server's configuration storage.
 func (s *Server) ConfigurationStorage() model.ConfigurationStorage {
 	return s.configurationStorage
@@ -200,6 +241,10 @@ func (s *Server) Close() {
 	s.TokenStorage().Close()
 	s.TokenBlacklist().Close()
 	s.VerificationCodeStorage().Close()
+	s.AuthorizationCodeStorage().Close()
+	s.DeviceCodeStorage().Close()
+	s.WebauthnChallengeStorage().Close()
+	s.LoginAttemptStorage().Close()
 	s.StaticFilesStorage().Close()
 }
 
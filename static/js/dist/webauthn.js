@@ -0,0 +1,79 @@
+(function () {
+  var button = document.getElementById('webauthn');
+  if (!button || !window.PublicKeyCredential) {
+    if (button) button.style.display = 'none';
+    return;
+  }
+
+  var form = document.getElementById('form');
+  var prefix = button.getAttribute('data-prefix');
+
+  function decode(value) {
+    var base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    var binary = atob(base64 + '==='.slice((base64.length + 3) % 4));
+    var bytes = new Uint8Array(binary.length);
+    for (var i = 0; i < binary.length; i++) bytes[i] = binary.charCodeAt(i);
+    return bytes.buffer;
+  }
+
+  function encode(buffer) {
+    if (!buffer) return '';
+    var bytes = new Uint8Array(buffer);
+    var binary = '';
+    for (var i = 0; i < bytes.length; i++) binary += String.fromCharCode(bytes[i]);
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function post(path, values) {
+    var body = new URLSearchParams();
+    ['appId', 'scopes', 'callbackUrl', 'returnTo', 'email'].forEach(function (name) {
+      body.append(name, form.elements[name].value);
+    });
+    Object.keys(values).forEach(function (name) { body.append(name, values[name]); });
+
+    return fetch(prefix + path, { method: 'POST', body: body, credentials: 'same-origin' })
+      .then(function (response) {
+        return response.json().then(function (data) {
+          if (!response.ok) throw new Error(data.error_description || 'Invalid Passkey');
+          return data;
+        });
+      });
+  }
+
+  function showError(message) {
+    document.getElementById('error').textContent = message;
+  }
+
+  button.addEventListener('click', function () {
+    post('/webauthn/login/begin', {})
+      .then(function (options) {
+        var publicKey = options.publicKey;
+        publicKey.challenge = decode(publicKey.challenge);
+        publicKey.allowCredentials = publicKey.allowCredentials.map(function (c) {
+          return { type: c.type, id: decode(c.id) };
+        });
+        return navigator.credentials.get({ publicKey: publicKey });
+      })
+      .then(function (credential) {
+        return post('/webauthn/login/finish', {
+          credential: JSON.stringify({
+            id: credential.id,
+            rawId: encode(credential.rawId),
+            type: credential.type,
+            response: {
+              clientDataJSON: encode(credential.response.clientDataJSON),
+              authenticatorData: encode(credential.response.authenticatorData),
+              signature: encode(credential.response.signature),
+              userHandle: encode(credential.response.userHandle)
+            }
+          })
+        });
+      })
+      .then(function (data) {
+        window.location.href = data.redirect;
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  });
+})();
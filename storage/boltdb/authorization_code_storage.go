@@ -0,0 +1,84 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// AuthorizationCodesBucket is a name for bucket with authorization codes.
+	AuthorizationCodesBucket = "AuthorizationCodes"
+)
+
+// NewAuthorizationCodeStorage creates a BoltDB authorization code storage.
+func NewAuthorizationCodeStorage(db *bolt.DB) (model.AuthorizationCodeStorage, error) {
+	acs := &AuthorizationCodeStorage{db: db}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(AuthorizationCodesBucket)); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return acs, nil
+}
+
+// AuthorizationCodeStorage is a BoltDB authorization code storage.
+type AuthorizationCodeStorage struct {
+	db *bolt.DB
+}
+
+// SaveAuthorizationCode saves authorization code in the storage.
+func (acs *AuthorizationCodeStorage) SaveAuthorizationCode(code model.AuthorizationCode) error {
+	if len(code.Code) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return acs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AuthorizationCodesBucket))
+		return b.Put([]byte(code.Code), data)
+	})
+}
+
+// ConsumeAuthorizationCode returns authorization code and removes it from the storage.
+// BoltDB has no TTL support, so expired codes are removed when someone tries to use them.
+func (acs *AuthorizationCodeStorage) ConsumeAuthorizationCode(code string) (model.AuthorizationCode, error) {
+	var ac model.AuthorizationCode
+
+	if err := acs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AuthorizationCodesBucket))
+		data := b.Get([]byte(code))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		if err := json.Unmarshal(data, &ac); err != nil {
+			return err
+		}
+		return b.Delete([]byte(code))
+	}); err != nil {
+		return model.AuthorizationCode{}, err
+	}
+
+	if ac.Expired() {
+		return model.AuthorizationCode{}, model.ErrorNotFound
+	}
+	return ac, nil
+}
+
+// Close closes underlying database.
+func (acs *AuthorizationCodeStorage) Close() {
+	if err := acs.db.Close(); err != nil {
+		log.Printf("Error closing authorization code storage: %s\n", err)
+	}
+}
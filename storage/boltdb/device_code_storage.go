@@ -0,0 +1,183 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// DeviceCodesBucket is a name for bucket with device codes.
+	DeviceCodesBucket = "DeviceCodes"
+	// DeviceUserCodesBucket is a name for bucket with user codes, mapped to the device codes.
+	DeviceUserCodesBucket = "DeviceUserCodes"
+)
+
+// NewDeviceCodeStorage creates a BoltDB device code storage.
+func NewDeviceCodeStorage(db *bolt.DB) (model.DeviceCodeStorage, error) {
+	dcs := &DeviceCodeStorage{db: db}
+	// Ensure that we have needed buckets in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(DeviceCodesBucket)); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(DeviceUserCodesBucket)); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return dcs, nil
+}
+
+// DeviceCodeStorage is a BoltDB device code storage.
+// BoltDB has no TTL support, so expired codes are removed when someone tries to use them.
+type DeviceCodeStorage struct {
+	db *bolt.DB
+}
+
+// SaveDeviceCode saves device code in the storage.
+func (dcs *DeviceCodeStorage) SaveDeviceCode(code model.DeviceCode) error {
+	if len(code.DeviceCode) == 0 || len(code.UserCode) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return dcs.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(DeviceCodesBucket)).Put([]byte(code.DeviceCode), data); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(DeviceUserCodesBucket)).Put([]byte(code.UserCode), []byte(code.DeviceCode))
+	})
+}
+
+// DeviceCodeByDeviceCode returns device code by its device code value.
+func (dcs *DeviceCodeStorage) DeviceCodeByDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	var dc model.DeviceCode
+
+	err := dcs.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(DeviceCodesBucket)).Get([]byte(deviceCode))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		return json.Unmarshal(data, &dc)
+	})
+	if err != nil {
+		return model.DeviceCode{}, err
+	}
+
+	if dc.Expired() {
+		if err = dcs.DeleteDeviceCode(dc.DeviceCode); err != nil {
+			log.Printf("Error deleting expired device code: %s\n", err)
+		}
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc, nil
+}
+
+// DeviceCodeByUserCode returns device code by the user code.
+func (dcs *DeviceCodeStorage) DeviceCodeByUserCode(userCode string) (model.DeviceCode, error) {
+	var deviceCode []byte
+
+	if err := dcs.db.View(func(tx *bolt.Tx) error {
+		deviceCode = tx.Bucket([]byte(DeviceUserCodesBucket)).Get([]byte(userCode))
+		if deviceCode == nil {
+			return model.ErrorNotFound
+		}
+		// Bolt values are valid only for the life of the transaction.
+		deviceCode = append([]byte{}, deviceCode...)
+		return nil
+	}); err != nil {
+		return model.DeviceCode{}, err
+	}
+	return dcs.DeviceCodeByDeviceCode(string(deviceCode))
+}
+
+// UpdateDeviceCodePolling updates polling state of the device code in one transaction.
+func (dcs *DeviceCodeStorage) UpdateDeviceCodePolling(deviceCode string, lastPolledAt, interval int64) error {
+	return dcs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(DeviceCodesBucket))
+		data := b.Get([]byte(deviceCode))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+
+		var dc model.DeviceCode
+		if err := json.Unmarshal(data, &dc); err != nil {
+			return err
+		}
+		dc.LastPolledAt = lastPolledAt
+		dc.Interval = interval
+
+		data, err := json.Marshal(dc)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(deviceCode), data)
+	})
+}
+
+// ConsumeDeviceCode removes approved device code and its user code from the storage, and returns the device code.
+func (dcs *DeviceCodeStorage) ConsumeDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	var dc model.DeviceCode
+
+	if err := dcs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(DeviceCodesBucket))
+		data := b.Get([]byte(deviceCode))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		if err := json.Unmarshal(data, &dc); err != nil {
+			return err
+		}
+		if dc.Status != model.DeviceCodeStatusApproved {
+			return model.ErrorNotFound
+		}
+		if err := tx.Bucket([]byte(DeviceUserCodesBucket)).Delete([]byte(dc.UserCode)); err != nil {
+			return err
+		}
+		return b.Delete([]byte(deviceCode))
+	}); err != nil {
+		return model.DeviceCode{}, err
+	}
+
+	if dc.Expired() {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc, nil
+}
+
+// DeleteDeviceCode removes device code and its user code from the storage.
+func (dcs *DeviceCodeStorage) DeleteDeviceCode(deviceCode string) error {
+	return dcs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(DeviceCodesBucket))
+		data := b.Get([]byte(deviceCode))
+		if data == nil {
+			return nil
+		}
+
+		var dc model.DeviceCode
+		if err := json.Unmarshal(data, &dc); err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte(DeviceUserCodesBucket)).Delete([]byte(dc.UserCode)); err != nil {
+			return err
+		}
+		return b.Delete([]byte(deviceCode))
+	})
+}
+
+// Close closes underlying database.
+func (dcs *DeviceCodeStorage) Close() {
+	if err := dcs.db.Close(); err != nil {
+		log.Printf("Error closing device code storage: %s\n", err)
+	}
+}
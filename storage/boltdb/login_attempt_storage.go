@@ -0,0 +1,88 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/madappgang/identifo/model"
+)
+
+// LoginAttemptsBucket is a name for bucket with failed login attempts.
+const LoginAttemptsBucket = "LoginAttempts"
+
+// NewLoginAttemptStorage creates a BoltDB login attempt storage.
+func NewLoginAttemptStorage(db *bolt.DB) (model.LoginAttemptStorage, error) {
+	las := &LoginAttemptStorage{db: db}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(LoginAttemptsBucket)); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return las, nil
+}
+
+// LoginAttemptStorage is a BoltDB login attempt storage.
+type LoginAttemptStorage struct {
+	db *bolt.DB
+}
+
+// AddFailedLoginAttempt counts failed login attempt in the storage.
+func (las *LoginAttemptStorage) AddFailedLoginAttempt(key string, resetBefore int64) (model.LoginAttempts, error) {
+	if len(key) == 0 {
+		return model.LoginAttempts{}, model.ErrorWrongDataFormat
+	}
+
+	attempts := model.LoginAttempts{Key: key}
+	err := las.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(LoginAttemptsBucket))
+		if data := b.Get([]byte(key)); data != nil {
+			if err := json.Unmarshal(data, &attempts); err != nil {
+				return err
+			}
+		}
+		if attempts.LastFailedAt < resetBefore {
+			attempts = model.LoginAttempts{Key: key}
+		}
+		attempts.Failures++
+		attempts.LastFailedAt = time.Now().Unix()
+
+		data, err := json.Marshal(attempts)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+	if err != nil {
+		return model.LoginAttempts{}, err
+	}
+	return attempts, nil
+}
+
+// LoginAttempts returns failed login attempts from the storage.
+func (las *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	attempts := model.LoginAttempts{Key: key}
+	err := las.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(LoginAttemptsBucket)).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &attempts)
+	})
+	return attempts, err
+}
+
+// ResetLoginAttempts removes failed login attempts from the storage.
+func (las *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	return las.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(LoginAttemptsBucket)).Delete([]byte(key))
+	})
+}
+
+// Close does nothing here.
+func (las *LoginAttemptStorage) Close() {}
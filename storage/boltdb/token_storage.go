@@ -1,8 +1,11 @@
 package boltdb
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/madappgang/identifo/model"
@@ -11,6 +14,8 @@ import (
 const (
 	// TokenBucket is a name for bucket with tokens.
 	TokenBucket = "Tokens"
+	// UserTokensRevocationBucket is a name for bucket with times users' tokens were revoked at.
+	UserTokensRevocationBucket = "UserTokensRevocations"
 )
 
 // NewTokenStorage creates a BoltDB token storage.
@@ -21,6 +26,9 @@ func NewTokenStorage(db *bolt.DB) (model.TokenStorage, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte(TokenBucket)); err != nil {
 			return fmt.Errorf("create bucket: %s", err)
 		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(UserTokensRevocationBucket)); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
 		return nil
 	}); err != nil {
 		return nil, err
@@ -34,11 +42,15 @@ type TokenStorage struct {
 }
 
 // SaveToken saves token in the storage.
-func (ts *TokenStorage) SaveToken(token string) error {
+func (ts *TokenStorage) SaveToken(token string, session model.UserSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
 	return ts.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TokenBucket))
-		// We use token as key and value.
-		return b.Put([]byte(token), []byte(token))
+		// We use token as key and its session as value.
+		return b.Put([]byte(token), data)
 	})
 }
 
@@ -47,7 +59,6 @@ func (ts *TokenStorage) HasToken(token string) bool {
 	var res bool
 	if err := ts.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TokenBucket))
-		// We use token as key and value.
 		res = b.Get([]byte(token)) != nil
 		return nil
 	}); err != nil {
@@ -61,11 +72,141 @@ func (ts *TokenStorage) HasToken(token string) bool {
 func (ts *TokenStorage) DeleteToken(token string) error {
 	return ts.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TokenBucket))
-		// We use token as key and value.
 		return b.Delete([]byte(token))
 	})
 }
 
+// TokenSession returns the session of the token.
+// Tokens saved before sessions were introduced have none.
+func (ts *TokenStorage) TokenSession(token string) (model.UserSession, error) {
+	var session model.UserSession
+	err := ts.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TokenBucket))
+		var ok bool
+		if session, ok = unmarshalSession(b.Get([]byte(token))); !ok {
+			return model.ErrorNotFound
+		}
+		return nil
+	})
+	return session, err
+}
+
+// TouchToken updates last use of the token session.
+func (ts *TokenStorage) TouchToken(token string, client model.ClientInfo) error {
+	return ts.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TokenBucket))
+		session, ok := unmarshalSession(b.Get([]byte(token)))
+		if !ok {
+			return model.ErrorNotFound
+		}
+		session.Touch(client)
+		data, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(token), data)
+	})
+}
+
+// UserSessions returns unexpired sessions of the user.
+func (ts *TokenStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	sessions := []model.UserSession{}
+	err := ts.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TokenBucket))
+		return b.ForEach(func(k, v []byte) error {
+			if s, ok := unmarshalSession(v); ok && s.UserID == userID && !s.Expired() {
+				sessions = append(sessions, s)
+			}
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// DeleteUserSession removes all tokens of the user's session.
+func (ts *TokenStorage) DeleteUserSession(userID, sessionID string) ([]string, error) {
+	tokens := []string{}
+	err := ts.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TokenBucket))
+		if err := b.ForEach(func(k, v []byte) error {
+			if s, ok := unmarshalSession(v); ok && s.UserID == userID && s.ID == sessionID {
+				tokens = append(tokens, string(k))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		// Bucket must not be modified while iterating over it.
+		for _, t := range tokens {
+			if err := b.Delete([]byte(t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, model.ErrorNotFound
+	}
+	return tokens, nil
+}
+
+// RevokeUserTokens removes all refresh tokens of the user and records the time of revocation.
+func (ts *TokenStorage) RevokeUserTokens(userID string) error {
+	return ts.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TokenBucket))
+		tokens := [][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			if s, ok := unmarshalSession(v); ok && s.UserID == userID {
+				tokens = append(tokens, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if err := b.Delete(t); err != nil {
+				return err
+			}
+		}
+
+		rb := tx.Bucket([]byte(UserTokensRevocationBucket))
+		return rb.Put([]byte(userID), []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+	})
+}
+
+// UserTokensRevokedAt returns the time tokens of the user were revoked at.
+func (ts *TokenStorage) UserTokensRevokedAt(userID string) int64 {
+	var revokedAt int64
+	if err := ts.db.View(func(tx *bolt.Tx) error {
+		rb := tx.Bucket([]byte(UserTokensRevocationBucket))
+		if v := rb.Get([]byte(userID)); v != nil {
+			var err error
+			revokedAt, err = strconv.ParseInt(string(v), 10, 64)
+			return err
+		}
+		return nil
+	}); err != nil {
+		log.Printf("Error getting revocation time of user %s tokens: %s\n", userID, err)
+		return 0
+	}
+	return revokedAt
+}
+
+// unmarshalSession parses stored session, tokens saved before sessions were introduced have token itself as a value.
+func unmarshalSession(data []byte) (model.UserSession, bool) {
+	var session model.UserSession
+	if len(data) == 0 || data[0] != '{' {
+		return session, false
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, false
+	}
+	return session, true
+}
+
 // Close closes underlying database.
 func (ts *TokenStorage) Close() {
 	if err := ts.db.Close(); err != nil {
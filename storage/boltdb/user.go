@@ -13,17 +13,20 @@ type User struct {
 
 // User data implementation.
 type userData struct {
-	ID              string        `json:"id,omitempty"`
-	Username        string        `json:"username,omitempty"`
-	Email           string        `json:"email,omitempty"`
-	Phone           string        `json:"phone,omitempty"`
-	Pswd            string        `json:"pswd,omitempty"`
-	Active          bool          `json:"active,omitempty"`
-	TFAInfo         model.TFAInfo `json:"tfa_info"`
-	NumOfLogins     int           `json:"num_of_logins,omitempty"`
-	LatestLoginTime int64         `json:"latest_login_time,omitempty"`
-	AccessRole      string        `json:"access_role,omitempty"`
-	Anonymous       bool          `json:"anonymous,omitempty"`
+	ID              string                     `json:"id,omitempty"`
+	Username        string                     `json:"username,omitempty"`
+	Email           string                     `json:"email,omitempty"`
+	EmailVerified   bool                       `json:"email_verified,omitempty"`
+	Phone           string                     `json:"phone,omitempty"`
+	Pswd            string                     `json:"pswd,omitempty"`
+	Active          bool                       `json:"active,omitempty"`
+	TFAInfo         model.TFAInfo              `json:"tfa_info"`
+	Credentials     []model.WebauthnCredential `json:"webauthn_credentials,omitempty"`
+	FederatedIDs    []string                   `json:"federated_ids,omitempty"`
+	NumOfLogins     int                        `json:"num_of_logins,omitempty"`
+	LatestLoginTime int64                      `json:"latest_login_time,omitempty"`
+	AccessRole      string                     `json:"access_role,omitempty"`
+	Anonymous       bool                       `json:"anonymous,omitempty"`
 }
 
 // Marshal serializes data to byte array.
@@ -34,7 +37,7 @@ func (u User) Marshal() ([]byte, error) {
 // Sanitize removes all sensitive data.
 func (u *User) Sanitize() {
 	u.userData.Pswd = ""
-	u.userData.TFAInfo.Secret = ""
+	u.userData.TFAInfo.Sanitize()
 }
 
 // ID implements model.User interface.
@@ -52,6 +55,12 @@ func (u *User) Email() string { return u.userData.Email }
 // SetEmail implements model.Email interface.
 func (u *User) SetEmail(email string) { u.userData.Email = email }
 
+// EmailVerified implements model.User interface.
+func (u *User) EmailVerified() bool { return u.userData.EmailVerified }
+
+// SetEmailVerified implements model.User interface.
+func (u *User) SetEmailVerified(verified bool) { u.userData.EmailVerified = verified }
+
 // Phone implements model.User interface.
 func (u *User) Phone() string { return u.userData.Phone }
 
@@ -61,6 +70,19 @@ func (u *User) TFAInfo() model.TFAInfo { return u.userData.TFAInfo }
 // SetTFAInfo implements model.User interface.
 func (u *User) SetTFAInfo(tfaInfo model.TFAInfo) { u.userData.TFAInfo = tfaInfo }
 
+// WebauthnCredentials implements model.User interface.
+func (u *User) WebauthnCredentials() []model.WebauthnCredential { return u.userData.Credentials }
+
+// SetWebauthnCredentials implements model.User interface.
+func (u *User) SetWebauthnCredentials(credentials []model.WebauthnCredential) {
+	u.userData.Credentials = credentials
+}
+
+// FederatedIdentities implements model.User interface.
+func (u *User) FederatedIdentities() []model.FederatedIdentity {
+	return model.FederatedIdentitiesFromStrings(u.userData.FederatedIDs)
+}
+
 // PasswordHash implements model.User interface.
 func (u *User) PasswordHash() string { return u.userData.Pswd }
 
@@ -70,6 +92,9 @@ func (u *User) Active() bool { return u.userData.Active }
 // AccessRole implements model.User interface.
 func (u *User) AccessRole() string { return u.userData.AccessRole }
 
+// SetAccessRole implements model.User interface.
+func (u *User) SetAccessRole(role string) { u.userData.AccessRole = role }
+
 // IsAnonymous implements model.User interface.
 func (u *User) IsAnonymous() bool { return u.userData.Anonymous }
 
@@ -42,7 +42,7 @@ func NewUserStorage(db *bolt.DB) (model.UserStorage, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte(UserByPhoneNumberBucket)); err != nil {
 			return fmt.Errorf("create bucket: %s", err)
 		}
-		return nil
+		return backfillFederatedIDs(tx)
 	}); err != nil {
 		return nil, err
 	}
@@ -50,6 +50,52 @@ func NewUserStorage(db *bolt.DB) (model.UserStorage, error) {
 	return &us, nil
 }
 
+// backfillFederatedIDs adds federated IDs to the users created before the users kept them,
+// when the IDs were only the keys of UserBySocialIDBucket.
+func backfillFederatedIDs(tx *bolt.Tx) error {
+	ub := tx.Bucket([]byte(UserBucket))
+	usib := tx.Bucket([]byte(UserBySocialIDBucket))
+
+	missing := make(map[string][]string)
+	if err := usib.ForEach(func(sid, userID []byte) error {
+		u := ub.Get(userID)
+		if u == nil {
+			return nil
+		}
+		user, err := UserFromJSON(u)
+		if err != nil {
+			return err
+		}
+		for _, fid := range user.userData.FederatedIDs {
+			if fid == string(sid) {
+				return nil
+			}
+		}
+		missing[string(userID)] = append(missing[string(userID)], string(sid))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Buckets must not be modified while they are iterated.
+	for userID, sids := range missing {
+		user, err := UserFromJSON(ub.Get([]byte(userID)))
+		if err != nil {
+			return err
+		}
+		user.userData.FederatedIDs = append(user.userData.FederatedIDs, sids...)
+
+		data, err := user.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := ub.Put([]byte(userID), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // UserStorage implements user storage interface for BoltDB.
 type UserStorage struct {
 	db *bolt.DB
@@ -81,9 +127,36 @@ func (us *UserStorage) UserByID(id string) (model.User, error) {
 }
 
 // UserByEmail returns user by its email.
+// There is no index by email, so users are scanned.
 func (us *UserStorage) UserByEmail(email string) (model.User, error) {
-	// TODO: implement boltdb UserByEmail
-	return nil, errors.New("Not implemented. ")
+	if email == "" {
+		return nil, model.ErrorWrongDataFormat
+	}
+
+	var res *User
+	err := us.db.View(func(tx *bolt.Tx) error {
+		ub := tx.Bucket([]byte(UserBucket))
+		return ub.ForEach(func(k, v []byte) error {
+			if res != nil {
+				return nil
+			}
+			user, err := UserFromJSON(v)
+			if err != nil {
+				return err
+			}
+			if strings.EqualFold(user.Email(), email) {
+				res = user
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, model.ErrUserNotFound
+	}
+	return res, nil
 }
 
 // DeleteUser deletes user by ID.
@@ -317,6 +390,50 @@ func (us *UserStorage) AddUserByPhone(phone, role string) (model.User, error) {
 	return u, err
 }
 
+// AddUserByEmail registers new user with email.
+func (us *UserStorage) AddUserByEmail(email, role string) (model.User, error) {
+	email = strings.ToLower(email)
+	if _, err := us.UserByEmail(email); err == nil {
+		return nil, model.ErrorUserExists
+	} else if err != model.ErrUserNotFound {
+		return nil, err
+	}
+
+	u := &User{
+		userData: userData{
+			ID:          xid.New().String(),
+			Username:    email,
+			Email:       email,
+			Active:      true,
+			AccessRole:  role,
+			NumOfLogins: 0,
+		},
+	}
+
+	err := us.db.Update(func(tx *bolt.Tx) error {
+		// Email is the username of such users, so it must not be the username of someone else.
+		unpb := tx.Bucket([]byte(UserByNameAndPassword))
+		if unpb.Get([]byte(email)) != nil {
+			return model.ErrorUserExists
+		}
+
+		data, err := u.Marshal()
+		if err != nil {
+			return err
+		}
+
+		ub := tx.Bucket([]byte(UserBucket))
+		if err := ub.Put([]byte(u.ID()), data); err != nil {
+			return err
+		}
+		return unpb.Put([]byte(email), []byte(u.ID()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 // AddUserWithFederatedID adds new user with social ID.
 func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityProvider, federatedID, role string) (model.User, error) {
 	sid := string(provider) + ":" + federatedID
@@ -325,7 +442,7 @@ func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityPr
 		return nil, model.ErrorUserExists
 	}
 
-	u := userData{Active: true, Username: sid, AccessRole: role, NumOfLogins: 0}
+	u := userData{Active: true, Username: sid, AccessRole: role, NumOfLogins: 0, FederatedIDs: []string{sid}}
 	u.ID = sid // not sure it's a good idea
 	user := &User{userData: u}
 
@@ -349,6 +466,79 @@ func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityPr
 	return user, nil
 }
 
+// LinkFederatedID links one more federated identity to the user.
+func (us *UserStorage) LinkFederatedID(userID string, provider model.FederatedIdentityProvider, federatedID string) error {
+	sid := model.FederatedIDString(provider, federatedID)
+
+	return us.db.Update(func(tx *bolt.Tx) error {
+		usib := tx.Bucket([]byte(UserBySocialIDBucket))
+		if linkedID := usib.Get([]byte(sid)); linkedID != nil {
+			if string(linkedID) == userID {
+				return nil
+			}
+			return model.ErrorUserExists
+		}
+
+		ub := tx.Bucket([]byte(UserBucket))
+		u := ub.Get([]byte(userID))
+		if u == nil {
+			return model.ErrUserNotFound
+		}
+		user, err := UserFromJSON(u)
+		if err != nil {
+			return err
+		}
+		user.userData.FederatedIDs = append(user.userData.FederatedIDs, sid)
+
+		data, err := user.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := ub.Put([]byte(userID), data); err != nil {
+			return err
+		}
+		return usib.Put([]byte(sid), []byte(userID))
+	})
+}
+
+// UnlinkFederatedID unlinks federated identity from the user.
+func (us *UserStorage) UnlinkFederatedID(userID string, provider model.FederatedIdentityProvider, federatedID string) error {
+	sid := model.FederatedIDString(provider, federatedID)
+
+	return us.db.Update(func(tx *bolt.Tx) error {
+		usib := tx.Bucket([]byte(UserBySocialIDBucket))
+		if linkedID := usib.Get([]byte(sid)); string(linkedID) != userID {
+			return model.ErrorNotFound
+		}
+
+		ub := tx.Bucket([]byte(UserBucket))
+		u := ub.Get([]byte(userID))
+		if u == nil {
+			return model.ErrUserNotFound
+		}
+		user, err := UserFromJSON(u)
+		if err != nil {
+			return err
+		}
+		fids := []string{}
+		for _, fid := range user.userData.FederatedIDs {
+			if fid != sid {
+				fids = append(fids, fid)
+			}
+		}
+		user.userData.FederatedIDs = fids
+
+		data, err := user.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := ub.Put([]byte(userID), data); err != nil {
+			return err
+		}
+		return usib.Delete([]byte(sid))
+	})
+}
+
 // AddUserByNameAndPassword creates new user and saves it in the database.
 func (us *UserStorage) AddUserByNameAndPassword(username, password, role string, isAnonymous bool) (model.User, error) {
 	if us.UserExists(username) {
@@ -386,17 +576,43 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 	}
 
 	err := us.db.Update(func(tx *bolt.Tx) error {
+		// The username must not be taken from another user, it is checked here not to race with other updates.
+		unpb := tx.Bucket([]byte(UserByNameAndPassword))
+		if owner := unpb.Get([]byte(res.Username())); len(res.Username()) > 0 && owner != nil && string(owner) != userID {
+			return model.ErrorUserExists
+		}
+
 		data, err := res.Marshal()
 		if err != nil {
 			return err
 		}
 
 		ub := tx.Bucket([]byte(UserBucket))
+		oldUsername := ""
+		if u := ub.Get([]byte(userID)); u != nil {
+			old, err := UserFromJSON(u)
+			if err != nil {
+				return err
+			}
+			oldUsername = old.Username()
+		}
 		if err := ub.Delete([]byte(userID)); err != nil {
 			return err
 		}
+		if err := ub.Put([]byte(res.ID()), data); err != nil {
+			return err
+		}
 
-		return ub.Put([]byte(res.ID()), data)
+		// Keep username index in sync, so the user can log in with the new username.
+		if len(oldUsername) > 0 && oldUsername != res.Username() && string(unpb.Get([]byte(oldUsername))) == userID {
+			if err := unpb.Delete([]byte(oldUsername)); err != nil {
+				return err
+			}
+		}
+		if len(res.Username()) > 0 && (oldUsername != res.Username() || res.ID() != userID) {
+			return unpb.Put([]byte(res.Username()), []byte(res.ID()))
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, err
@@ -406,6 +622,66 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 	return updatedUser, err
 }
 
+// ConsumeRecoveryCode removes TFA recovery code hash from the user in the same transaction it is looked up in.
+func (us *UserStorage) ConsumeRecoveryCode(userID, codeHash string) error {
+	return us.updateTFAInfo(userID, func(tfaInfo *model.TFAInfo) error {
+		codes := []string{}
+		for _, h := range tfaInfo.RecoveryCodes {
+			if h != codeHash {
+				codes = append(codes, h)
+			}
+		}
+		if len(codes) == len(tfaInfo.RecoveryCodes) {
+			return model.ErrorNotFound
+		}
+		tfaInfo.RecoveryCodes = codes
+		return nil
+	})
+}
+
+// SetRecoveryCodes replaces TFA recovery code hashes of the user.
+func (us *UserStorage) SetRecoveryCodes(userID string, codeHashes []string) error {
+	return us.updateTFAInfo(userID, func(tfaInfo *model.TFAInfo) error {
+		tfaInfo.RecoveryCodes = codeHashes
+		tfaInfo.RecoveryCodesIssued = true
+		return nil
+	})
+}
+
+// AddTrustedDevice remembers the device in TFA info of the user.
+func (us *UserStorage) AddTrustedDevice(userID string, device model.TrustedDevice) error {
+	return us.updateTFAInfo(userID, func(tfaInfo *model.TFAInfo) error {
+		tfaInfo.AddTrustedDevice(device)
+		return nil
+	})
+}
+
+// updateTFAInfo changes TFA info of the user in the same transaction it is read in,
+// so that concurrent changes of other TFA data are not lost.
+func (us *UserStorage) updateTFAInfo(userID string, update func(*model.TFAInfo) error) error {
+	return us.db.Update(func(tx *bolt.Tx) error {
+		ub := tx.Bucket([]byte(UserBucket))
+		u := ub.Get([]byte(userID))
+		if u == nil {
+			return model.ErrUserNotFound
+		}
+		user, err := UserFromJSON(u)
+		if err != nil {
+			return err
+		}
+
+		if err = update(&user.userData.TFAInfo); err != nil {
+			return err
+		}
+
+		data, err := user.Marshal()
+		if err != nil {
+			return err
+		}
+		return ub.Put([]byte(userID), data)
+	})
+}
+
 // ResetPassword sets new user password.
 func (us *UserStorage) ResetPassword(id, password string) error {
 	return us.db.Update(func(tx *bolt.Tx) error {
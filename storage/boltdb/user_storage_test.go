@@ -0,0 +1,72 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/madappgang/identifo/model"
+)
+
+func testUserStorage(t *testing.T) model.UserStorage {
+	t.Helper()
+
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Unable to open database %v", err)
+	}
+	t.Cleanup(func() { CloseDB(db) })
+
+	us, err := NewUserStorage(db)
+	if err != nil {
+		t.Fatalf("Unable to create user storage %v", err)
+	}
+	return us
+}
+
+func TestUpdateUserTakenUsername(t *testing.T) {
+	us := testUserStorage(t)
+
+	alice, err := us.AddUserByNameAndPassword("alice", "Password1!", "user", false)
+	if err != nil {
+		t.Fatalf("Unable to add user %v", err)
+	}
+	bob, err := us.AddUserByNameAndPassword("bob", "Password1!", "user", false)
+	if err != nil {
+		t.Fatalf("Unable to add user %v", err)
+	}
+
+	bob.SetUsername("alice")
+	if _, err = us.UpdateUser(bob.ID(), bob); err != model.ErrorUserExists {
+		t.Errorf("UpdateUser() to taken username error = %v, want %v", err, model.ErrorUserExists)
+	}
+	if user, err := us.UserByNamePassword("alice", "Password1!"); err != nil || user.ID() != alice.ID() {
+		t.Errorf("Username of %v is taken over", alice.ID())
+	}
+
+	// Keeping own username is fine.
+	alice.SetEmail("alice@example.com")
+	if _, err = us.UpdateUser(alice.ID(), alice); err != nil {
+		t.Errorf("UpdateUser() with own username error = %v", err)
+	}
+}
+
+func TestAddUserByEmailTakenUsername(t *testing.T) {
+	us := testUserStorage(t)
+
+	owner, err := us.AddUserByNameAndPassword("carol@example.com", "Password1!", "user", false)
+	if err != nil {
+		t.Fatalf("Unable to add user %v", err)
+	}
+	// The email is not found by itself, when the user has moved to another one.
+	owner.SetEmail("carol@example.org")
+	if _, err = us.UpdateUser(owner.ID(), owner); err != nil {
+		t.Fatalf("Unable to update user %v", err)
+	}
+
+	if _, err = us.AddUserByEmail("Carol@example.com", "user"); err != model.ErrorUserExists {
+		t.Errorf("AddUserByEmail() with taken username error = %v, want %v", err, model.ErrorUserExists)
+	}
+	if user, err := us.UserByNamePassword("carol@example.com", "Password1!"); err != nil || user.ID() != owner.ID() {
+		t.Errorf("Username of %v is taken over", owner.ID())
+	}
+}
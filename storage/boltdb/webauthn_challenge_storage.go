@@ -0,0 +1,101 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// WebauthnChallengesBucket is a name for bucket with WebAuthn challenges.
+	WebauthnChallengesBucket = "WebauthnChallenges"
+)
+
+// NewWebauthnChallengeStorage creates a BoltDB WebAuthn challenge storage.
+func NewWebauthnChallengeStorage(db *bolt.DB) (model.WebauthnChallengeStorage, error) {
+	wcs := &WebauthnChallengeStorage{db: db}
+	// Ensure that we have needed bucket in the database.
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(WebauthnChallengesBucket)); err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return wcs, nil
+}
+
+// WebauthnChallengeStorage is a BoltDB WebAuthn challenge storage.
+type WebauthnChallengeStorage struct {
+	db *bolt.DB
+}
+
+// SaveWebauthnChallenge saves challenge in the storage.
+// BoltDB has no TTL support, so expired challenges nobody has answered are removed here.
+func (wcs *WebauthnChallengeStorage) SaveWebauthnChallenge(challenge model.WebauthnChallenge) error {
+	if len(challenge.Challenge) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return wcs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebauthnChallengesBucket))
+
+		var expired [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			var wc model.WebauthnChallenge
+			if err := json.Unmarshal(v, &wc); err != nil || wc.Expired() {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return b.Put([]byte(challenge.Challenge), data)
+	})
+}
+
+// ConsumeWebauthnChallenge returns challenge and removes it from the storage.
+func (wcs *WebauthnChallengeStorage) ConsumeWebauthnChallenge(challenge string) (model.WebauthnChallenge, error) {
+	var wc model.WebauthnChallenge
+
+	if err := wcs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebauthnChallengesBucket))
+		data := b.Get([]byte(challenge))
+		if data == nil {
+			return model.ErrorNotFound
+		}
+		if err := json.Unmarshal(data, &wc); err != nil {
+			return err
+		}
+		return b.Delete([]byte(challenge))
+	}); err != nil {
+		return model.WebauthnChallenge{}, err
+	}
+
+	if wc.Expired() {
+		return model.WebauthnChallenge{}, model.ErrorNotFound
+	}
+	return wc, nil
+}
+
+// Close closes underlying database.
+func (wcs *WebauthnChallengeStorage) Close() {
+	if err := wcs.db.Close(); err != nil {
+		log.Printf("Error closing WebAuthn challenge storage: %s\n", err)
+	}
+}
@@ -0,0 +1,53 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/model"
+)
+
+func TestConsumeWebauthnChallenge(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Unable to open database %v", err)
+	}
+	t.Cleanup(func() { CloseDB(db) })
+
+	wcs, err := NewWebauthnChallengeStorage(db)
+	if err != nil {
+		t.Fatalf("Unable to create WebAuthn challenge storage %v", err)
+	}
+
+	expired := model.NewWebauthnChallenge("expired", model.WebauthnCeremonyLogin, "")
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	if err = wcs.SaveWebauthnChallenge(expired); err != nil {
+		t.Fatalf("Unable to save challenge %v", err)
+	}
+	if err = wcs.SaveWebauthnChallenge(model.NewWebauthnChallenge("valid", model.WebauthnCeremonyRegistration, "user")); err != nil {
+		t.Fatalf("Unable to save challenge %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		challenge string
+		wantErr   error
+	}{
+		{"valid", "valid", nil},
+		{"answered twice", "valid", model.ErrorNotFound},
+		{"expired", "expired", model.ErrorNotFound},
+		{"unknown", "unknown", model.ErrorNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wc, err := wcs.ConsumeWebauthnChallenge(tt.challenge)
+			if err != tt.wantErr {
+				t.Fatalf("ConsumeWebauthnChallenge() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (wc.Ceremony != model.WebauthnCeremonyRegistration || wc.UserID != "user") {
+				t.Errorf("ConsumeWebauthnChallenge() = %+v, want registration challenge of the user", wc)
+			}
+		})
+	}
+}
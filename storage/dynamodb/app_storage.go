@@ -218,11 +218,11 @@ func (as *AppStorage) UpdateApp(appID string, newApp model.AppData) (model.AppDa
 }
 
 // FetchApps fetches apps which name satisfies provided filterString.
-// Supports pagination. Search is case-senstive for now.
+// Supports pagination, zero limit means all apps. Search is case-senstive for now.
 func (as *AppStorage) FetchApps(filterString string, skip, limit int) ([]model.AppData, int, error) {
+	// Scan limit caps items evaluated per page, not the matching ones, so all pages are read and then paginated here.
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(appsTableName),
-		Limit:     aws.Int64(int64(limit)),
 	}
 
 	if len(filterString) != 0 {
@@ -235,25 +235,35 @@ func (as *AppStorage) FetchApps(filterString string, skip, limit int) ([]model.A
 		}
 	}
 
-	result, err := as.db.C.Scan(scanInput)
+	var items []map[string]*dynamodb.AttributeValue
+	err := as.db.C.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
 	if err != nil {
 		log.Println("Error querying for apps:", err)
 		return []model.AppData{}, 0, ErrorInternalError
 	}
 
-	apps := make([]model.AppData, len(result.Items))
-	for i := 0; i < len(result.Items); i++ {
-		if i < skip {
-			continue // TODO: use internal pagination mechanism
-		}
+	total := len(items)
+	if skip > total {
+		skip = total
+	}
+	items = items[skip:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	apps := make([]model.AppData, len(items))
+	for i, item := range items {
 		appData := appData{}
-		if err = dynamodbattribute.UnmarshalMap(result.Items[i], &appData); err != nil {
+		if err = dynamodbattribute.UnmarshalMap(item, &appData); err != nil {
 			log.Println("Error unmarshalling app:", err)
 			return []model.AppData{}, 0, ErrorInternalError
 		}
 		apps[i] = &AppData{appData: appData}
 	}
-	return apps, len(result.Items), nil
+	return apps, total, nil
 }
 
 // DeleteApp deletes app by id.
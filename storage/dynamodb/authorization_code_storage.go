@@ -0,0 +1,137 @@
+package dynamodb
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// authorizationCodesTableName is a table name for authorization codes.
+	authorizationCodesTableName = "AuthorizationCodes"
+	// authorizationCodesTTLField is an attribute used by DynamoDB to remove expired codes.
+	authorizationCodesTTLField = "expires_at"
+)
+
+// NewAuthorizationCodeStorage creates and provisions new DynamoDB authorization code storage.
+func NewAuthorizationCodeStorage(db *DB) (model.AuthorizationCodeStorage, error) {
+	acs := &AuthorizationCodeStorage{db: db}
+	err := acs.ensureTable()
+	return acs, err
+}
+
+// AuthorizationCodeStorage is a DynamoDB authorization code storage.
+type AuthorizationCodeStorage struct {
+	db *DB
+}
+
+// SaveAuthorizationCode saves authorization code in the database.
+func (acs *AuthorizationCodeStorage) SaveAuthorizationCode(code model.AuthorizationCode) error {
+	if len(code.Code) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+
+	item, err := dynamodbattribute.MarshalMap(code)
+	if err != nil {
+		log.Println("Error marshalling authorization code:", err)
+		return ErrorInternalError
+	}
+
+	if _, err = acs.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(authorizationCodesTableName),
+	}); err != nil {
+		log.Println("Error putting authorization code to database:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// ConsumeAuthorizationCode returns authorization code and removes it from the database.
+func (acs *AuthorizationCodeStorage) ConsumeAuthorizationCode(code string) (model.AuthorizationCode, error) {
+	result, err := acs.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(authorizationCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"code": {S: aws.String(code)},
+		},
+		ReturnValues: aws.String("ALL_OLD"),
+	})
+	if err != nil {
+		log.Println("Error deleting authorization code:", err)
+		return model.AuthorizationCode{}, ErrorInternalError
+	}
+	if len(result.Attributes) == 0 {
+		return model.AuthorizationCode{}, model.ErrorNotFound
+	}
+
+	ac := model.AuthorizationCode{}
+	if err = dynamodbattribute.UnmarshalMap(result.Attributes, &ac); err != nil {
+		log.Println("Error unmarshalling authorization code:", err)
+		return model.AuthorizationCode{}, ErrorInternalError
+	}
+
+	// DynamoDB deletes expired items within 48 hours, so we have to check it on our own.
+	if ac.Expired() {
+		return model.AuthorizationCode{}, model.ErrorNotFound
+	}
+	return ac, nil
+}
+
+// ensureTable ensures that authorization code storage table exists in the database.
+func (acs *AuthorizationCodeStorage) ensureTable() error {
+	exists, err := acs.db.IsTableExists(authorizationCodesTableName)
+	if err != nil {
+		log.Printf("Error while checking if %s exists: %v", authorizationCodesTableName, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("code"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("code"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(authorizationCodesTableName),
+	}
+
+	if _, err = acs.db.C.CreateTable(input); err != nil {
+		log.Printf("Error while creating %s table: %v", authorizationCodesTableName, err)
+		return err
+	}
+
+	if err = acs.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(authorizationCodesTableName),
+	}); err != nil {
+		log.Printf("Error while waiting for %s table: %v", authorizationCodesTableName, err)
+		return err
+	}
+
+	if _, err = acs.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(authorizationCodesTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(authorizationCodesTTLField),
+			Enabled:       aws.Bool(true),
+		},
+	}); err != nil {
+		log.Printf("Error while setting %s expiration time: %v", authorizationCodesTableName, err)
+		return err
+	}
+	return nil
+}
+
+// Close does nothing here.
+func (acs *AuthorizationCodeStorage) Close() {}
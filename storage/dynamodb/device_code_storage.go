@@ -0,0 +1,252 @@
+package dynamodb
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// deviceCodesTableName is a table name for device codes.
+	deviceCodesTableName = "DeviceCodes"
+	// deviceCodesUserCodeIndexName is a device codes table global index to access device codes by user codes.
+	deviceCodesUserCodeIndexName = "user-code-index"
+	// deviceCodesTTLField is an attribute used by DynamoDB to remove expired codes.
+	deviceCodesTTLField = "expires_at"
+)
+
+// NewDeviceCodeStorage creates and provisions new DynamoDB device code storage.
+func NewDeviceCodeStorage(db *DB) (model.DeviceCodeStorage, error) {
+	dcs := &DeviceCodeStorage{db: db}
+	err := dcs.ensureTable()
+	return dcs, err
+}
+
+// DeviceCodeStorage is a DynamoDB device code storage.
+type DeviceCodeStorage struct {
+	db *DB
+}
+
+// SaveDeviceCode saves device code in the database.
+func (dcs *DeviceCodeStorage) SaveDeviceCode(code model.DeviceCode) error {
+	if len(code.DeviceCode) == 0 || len(code.UserCode) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+
+	item, err := dynamodbattribute.MarshalMap(code)
+	if err != nil {
+		log.Println("Error marshalling device code:", err)
+		return ErrorInternalError
+	}
+
+	if _, err = dcs.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(deviceCodesTableName),
+	}); err != nil {
+		log.Println("Error putting device code to database:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// DeviceCodeByDeviceCode returns device code by its device code value.
+func (dcs *DeviceCodeStorage) DeviceCodeByDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	result, err := dcs.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(deviceCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"device_code": {S: aws.String(deviceCode)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Println("Error getting device code:", err)
+		return model.DeviceCode{}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dcs.unmarshalDeviceCode(result.Item)
+}
+
+// DeviceCodeByUserCode returns device code by the user code.
+func (dcs *DeviceCodeStorage) DeviceCodeByUserCode(userCode string) (model.DeviceCode, error) {
+	result, err := dcs.db.C.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(deviceCodesTableName),
+		IndexName:              aws.String(deviceCodesUserCodeIndexName),
+		KeyConditionExpression: aws.String("user_code = :c"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":c": {S: aws.String(userCode)},
+		},
+	})
+	if err != nil {
+		log.Println("Error querying for device code by user code:", err)
+		return model.DeviceCode{}, ErrorInternalError
+	}
+	if len(result.Items) == 0 {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dcs.unmarshalDeviceCode(result.Items[0])
+}
+
+func (dcs *DeviceCodeStorage) unmarshalDeviceCode(item map[string]*dynamodb.AttributeValue) (model.DeviceCode, error) {
+	dc := model.DeviceCode{}
+	if err := dynamodbattribute.UnmarshalMap(item, &dc); err != nil {
+		log.Println("Error unmarshalling device code:", err)
+		return model.DeviceCode{}, ErrorInternalError
+	}
+
+	// DynamoDB deletes expired items within 48 hours, so we have to check it on our own.
+	if dc.Expired() {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc, nil
+}
+
+// UpdateDeviceCodePolling updates polling state of the device code in the database.
+func (dcs *DeviceCodeStorage) UpdateDeviceCodePolling(deviceCode string, lastPolledAt, interval int64) error {
+	if _, err := dcs.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(deviceCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"device_code": {S: aws.String(deviceCode)},
+		},
+		ConditionExpression: aws.String("attribute_exists(device_code)"),
+		UpdateExpression:    aws.String("SET last_polled_at = :polled, #interval = :interval"),
+		// INTERVAL is a reserved word in DynamoDB.
+		ExpressionAttributeNames: map[string]*string{
+			"#interval": aws.String("interval"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":polled":   {N: aws.String(strconv.FormatInt(lastPolledAt, 10))},
+			":interval": {N: aws.String(strconv.FormatInt(interval, 10))},
+		},
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return model.ErrorNotFound
+		}
+		log.Println("Error updating device code:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// ConsumeDeviceCode removes approved device code from the database and returns it.
+func (dcs *DeviceCodeStorage) ConsumeDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	result, err := dcs.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(deviceCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"device_code": {S: aws.String(deviceCode)},
+		},
+		ConditionExpression: aws.String("#status = :approved"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":approved": {S: aws.String(string(model.DeviceCodeStatusApproved))},
+		},
+		ReturnValues: aws.String("ALL_OLD"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return model.DeviceCode{}, model.ErrorNotFound
+		}
+		log.Println("Error deleting device code:", err)
+		return model.DeviceCode{}, ErrorInternalError
+	}
+	if len(result.Attributes) == 0 {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dcs.unmarshalDeviceCode(result.Attributes)
+}
+
+// DeleteDeviceCode removes device code from the database.
+func (dcs *DeviceCodeStorage) DeleteDeviceCode(deviceCode string) error {
+	if _, err := dcs.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(deviceCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"device_code": {S: aws.String(deviceCode)},
+		},
+	}); err != nil {
+		log.Println("Error deleting device code:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// ensureTable ensures that device code storage table exists in the database.
+func (dcs *DeviceCodeStorage) ensureTable() error {
+	exists, err := dcs.db.IsTableExists(deviceCodesTableName)
+	if err != nil {
+		log.Printf("Error while checking if %s exists: %v", deviceCodesTableName, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("device_code"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("user_code"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("device_code"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(deviceCodesUserCodeIndexName),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String("user_code"),
+						KeyType:       aws.String("HASH"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String("ALL"),
+				},
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(deviceCodesTableName),
+	}
+
+	if _, err = dcs.db.C.CreateTable(input); err != nil {
+		log.Printf("Error while creating %s table: %v", deviceCodesTableName, err)
+		return err
+	}
+
+	if err = dcs.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(deviceCodesTableName),
+	}); err != nil {
+		log.Printf("Error while waiting for %s table: %v", deviceCodesTableName, err)
+		return err
+	}
+
+	if _, err = dcs.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(deviceCodesTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(deviceCodesTTLField),
+			Enabled:       aws.Bool(true),
+		},
+	}); err != nil {
+		log.Printf("Error while setting %s expiration time: %v", deviceCodesTableName, err)
+		return err
+	}
+	return nil
+}
+
+// Close does nothing here.
+func (dcs *DeviceCodeStorage) Close() {}
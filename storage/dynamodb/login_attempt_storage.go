@@ -0,0 +1,157 @@
+package dynamodb
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/model"
+)
+
+// loginAttemptsTableName is a table name for failed login attempts.
+const loginAttemptsTableName = "LoginAttempts"
+
+// NewLoginAttemptStorage creates and provisions new DynamoDB login attempt storage.
+func NewLoginAttemptStorage(db *DB) (model.LoginAttemptStorage, error) {
+	las := &LoginAttemptStorage{db: db}
+	err := las.ensureTable()
+	return las, err
+}
+
+// LoginAttemptStorage is a DynamoDB login attempt storage.
+type LoginAttemptStorage struct {
+	db *DB
+}
+
+// AddFailedLoginAttempt counts failed login attempt in the database.
+// Recent attempts are incremented atomically, stale ones are replaced.
+func (las *LoginAttemptStorage) AddFailedLoginAttempt(key string, resetBefore int64) (model.LoginAttempts, error) {
+	if len(key) == 0 {
+		return model.LoginAttempts{}, model.ErrorWrongDataFormat
+	}
+
+	now := time.Now().Unix()
+	result, err := las.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(loginAttemptsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+		UpdateExpression:    aws.String("ADD failures :one SET last_failed_at = :now"),
+		ConditionExpression: aws.String("last_failed_at >= :reset"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":   {N: aws.String("1")},
+			":now":   {N: aws.String(strconv.FormatInt(now, 10))},
+			":reset": {N: aws.String(strconv.FormatInt(resetBefore, 10))},
+		},
+		ReturnValues: aws.String("ALL_NEW"),
+	})
+	if err == nil {
+		attempts := model.LoginAttempts{}
+		if err = dynamodbattribute.UnmarshalMap(result.Attributes, &attempts); err != nil {
+			log.Println("Error unmarshalling login attempts:", err)
+			return model.LoginAttempts{}, ErrorInternalError
+		}
+		return attempts, nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		log.Println("Error updating login attempts:", err)
+		return model.LoginAttempts{}, ErrorInternalError
+	}
+
+	// No attempts or stale ones, counting starts over.
+	attempts := model.LoginAttempts{Key: key, Failures: 1, LastFailedAt: now}
+	item, err := dynamodbattribute.MarshalMap(attempts)
+	if err != nil {
+		log.Println("Error marshalling login attempts:", err)
+		return model.LoginAttempts{}, ErrorInternalError
+	}
+	if _, err = las.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(loginAttemptsTableName),
+	}); err != nil {
+		log.Println("Error putting login attempts to database:", err)
+		return model.LoginAttempts{}, ErrorInternalError
+	}
+	return attempts, nil
+}
+
+// LoginAttempts returns failed login attempts from the database.
+func (las *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	result, err := las.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(loginAttemptsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Println("Error getting login attempts:", err)
+		return model.LoginAttempts{}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.LoginAttempts{Key: key}, nil
+	}
+
+	attempts := model.LoginAttempts{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &attempts); err != nil {
+		log.Println("Error unmarshalling login attempts:", err)
+		return model.LoginAttempts{}, ErrorInternalError
+	}
+	return attempts, nil
+}
+
+// ResetLoginAttempts removes failed login attempts from the database.
+func (las *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	if _, err := las.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(loginAttemptsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+	}); err != nil {
+		log.Println("Error deleting login attempts:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// ensureTable ensures that login attempt storage table exists in the database.
+func (las *LoginAttemptStorage) ensureTable() error {
+	exists, err := las.db.IsTableExists(loginAttemptsTableName)
+	if err != nil {
+		log.Printf("Error while checking if %s exists: %v", loginAttemptsTableName, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("key"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("key"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(loginAttemptsTableName),
+	}
+
+	if _, err = las.db.C.CreateTable(input); err != nil {
+		log.Printf("Error while creating %s table: %v", loginAttemptsTableName, err)
+		return err
+	}
+	return nil
+}
+
+// Close does nothing here.
+func (las *LoginAttemptStorage) Close() {}
@@ -2,6 +2,8 @@ package dynamodb
 
 import (
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -9,12 +11,19 @@ import (
 	"github.com/madappgang/identifo/model"
 )
 
-const tokensTableName = "RefreshTokens"
+const (
+	tokensTableName = "RefreshTokens"
+	// userTokensRevocationsTableName is a table to store times users' tokens were revoked at.
+	userTokensRevocationsTableName = "UserTokensRevocations"
+)
 
 // NewTokenStorage creates new DynamoDB token storage.
 func NewTokenStorage(db *DB) (model.TokenStorage, error) {
 	ts := &TokenStorage{db: db}
-	err := ts.ensureTable()
+	if err := ts.ensureTable(tokensTableName, "token"); err != nil {
+		return ts, err
+	}
+	err := ts.ensureTable(userTokensRevocationsTableName, "user_id")
 	return ts, err
 }
 
@@ -23,11 +32,11 @@ type TokenStorage struct {
 	db *DB
 }
 
-// ensureTable ensures that token storage exists in the database.
-func (ts *TokenStorage) ensureTable() error {
-	exists, err := ts.db.IsTableExists(tokensTableName)
+// ensureTable ensures that the token storage table exists in the database.
+func (ts *TokenStorage) ensureTable(tableName, hashKey string) error {
+	exists, err := ts.db.IsTableExists(tableName)
 	if err != nil {
-		log.Printf("Error while checking if %s exists: %v", tokensTableName, err)
+		log.Printf("Error while checking if %s exists: %v", tableName, err)
 		return err
 	}
 	if exists {
@@ -37,37 +46,34 @@ func (ts *TokenStorage) ensureTable() error {
 	input := &dynamodb.CreateTableInput{
 		AttributeDefinitions: []*dynamodb.AttributeDefinition{
 			{
-				AttributeName: aws.String("token"),
+				AttributeName: aws.String(hashKey),
 				AttributeType: aws.String("S"),
 			},
 		},
 		KeySchema: []*dynamodb.KeySchemaElement{
 			{
-				AttributeName: aws.String("token"),
+				AttributeName: aws.String(hashKey),
 				KeyType:       aws.String("HASH"),
 			},
 		},
 		BillingMode: aws.String("PAY_PER_REQUEST"),
-		TableName:   aws.String(tokensTableName),
+		TableName:   aws.String(tableName),
 	}
 
 	if _, err = ts.db.C.CreateTable(input); err != nil {
-		log.Printf("Error while creating %s table: %v", tokensTableName, err)
+		log.Printf("Error while creating %s table: %v", tableName, err)
 		return err
 	}
 	return nil
 }
 
 // SaveToken saves token in the database.
-func (ts *TokenStorage) SaveToken(token string) error {
+func (ts *TokenStorage) SaveToken(token string, session model.UserSession) error {
 	if len(token) == 0 {
 		return model.ErrorWrongDataFormat
 	}
-	if ts.HasToken(token) {
-		return nil
-	}
 
-	t, err := dynamodbattribute.MarshalMap(Token{Token: token})
+	t, err := dynamodbattribute.MarshalMap(Token{Token: token, UserSession: session})
 	if err != nil {
 		log.Println(err)
 		return ErrorInternalError
@@ -129,10 +135,187 @@ func (ts *TokenStorage) DeleteToken(token string) error {
 	return nil
 }
 
+// TokenSession returns the session of the token.
+// Tokens saved before sessions were introduced have none.
+func (ts *TokenStorage) TokenSession(token string) (model.UserSession, error) {
+	if len(token) == 0 {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+
+	result, err := ts.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(tokensTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"token": {
+				S: aws.String(token),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Println("Error while fetching token from db:", err)
+		return model.UserSession{}, ErrorInternalError
+	}
+	if result.Item == nil {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+
+	t := Token{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &t); err != nil {
+		log.Println("Error while unmarshalling token:", err)
+		return model.UserSession{}, ErrorInternalError
+	}
+	if len(t.UserSession.ID) == 0 {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return t.UserSession, nil
+}
+
+// TouchToken updates last use of the token session.
+func (ts *TokenStorage) TouchToken(token string, client model.ClientInfo) error {
+	session, err := ts.TokenSession(token)
+	if err != nil {
+		return err
+	}
+	session.Touch(client)
+	return ts.SaveToken(token, session)
+}
+
+// UserSessions returns unexpired sessions of the user.
+// There is no index by user, so the table is scanned.
+func (ts *TokenStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	tokens, err := ts.userSessionTokens(userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []model.UserSession{}
+	for _, t := range tokens {
+		if !t.UserSession.Expired() {
+			sessions = append(sessions, t.UserSession)
+		}
+	}
+	return sessions, nil
+}
+
+// DeleteUserSession removes all tokens of the user's session.
+func (ts *TokenStorage) DeleteUserSession(userID, sessionID string) ([]string, error) {
+	if len(sessionID) == 0 {
+		return nil, model.ErrorNotFound
+	}
+	found, err := ts.userSessionTokens(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, model.ErrorNotFound
+	}
+
+	tokens := make([]string, 0, len(found))
+	for _, t := range found {
+		if err = ts.DeleteToken(t.Token); err != nil && err != model.ErrorNotFound {
+			return nil, err
+		}
+		tokens = append(tokens, t.Token)
+	}
+	return tokens, nil
+}
+
+// RevokeUserTokens removes all refresh tokens of the user and records the time of revocation.
+func (ts *TokenStorage) RevokeUserTokens(userID string) error {
+	tokens, err := ts.userSessionTokens(userID, "")
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if err = ts.DeleteToken(t.Token); err != nil && err != model.ErrorNotFound {
+			return err
+		}
+	}
+
+	if _, err = ts.db.C.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(userTokensRevocationsTableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"user_id":    {S: aws.String(userID)},
+			"revoked_at": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+		},
+	}); err != nil {
+		log.Println("Error while putting user tokens revocation to db:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// UserTokensRevokedAt returns the time tokens of the user were revoked at.
+func (ts *TokenStorage) UserTokensRevokedAt(userID string) int64 {
+	if len(userID) == 0 {
+		return 0
+	}
+
+	result, err := ts.db.C.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(userTokensRevocationsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"user_id": {S: aws.String(userID)},
+		},
+	})
+	if err != nil {
+		log.Println("Error while fetching user tokens revocation from db:", err)
+		return 0
+	}
+	if result.Item == nil || result.Item["revoked_at"] == nil || result.Item["revoked_at"].N == nil {
+		return 0
+	}
+	revokedAt, err := strconv.ParseInt(*result.Item["revoked_at"].N, 10, 64)
+	if err != nil {
+		log.Println("Error while parsing user tokens revocation time:", err)
+		return 0
+	}
+	return revokedAt
+}
+
+// userSessionTokens scans for tokens of the user, if session ID is not empty, only for tokens of this session.
+func (ts *TokenStorage) userSessionTokens(userID, sessionID string) ([]Token, error) {
+	filter := "user_id = :u"
+	values := map[string]*dynamodb.AttributeValue{
+		":u": {S: aws.String(userID)},
+	}
+	var names map[string]*string
+	if len(sessionID) > 0 {
+		filter += " AND #id = :s"
+		values[":s"] = &dynamodb.AttributeValue{S: aws.String(sessionID)}
+		names = map[string]*string{"#id": aws.String("id")}
+	}
+
+	tokens := []Token{}
+	var unmarshalErr error
+	err := ts.db.C.ScanPages(&dynamodb.ScanInput{
+		TableName:                 aws.String(tokensTableName),
+		FilterExpression:          aws.String(filter),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		pageTokens := []Token{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageTokens); unmarshalErr != nil {
+			return false
+		}
+		tokens = append(tokens, pageTokens...)
+		return true
+	})
+	if err != nil {
+		log.Println("Error while scanning for user tokens:", err)
+		return nil, ErrorInternalError
+	}
+	if unmarshalErr != nil {
+		log.Println("Error while unmarshalling user tokens:", unmarshalErr)
+		return nil, ErrorInternalError
+	}
+	return tokens, nil
+}
+
 // Close does nothing here.
 func (ts *TokenStorage) Close() {}
 
 // Token is a struct to store tokens in the database.
 type Token struct {
 	Token string `json:"token,omitempty"`
+	model.UserSession
 }
@@ -2,13 +2,13 @@ package dynamodb
 
 import (
 	"encoding/json"
-	"errors"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/madappgang/identifo/model"
@@ -77,9 +77,40 @@ func (us *UserStorage) UserByID(id string) (model.User, error) {
 }
 
 // UserByEmail returns user by its email.
+// There is no index by email, so the table is scanned.
 func (us *UserStorage) UserByEmail(email string) (model.User, error) {
-	// TODO: implement dynamodb UserByEmail
-	return nil, errors.New("Not implemented. ")
+	if email == "" {
+		return nil, model.ErrorWrongDataFormat
+	}
+
+	var item map[string]*dynamodb.AttributeValue
+	err := us.db.C.ScanPages(&dynamodb.ScanInput{
+		TableName:        aws.String(usersTableName),
+		FilterExpression: aws.String("email = :e"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":e": {S: aws.String(strings.ToLower(email))},
+		},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		if len(page.Items) > 0 {
+			item = page.Items[0]
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		log.Println("Error querying for user by email:", err)
+		return nil, ErrorInternalError
+	}
+	if item == nil {
+		return nil, model.ErrUserNotFound
+	}
+
+	userdata := userData{}
+	if err = dynamodbattribute.UnmarshalMap(item, &userdata); err != nil {
+		log.Println("Error unmarshalling item:", err)
+		return nil, ErrorInternalError
+	}
+	return &User{userData: userdata}, nil
 }
 
 func (us *UserStorage) userIDByFederatedID(provider model.FederatedIdentityProvider, id string) (string, error) {
@@ -352,7 +383,7 @@ func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityPr
 		return nil, err
 	} else if err == model.ErrUserNotFound {
 		// no such user, let's create it
-		uData := userData{Username: fid, AccessRole: role, Active: true}
+		uData := userData{Username: fid, AccessRole: role, Active: true, FederatedIDs: []string{fid}}
 		u, creationErr := us.AddNewUser(&User{userData: uData}, "")
 		if creationErr != nil {
 			log.Println("Error adding new user:", creationErr)
@@ -385,10 +416,106 @@ func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityPr
 		return nil, ErrorInternalError
 	}
 
-	udata := userData{ID: user.ID, Username: user.Username, Active: true}
+	udata := userData{ID: user.ID, Username: user.Username, Active: true, FederatedIDs: []string{fid}}
 	return &User{userData: udata}, nil
 }
 
+// LinkFederatedID links one more federated identity to the user.
+func (us *UserStorage) LinkFederatedID(userID string, provider model.FederatedIdentityProvider, federatedID string) error {
+	linkedUserID, err := us.userIDByFederatedID(provider, federatedID)
+	if err == nil {
+		if linkedUserID == userID {
+			return nil
+		}
+		return model.ErrorUserExists
+	}
+	if err != model.ErrUserNotFound {
+		return err
+	}
+	if _, err = us.UserByID(userID); err != nil {
+		return err
+	}
+
+	fid := model.FederatedIDString(provider, federatedID)
+	fedInputData, err := dynamodbattribute.MarshalMap(federatedUserID{FederatedID: fid, UserID: userID})
+	if err != nil {
+		log.Println("Error marshalling federated data:", err)
+		return ErrorInternalError
+	}
+	if _, err = us.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:      fedInputData,
+		TableName: aws.String(usersFederatedIDTableName),
+		// The identity may have been linked to someone else in the meantime.
+		ConditionExpression: aws.String("attribute_not_exists(federated_id)"),
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return model.ErrorUserExists
+		}
+		log.Println("Error putting item:", err)
+		return ErrorInternalError
+	}
+
+	return us.updateFederatedIDs(userID, func(fids []string) []string {
+		return append(fids, fid)
+	})
+}
+
+// UnlinkFederatedID unlinks federated identity from the user.
+func (us *UserStorage) UnlinkFederatedID(userID string, provider model.FederatedIdentityProvider, federatedID string) error {
+	fid := model.FederatedIDString(provider, federatedID)
+	if _, err := us.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(usersFederatedIDTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"federated_id": {S: aws.String(fid)},
+		},
+		ConditionExpression:       aws.String("user_id = :u"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":u": {S: aws.String(userID)}},
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return model.ErrorNotFound
+		}
+		log.Println("Error deleting item:", err)
+		return ErrorInternalError
+	}
+
+	return us.updateFederatedIDs(userID, func(fids []string) []string {
+		res := []string{}
+		for _, f := range fids {
+			if f != fid {
+				res = append(res, f)
+			}
+		}
+		return res
+	})
+}
+
+// updateFederatedIDs keeps the list of user's federated IDs in sync with the federated ID table.
+func (us *UserStorage) updateFederatedIDs(userID string, update func([]string) []string) error {
+	user, err := us.UserByID(userID)
+	if err != nil {
+		return err
+	}
+	fids, err := dynamodbattribute.Marshal(update(user.(*User).userData.FederatedIDs))
+	if err != nil {
+		log.Println("Error marshalling federated IDs:", err)
+		return ErrorInternalError
+	}
+
+	if _, err = us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(usersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(userID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":f": fids},
+		UpdateExpression:          aws.String("set federated_ids = :f"),
+		ReturnValues:              aws.String("NONE"),
+	}); err != nil {
+		log.Println("Error updating federated IDs:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
 // AddUserByPhone registers new user with phone number.
 func (us *UserStorage) AddUserByPhone(phone, role string) (model.User, error) {
 	_, err := us.userIdxByPhone(phone)
@@ -410,6 +537,28 @@ func (us *UserStorage) AddUserByPhone(phone, role string) (model.User, error) {
 	return us.AddNewUser(&User{userData: u}, "")
 }
 
+// AddUserByEmail registers new user with email.
+func (us *UserStorage) AddUserByEmail(email, role string) (model.User, error) {
+	email = strings.ToLower(email)
+	_, err := us.UserByEmail(email)
+	if err != nil && err != model.ErrUserNotFound {
+		log.Println(err)
+		return nil, err
+	} else if err == nil {
+		return nil, model.ErrorUserExists
+	}
+
+	u := userData{
+		ID:          xid.New().String(),
+		Username:    email,
+		Email:       email,
+		Active:      true,
+		AccessRole:  role,
+		NumOfLogins: 0,
+	}
+	return us.AddNewUser(&User{userData: u}, "")
+}
+
 // UpdateUser updates user in DynamoDB storage.
 func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User, error) {
 	if _, err := xid.FromString(userID); err != nil {
@@ -441,6 +590,134 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 	return updatedUser, err
 }
 
+// tfaUpdateAttempts limits retries when TFA info of the user is changed concurrently.
+const tfaUpdateAttempts = 3
+
+// ConsumeRecoveryCode removes TFA recovery code hash from the user.
+// Codes are replaced only if nobody has changed them since they were read, so each code is consumed once.
+func (us *UserStorage) ConsumeRecoveryCode(userID, codeHash string) error {
+	for i := 0; i < tfaUpdateAttempts; i++ {
+		user, err := us.UserByID(userID)
+		if err != nil {
+			return err
+		}
+		old := user.(*User).userData.TFAInfo.RecoveryCodes
+
+		codes := []string{}
+		for _, h := range old {
+			if h != codeHash {
+				codes = append(codes, h)
+			}
+		}
+		if len(codes) == len(old) {
+			return model.ErrorNotFound
+		}
+
+		replaced, err := us.replaceTFAField(userID, "recovery_codes", old, codes)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			return nil
+		}
+	}
+	return model.ErrorNotFound
+}
+
+// SetRecoveryCodes replaces TFA recovery code hashes of the user.
+func (us *UserStorage) SetRecoveryCodes(userID string, codeHashes []string) error {
+	codes, err := dynamodbattribute.Marshal(codeHashes)
+	if err != nil {
+		log.Println("Error marshalling recovery codes:", err)
+		return ErrorInternalError
+	}
+
+	if _, err = us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(usersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(userID)},
+		},
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+		UpdateExpression:          aws.String("set #tfa.#codes = :codes, #tfa.#issued = :issued"),
+		ExpressionAttributeNames:  map[string]*string{"#tfa": aws.String("tfa_info"), "#codes": aws.String("recovery_codes"), "#issued": aws.String("recovery_codes_issued")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":codes": codes, ":issued": {BOOL: aws.Bool(true)}},
+		ReturnValues:              aws.String("NONE"),
+	}); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return model.ErrUserNotFound
+		}
+		log.Println("Error setting recovery codes:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// AddTrustedDevice remembers the device in TFA info of the user.
+// Devices are replaced only if nobody has changed them since they were read, so concurrent changes are not lost.
+func (us *UserStorage) AddTrustedDevice(userID string, device model.TrustedDevice) error {
+	for i := 0; i < tfaUpdateAttempts; i++ {
+		user, err := us.UserByID(userID)
+		if err != nil {
+			return err
+		}
+		tfaInfo := user.(*User).userData.TFAInfo
+		old := tfaInfo.TrustedDevices
+		tfaInfo.AddTrustedDevice(device)
+
+		replaced, err := us.replaceTFAField(userID, "trusted_devices", old, tfaInfo.TrustedDevices)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			return nil
+		}
+	}
+	return ErrorInternalError
+}
+
+// replaceTFAField sets the field of user's TFA info to the new value, if it still has the old one.
+// It returns false if the field has been changed concurrently.
+func (us *UserStorage) replaceTFAField(userID, field string, old, new interface{}) (bool, error) {
+	oldValue, err := dynamodbattribute.Marshal(old)
+	if err != nil {
+		log.Println("Error marshalling TFA info:", err)
+		return false, ErrorInternalError
+	}
+	newValue, err := dynamodbattribute.Marshal(new)
+	if err != nil {
+		log.Println("Error marshalling TFA info:", err)
+		return false, ErrorInternalError
+	}
+
+	condition := "#tfa.#field = :old"
+	values := map[string]*dynamodb.AttributeValue{":old": oldValue, ":new": newValue}
+	if oldValue.NULL != nil || (oldValue.L != nil && len(oldValue.L) == 0) {
+		// Empty list is read from the missing, null or empty attribute alike.
+		condition = "attribute_not_exists(#tfa.#field) OR attribute_type(#tfa.#field, :null) OR size(#tfa.#field) = :zero"
+		values = map[string]*dynamodb.AttributeValue{":new": newValue, ":null": {S: aws.String("NULL")}, ":zero": {N: aws.String("0")}}
+	}
+
+	_, err = us.db.C.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(usersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(userID)},
+		},
+		ConditionExpression:       aws.String(condition),
+		UpdateExpression:          aws.String("set #tfa.#field = :new"),
+		ExpressionAttributeNames:  map[string]*string{"#tfa": aws.String("tfa_info"), "#field": aws.String(field)},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String("NONE"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		log.Println("Error updating TFA info:", err)
+		return false, ErrorInternalError
+	}
+	return true, nil
+}
+
 // ResetPassword sets new user password.
 func (us *UserStorage) ResetPassword(id, password string) error {
 	idx, err := xid.FromString(id)
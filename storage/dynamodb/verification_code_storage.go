@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/madappgang/identifo/model"
@@ -17,9 +18,10 @@ const (
 	// verificationCodesExpirationTime specifies time before deleting records.
 	verificationCodesExpirationTime = 5 * time.Minute
 
-	phoneField     = "phone"
-	codeField      = "code"
-	expiresAtField = "expiresAt"
+	// identifierField keeps its historical name, as codes used to be sent to phone numbers only.
+	identifierField = "phone"
+	codeField       = "code"
+	expiresAtField  = "expiresAt"
 )
 
 // NewVerificationCodeStorage creates and provisions new DynamoDB verification code storage.
@@ -35,31 +37,36 @@ type VerificationCodeStorage struct {
 }
 
 // IsVerificationCodeFound checks whether verification code can be found.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
-	result, err := vcs.db.C.Query(&dynamodb.QueryInput{
-		TableName:              aws.String(verificationCodesTableName),
-		KeyConditionExpression: aws.String("phone = :phone"),
+// Found code is deleted, so it cannot be used twice.
+func (vcs *VerificationCodeStorage) IsVerificationCodeFound(identifier, code string) (bool, error) {
+	result, err := vcs.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(verificationCodesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			identifierField: {S: aws.String(identifier)},
+		},
+		ConditionExpression: aws.String("code = :code"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":phone": {S: aws.String(phone)},
+			":code": {S: aws.String(code)},
 		},
+		ReturnValues: aws.String("ALL_OLD"),
 	})
 
 	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
 		log.Println("Error querying for verification code:", err)
 		return false, ErrorInternalError
 	}
-	if len(result.Items) == 0 {
-		return false, nil
-	}
-	return true, nil
+	return len(result.Attributes) > 0, nil
 }
 
 // CreateVerificationCode inserts new verification code to the database.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
+func (vcs *VerificationCodeStorage) CreateVerificationCode(identifier, code string) error {
 	// Remove old item first.
 	delInput := &dynamodb.DeleteItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
-			phoneField: {S: aws.String(phone)},
+			identifierField: {S: aws.String(identifier)},
 		},
 		TableName: aws.String(verificationCodesTableName),
 	}
@@ -71,9 +78,9 @@ func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) e
 
 	// Then put a new one.
 	item, err := dynamodbattribute.MarshalMap(map[string]interface{}{
-		phoneField:     phone,
-		codeField:      code,
-		expiresAtField: time.Now().Add(verificationCodesExpirationTime),
+		identifierField: identifier,
+		codeField:       code,
+		expiresAtField:  time.Now().Add(verificationCodesExpirationTime),
 	})
 	if err != nil {
 		log.Println("Error marshalling verification code:", err)
@@ -106,13 +113,13 @@ func (vcs *VerificationCodeStorage) ensureTable() error {
 	createTableInput := &dynamodb.CreateTableInput{
 		AttributeDefinitions: []*dynamodb.AttributeDefinition{
 			{
-				AttributeName: aws.String(phoneField),
+				AttributeName: aws.String(identifierField),
 				AttributeType: aws.String("S"),
 			},
 		},
 		KeySchema: []*dynamodb.KeySchemaElement{
 			{
-				AttributeName: aws.String(phoneField),
+				AttributeName: aws.String(identifierField),
 				KeyType:       aws.String("HASH"),
 			},
 		},
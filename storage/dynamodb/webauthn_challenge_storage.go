@@ -0,0 +1,137 @@
+package dynamodb
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// webauthnChallengesTableName is a table name for WebAuthn challenges.
+	webauthnChallengesTableName = "WebauthnChallenges"
+	// webauthnChallengesTTLField is an attribute used by DynamoDB to remove expired challenges.
+	webauthnChallengesTTLField = "expires_at"
+)
+
+// NewWebauthnChallengeStorage creates and provisions new DynamoDB WebAuthn challenge storage.
+func NewWebauthnChallengeStorage(db *DB) (model.WebauthnChallengeStorage, error) {
+	wcs := &WebauthnChallengeStorage{db: db}
+	err := wcs.ensureTable()
+	return wcs, err
+}
+
+// WebauthnChallengeStorage is a DynamoDB WebAuthn challenge storage.
+type WebauthnChallengeStorage struct {
+	db *DB
+}
+
+// SaveWebauthnChallenge saves WebAuthn challenge in the database.
+func (wcs *WebauthnChallengeStorage) SaveWebauthnChallenge(challenge model.WebauthnChallenge) error {
+	if len(challenge.Challenge) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+
+	item, err := dynamodbattribute.MarshalMap(challenge)
+	if err != nil {
+		log.Println("Error marshalling WebAuthn challenge:", err)
+		return ErrorInternalError
+	}
+
+	if _, err = wcs.db.C.PutItem(&dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(webauthnChallengesTableName),
+	}); err != nil {
+		log.Println("Error putting WebAuthn challenge to database:", err)
+		return ErrorInternalError
+	}
+	return nil
+}
+
+// ConsumeWebauthnChallenge returns WebAuthn challenge and removes it from the database.
+func (wcs *WebauthnChallengeStorage) ConsumeWebauthnChallenge(challenge string) (model.WebauthnChallenge, error) {
+	result, err := wcs.db.C.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(webauthnChallengesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"challenge": {S: aws.String(challenge)},
+		},
+		ReturnValues: aws.String("ALL_OLD"),
+	})
+	if err != nil {
+		log.Println("Error deleting WebAuthn challenge:", err)
+		return model.WebauthnChallenge{}, ErrorInternalError
+	}
+	if len(result.Attributes) == 0 {
+		return model.WebauthnChallenge{}, model.ErrorNotFound
+	}
+
+	wc := model.WebauthnChallenge{}
+	if err = dynamodbattribute.UnmarshalMap(result.Attributes, &wc); err != nil {
+		log.Println("Error unmarshalling WebAuthn challenge:", err)
+		return model.WebauthnChallenge{}, ErrorInternalError
+	}
+
+	// DynamoDB deletes expired items within 48 hours, so we have to check it on our own.
+	if wc.Expired() {
+		return model.WebauthnChallenge{}, model.ErrorNotFound
+	}
+	return wc, nil
+}
+
+// ensureTable ensures that WebAuthn challenge storage table exists in the database.
+func (wcs *WebauthnChallengeStorage) ensureTable() error {
+	exists, err := wcs.db.IsTableExists(webauthnChallengesTableName)
+	if err != nil {
+		log.Printf("Error while checking if %s exists: %v", webauthnChallengesTableName, err)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("challenge"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("challenge"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+		TableName:   aws.String(webauthnChallengesTableName),
+	}
+
+	if _, err = wcs.db.C.CreateTable(input); err != nil {
+		log.Printf("Error while creating %s table: %v", webauthnChallengesTableName, err)
+		return err
+	}
+
+	if err = wcs.db.C.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(webauthnChallengesTableName),
+	}); err != nil {
+		log.Printf("Error while waiting for %s table: %v", webauthnChallengesTableName, err)
+		return err
+	}
+
+	if _, err = wcs.db.C.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(webauthnChallengesTableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(webauthnChallengesTTLField),
+			Enabled:       aws.Bool(true),
+		},
+	}); err != nil {
+		log.Printf("Error while setting %s expiration time: %v", webauthnChallengesTableName, err)
+		return err
+	}
+	return nil
+}
+
+// Close does nothing here.
+func (wcs *WebauthnChallengeStorage) Close() {}
@@ -0,0 +1,218 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// LDAP messages are encoded with Basic Encoding Rules of ASN.1, see RFC 4511, section 5.1.
+// Directory servers use BER features encoding/asn1 rejects, e.g. Active Directory encodes lengths in non-minimal form,
+// so messages are encoded and decoded here. LDAP tags all fit in a single identifier octet.
+
+const (
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10 | constructed
+	tagSet         = 0x11 | constructed
+
+	// maxPacketSize limits the size of the message we read, to protect from misbehaving servers.
+	maxPacketSize = 16 << 20
+)
+
+var errMalformedPacket = errors.New("ldap: malformed BER packet")
+
+// packet is BER encoded value, either primitive with its contents or constructed of other packets.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func newPacket(tag byte, value []byte) *packet {
+	return &packet{tag: tag, value: value}
+}
+
+func newConstructed(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag | constructed, children: children}
+}
+
+func newString(tag byte, s string) *packet {
+	return newPacket(tag, []byte(s))
+}
+
+func newInteger(tag byte, i int64) *packet {
+	// Two's complement in the minimal number of octets.
+	var b []byte
+	for {
+		b = append([]byte{byte(i)}, b...)
+		if i < 128 && i >= -128 {
+			break
+		}
+		i >>= 8
+	}
+	return newPacket(tag, b)
+}
+
+func newBoolean(v bool) *packet {
+	if v {
+		return newPacket(tagBoolean, []byte{0xff})
+	}
+	return newPacket(tagBoolean, []byte{0x00})
+}
+
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+// bytes encodes the packet with definite lengths.
+func (p *packet) bytes() []byte {
+	value := p.value
+	if p.isConstructed() {
+		value = nil
+		for _, c := range p.children {
+			value = append(value, c.bytes()...)
+		}
+	}
+
+	res := []byte{p.tag}
+	if l := len(value); l < 0x80 {
+		res = append(res, byte(l))
+	} else {
+		var lb []byte
+		for ; l > 0; l >>= 8 {
+			lb = append([]byte{byte(l)}, lb...)
+		}
+		res = append(res, 0x80|byte(len(lb)))
+		res = append(res, lb...)
+	}
+	return append(res, value...)
+}
+
+func (p *packet) str() string {
+	return string(p.value)
+}
+
+func (p *packet) integer() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errMalformedPacket
+	}
+	i := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		i = i<<8 | int64(b)
+	}
+	return i, nil
+}
+
+// child returns the child packet, or nil if there are fewer children.
+func (p *packet) child(i int) *packet {
+	if i < len(p.children) {
+		return p.children[i]
+	}
+	return nil
+}
+
+// readPacket reads the whole packet from the stream.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, length)
+	if _, err = io.ReadFull(r, value); err != nil {
+		return nil, err
+	}
+	return parseValue(tag, value)
+}
+
+// parsePackets parses contents of constructed packet.
+func parsePackets(data []byte) ([]*packet, error) {
+	var res []*packet
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errMalformedPacket
+		}
+		tag := data[0]
+		data = data[1:]
+
+		length := int(data[0])
+		data = data[1:]
+		if length&0x80 != 0 {
+			n := length & 0x7f
+			if n == 0 || n > 4 || len(data) < n {
+				return nil, errMalformedPacket
+			}
+			length = 0
+			for _, b := range data[:n] {
+				length = length<<8 | int(b)
+			}
+			data = data[n:]
+		}
+		if length < 0 || length > len(data) {
+			return nil, errMalformedPacket
+		}
+
+		p, err := parseValue(tag, data[:length])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+		data = data[length:]
+	}
+	return res, nil
+}
+
+func parseValue(tag byte, value []byte) (*packet, error) {
+	// High tag numbers are never used in LDAP.
+	if tag&0x1f == 0x1f {
+		return nil, errMalformedPacket
+	}
+	p := &packet{tag: tag}
+	if !p.isConstructed() {
+		p.value = value
+		return p, nil
+	}
+	children, err := parsePackets(value)
+	if err != nil {
+		return nil, err
+	}
+	p.children = children
+	return p, nil
+}
+
+// readLength reads definite length, indefinite form is not allowed in LDAP.
+func readLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b&0x80 == 0 {
+		return int(b), nil
+	}
+
+	n := int(b & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, errMalformedPacket
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxPacketSize {
+		return 0, errMalformedPacket
+	}
+	return length, nil
+}
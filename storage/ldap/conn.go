@@ -0,0 +1,278 @@
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Protocol operations, see RFC 4511, section 4.2 and further.
+const (
+	opBindRequest         = classApplication | constructed | 0
+	opBindResponse        = classApplication | constructed | 1
+	opUnbindRequest       = classApplication | 2
+	opSearchRequest       = classApplication | constructed | 3
+	opSearchResultEntry   = classApplication | constructed | 4
+	opSearchResultDone    = classApplication | constructed | 5
+	opSearchResultRef     = classApplication | constructed | 19
+	opExtendedRequest     = classApplication | constructed | 23
+	opExtendedResponse    = classApplication | constructed | 24
+	authSimple            = classContext | 0
+	extendedRequestName   = classContext | 0
+	oidStartTLS           = "1.3.6.1.4.1.1466.20037"
+	scopeWholeSubtree     = 2
+	derefAliasesNever     = 0
+	resultSuccess         = 0
+	resultInvalidCreds    = 49
+	defaultOperationLimit = 15 * time.Second
+)
+
+// ResultError is an operation result other than success.
+type ResultError struct {
+	Code    int64
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// entry is a search result entry, attribute names are in lower case, as they are case-insensitive.
+type entry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// first returns the first value of the attribute.
+func (e entry) first(attr string) string {
+	if values := e.attrs[strings.ToLower(attr)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// conn is a connection to the directory server. Operations are performed one at a time.
+type conn struct {
+	c         net.Conn
+	r         *bufio.Reader
+	messageID int64
+	timeout   time.Duration
+}
+
+// dial connects to ldap:// or ldaps:// URL. Plain ldap:// connections are upgraded to TLS with StartTLS if startTLS is set.
+// Server certificates are verified against rootCAs, or against the system roots if it is nil.
+func dial(endpoint string, startTLS bool, rootCAs *x509.CertPool, timeout time.Duration) (*conn, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), RootCAs: rootCAs}
+
+	var c net.Conn
+	dialer := &net.Dialer{Timeout: timeout}
+	switch u.Scheme {
+	case "ldap":
+		c, err = dialer.Dial("tcp", hostPort(u, "389"))
+	case "ldaps":
+		if startTLS {
+			return nil, fmt.Errorf("ldap: StartTLS can't be used with ldaps:// connections")
+		}
+		c, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "636"), tlsConfig)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lc := &conn{c: c, r: bufio.NewReader(c), timeout: timeout}
+	if startTLS {
+		if err = lc.startTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return lc, nil
+}
+
+// startTLS upgrades the connection to TLS, see RFC 4511, section 4.14.
+// Nothing else must be sent on the connection before, so no credentials go over it in plain text.
+func (c *conn) startTLS(config *tls.Config) error {
+	id, err := c.send(newConstructed(opExtendedRequest, newString(extendedRequestName, oidStartTLS)))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != opExtendedResponse {
+		return fmt.Errorf("ldap: unexpected response to StartTLS request")
+	}
+	if err = result(op); err != nil {
+		return err
+	}
+
+	tc := tls.Client(c.c, config)
+	tc.SetDeadline(time.Now().Add(c.timeout))
+	if err = tc.Handshake(); err != nil {
+		return err
+	}
+	c.c, c.r = tc, bufio.NewReader(tc)
+	return nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// close unbinds and closes the connection.
+func (c *conn) close() {
+	c.send(newPacket(opUnbindRequest, nil))
+	c.c.Close()
+}
+
+// bind authenticates with simple bind.
+// Empty password must be checked by the caller, servers treat such binds as anonymous ones and let them succeed.
+func (c *conn) bind(dn, password string) error {
+	id, err := c.send(newConstructed(opBindRequest,
+		newInteger(tagInteger, 3),
+		newString(tagOctetString, dn),
+		newString(authSimple, password),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != opBindResponse {
+		return fmt.Errorf("ldap: unexpected response to bind request")
+	}
+	return result(op)
+}
+
+// search finds entries in the whole subtree of the base, returning the requested attributes.
+func (c *conn) search(baseDN, filter string, attrs []string, sizeLimit int64) ([]entry, error) {
+	f, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := newConstructed(tagSequence)
+	for _, a := range attrs {
+		attributes.children = append(attributes.children, newString(tagOctetString, a))
+	}
+	id, err := c.send(newConstructed(opSearchRequest,
+		newString(tagOctetString, baseDN),
+		newInteger(tagEnumerated, scopeWholeSubtree),
+		newInteger(tagEnumerated, derefAliasesNever),
+		newInteger(tagInteger, sizeLimit),
+		newInteger(tagInteger, int64(c.timeout/time.Second)),
+		newBoolean(false),
+		f,
+		attributes,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchResultEntry:
+			e, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case opSearchResultRef:
+			// Referrals to other servers are not followed.
+		case opSearchResultDone:
+			return entries, result(op)
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response to search request")
+		}
+	}
+}
+
+func (c *conn) send(op *packet) (int64, error) {
+	c.messageID++
+	msg := newConstructed(tagSequence, newInteger(tagInteger, c.messageID), op)
+	c.c.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.c.Write(msg.bytes())
+	return c.messageID, err
+}
+
+// receive reads the response to the message and returns its protocol operation.
+func (c *conn) receive(messageID int64) (*packet, error) {
+	c.c.SetDeadline(time.Now().Add(c.timeout))
+	msg, err := readPacket(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if msg.tag != tagSequence || len(msg.children) < 2 {
+		return nil, errMalformedPacket
+	}
+	id, err := msg.children[0].integer()
+	if err != nil {
+		return nil, err
+	}
+	op := msg.children[1]
+
+	// Unsolicited notification, the server is about to close the connection.
+	if id == 0 && op.tag == opExtendedResponse {
+		return nil, result(op)
+	}
+	if id != messageID {
+		return nil, fmt.Errorf("ldap: unexpected message ID %d", id)
+	}
+	return op, nil
+}
+
+// result reads LDAPResult of the response.
+func result(op *packet) error {
+	if len(op.children) < 3 {
+		return errMalformedPacket
+	}
+	code, err := op.children[0].integer()
+	if err != nil {
+		return err
+	}
+	if code != resultSuccess {
+		return &ResultError{Code: code, Message: op.children[2].str()}
+	}
+	return nil
+}
+
+func parseEntry(op *packet) (entry, error) {
+	e := entry{attrs: make(map[string][]string)}
+	if len(op.children) < 2 {
+		return e, errMalformedPacket
+	}
+	e.dn = op.children[0].str()
+	for _, attr := range op.children[1].children {
+		if len(attr.children) < 2 {
+			return e, errMalformedPacket
+		}
+		name := strings.ToLower(attr.children[0].str())
+		for _, v := range attr.children[1].children {
+			e.attrs[name] = append(e.attrs[name], v.str())
+		}
+	}
+	return e, nil
+}
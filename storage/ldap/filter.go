@@ -0,0 +1,224 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choices, see RFC 4511, section 4.5.1.7.
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEqualityMatch  = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+	filterApproxMatch    = classContext | constructed | 8
+	filterExtensible     = classContext | constructed | 9
+
+	substringInitial = classContext | 0
+	substringAny     = classContext | 1
+	substringFinal   = classContext | 2
+
+	matchingRule      = classContext | 1
+	matchingRuleType  = classContext | 2
+	matchingRuleValue = classContext | 3
+	matchingRuleDN    = classContext | 4
+)
+
+// escapeFilter escapes the value for use in the filter, see RFC 4515, section 3.
+func escapeFilter(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&sb, "\\%02x", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// compileFilter encodes string representation of the filter, see RFC 4515.
+// Extensible match is not supported.
+func compileFilter(filter string) (*packet, error) {
+	p, rest, err := parseFilter(strings.TrimSpace(filter))
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: unexpected %q after filter", rest)
+	}
+	return p, nil
+}
+
+func parseFilter(s string) (*packet, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, s, fmt.Errorf("ldap: filter must start with '(': %q", s)
+	}
+	s = s[1:]
+
+	var p *packet
+	var err error
+	switch {
+	case strings.HasPrefix(s, "&"), strings.HasPrefix(s, "|"):
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		p = newConstructed(tag)
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			var child *packet
+			if child, s, err = parseFilter(s); err != nil {
+				return nil, s, err
+			}
+			p.children = append(p.children, child)
+		}
+	case strings.HasPrefix(s, "!"):
+		var child *packet
+		if child, s, err = parseFilter(s[1:]); err != nil {
+			return nil, s, err
+		}
+		p = newConstructed(filterNot, child)
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, s, fmt.Errorf("ldap: unterminated filter")
+		}
+		if p, err = parseItem(s[:end]); err != nil {
+			return nil, s, err
+		}
+		s = s[end:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, s, fmt.Errorf("ldap: filter must end with ')'")
+	}
+	return p, s[1:], nil
+}
+
+// parseItem parses simple, presence, substring and extensible match filters.
+func parseItem(item string) (*packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+	attr, value := item[:eq], item[eq+1:]
+
+	tag := byte(filterEqualityMatch)
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = filterGreaterOrEqual, attr[:len(attr)-1]
+	case '<':
+		tag, attr = filterLessOrEqual, attr[:len(attr)-1]
+	case '~':
+		tag, attr = filterApproxMatch, attr[:len(attr)-1]
+	case ':':
+		return parseExtensible(item, attr[:len(attr)-1], value)
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+
+	if tag == filterEqualityMatch && value == "*" {
+		return newString(filterPresent, attr), nil
+	}
+
+	// Unescaped asterisks split the value into substrings.
+	parts := strings.Split(value, "*")
+	if len(parts) > 1 && tag != filterEqualityMatch {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+	for i, part := range parts {
+		unescaped, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = unescaped
+	}
+
+	if len(parts) == 1 {
+		return newConstructed(tag, newString(tagOctetString, attr), newString(tagOctetString, parts[0])), nil
+	}
+
+	substrings := newConstructed(tagSequence)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		t := byte(substringAny)
+		if i == 0 {
+			t = substringInitial
+		} else if i == len(parts)-1 {
+			t = substringFinal
+		}
+		substrings.children = append(substrings.children, newString(t, part))
+	}
+	return newConstructed(filterSubstrings, newString(tagOctetString, attr), substrings), nil
+}
+
+// parseExtensible parses extensible match filters, like Active Directory bitwise ones
+// (userAccountControl:1.2.840.113556.1.4.803:=2), the attribute description is attr[:dn][:rule].
+func parseExtensible(item, attr, value string) (*packet, error) {
+	parts := strings.Split(attr, ":")
+	attrType, rule, dnAttributes := parts[0], "", false
+	for _, part := range parts[1:] {
+		switch {
+		case strings.EqualFold(part, "dn") && !dnAttributes && rule == "":
+			dnAttributes = true
+		case part != "" && rule == "":
+			rule = part
+		default:
+			return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+		}
+	}
+	// Either the attribute or the matching rule must be set.
+	if attrType == "" && rule == "" {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+	matchValue, err := unescapeFilter(value)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newConstructed(filterExtensible)
+	if rule != "" {
+		p.children = append(p.children, newString(matchingRule, rule))
+	}
+	if attrType != "" {
+		p.children = append(p.children, newString(matchingRuleType, attrType))
+	}
+	p.children = append(p.children, newString(matchingRuleValue, matchValue))
+	if dnAttributes {
+		p.children = append(p.children, newPacket(matchingRuleDN, []byte{0xff}))
+	}
+	return p, nil
+}
+
+func unescapeFilter(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		b, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		sb.Write(b)
+		i += 2
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,389 @@
+package ldap
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is an in-process LDAP server with simple bind and search, enough to stand in for a directory in tests.
+// Passwords are kept in userPassword attributes in plain text.
+type testServer struct {
+	t       *testing.T
+	ln      net.Listener
+	entries []entry
+	// tlsConfig is used for StartTLS, with a self-signed certificate for 127.0.0.1 the roots trust.
+	tlsConfig *tls.Config
+	roots     *x509.CertPool
+
+	mu sync.Mutex
+	// binds are DNs of all bind requests, successful or not.
+	binds []string
+	// searches is the number of search requests.
+	searches int
+	// anonymousSearch allows searching without binding as someone first.
+	anonymousSearch bool
+	// requireTLS rejects binds and searches before StartTLS, like directories requiring confidentiality do.
+	requireTLS bool
+}
+
+func newTestServer(t *testing.T, entries ...entry) *testServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	s := &testServer{t: t, ln: ln, entries: entries}
+	s.tlsConfig, s.roots = testCertificate(t)
+	go s.serve()
+	return s
+}
+
+// testCertificate returns TLS config with a self-signed certificate for 127.0.0.1, and the pool trusting it.
+func testCertificate(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unable to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Unable to parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, roots
+}
+
+func (s *testServer) URL() string {
+	return "ldap://" + s.ln.Addr().String()
+}
+
+func (s *testServer) Close() {
+	s.ln.Close()
+}
+
+func (s *testServer) bindDNs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *testServer) searchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.searches
+}
+
+func (s *testServer) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *testServer) handle(c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	bound := ""
+	encrypted := false
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		id, op := msg.child(0), msg.child(1)
+		if id == nil || op == nil {
+			return
+		}
+		messageID, _ := id.integer()
+
+		reply := func(resp *packet) {
+			c.Write(newConstructed(tagSequence, newInteger(tagInteger, messageID), resp).bytes())
+		}
+		ldapResult := func(tag byte, code int64, message string) *packet {
+			return newConstructed(tag,
+				newInteger(tagEnumerated, code),
+				newString(tagOctetString, ""),
+				newString(tagOctetString, message),
+			)
+		}
+
+		s.mu.Lock()
+		requireTLS := s.requireTLS
+		s.mu.Unlock()
+
+		switch op.tag {
+		case opExtendedRequest:
+			if op.child(0).str() != oidStartTLS || encrypted {
+				reply(ldapResult(opExtendedResponse, 2, "unsupported extended operation"))
+				continue
+			}
+			reply(ldapResult(opExtendedResponse, resultSuccess, ""))
+			tc := tls.Server(c, s.tlsConfig)
+			if err = tc.Handshake(); err != nil {
+				return
+			}
+			c, r, encrypted = tc, bufio.NewReader(tc), true
+
+		case opBindRequest:
+			dn, password := op.child(1).str(), op.child(2).str()
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+
+			if requireTLS && !encrypted {
+				reply(ldapResult(opBindResponse, 13, "confidentiality required"))
+				continue
+			}
+
+			if dn == "" && password == "" {
+				bound = ""
+				reply(ldapResult(opBindResponse, resultSuccess, ""))
+				continue
+			}
+			e, ok := s.entry(dn)
+			if !ok || password == "" || e.first("userPassword") != password {
+				reply(ldapResult(opBindResponse, resultInvalidCreds, "invalid credentials"))
+				continue
+			}
+			bound = e.dn
+			reply(ldapResult(opBindResponse, resultSuccess, ""))
+
+		case opSearchRequest:
+			s.mu.Lock()
+			s.searches++
+			anonymousSearch := s.anonymousSearch
+			s.mu.Unlock()
+
+			if bound == "" && !anonymousSearch {
+				reply(ldapResult(opSearchResultDone, 50, "insufficient access rights"))
+				continue
+			}
+			base := strings.ToLower(op.child(0).str())
+			sizeLimit, _ := op.child(3).integer()
+			filter := op.child(6)
+
+			var found []entry
+			for _, e := range s.directory() {
+				if strings.HasSuffix(strings.ToLower(e.dn), base) && s.match(filter, e) {
+					found = append(found, e)
+				}
+			}
+			if sizeLimit > 0 && int64(len(found)) > sizeLimit {
+				found = found[:sizeLimit]
+				for _, e := range found {
+					reply(s.entryPacket(e, op.child(7)))
+				}
+				reply(ldapResult(opSearchResultDone, resultSizeLimitExceeded, "size limit exceeded"))
+				continue
+			}
+			for _, e := range found {
+				reply(s.entryPacket(e, op.child(7)))
+			}
+			reply(ldapResult(opSearchResultDone, resultSuccess, ""))
+
+		case opUnbindRequest:
+			return
+
+		default:
+			s.t.Errorf("Unexpected LDAP operation %#x", op.tag)
+			return
+		}
+	}
+}
+
+// directory returns the current entries.
+func (s *testServer) directory() []entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]entry(nil), s.entries...)
+}
+
+// remove deletes the entry from the directory.
+func (s *testServer) remove(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if strings.EqualFold(e.dn, dn) {
+			s.entries = append(s.entries[:i:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// set replaces values of the entry attribute.
+func (s *testServer) set(dn, attr string, values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if strings.EqualFold(e.dn, dn) {
+			attrs := make(map[string][]string, len(e.attrs))
+			for name, v := range e.attrs {
+				attrs[name] = v
+			}
+			attrs[strings.ToLower(attr)] = values
+			s.entries[i].attrs = attrs
+			return
+		}
+	}
+}
+
+func (s *testServer) entry(dn string) (entry, bool) {
+	for _, e := range s.directory() {
+		if strings.EqualFold(e.dn, dn) {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+// entryPacket returns requested attributes of the entry, passwords are never returned.
+func (s *testServer) entryPacket(e entry, requested *packet) *packet {
+	attrs := newConstructed(tagSequence)
+	for _, r := range requested.children {
+		name := r.str()
+		values := e.attrs[strings.ToLower(name)]
+		if len(values) == 0 || strings.EqualFold(name, "userPassword") {
+			continue
+		}
+		set := newConstructed(tagSet)
+		for _, v := range values {
+			set.children = append(set.children, newString(tagOctetString, v))
+		}
+		attrs.children = append(attrs.children, newConstructed(tagSequence, newString(tagOctetString, name), set))
+	}
+	return newConstructed(opSearchResultEntry, newString(tagOctetString, e.dn), attrs)
+}
+
+// match evaluates the filter with case-insensitive matching, like most directory attributes have.
+func (s *testServer) match(f *packet, e entry) bool {
+	switch f.tag {
+	case filterAnd:
+		for _, c := range f.children {
+			if !s.match(c, e) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, c := range f.children {
+			if s.match(c, e) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !s.match(f.child(0), e)
+	case filterPresent:
+		return len(e.attrs[strings.ToLower(f.str())]) > 0
+	case filterEqualityMatch:
+		for _, v := range e.attrs[strings.ToLower(f.child(0).str())] {
+			if strings.EqualFold(v, f.child(1).str()) {
+				return true
+			}
+		}
+		return false
+	case filterSubstrings:
+		for _, v := range e.attrs[strings.ToLower(f.child(0).str())] {
+			if matchSubstrings(strings.ToLower(v), f.child(1)) {
+				return true
+			}
+		}
+		return false
+	case filterExtensible:
+		return matchExtensible(f, e)
+	}
+	s.t.Errorf("Unexpected filter %#x", f.tag)
+	return false
+}
+
+// matchExtensible supports Active Directory bitwise AND and OR matching rules only.
+func matchExtensible(f *packet, e entry) bool {
+	var rule, attr, value string
+	for _, c := range f.children {
+		switch c.tag {
+		case matchingRule:
+			rule = c.str()
+		case matchingRuleType:
+			attr = c.str()
+		case matchingRuleValue:
+			value = c.str()
+		}
+	}
+	mask, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return false
+	}
+	for _, v := range e.attrs[strings.ToLower(attr)] {
+		flags, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			continue
+		}
+		switch rule {
+		case "1.2.840.113556.1.4.803":
+			if flags&mask == mask {
+				return true
+			}
+		case "1.2.840.113556.1.4.804":
+			if flags&mask != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSubstrings(v string, substrings *packet) bool {
+	for _, sub := range substrings.children {
+		part := strings.ToLower(sub.str())
+		switch sub.tag {
+		case substringInitial:
+			if !strings.HasPrefix(v, part) {
+				return false
+			}
+			v = v[len(part):]
+		case substringAny:
+			i := strings.Index(v, part)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(part):]
+		case substringFinal:
+			if !strings.HasSuffix(v, part) {
+				return false
+			}
+		}
+	}
+	return true
+}
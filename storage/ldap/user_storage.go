@@ -0,0 +1,288 @@
+package ldap
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	defaultUsernameAttribute = "uid"
+	defaultEmailAttribute    = "mail"
+	defaultGroupAttribute    = "memberOf"
+	usernamePlaceholder      = "{username}"
+	resultSizeLimitExceeded  = 4
+)
+
+// NewUserStorage creates user storage backed with LDAP directory.
+// Local records of directory users are kept in the shadow storage.
+func NewUserStorage(settings model.DatabaseSettings, shadow model.UserStorage) (model.UserStorage, error) {
+	if settings.LDAP == nil {
+		return nil, fmt.Errorf("Empty LDAP settings")
+	}
+	if shadow == nil {
+		return nil, fmt.Errorf("Empty LDAP shadow storage")
+	}
+
+	s := *settings.LDAP
+	u, err := url.Parse(settings.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid LDAP endpoint. %s", err)
+	}
+	switch {
+	case u.Scheme == "ldaps" && s.StartTLS:
+		return nil, fmt.Errorf("LDAP StartTLS can't be used with ldaps:// endpoint")
+	case u.Scheme == "ldap" && !s.StartTLS && !s.AllowUnencrypted:
+		return nil, fmt.Errorf("LDAP endpoint %s is not encrypted, use ldaps://, enable StartTLS or allow unencrypted connections explicitly", settings.Endpoint)
+	case u.Scheme != "ldap" && u.Scheme != "ldaps":
+		return nil, fmt.Errorf("Unsupported LDAP endpoint scheme %q", u.Scheme)
+	}
+	if len(s.UsernameAttribute) == 0 {
+		s.UsernameAttribute = defaultUsernameAttribute
+	}
+	if len(s.EmailAttribute) == 0 {
+		s.EmailAttribute = defaultEmailAttribute
+	}
+	if len(s.GroupAttribute) == 0 {
+		s.GroupAttribute = defaultGroupAttribute
+	}
+	if len(s.UserFilter) == 0 {
+		s.UserFilter = "(" + s.UsernameAttribute + "=" + usernamePlaceholder + ")"
+	}
+	if _, err := compileFilter(strings.Replace(s.UserFilter, usernamePlaceholder, "x", -1)); err != nil {
+		return nil, fmt.Errorf("Invalid LDAP user filter. %s", err)
+	}
+
+	return &UserStorage{
+		UserStorage: shadow,
+		endpoint:    settings.Endpoint,
+		settings:    s,
+	}, nil
+}
+
+// UserStorage authenticates users against LDAP directory, like Active Directory.
+// Usernames, passwords, emails and access roles come from the directory, the rest of user data is kept
+// in the embedded shadow storage, where directory users are linked to their local records by usernames as federated IDs.
+// Directory accounts are managed in the directory, so they can't be created and their passwords can't be reset here.
+// Directory users are looked up in the directory every time their local records are, so accounts removed from the directory,
+// or excluded by the user filter, e.g. disabled Active Directory ones, can't refresh tokens or log in without password either.
+type UserStorage struct {
+	model.UserStorage
+	endpoint string
+	settings model.LDAPSettings
+	// rootCAs verify directory certificates, system roots are used if it is nil.
+	rootCAs *x509.CertPool
+}
+
+// UserByNamePassword finds the user in the directory and checks the password by binding as the user.
+// Local record of the user is created on the first login, and updated with directory data on every login.
+func (us *UserStorage) UserByNamePassword(name, password string) (model.User, error) {
+	// Servers treat simple binds without password as anonymous ones, and let them succeed.
+	if len(name) == 0 || len(password) == 0 {
+		return nil, model.ErrUserNotFound
+	}
+
+	c, err := us.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	e, err := us.findUser(c, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.bind(e.dn, password); err != nil {
+		if re, ok := err.(*ResultError); ok && re.Code == resultInvalidCreds {
+			// return this error to hide the existence of the user.
+			return nil, model.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return us.syncUser(e)
+}
+
+// UserExists checks whether the user exists either in the directory or locally, e.g. as anonymous one.
+func (us *UserStorage) UserExists(name string) bool {
+	if us.UserStorage.UserExists(name) {
+		return true
+	}
+
+	c, err := us.connect()
+	if err != nil {
+		return false
+	}
+	defer c.close()
+
+	_, err = us.findUser(c, name)
+	return err == nil
+}
+
+// IDByName returns ID of the local record of the directory user. The user must have logged in at least once.
+func (us *UserStorage) IDByName(name string) (string, error) {
+	user, err := us.UserStorage.UserByFederatedID(model.LDAPIDProvider, strings.ToLower(name))
+	if err != nil {
+		return "", err
+	}
+	return user.ID(), nil
+}
+
+// UserByID returns local record of the user, if the user is still in the directory.
+func (us *UserStorage) UserByID(id string) (model.User, error) {
+	return us.checkDirectory(us.UserStorage.UserByID(id))
+}
+
+// UserByEmail returns local record of the user, if the user is still in the directory.
+func (us *UserStorage) UserByEmail(email string) (model.User, error) {
+	return us.checkDirectory(us.UserStorage.UserByEmail(email))
+}
+
+// UserByPhone returns local record of the user, if the user is still in the directory.
+func (us *UserStorage) UserByPhone(phone string) (model.User, error) {
+	return us.checkDirectory(us.UserStorage.UserByPhone(phone))
+}
+
+// UserByFederatedID returns local record of the user, if the user is still in the directory.
+func (us *UserStorage) UserByFederatedID(provider model.FederatedIdentityProvider, id string) (model.User, error) {
+	return us.checkDirectory(us.UserStorage.UserByFederatedID(provider, id))
+}
+
+// AddUserByNameAndPassword creates anonymous users in the shadow storage, other users must be created in the directory.
+func (us *UserStorage) AddUserByNameAndPassword(username, password, role string, isAnonymous bool) (model.User, error) {
+	if !isAnonymous {
+		return nil, model.ErrorNotImplemented
+	}
+	return us.UserStorage.AddUserByNameAndPassword(username, password, role, isAnonymous)
+}
+
+// ResetPassword is not supported, passwords are changed in the directory.
+func (us *UserStorage) ResetPassword(id, password string) error {
+	return model.ErrorNotImplemented
+}
+
+// checkDirectory makes sure the directory user found locally still matches the user filter.
+// Local users, like anonymous ones, are returned as is.
+func (us *UserStorage) checkDirectory(user model.User, err error) (model.User, error) {
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range user.FederatedIdentities() {
+		if fi.Provider != model.LDAPIDProvider {
+			continue
+		}
+
+		c, err := us.connect()
+		if err != nil {
+			return nil, err
+		}
+		defer c.close()
+
+		if _, err = us.findUser(c, fi.ID); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	return user, nil
+}
+
+// connect dials the directory and binds as the service account, if it is set.
+func (us *UserStorage) connect() (*conn, error) {
+	c, err := dial(us.endpoint, us.settings.StartTLS, us.rootCAs, defaultOperationLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(us.settings.BindDN) > 0 {
+		if err = c.bind(us.settings.BindDN, us.settings.BindPassword); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// findUser searches for the only entry matching the user filter.
+func (us *UserStorage) findUser(c *conn, name string) (entry, error) {
+	filter := strings.Replace(us.settings.UserFilter, usernamePlaceholder, escapeFilter(name), -1)
+	attrs := []string{us.settings.UsernameAttribute, us.settings.EmailAttribute, us.settings.GroupAttribute}
+
+	entries, err := c.search(us.settings.BaseDN, filter, attrs, 2)
+	if re, ok := err.(*ResultError); ok && re.Code == resultSizeLimitExceeded {
+		return entry{}, fmt.Errorf("More than one LDAP entry matches user %s", name)
+	}
+	if err != nil {
+		return entry{}, err
+	}
+
+	switch len(entries) {
+	case 0:
+		return entry{}, model.ErrUserNotFound
+	case 1:
+		// Binding with empty DN is anonymous, so it would let anyone in.
+		if len(entries[0].dn) == 0 {
+			return entry{}, fmt.Errorf("LDAP entry of user %s has empty DN", name)
+		}
+		return entries[0], nil
+	}
+	return entry{}, fmt.Errorf("More than one LDAP entry matches user %s", name)
+}
+
+// syncUser returns local record of the directory user, creating it if needed, with username, email and role from the directory.
+func (us *UserStorage) syncUser(e entry) (model.User, error) {
+	username := e.first(us.settings.UsernameAttribute)
+	if len(username) == 0 {
+		return nil, fmt.Errorf("LDAP entry %s has no %s attribute", e.dn, us.settings.UsernameAttribute)
+	}
+	federatedID := strings.ToLower(username)
+	role := us.accessRole(e.attrs[strings.ToLower(us.settings.GroupAttribute)])
+
+	user, err := us.UserStorage.UserByFederatedID(model.LDAPIDProvider, federatedID)
+	if err == model.ErrUserNotFound {
+		user, err = us.UserStorage.AddUserWithFederatedID(model.LDAPIDProvider, federatedID, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.SetUsername(username)
+	user.SetAccessRole(role)
+	// The directory is trusted, so its emails are verified. The email is not taken if someone else uses it.
+	if email := e.first(us.settings.EmailAttribute); len(email) > 0 && email != user.Email() {
+		if owner, err := us.UserStorage.UserByEmail(email); err == model.ErrUserNotFound || (err == nil && owner.ID() == user.ID()) {
+			user.SetEmail(email)
+			user.SetEmailVerified(true)
+		}
+	}
+	return us.UserStorage.UpdateUser(user.ID(), user)
+}
+
+// accessRole maps directory groups to access role, the first matching group from the settings wins.
+func (us *UserStorage) accessRole(groups []string) string {
+	for _, gr := range us.settings.GroupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(group, gr.Group) || strings.EqualFold(commonName(group), gr.Group) {
+				return gr.Role
+			}
+		}
+	}
+	return us.settings.DefaultRole
+}
+
+// commonName returns CN of the group DN, or an empty string if the DN does not start with it.
+func commonName(dn string) string {
+	if len(dn) < 3 || !strings.EqualFold(dn[:3], "cn=") {
+		return ""
+	}
+	rdn := dn[3:]
+	for i := 0; i < len(rdn); i++ {
+		switch rdn[i] {
+		case '\\':
+			i++
+		case ',', '+':
+			return strings.TrimSpace(rdn[:i])
+		}
+	}
+	return strings.TrimSpace(rdn)
+}
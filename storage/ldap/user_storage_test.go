@@ -0,0 +1,403 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/storage/boltdb"
+)
+
+const (
+	testBaseDN       = "ou=staff,dc=example,dc=com"
+	testServiceDN    = "cn=identifo,ou=services,dc=example,dc=com"
+	testAdminsDN     = "cn=Identifo Admins,ou=groups,dc=example,dc=com"
+	testDevelopersDN = "cn=Developers,ou=groups,dc=example,dc=com"
+)
+
+func testEntry(dn string, attrs map[string][]string) entry {
+	return entry{dn: dn, attrs: attrs}
+}
+
+func testDirectory(t *testing.T) *testServer {
+	return newTestServer(t,
+		testEntry(testServiceDN, map[string][]string{"cn": {"identifo"}, "userpassword": {"service-secret"}}),
+		testEntry("cn=Alice Smith,"+testBaseDN, map[string][]string{
+			"objectclass":    {"user"},
+			"samaccountname": {"Alice"},
+			"mail":           {"alice@example.com"},
+			"memberof":       {testDevelopersDN, testAdminsDN},
+			"userpassword":   {"alice-secret"},
+		}),
+		testEntry("cn=Bob Jones,"+testBaseDN, map[string][]string{
+			"objectclass":    {"user"},
+			"samaccountname": {"bob"},
+			"mail":           {"bob@example.com"},
+			"memberof":       {testDevelopersDN},
+			"userpassword":   {"bob-secret"},
+		}),
+		testEntry("cn=Carol White,ou=former,dc=example,dc=com", map[string][]string{
+			"objectclass":    {"user"},
+			"samaccountname": {"carol"},
+			"userpassword":   {"carol-secret"},
+		}),
+	)
+}
+
+// testUserStorage returns LDAP user storage with BoltDB shadow storage, that must be closed after the test.
+func testUserStorage(t *testing.T, s *testServer, modify func(*model.LDAPSettings)) model.UserStorage {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "identifo-ldap")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	db, err := boltdb.InitDB(filepath.Join(dir, "db.db"))
+	if err != nil {
+		t.Fatalf("Unable to open shadow database: %v", err)
+	}
+	os.RemoveAll(dir) // The database stays open, and is removed when it is closed.
+	shadow, err := boltdb.NewUserStorage(db)
+	if err != nil {
+		t.Fatalf("Unable to create shadow storage: %v", err)
+	}
+
+	settings := model.LDAPSettings{
+		BindDN:            testServiceDN,
+		BindPassword:      "service-secret",
+		BaseDN:            testBaseDN,
+		UserFilter:        "(&(objectClass=*)(sAMAccountName={username}))",
+		UsernameAttribute: "sAMAccountName",
+		GroupRoles: []model.LDAPGroupRole{
+			{Group: "identifo admins", Role: "admin"},
+			{Group: testDevelopersDN, Role: "developer"},
+		},
+		DefaultRole:      "user",
+		AllowUnencrypted: true,
+	}
+	if modify != nil {
+		modify(&settings)
+	}
+
+	us, err := NewUserStorage(model.DatabaseSettings{Type: model.DBTypeLDAP, Endpoint: s.URL(), LDAP: &settings}, shadow)
+	if err != nil {
+		t.Fatalf("Unable to create LDAP user storage: %v", err)
+	}
+	us.(*UserStorage).rootCAs = s.roots
+	return us
+}
+
+func TestUserByNamePassword(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, nil)
+	defer us.Close()
+
+	user, err := us.UserByNamePassword("alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+	if user.Username() != "Alice" {
+		t.Errorf("Username = %q, want directory one %q", user.Username(), "Alice")
+	}
+	if user.Email() != "alice@example.com" || !user.EmailVerified() {
+		t.Errorf("Email = %q, verified %v, want verified alice@example.com", user.Email(), user.EmailVerified())
+	}
+	if user.AccessRole() != "admin" {
+		t.Errorf("AccessRole = %q, want role of the first matching group %q", user.AccessRole(), "admin")
+	}
+	if user.PasswordHash() != "" {
+		t.Errorf("Password must not be kept locally")
+	}
+
+	binds := s.bindDNs()
+	if len(binds) != 2 || binds[0] != testServiceDN || binds[1] != "cn=Alice Smith,"+testBaseDN {
+		t.Errorf("Binds = %q, want service account and then the user", binds)
+	}
+
+	// Local record keeps TFA settings between logins.
+	user.SetTFAInfo(model.TFAInfo{IsEnabled: true, RecoveryCodes: []string{"code-hash"}})
+	if _, err = us.UpdateUser(user.ID(), user); err != nil {
+		t.Fatalf("Unable to update user: %v", err)
+	}
+	again, err := us.UserByNamePassword("ALICE", "alice-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in again: %v", err)
+	}
+	if again.ID() != user.ID() {
+		t.Errorf("ID = %q, want the same local record %q", again.ID(), user.ID())
+	}
+	if !again.TFAInfo().IsEnabled || len(again.TFAInfo().RecoveryCodes) != 1 {
+		t.Errorf("TFA info of the local record is lost: %+v", again.TFAInfo())
+	}
+
+	id, err := us.IDByName("alice")
+	if err != nil || id != user.ID() {
+		t.Errorf("IDByName = %q, %v, want %q", id, err, user.ID())
+	}
+
+	bob, err := us.UserByNamePassword("bob", "bob-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+	if bob.AccessRole() != "developer" {
+		t.Errorf("AccessRole = %q, want role of group matched by DN %q", bob.AccessRole(), "developer")
+	}
+	if bob.ID() == user.ID() {
+		t.Errorf("Different directory users share the local record")
+	}
+}
+
+func TestUserByNamePasswordRejected(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, func(settings *model.LDAPSettings) {
+		settings.UserFilter = ""
+	})
+	defer us.Close()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "bob", "alice-secret"},
+		{"unknown user", "mallory", "secret"},
+		{"empty password", "bob", ""},
+		{"outside of base DN", "carol", "carol-secret"},
+		{"filter injection", "*", "bob-secret"},
+		{"filter injection with parentheses", "bob)(sAMAccountName=*", "bob-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := us.UserByNamePassword(tt.username, tt.password); err != model.ErrUserNotFound {
+				t.Errorf("UserByNamePassword(%q, %q) error = %v, want %v", tt.username, tt.password, err, model.ErrUserNotFound)
+			}
+		})
+	}
+
+	for _, dn := range s.bindDNs() {
+		if dn == "" {
+			t.Errorf("Anonymous bind must not be used to check passwords")
+		}
+	}
+}
+
+func TestUserByNamePasswordAmbiguous(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, func(settings *model.LDAPSettings) {
+		settings.UserFilter = "(|(sAMAccountName={username})(memberOf=" + escapeFilter(testDevelopersDN) + "))"
+	})
+	defer us.Close()
+
+	if _, err := us.UserByNamePassword("bob", "bob-secret"); err == nil || err == model.ErrUserNotFound {
+		t.Errorf("Login must fail when several entries match, got %v", err)
+	}
+}
+
+func TestDefaultSettings(t *testing.T) {
+	s := newTestServer(t, testEntry("uid=dave,"+testBaseDN, map[string][]string{
+		"uid":          {"dave"},
+		"memberof":     {"cn=Contractors,ou=groups,dc=example,dc=com"},
+		"userpassword": {"dave-secret"},
+	}))
+	defer s.Close()
+	s.anonymousSearch = true
+	us := testUserStorage(t, s, func(settings *model.LDAPSettings) {
+		*settings = model.LDAPSettings{BaseDN: testBaseDN, DefaultRole: "user", AllowUnencrypted: true}
+	})
+	defer us.Close()
+
+	user, err := us.UserByNamePassword("dave", "dave-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+	if user.AccessRole() != "user" {
+		t.Errorf("AccessRole = %q, want default role %q", user.AccessRole(), "user")
+	}
+	if user.Email() != "" {
+		t.Errorf("Email = %q, want none", user.Email())
+	}
+	if binds := s.bindDNs(); len(binds) != 1 {
+		t.Errorf("Binds = %q, want only the user bind when service account is not set", binds)
+	}
+}
+
+func TestServiceAccountRejected(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, func(settings *model.LDAPSettings) {
+		settings.BindPassword = "wrong"
+	})
+	defer us.Close()
+
+	_, err := us.UserByNamePassword("bob", "bob-secret")
+	if re, ok := err.(*ResultError); !ok || re.Code != resultInvalidCreds {
+		t.Errorf("Error = %v, want invalid credentials of the service account", err)
+	}
+	if s.searchCount() != 0 {
+		t.Errorf("Directory must not be searched after failed service account bind")
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	s.requireTLS = true
+	us := testUserStorage(t, s, func(settings *model.LDAPSettings) {
+		settings.StartTLS = true
+		settings.AllowUnencrypted = false
+	})
+	defer us.Close()
+
+	if _, err := us.UserByNamePassword("bob", "bob-secret"); err != nil {
+		t.Fatalf("Unable to log in over StartTLS: %v", err)
+	}
+
+	// Certificate of the directory is verified.
+	us.(*UserStorage).rootCAs = nil
+	if _, err := us.UserByNamePassword("bob", "bob-secret"); err == nil || err == model.ErrUserNotFound {
+		t.Errorf("Login must fail when the directory certificate is not trusted, got %v", err)
+	}
+}
+
+func TestUnencryptedEndpoint(t *testing.T) {
+	settings := model.LDAPSettings{BaseDN: testBaseDN}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		modify   func(*model.LDAPSettings)
+		valid    bool
+	}{
+		{"plain without opt-in", "ldap://ad.example.com", func(*model.LDAPSettings) {}, false},
+		{"plain with StartTLS", "ldap://ad.example.com", func(ls *model.LDAPSettings) { ls.StartTLS = true }, true},
+		{"plain allowed explicitly", "ldap://ad.example.com", func(ls *model.LDAPSettings) { ls.AllowUnencrypted = true }, true},
+		{"ldaps", "ldaps://ad.example.com", func(*model.LDAPSettings) {}, true},
+		{"ldaps with StartTLS", "ldaps://ad.example.com", func(ls *model.LDAPSettings) { ls.StartTLS = true }, false},
+		{"other scheme", "http://ad.example.com", func(ls *model.LDAPSettings) { ls.AllowUnencrypted = true }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := settings
+			tt.modify(&ls)
+			_, err := NewUserStorage(model.DatabaseSettings{Type: model.DBTypeLDAP, Endpoint: tt.endpoint, LDAP: &ls}, &UserStorage{})
+			if (err == nil) != tt.valid {
+				t.Errorf("NewUserStorage() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestDirectoryRechecked(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, func(settings *model.LDAPSettings) {
+		// Accounts disabled in Active Directory have ACCOUNTDISABLE flag set.
+		settings.UserFilter = "(&(sAMAccountName={username})(!(userAccountControl:1.2.840.113556.1.4.803:=2)))"
+	})
+	defer us.Close()
+
+	alice, err := us.UserByNamePassword("alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+	bob, err := us.UserByNamePassword("bob", "bob-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+	if _, err = us.UserByID(bob.ID()); err != nil {
+		t.Fatalf("UserByID error = %v", err)
+	}
+
+	s.set("cn=Bob Jones,"+testBaseDN, "userAccountControl", "514")
+	if _, err = us.UserByID(bob.ID()); err != model.ErrUserNotFound {
+		t.Errorf("UserByID of disabled account error = %v, want %v", err, model.ErrUserNotFound)
+	}
+	if _, err = us.UserByEmail("bob@example.com"); err != model.ErrUserNotFound {
+		t.Errorf("UserByEmail of disabled account error = %v, want %v", err, model.ErrUserNotFound)
+	}
+	if _, err = us.UserByFederatedID(model.LDAPIDProvider, "bob"); err != model.ErrUserNotFound {
+		t.Errorf("UserByFederatedID of disabled account error = %v, want %v", err, model.ErrUserNotFound)
+	}
+	s.set("cn=Bob Jones,"+testBaseDN, "userAccountControl", "512")
+	if _, err = us.UserByID(bob.ID()); err != nil {
+		t.Errorf("UserByID of enabled account error = %v", err)
+	}
+
+	s.remove("cn=Alice Smith," + testBaseDN)
+	if _, err = us.UserByID(alice.ID()); err != model.ErrUserNotFound {
+		t.Errorf("UserByID of removed account error = %v, want %v", err, model.ErrUserNotFound)
+	}
+
+	// Local users are not looked up in the directory.
+	anonymous, err := us.AddUserByNameAndPassword("anon", "anon-secret", "user", true)
+	if err != nil {
+		t.Fatalf("Unable to add anonymous user: %v", err)
+	}
+	searches := s.searchCount()
+	if _, err = us.UserByID(anonymous.ID()); err != nil {
+		t.Errorf("UserByID of local user error = %v", err)
+	}
+	if s.searchCount() != searches {
+		t.Errorf("Directory must not be searched for local users")
+	}
+}
+
+func TestUserExists(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, nil)
+	defer us.Close()
+
+	if !us.UserExists("bob") {
+		t.Errorf("Directory user must exist")
+	}
+	if us.UserExists("mallory") || us.UserExists("*") {
+		t.Errorf("Unknown user must not exist")
+	}
+}
+
+func TestAccountsManagedInDirectory(t *testing.T) {
+	s := testDirectory(t)
+	defer s.Close()
+	us := testUserStorage(t, s, nil)
+	defer us.Close()
+
+	if _, err := us.AddUserByNameAndPassword("eve", "eve-secret", "user", false); err != model.ErrorNotImplemented {
+		t.Errorf("AddUserByNameAndPassword error = %v, want %v", err, model.ErrorNotImplemented)
+	}
+	user, err := us.UserByNamePassword("bob", "bob-secret")
+	if err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+	if err = us.ResetPassword(user.ID(), "new-secret"); err != model.ErrorNotImplemented {
+		t.Errorf("ResetPassword error = %v, want %v", err, model.ErrorNotImplemented)
+	}
+
+	anonymous, err := us.AddUserByNameAndPassword("anon", "anon-secret", "user", true)
+	if err != nil {
+		t.Fatalf("Unable to add anonymous user: %v", err)
+	}
+	if !us.UserExists(anonymous.Username()) {
+		t.Errorf("Local anonymous user must exist")
+	}
+}
+
+func TestCommonName(t *testing.T) {
+	tests := map[string]string{
+		"cn=Admins,ou=groups,dc=example,dc=com": "Admins",
+		"CN=Smith\\, John,OU=Users":             "Smith\\, John",
+		"cn=Solo":                               "Solo",
+		"ou=groups,dc=example,dc=com":           "",
+		"":                                      "",
+	}
+	for dn, want := range tests {
+		if got := commonName(dn); got != want {
+			t.Errorf("commonName(%q) = %q, want %q", dn, got, want)
+		}
+	}
+}
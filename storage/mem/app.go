@@ -20,10 +20,15 @@ type appData struct {
 	Type                         model.AppType          `json:"type,omitempty"`
 	RedirectURLs                 []string               `json:"redirect_urls,omitempty"`
 	RefreshTokenLifespan         int64                  `json:"refresh_token_lifespan,omitempty"`
+	RefreshTokenRotation         bool                   `json:"refresh_token_rotation"`
+	TrustedDeviceLifespan        int64                  `json:"trusted_device_lifespan,omitempty"`
+	TokenExchangeAudiences       []string               `json:"token_exchange_audiences,omitempty"`
+	BackChannelLogoutURI         string                 `json:"backchannel_logout_uri,omitempty"`
 	InviteTokenLifespan          int64                  `json:"invite_token_lifespan,omitempty"`
 	TokenLifespan                int64                  `json:"token_lifespan,omitempty"`
 	TokenPayload                 []string               `json:"token_payload,omitempty"`
 	RegistrationForbidden        bool                   `json:"registration_forbidden"`
+	EmailVerificationRequired    bool                   `json:"email_verification_required"`
 	AnonymousRegistrationAllowed bool                   `json:"anonymous_registration_allowed"`
 	TFAStatus                    model.TFAStatus        `json:"tfa_status"`
 	DebugTFACode                 string                 `json:"debug_tfa_code,omitempty"`
@@ -34,6 +39,9 @@ type appData struct {
 	RolesBlacklist               []string               `json:"roles_blacklist,omitempty"`
 	NewUserDefaultRole           string                 `json:"new_user_default_role,omitempty"`
 	AppleInfo                    *model.AppleInfo       `json:"apple_info,omitempty"`
+	GoogleInfo                   *model.GoogleInfo      `json:"google_info,omitempty"`
+	OIDCProviders                []model.OIDCProvider   `json:"oidc_providers,omitempty"`
+	SAMLInfo                     *model.SAMLInfo        `json:"saml_info,omitempty"`
 }
 
 // NewAppData instantiates app data in-memory model from the general one.
@@ -46,12 +54,18 @@ func NewAppData(data model.AppData) AppData {
 		Description:                  data.Description(),
 		Scopes:                       data.Scopes(),
 		Offline:                      data.Offline(),
+		Type:                         data.Type(),
 		RedirectURLs:                 data.RedirectURLs(),
 		RefreshTokenLifespan:         data.RefreshTokenLifespan(),
+		RefreshTokenRotation:         data.RefreshTokenRotation(),
+		TrustedDeviceLifespan:        data.TrustedDeviceLifespan(),
+		TokenExchangeAudiences:       data.TokenExchangeAudiences(),
+		BackChannelLogoutURI:         data.BackChannelLogoutURI(),
 		InviteTokenLifespan:          data.InviteTokenLifespan(),
 		TokenLifespan:                data.TokenLifespan(),
 		TokenPayload:                 data.TokenPayload(),
 		RegistrationForbidden:        data.RegistrationForbidden(),
+		EmailVerificationRequired:    data.EmailVerificationRequired(),
 		AnonymousRegistrationAllowed: data.AnonymousRegistrationAllowed(),
 	}}
 }
@@ -117,6 +131,18 @@ func (ad *AppData) RedirectURLs() []string { return ad.appData.RedirectURLs }
 // RefreshTokenLifespan implements model.AppData interface.
 func (ad *AppData) RefreshTokenLifespan() int64 { return ad.appData.RefreshTokenLifespan }
 
+// RefreshTokenRotation implements model.AppData interface.
+func (ad *AppData) RefreshTokenRotation() bool { return ad.appData.RefreshTokenRotation }
+
+// TrustedDeviceLifespan implements model.AppData interface.
+func (ad *AppData) TrustedDeviceLifespan() int64 { return ad.appData.TrustedDeviceLifespan }
+
+// TokenExchangeAudiences implements model.AppData interface.
+func (ad *AppData) TokenExchangeAudiences() []string { return ad.appData.TokenExchangeAudiences }
+
+// BackChannelLogoutURI implements model.AppData interface.
+func (ad *AppData) BackChannelLogoutURI() string { return ad.appData.BackChannelLogoutURI }
+
 // InviteTokenLifespan a inviteToken lifespan in seconds, if 0 - default one is used.
 func (ad *AppData) InviteTokenLifespan() int64 { return ad.appData.InviteTokenLifespan }
 
@@ -129,6 +155,9 @@ func (ad *AppData) TokenPayload() []string { return ad.appData.TokenPayload }
 // RegistrationForbidden implements model.AppData interface.
 func (ad *AppData) RegistrationForbidden() bool { return ad.appData.RegistrationForbidden }
 
+// EmailVerificationRequired implements model.AppData interface.
+func (ad *AppData) EmailVerificationRequired() bool { return ad.appData.EmailVerificationRequired }
+
 // AnonymousRegistrationAllowed implements model.AppData interface.
 func (ad *AppData) AnonymousRegistrationAllowed() bool { return ad.appData.AnonymousRegistrationAllowed }
 
@@ -159,6 +188,15 @@ func (ad *AppData) NewUserDefaultRole() string { return ad.appData.NewUserDefaul
 // AppleInfo implements model.AppData interface.
 func (ad *AppData) AppleInfo() *model.AppleInfo { return ad.appData.AppleInfo }
 
+// GoogleInfo implements model.AppData interface.
+func (ad *AppData) GoogleInfo() *model.GoogleInfo { return ad.appData.GoogleInfo }
+
+// OIDCProviders implements model.AppData interface.
+func (ad *AppData) OIDCProviders() []model.OIDCProvider { return ad.appData.OIDCProviders }
+
+// SAMLInfo implements model.AppData interface.
+func (ad *AppData) SAMLInfo() *model.SAMLInfo { return ad.appData.SAMLInfo }
+
 // SetSecret implements model.AppData interface.
 func (ad *AppData) SetSecret(secret string) {
 	if ad == nil {
@@ -176,6 +214,9 @@ func (ad *AppData) Sanitize() {
 	if ad.appData.AppleInfo != nil {
 		ad.appData.AppleInfo.ClientSecret = ""
 	}
+	for i := range ad.appData.OIDCProviders {
+		ad.appData.OIDCProviders[i].ClientSecret = ""
+	}
 
 	ad.appData.AuthorizationWay = ""
 	ad.appData.AuthorizationModel = ""
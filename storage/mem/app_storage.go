@@ -105,6 +105,7 @@ func (as *AppStorage) FetchApps(filterString string, skip, limit int) ([]model.A
 			break
 		}
 		if strings.Contains(strings.ToLower(app.Name()), strings.ToLower(filterString)) {
+			app := app
 			apps = append(apps, &app)
 		}
 	}
@@ -0,0 +1,58 @@
+package mem
+
+import (
+	"sync"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// NewAuthorizationCodeStorage creates an in-memory authorization code storage.
+func NewAuthorizationCodeStorage() (model.AuthorizationCodeStorage, error) {
+	return &AuthorizationCodeStorage{storage: make(map[string]model.AuthorizationCode)}, nil
+}
+
+// AuthorizationCodeStorage is an in-memory authorization code storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type AuthorizationCodeStorage struct {
+	sync.Mutex
+	storage map[string]model.AuthorizationCode
+}
+
+// SaveAuthorizationCode saves authorization code in memory.
+func (acs *AuthorizationCodeStorage) SaveAuthorizationCode(code model.AuthorizationCode) error {
+	if len(code.Code) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+	acs.Lock()
+	defer acs.Unlock()
+
+	acs.storage[code.Code] = code
+	return nil
+}
+
+// ConsumeAuthorizationCode returns authorization code and removes it from the storage.
+func (acs *AuthorizationCodeStorage) ConsumeAuthorizationCode(code string) (model.AuthorizationCode, error) {
+	acs.Lock()
+	defer acs.Unlock()
+
+	ac, ok := acs.storage[code]
+	if !ok {
+		return model.AuthorizationCode{}, model.ErrorNotFound
+	}
+	delete(acs.storage, code)
+
+	if ac.Expired() {
+		return model.AuthorizationCode{}, model.ErrorNotFound
+	}
+	return ac, nil
+}
+
+// Close clears storage.
+func (acs *AuthorizationCodeStorage) Close() {
+	acs.Lock()
+	defer acs.Unlock()
+
+	for k := range acs.storage {
+		delete(acs.storage, k)
+	}
+}
@@ -0,0 +1,107 @@
+package mem
+
+import (
+	"sync"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// NewDeviceCodeStorage creates an in-memory device code storage.
+func NewDeviceCodeStorage() (model.DeviceCodeStorage, error) {
+	return &DeviceCodeStorage{storage: make(map[string]model.DeviceCode)}, nil
+}
+
+// DeviceCodeStorage is an in-memory device code storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type DeviceCodeStorage struct {
+	sync.Mutex
+	storage map[string]model.DeviceCode
+}
+
+// SaveDeviceCode saves device code in memory.
+func (dcs *DeviceCodeStorage) SaveDeviceCode(code model.DeviceCode) error {
+	if len(code.DeviceCode) == 0 || len(code.UserCode) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	dcs.storage[code.DeviceCode] = code
+	return nil
+}
+
+// DeviceCodeByDeviceCode returns device code by its device code value.
+func (dcs *DeviceCodeStorage) DeviceCodeByDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	dc, ok := dcs.storage[deviceCode]
+	if !ok || dc.Expired() {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc, nil
+}
+
+// DeviceCodeByUserCode returns device code by the user code.
+func (dcs *DeviceCodeStorage) DeviceCodeByUserCode(userCode string) (model.DeviceCode, error) {
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	for _, dc := range dcs.storage {
+		if dc.UserCode == userCode && !dc.Expired() {
+			return dc, nil
+		}
+	}
+	return model.DeviceCode{}, model.ErrorNotFound
+}
+
+// UpdateDeviceCodePolling updates polling state of the device code in memory.
+func (dcs *DeviceCodeStorage) UpdateDeviceCodePolling(deviceCode string, lastPolledAt, interval int64) error {
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	dc, ok := dcs.storage[deviceCode]
+	if !ok {
+		return model.ErrorNotFound
+	}
+	dc.LastPolledAt = lastPolledAt
+	dc.Interval = interval
+	dcs.storage[deviceCode] = dc
+	return nil
+}
+
+// ConsumeDeviceCode removes approved device code from memory and returns it.
+func (dcs *DeviceCodeStorage) ConsumeDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	dc, ok := dcs.storage[deviceCode]
+	if !ok || dc.Status != model.DeviceCodeStatusApproved {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	delete(dcs.storage, deviceCode)
+
+	if dc.Expired() {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc, nil
+}
+
+// DeleteDeviceCode removes device code from memory.
+func (dcs *DeviceCodeStorage) DeleteDeviceCode(deviceCode string) error {
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	delete(dcs.storage, deviceCode)
+	return nil
+}
+
+// Close clears storage.
+func (dcs *DeviceCodeStorage) Close() {
+	dcs.Lock()
+	defer dcs.Unlock()
+
+	for k := range dcs.storage {
+		delete(dcs.storage, k)
+	}
+}
@@ -0,0 +1,68 @@
+package mem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// NewLoginAttemptStorage creates an in-memory login attempt storage.
+func NewLoginAttemptStorage() (model.LoginAttemptStorage, error) {
+	return &LoginAttemptStorage{storage: make(map[string]model.LoginAttempts)}, nil
+}
+
+// LoginAttemptStorage is an in-memory login attempt storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type LoginAttemptStorage struct {
+	sync.Mutex
+	storage map[string]model.LoginAttempts
+}
+
+// AddFailedLoginAttempt counts failed login attempt in memory.
+func (las *LoginAttemptStorage) AddFailedLoginAttempt(key string, resetBefore int64) (model.LoginAttempts, error) {
+	if len(key) == 0 {
+		return model.LoginAttempts{}, model.ErrorWrongDataFormat
+	}
+	las.Lock()
+	defer las.Unlock()
+
+	attempts, ok := las.storage[key]
+	if !ok || attempts.LastFailedAt < resetBefore {
+		attempts = model.LoginAttempts{Key: key}
+	}
+	attempts.Failures++
+	attempts.LastFailedAt = time.Now().Unix()
+	las.storage[key] = attempts
+	return attempts, nil
+}
+
+// LoginAttempts returns failed login attempts from memory.
+func (las *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	las.Lock()
+	defer las.Unlock()
+
+	if attempts, ok := las.storage[key]; ok {
+		return attempts, nil
+	}
+	return model.LoginAttempts{Key: key}, nil
+}
+
+// ResetLoginAttempts removes failed login attempts from memory.
+func (las *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	las.Lock()
+	defer las.Unlock()
+
+	delete(las.storage, key)
+	return nil
+}
+
+// Close clears storage.
+func (las *LoginAttemptStorage) Close() {
+	las.Lock()
+	defer las.Unlock()
+
+	for k := range las.storage {
+		delete(las.storage, k)
+	}
+}
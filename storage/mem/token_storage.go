@@ -1,42 +1,131 @@
 package mem
 
 import (
+	"sync"
+	"time"
+
 	"github.com/madappgang/identifo/model"
 )
 
 // NewTokenStorage creates an in-memory token storage.
 func NewTokenStorage() (model.TokenStorage, error) {
-	return &TokenStorage{storage: make(map[string]bool)}, nil
+	return &TokenStorage{storage: make(map[string]model.UserSession), revokedAt: make(map[string]int64)}, nil
 }
 
 // TokenStorage is an in-memory token storage.
 // Please do not use it in production, it has no disk swap or persistent cache support.
 type TokenStorage struct {
-	storage map[string]bool
+	sync.RWMutex
+	storage   map[string]model.UserSession
+	revokedAt map[string]int64
 }
 
 // SaveToken saves token in memory.
-func (ts *TokenStorage) SaveToken(token string) error {
-	ts.storage[token] = true
+func (ts *TokenStorage) SaveToken(token string, session model.UserSession) error {
+	ts.Lock()
+	defer ts.Unlock()
+	ts.storage[token] = session
 	return nil
 }
 
 // HasToken returns true if the token is present in the storage.
 func (ts *TokenStorage) HasToken(token string) bool {
-	has := ts.storage[token]
+	ts.RLock()
+	defer ts.RUnlock()
+	_, has := ts.storage[token]
 	return has
 }
 
 // DeleteToken removes token from memory storage.
-// Actually, just marks it as deleted.
 func (ts *TokenStorage) DeleteToken(token string) error {
-	ts.storage[token] = false
+	ts.Lock()
+	defer ts.Unlock()
+	delete(ts.storage, token)
+	return nil
+}
+
+// TokenSession returns the session of the token.
+func (ts *TokenStorage) TokenSession(token string) (model.UserSession, error) {
+	ts.RLock()
+	defer ts.RUnlock()
+	session, ok := ts.storage[token]
+	if !ok {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return session, nil
+}
+
+// TouchToken updates last use of the token session.
+func (ts *TokenStorage) TouchToken(token string, client model.ClientInfo) error {
+	ts.Lock()
+	defer ts.Unlock()
+	session, ok := ts.storage[token]
+	if !ok {
+		return model.ErrorNotFound
+	}
+	session.Touch(client)
+	ts.storage[token] = session
+	return nil
+}
+
+// UserSessions returns unexpired sessions of the user.
+func (ts *TokenStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	ts.RLock()
+	defer ts.RUnlock()
+	sessions := []model.UserSession{}
+	for _, s := range ts.storage {
+		if s.UserID == userID && !s.Expired() {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+// DeleteUserSession removes all tokens of the user's session.
+func (ts *TokenStorage) DeleteUserSession(userID, sessionID string) ([]string, error) {
+	ts.Lock()
+	defer ts.Unlock()
+	tokens := []string{}
+	for t, s := range ts.storage {
+		if s.UserID == userID && s.ID == sessionID {
+			tokens = append(tokens, t)
+			delete(ts.storage, t)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, model.ErrorNotFound
+	}
+	return tokens, nil
+}
+
+// RevokeUserTokens removes all refresh tokens of the user and records the time of revocation.
+func (ts *TokenStorage) RevokeUserTokens(userID string) error {
+	ts.Lock()
+	defer ts.Unlock()
+	for t, s := range ts.storage {
+		if s.UserID == userID {
+			delete(ts.storage, t)
+		}
+	}
+	ts.revokedAt[userID] = time.Now().Unix()
 	return nil
 }
 
+// UserTokensRevokedAt returns the time tokens of the user were revoked at.
+func (ts *TokenStorage) UserTokensRevokedAt(userID string) int64 {
+	ts.RLock()
+	defer ts.RUnlock()
+	return ts.revokedAt[userID]
+}
+
 // Close clears storage.
 func (ts *TokenStorage) Close() {
+	ts.Lock()
+	defer ts.Unlock()
 	for k := range ts.storage {
 		delete(ts.storage, k)
 	}
+	for k := range ts.revokedAt {
+		delete(ts.revokedAt, k)
+	}
 }
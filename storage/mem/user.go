@@ -7,15 +7,18 @@ import (
 
 // User data implementation.
 type userData struct {
-	ID         string        `json:"id,omitempty"`
-	Username   string        `json:"username,omitempty"`
-	Email      string        `json:"email,omitempty"`
-	Phone      string        `json:"phone,omitempty"`
-	Pswd       string        `json:"pswd,omitempty"`
-	Active     bool          `json:"active,omitempty"`
-	TFAInfo    model.TFAInfo `json:"tfa_info"`
-	AccessRole string        `json:"access_role,omitempty"`
-	Anonymous  bool          `json:"anonymous,omitempty"`
+	ID            string                     `json:"id,omitempty"`
+	Username      string                     `json:"username,omitempty"`
+	Email         string                     `json:"email,omitempty"`
+	EmailVerified bool                       `json:"email_verified,omitempty"`
+	Phone         string                     `json:"phone,omitempty"`
+	Pswd          string                     `json:"pswd,omitempty"`
+	Active        bool                       `json:"active,omitempty"`
+	TFAInfo       model.TFAInfo              `json:"tfa_info"`
+	Credentials   []model.WebauthnCredential `json:"webauthn_credentials,omitempty"`
+	FederatedIDs  []string                   `json:"federated_ids,omitempty"`
+	AccessRole    string                     `json:"access_role,omitempty"`
+	Anonymous     bool                       `json:"anonymous,omitempty"`
 }
 
 type user struct {
@@ -24,7 +27,7 @@ type user struct {
 
 func (u *user) Sanitize() {
 	u.userData.Pswd = ""
-	u.userData.TFAInfo.Secret = ""
+	u.userData.TFAInfo.Sanitize()
 }
 
 // ID implements model.User interface.
@@ -42,6 +45,12 @@ func (u *user) Email() string { return u.userData.Email }
 // SetEmail implements model.User interface.
 func (u *user) SetEmail(email string) { u.userData.Email = email }
 
+// EmailVerified implements model.User interface.
+func (u *user) EmailVerified() bool { return u.userData.EmailVerified }
+
+// SetEmailVerified implements model.User interface.
+func (u *user) SetEmailVerified(verified bool) { u.userData.EmailVerified = verified }
+
 // Phone implements model.User interface.
 func (u *user) Phone() string { return u.userData.Phone }
 
@@ -51,6 +60,19 @@ func (u *user) TFAInfo() model.TFAInfo { return u.userData.TFAInfo }
 // SetTFAInfo implements model.User interface.
 func (u *user) SetTFAInfo(tfaInfo model.TFAInfo) { u.userData.TFAInfo = tfaInfo }
 
+// WebauthnCredentials implements model.User interface.
+func (u *user) WebauthnCredentials() []model.WebauthnCredential { return u.userData.Credentials }
+
+// SetWebauthnCredentials implements model.User interface.
+func (u *user) SetWebauthnCredentials(credentials []model.WebauthnCredential) {
+	u.userData.Credentials = credentials
+}
+
+// FederatedIdentities implements model.User interface.
+func (u *user) FederatedIdentities() []model.FederatedIdentity {
+	return model.FederatedIdentitiesFromStrings(u.userData.FederatedIDs)
+}
+
 // PasswordHash implements model.User interface.
 func (u *user) PasswordHash() string { return u.userData.Pswd }
 
@@ -60,6 +82,9 @@ func (u *user) Active() bool { return u.userData.Active }
 // AccessRole implements model.User interface.
 func (u *user) AccessRole() string { return u.userData.AccessRole }
 
+// SetAccessRole implements model.User interface.
+func (u *user) SetAccessRole(role string) { u.userData.AccessRole = role }
+
 // IsAnonymous implements model.User interface.
 func (u *user) IsAnonymous() bool { return u.userData.Anonymous }
 
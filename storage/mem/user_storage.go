@@ -80,6 +80,11 @@ func (us *UserStorage) AddUserByPhone(phone, role string) (model.User, error) {
 	return randUser(), nil
 }
 
+// AddUserByEmail returns randomly generated user.
+func (us *UserStorage) AddUserByEmail(email, role string) (model.User, error) {
+	return randUser(), nil
+}
+
 // UserByFederatedID returns randomly generated user.
 func (us *UserStorage) UserByFederatedID(provider model.FederatedIdentityProvider, id string) (model.User, error) {
 	return randUser(), nil
@@ -90,11 +95,36 @@ func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityPr
 	return randUser(), nil
 }
 
+// LinkFederatedID does nothing here.
+func (us *UserStorage) LinkFederatedID(userID string, provider model.FederatedIdentityProvider, id string) error {
+	return nil
+}
+
+// UnlinkFederatedID does nothing here.
+func (us *UserStorage) UnlinkFederatedID(userID string, provider model.FederatedIdentityProvider, id string) error {
+	return nil
+}
+
 // UpdateUser returns what it receives.
 func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User, error) {
 	return newUser, nil
 }
 
+// ConsumeRecoveryCode does nothing here.
+func (us *UserStorage) ConsumeRecoveryCode(userID, codeHash string) error {
+	return nil
+}
+
+// SetRecoveryCodes does nothing here.
+func (us *UserStorage) SetRecoveryCodes(userID string, codeHashes []string) error {
+	return nil
+}
+
+// AddTrustedDevice does nothing here.
+func (us *UserStorage) AddTrustedDevice(userID string, device model.TrustedDevice) error {
+	return nil
+}
+
 // ResetPassword does nothing here.
 func (us *UserStorage) ResetPassword(id, password string) error {
 	return nil
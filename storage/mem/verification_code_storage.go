@@ -13,12 +13,12 @@ func NewVerificationCodeStorage() (model.VerificationCodeStorage, error) {
 type VerificationCodeStorage struct{}
 
 // IsVerificationCodeFound is always optimistic.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
+func (vcs *VerificationCodeStorage) IsVerificationCodeFound(identifier, code string) (bool, error) {
 	return true, nil
 }
 
 // CreateVerificationCode is always optimistic.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
+func (vcs *VerificationCodeStorage) CreateVerificationCode(identifier, code string) error {
 	return nil
 }
 
@@ -0,0 +1,58 @@
+package mem
+
+import (
+	"sync"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// NewWebauthnChallengeStorage creates an in-memory WebAuthn challenge storage.
+func NewWebauthnChallengeStorage() (model.WebauthnChallengeStorage, error) {
+	return &WebauthnChallengeStorage{storage: make(map[string]model.WebauthnChallenge)}, nil
+}
+
+// WebauthnChallengeStorage is an in-memory WebAuthn challenge storage.
+// Please do not use it in production, it has no disk swap or persistent cache support.
+type WebauthnChallengeStorage struct {
+	sync.Mutex
+	storage map[string]model.WebauthnChallenge
+}
+
+// SaveWebauthnChallenge saves challenge in memory.
+func (wcs *WebauthnChallengeStorage) SaveWebauthnChallenge(challenge model.WebauthnChallenge) error {
+	if len(challenge.Challenge) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+	wcs.Lock()
+	defer wcs.Unlock()
+
+	wcs.storage[challenge.Challenge] = challenge
+	return nil
+}
+
+// ConsumeWebauthnChallenge returns challenge and removes it from the storage.
+func (wcs *WebauthnChallengeStorage) ConsumeWebauthnChallenge(challenge string) (model.WebauthnChallenge, error) {
+	wcs.Lock()
+	defer wcs.Unlock()
+
+	wc, ok := wcs.storage[challenge]
+	if !ok {
+		return model.WebauthnChallenge{}, model.ErrorNotFound
+	}
+	delete(wcs.storage, challenge)
+
+	if wc.Expired() {
+		return model.WebauthnChallenge{}, model.ErrorNotFound
+	}
+	return wc, nil
+}
+
+// Close clears storage.
+func (wcs *WebauthnChallengeStorage) Close() {
+	wcs.Lock()
+	defer wcs.Unlock()
+
+	for k := range wcs.storage {
+		delete(wcs.storage, k)
+	}
+}
@@ -23,10 +23,15 @@ type appData struct {
 	Type                         model.AppType          `bson:"type,omitempty" json:"type,omitempty"`
 	RedirectURLs                 []string               `bson:"redirect_urls,omitempty" json:"redirect_urls,omitempty"`
 	RefreshTokenLifespan         int64                  `bson:"refresh_token_lifespan,omitempty" json:"refresh_token_lifespan,omitempty"`
+	RefreshTokenRotation         bool                   `bson:"refresh_token_rotation" json:"refresh_token_rotation"`
+	TrustedDeviceLifespan        int64                  `bson:"trusted_device_lifespan,omitempty" json:"trusted_device_lifespan,omitempty"`
+	TokenExchangeAudiences       []string               `bson:"token_exchange_audiences,omitempty" json:"token_exchange_audiences,omitempty"`
+	BackChannelLogoutURI         string                 `bson:"backchannel_logout_uri,omitempty" json:"backchannel_logout_uri,omitempty"`
 	InviteTokenLifespan          int64                  `bson:"invite_token_lifespan,omitempty" json:"invite_token_lifespan,omitempty"`
 	TokenLifespan                int64                  `bson:"token_lifespan,omitempty" json:"token_lifespan,omitempty"`
 	TokenPayload                 []string               `bson:"token_payload,omitempty" json:"token_payload,omitempty"`
 	RegistrationForbidden        bool                   `bson:"registration_forbidden" json:"registration_forbidden"`
+	EmailVerificationRequired    bool                   `bson:"email_verification_required" json:"email_verification_required"`
 	AnonymousRegistrationAllowed bool                   `bson:"anonymous_registration_allowed" json:"anonymous_registration_allowed"`
 	TFAStatus                    model.TFAStatus        `bson:"tfa_status" json:"tfa_status"`
 	DebugTFACode                 string                 `bson:"debug_tfa_code,omitempty" json:"debug_tfa_code,omitempty"`
@@ -37,6 +42,9 @@ type appData struct {
 	RolesBlacklist               []string               `bson:"roles_blacklist,omitempty" json:"roles_blacklist,omitempty"`
 	NewUserDefaultRole           string                 `bson:"new_user_default_role,omitempty" json:"new_user_default_role,omitempty"`
 	AppleInfo                    *model.AppleInfo       `bson:"apple_info,omitempty" json:"apple_info,omitempty"`
+	GoogleInfo                   *model.GoogleInfo      `bson:"google_info,omitempty" json:"google_info,omitempty"`
+	OIDCProviders                []model.OIDCProvider   `bson:"oidc_providers,omitempty" json:"oidc_providers,omitempty"`
+	SAMLInfo                     *model.SAMLInfo        `bson:"saml_info,omitempty" json:"saml_info,omitempty"`
 }
 
 // NewAppData instantiates MongoDB app data model from the general one.
@@ -52,12 +60,18 @@ func NewAppData(data model.AppData) (AppData, error) {
 		Description:                  data.Description(),
 		Scopes:                       data.Scopes(),
 		Offline:                      data.Offline(),
+		Type:                         data.Type(),
 		RedirectURLs:                 data.RedirectURLs(),
 		RefreshTokenLifespan:         data.RefreshTokenLifespan(),
+		RefreshTokenRotation:         data.RefreshTokenRotation(),
+		TrustedDeviceLifespan:        data.TrustedDeviceLifespan(),
+		TokenExchangeAudiences:       data.TokenExchangeAudiences(),
+		BackChannelLogoutURI:         data.BackChannelLogoutURI(),
 		InviteTokenLifespan:          data.InviteTokenLifespan(),
 		TokenLifespan:                data.TokenLifespan(),
 		TokenPayload:                 data.TokenPayload(),
 		RegistrationForbidden:        data.RegistrationForbidden(),
+		EmailVerificationRequired:    data.EmailVerificationRequired(),
 		AnonymousRegistrationAllowed: data.AnonymousRegistrationAllowed(),
 		TFAStatus:                    data.TFAStatus(),
 		AuthorizationWay:             data.AuthzWay(),
@@ -150,6 +164,18 @@ func (ad *AppData) InviteTokenLifespan() int64 { return ad.appData.InviteTokenLi
 // RefreshTokenLifespan implements model.AppData interface.
 func (ad *AppData) RefreshTokenLifespan() int64 { return ad.appData.RefreshTokenLifespan }
 
+// RefreshTokenRotation implements model.AppData interface.
+func (ad *AppData) RefreshTokenRotation() bool { return ad.appData.RefreshTokenRotation }
+
+// TrustedDeviceLifespan implements model.AppData interface.
+func (ad *AppData) TrustedDeviceLifespan() int64 { return ad.appData.TrustedDeviceLifespan }
+
+// TokenExchangeAudiences implements model.AppData interface.
+func (ad *AppData) TokenExchangeAudiences() []string { return ad.appData.TokenExchangeAudiences }
+
+// BackChannelLogoutURI implements model.AppData interface.
+func (ad *AppData) BackChannelLogoutURI() string { return ad.appData.BackChannelLogoutURI }
+
 // TokenLifespan implements model.AppData interface.
 func (ad *AppData) TokenLifespan() int64 { return ad.appData.TokenLifespan }
 
@@ -159,6 +185,9 @@ func (ad *AppData) TokenPayload() []string { return ad.appData.TokenPayload }
 // RegistrationForbidden implements model.AppData interface.
 func (ad *AppData) RegistrationForbidden() bool { return ad.appData.RegistrationForbidden }
 
+// EmailVerificationRequired implements model.AppData interface.
+func (ad *AppData) EmailVerificationRequired() bool { return ad.appData.EmailVerificationRequired }
+
 // AnonymousRegistrationAllowed implements model.AppData interface.
 func (ad *AppData) AnonymousRegistrationAllowed() bool { return ad.appData.AnonymousRegistrationAllowed }
 
@@ -189,6 +218,15 @@ func (ad *AppData) NewUserDefaultRole() string { return ad.appData.NewUserDefaul
 // AppleInfo implements model.AppData interface.
 func (ad *AppData) AppleInfo() *model.AppleInfo { return ad.appData.AppleInfo }
 
+// GoogleInfo implements model.AppData interface.
+func (ad *AppData) GoogleInfo() *model.GoogleInfo { return ad.appData.GoogleInfo }
+
+// OIDCProviders implements model.AppData interface.
+func (ad *AppData) OIDCProviders() []model.OIDCProvider { return ad.appData.OIDCProviders }
+
+// SAMLInfo implements model.AppData interface.
+func (ad *AppData) SAMLInfo() *model.SAMLInfo { return ad.appData.SAMLInfo }
+
 // SetSecret implements model.AppData interface.
 func (ad *AppData) SetSecret(secret string) {
 	if ad == nil {
@@ -206,6 +244,9 @@ func (ad *AppData) Sanitize() {
 	if ad.appData.AppleInfo != nil {
 		ad.appData.AppleInfo.ClientSecret = ""
 	}
+	for i := range ad.appData.OIDCProviders {
+		ad.appData.OIDCProviders[i].ClientSecret = ""
+	}
 
 	ad.appData.AuthorizationWay = ""
 	ad.appData.AuthorizationModel = ""
@@ -0,0 +1,87 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/madappgang/identifo/model"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// AuthorizationCodesCollection is a collection name for authorization codes.
+	AuthorizationCodesCollection = "AuthorizationCodes"
+
+	expiresAtField = "expiresAt"
+)
+
+// NewAuthorizationCodeStorage creates and inits MongoDB authorization code storage.
+func NewAuthorizationCodeStorage(db *DB) (model.AuthorizationCodeStorage, error) {
+	acs := &AuthorizationCodeStorage{db: db}
+
+	s := acs.db.Session(AuthorizationCodesCollection)
+	defer s.Close()
+
+	if err := s.EnsureIndex(mgo.Index{
+		Key:    []string{codeField},
+		Unique: true,
+	}); err != nil {
+		return nil, err
+	}
+
+	// Let MongoDB remove expired codes.
+	if err := s.EnsureIndex(mgo.Index{
+		Key:         []string{expiresAtField},
+		ExpireAfter: time.Second,
+	}); err != nil {
+		return nil, err
+	}
+	return acs, nil
+}
+
+// AuthorizationCodeStorage is a MongoDB authorization code storage.
+type AuthorizationCodeStorage struct {
+	db *DB
+}
+
+// authorizationCode is a MongoDB representation of model.AuthorizationCode.
+type authorizationCode struct {
+	model.AuthorizationCode `bson:",inline"`
+	ExpireAt                time.Time `bson:"expiresAt"`
+}
+
+// SaveAuthorizationCode saves authorization code in the database.
+func (acs *AuthorizationCodeStorage) SaveAuthorizationCode(code model.AuthorizationCode) error {
+	if len(code.Code) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+	s := acs.db.Session(AuthorizationCodesCollection)
+	defer s.Close()
+
+	return s.C.Insert(authorizationCode{AuthorizationCode: code, ExpireAt: time.Unix(code.ExpiresAt, 0)})
+}
+
+// ConsumeAuthorizationCode returns authorization code and removes it from the database.
+func (acs *AuthorizationCodeStorage) ConsumeAuthorizationCode(code string) (model.AuthorizationCode, error) {
+	s := acs.db.Session(AuthorizationCodesCollection)
+	defer s.Close()
+
+	var ac authorizationCode
+	if _, err := s.C.Find(bson.M{codeField: code}).Apply(mgo.Change{Remove: true}, &ac); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.AuthorizationCode{}, model.ErrorNotFound
+		}
+		return model.AuthorizationCode{}, err
+	}
+
+	// TTL monitor runs once a minute, so the code could still be there.
+	if ac.AuthorizationCode.Expired() {
+		return model.AuthorizationCode{}, model.ErrorNotFound
+	}
+	return ac.AuthorizationCode, nil
+}
+
+// Close closes database connection.
+func (acs *AuthorizationCodeStorage) Close() {
+	acs.db.Close()
+}
@@ -0,0 +1,152 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/madappgang/identifo/model"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// DeviceCodesCollection is a collection name for device codes.
+	DeviceCodesCollection = "DeviceCodes"
+
+	deviceCodeField = "device_code"
+	userCodeField   = "user_code"
+)
+
+// NewDeviceCodeStorage creates and inits MongoDB device code storage.
+func NewDeviceCodeStorage(db *DB) (model.DeviceCodeStorage, error) {
+	dcs := &DeviceCodeStorage{db: db}
+
+	s := dcs.db.Session(DeviceCodesCollection)
+	defer s.Close()
+
+	if err := s.EnsureIndex(mgo.Index{
+		Key:    []string{deviceCodeField},
+		Unique: true,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.EnsureIndex(mgo.Index{
+		Key:    []string{userCodeField},
+		Unique: true,
+	}); err != nil {
+		return nil, err
+	}
+
+	// Let MongoDB remove expired codes.
+	if err := s.EnsureIndex(mgo.Index{
+		Key:         []string{expiresAtField},
+		ExpireAfter: time.Second,
+	}); err != nil {
+		return nil, err
+	}
+	return dcs, nil
+}
+
+// DeviceCodeStorage is a MongoDB device code storage.
+type DeviceCodeStorage struct {
+	db *DB
+}
+
+// deviceCode is a MongoDB representation of model.DeviceCode.
+type deviceCode struct {
+	model.DeviceCode `bson:",inline"`
+	ExpireAt         time.Time `bson:"expiresAt"`
+}
+
+// SaveDeviceCode saves device code in the database.
+func (dcs *DeviceCodeStorage) SaveDeviceCode(code model.DeviceCode) error {
+	if len(code.DeviceCode) == 0 || len(code.UserCode) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+	s := dcs.db.Session(DeviceCodesCollection)
+	defer s.Close()
+
+	_, err := s.C.Upsert(bson.M{deviceCodeField: code.DeviceCode}, deviceCode{DeviceCode: code, ExpireAt: time.Unix(code.ExpiresAt, 0)})
+	return err
+}
+
+// DeviceCodeByDeviceCode returns device code by its device code value.
+func (dcs *DeviceCodeStorage) DeviceCodeByDeviceCode(code string) (model.DeviceCode, error) {
+	return dcs.deviceCode(bson.M{deviceCodeField: code})
+}
+
+// DeviceCodeByUserCode returns device code by the user code.
+func (dcs *DeviceCodeStorage) DeviceCodeByUserCode(userCode string) (model.DeviceCode, error) {
+	return dcs.deviceCode(bson.M{userCodeField: userCode})
+}
+
+func (dcs *DeviceCodeStorage) deviceCode(q bson.M) (model.DeviceCode, error) {
+	s := dcs.db.Session(DeviceCodesCollection)
+	defer s.Close()
+
+	var dc deviceCode
+	if err := s.C.Find(q).One(&dc); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.DeviceCode{}, model.ErrorNotFound
+		}
+		return model.DeviceCode{}, err
+	}
+
+	// TTL monitor runs once a minute, so the code could still be there.
+	if dc.DeviceCode.Expired() {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc.DeviceCode, nil
+}
+
+// UpdateDeviceCodePolling updates polling state of the device code in the database.
+func (dcs *DeviceCodeStorage) UpdateDeviceCodePolling(code string, lastPolledAt, interval int64) error {
+	s := dcs.db.Session(DeviceCodesCollection)
+	defer s.Close()
+
+	update := bson.M{"$set": bson.M{"last_polled_at": lastPolledAt, "interval": interval}}
+	if err := s.C.Update(bson.M{deviceCodeField: code}, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.ErrorNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ConsumeDeviceCode removes approved device code from the database and returns it.
+func (dcs *DeviceCodeStorage) ConsumeDeviceCode(code string) (model.DeviceCode, error) {
+	s := dcs.db.Session(DeviceCodesCollection)
+	defer s.Close()
+
+	var dc deviceCode
+	q := bson.M{deviceCodeField: code, "status": model.DeviceCodeStatusApproved}
+	if _, err := s.C.Find(q).Apply(mgo.Change{Remove: true}, &dc); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.DeviceCode{}, model.ErrorNotFound
+		}
+		return model.DeviceCode{}, err
+	}
+
+	// TTL monitor runs once a minute, so the code could still be there.
+	if dc.DeviceCode.Expired() {
+		return model.DeviceCode{}, model.ErrorNotFound
+	}
+	return dc.DeviceCode, nil
+}
+
+// DeleteDeviceCode removes device code from the database.
+func (dcs *DeviceCodeStorage) DeleteDeviceCode(code string) error {
+	s := dcs.db.Session(DeviceCodesCollection)
+	defer s.Close()
+
+	if err := s.C.Remove(bson.M{deviceCodeField: code}); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// Close closes database connection.
+func (dcs *DeviceCodeStorage) Close() {
+	dcs.db.Close()
+}
@@ -0,0 +1,87 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/madappgang/identifo/model"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// LoginAttemptsCollection is a collection name for failed login attempts.
+	LoginAttemptsCollection = "LoginAttempts"
+
+	failuresField     = "failures"
+	lastFailedAtField = "last_failed_at"
+)
+
+// NewLoginAttemptStorage creates and inits MongoDB login attempt storage.
+func NewLoginAttemptStorage(db *DB) (model.LoginAttemptStorage, error) {
+	return &LoginAttemptStorage{db: db}, nil
+}
+
+// LoginAttemptStorage is a MongoDB login attempt storage.
+type LoginAttemptStorage struct {
+	db *DB
+}
+
+// AddFailedLoginAttempt counts failed login attempt in the database.
+// Recent attempts are incremented atomically, stale ones are replaced.
+func (las *LoginAttemptStorage) AddFailedLoginAttempt(key string, resetBefore int64) (model.LoginAttempts, error) {
+	if len(key) == 0 {
+		return model.LoginAttempts{}, model.ErrorWrongDataFormat
+	}
+	s := las.db.Session(LoginAttemptsCollection)
+	defer s.Close()
+
+	now := time.Now().Unix()
+	var attempts model.LoginAttempts
+	_, err := s.C.Find(bson.M{"_id": key, lastFailedAtField: bson.M{"$gte": resetBefore}}).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{failuresField: 1}, "$set": bson.M{lastFailedAtField: now}},
+		ReturnNew: true,
+	}, &attempts)
+	if err == nil {
+		return attempts, nil
+	}
+	if err != mgo.ErrNotFound {
+		return model.LoginAttempts{}, err
+	}
+
+	attempts = model.LoginAttempts{Key: key, Failures: 1, LastFailedAt: now}
+	if _, err = s.C.UpsertId(key, attempts); err != nil {
+		return model.LoginAttempts{}, err
+	}
+	return attempts, nil
+}
+
+// LoginAttempts returns failed login attempts from the database.
+func (las *LoginAttemptStorage) LoginAttempts(key string) (model.LoginAttempts, error) {
+	s := las.db.Session(LoginAttemptsCollection)
+	defer s.Close()
+
+	var attempts model.LoginAttempts
+	if err := s.C.FindId(key).One(&attempts); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.LoginAttempts{Key: key}, nil
+		}
+		return model.LoginAttempts{}, err
+	}
+	return attempts, nil
+}
+
+// ResetLoginAttempts removes failed login attempts from the database.
+func (las *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	s := las.db.Session(LoginAttemptsCollection)
+	defer s.Close()
+
+	if err := s.C.RemoveId(key); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// Close closes underlying database.
+func (las *LoginAttemptStorage) Close() {
+	las.db.Close()
+}
@@ -1,18 +1,37 @@
 package mongo
 
 import (
+	"log"
+	"time"
+
 	"github.com/madappgang/identifo/model"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 const (
 	// TokensCollection is a collection to store refresh tokens.
 	TokensCollection = "RefreshTokens"
+	// UserTokensRevocationsCollection is a collection to store times users' tokens were revoked at.
+	UserTokensRevocationsCollection = "UserTokensRevocations"
+
+	tokenUserIDField = "user_id"
 )
 
 // NewTokenStorage creates a MongoDB token storage.
 func NewTokenStorage(db *DB) (model.TokenStorage, error) {
-	return &TokenStorage{db: db}, nil
+	ts := &TokenStorage{db: db}
+
+	s := ts.db.Session(TokensCollection)
+	defer s.Close()
+
+	// Users list their sessions.
+	if err := s.EnsureIndex(mgo.Index{
+		Key: []string{tokenUserIDField},
+	}); err != nil {
+		return nil, err
+	}
+	return ts, nil
 }
 
 // TokenStorage is a MongoDB token storage.
@@ -21,14 +40,14 @@ type TokenStorage struct {
 }
 
 // SaveToken saves token in the database.
-func (ts *TokenStorage) SaveToken(token string) error {
+func (ts *TokenStorage) SaveToken(token string, session model.UserSession) error {
 	if len(token) == 0 {
 		return model.ErrorWrongDataFormat
 	}
 	s := ts.db.Session(TokensCollection)
 	defer s.Close()
 
-	var t = Token{Token: token, ID: bson.NewObjectId()}
+	var t = Token{Token: token, ID: bson.NewObjectId(), UserSession: session}
 	err := s.C.Insert(t)
 	return err
 }
@@ -60,6 +79,123 @@ func (ts *TokenStorage) DeleteToken(token string) error {
 	return nil
 }
 
+// TokenSession returns the session of the token.
+// Tokens saved before sessions were introduced have none.
+func (ts *TokenStorage) TokenSession(token string) (model.UserSession, error) {
+	s := ts.db.Session(TokensCollection)
+	defer s.Close()
+
+	var t Token
+	if err := s.C.Find(bson.M{"token": token}).One(&t); err == mgo.ErrNotFound {
+		return model.UserSession{}, model.ErrorNotFound
+	} else if err != nil {
+		return model.UserSession{}, err
+	}
+	if len(t.UserSession.ID) == 0 {
+		return model.UserSession{}, model.ErrorNotFound
+	}
+	return t.UserSession, nil
+}
+
+// TouchToken updates last use of the token session.
+func (ts *TokenStorage) TouchToken(token string, client model.ClientInfo) error {
+	session, err := ts.TokenSession(token)
+	if err != nil {
+		return err
+	}
+	session.Touch(client)
+
+	s := ts.db.Session(TokensCollection)
+	defer s.Close()
+
+	update := bson.M{"$set": bson.M{
+		"device_name":  session.DeviceName,
+		"ip":           session.IP,
+		"user_agent":   session.UserAgent,
+		"last_used_at": session.LastUsedAt,
+	}}
+	if err = s.C.Update(bson.M{"token": token}, update); err == mgo.ErrNotFound {
+		return model.ErrorNotFound
+	}
+	return err
+}
+
+// UserSessions returns unexpired sessions of the user.
+func (ts *TokenStorage) UserSessions(userID string) ([]model.UserSession, error) {
+	s := ts.db.Session(TokensCollection)
+	defer s.Close()
+
+	var tokens []Token
+	if err := s.C.Find(bson.M{tokenUserIDField: userID}).All(&tokens); err != nil {
+		return nil, err
+	}
+
+	sessions := []model.UserSession{}
+	for _, t := range tokens {
+		if !t.UserSession.Expired() {
+			sessions = append(sessions, t.UserSession)
+		}
+	}
+	return sessions, nil
+}
+
+// DeleteUserSession removes all tokens of the user's session.
+func (ts *TokenStorage) DeleteUserSession(userID, sessionID string) ([]string, error) {
+	s := ts.db.Session(TokensCollection)
+	defer s.Close()
+
+	q := bson.M{tokenUserIDField: userID, "session_id": sessionID}
+	var found []Token
+	if err := s.C.Find(q).All(&found); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, model.ErrorNotFound
+	}
+	if _, err := s.C.RemoveAll(q); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, len(found))
+	for i, t := range found {
+		tokens[i] = t.Token
+	}
+	return tokens, nil
+}
+
+// RevokeUserTokens removes all refresh tokens of the user and records the time of revocation.
+func (ts *TokenStorage) RevokeUserTokens(userID string) error {
+	s := ts.db.Session(TokensCollection)
+	defer s.Close()
+
+	if _, err := s.C.RemoveAll(bson.M{tokenUserIDField: userID}); err != nil {
+		return err
+	}
+
+	rs := ts.db.Session(UserTokensRevocationsCollection)
+	defer rs.Close()
+
+	_, err := rs.C.UpsertId(userID, bson.M{"$set": bson.M{"revoked_at": time.Now().Unix()}})
+	return err
+}
+
+// UserTokensRevokedAt returns the time tokens of the user were revoked at.
+func (ts *TokenStorage) UserTokensRevokedAt(userID string) int64 {
+	s := ts.db.Session(UserTokensRevocationsCollection)
+	defer s.Close()
+
+	var revocation struct {
+		RevokedAt int64 `bson:"revoked_at"`
+	}
+	if err := s.C.FindId(userID).One(&revocation); err != nil {
+		if err != mgo.ErrNotFound {
+			log.Printf("Error getting revocation time of user %s tokens: %s\n", userID, err)
+		}
+		return 0
+	}
+	return revocation.RevokedAt
+}
+
 // Close closes database connection.
 func (ts *TokenStorage) Close() {
 	ts.db.Close()
@@ -67,6 +203,7 @@ func (ts *TokenStorage) Close() {
 
 // Token is struct to store tokens in database.
 type Token struct {
-	ID    bson.ObjectId `bson:"_id,omitempty"`
-	Token string        `bson:"token,omitempty"`
+	ID                bson.ObjectId `bson:"_id,omitempty"`
+	Token             string        `bson:"token,omitempty"`
+	model.UserSession `bson:",inline"`
 }
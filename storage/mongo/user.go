@@ -14,24 +14,26 @@ type User struct {
 
 // User data implementation.
 type userData struct {
-	ID              bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty"`
-	Username        string        `bson:"username,omitempty" json:"username,omitempty"`
-	Email           string        `bson:"email,omitempty" json:"email,omitempty"`
-	Phone           string        `bson:"phone,omitempty" json:"phone,omitempty"`
-	Pswd            string        `bson:"pswd,omitempty" json:"pswd,omitempty"`
-	Active          bool          `bson:"active,omitempty" json:"active,omitempty"`
-	TFAInfo         model.TFAInfo `bson:"tfa_info" json:"tfa_info"`
-	FederatedIDs    []string      `bson:"federated_ids,omitempty" json:"federated_ids,omitempty"`
-	NumOfLogins     int           `bson:"num_of_logins" json:"num_of_logins,omitempty"`
-	LatestLoginTime int64         `bson:"latest_login_time,omitempty" json:"latest_login_time,omitempty"`
-	AccessRole      string        `bson:"access_role,omitempty" json:"access_role,omitempty"`
-	Anonymous       bool          `json:"anonymous,omitempty"`
+	ID              bson.ObjectId              `bson:"_id,omitempty" json:"id,omitempty"`
+	Username        string                     `bson:"username,omitempty" json:"username,omitempty"`
+	Email           string                     `bson:"email,omitempty" json:"email,omitempty"`
+	EmailVerified   bool                       `bson:"email_verified" json:"email_verified,omitempty"`
+	Phone           string                     `bson:"phone,omitempty" json:"phone,omitempty"`
+	Pswd            string                     `bson:"pswd,omitempty" json:"pswd,omitempty"`
+	Active          bool                       `bson:"active,omitempty" json:"active,omitempty"`
+	TFAInfo         model.TFAInfo              `bson:"tfa_info" json:"tfa_info"`
+	Credentials     []model.WebauthnCredential `bson:"webauthn_credentials,omitempty" json:"webauthn_credentials,omitempty"`
+	FederatedIDs    []string                   `bson:"federated_ids,omitempty" json:"federated_ids,omitempty"`
+	NumOfLogins     int                        `bson:"num_of_logins" json:"num_of_logins,omitempty"`
+	LatestLoginTime int64                      `bson:"latest_login_time,omitempty" json:"latest_login_time,omitempty"`
+	AccessRole      string                     `bson:"access_role,omitempty" json:"access_role,omitempty"`
+	Anonymous       bool                       `json:"anonymous,omitempty"`
 }
 
 // Sanitize removes sensitive data.
 func (u *User) Sanitize() {
 	u.userData.Pswd = ""
-	u.userData.TFAInfo.Secret = ""
+	u.userData.TFAInfo.Sanitize()
 }
 
 // UserFromJSON deserializes user from JSON.
@@ -58,6 +60,12 @@ func (u *User) Email() string { return u.userData.Email }
 // SetEmail implements model.User interface.
 func (u *User) SetEmail(email string) { u.userData.Email = email }
 
+// EmailVerified implements model.User interface.
+func (u *User) EmailVerified() bool { return u.userData.EmailVerified }
+
+// SetEmailVerified implements model.User interface.
+func (u *User) SetEmailVerified(verified bool) { u.userData.EmailVerified = verified }
+
 // Phone implements model.User interface.
 func (u *User) Phone() string { return u.userData.Phone }
 
@@ -67,6 +75,19 @@ func (u *User) TFAInfo() model.TFAInfo { return u.userData.TFAInfo }
 // SetTFAInfo implements model.User interface.
 func (u *User) SetTFAInfo(tfaInfo model.TFAInfo) { u.userData.TFAInfo = tfaInfo }
 
+// WebauthnCredentials implements model.User interface.
+func (u *User) WebauthnCredentials() []model.WebauthnCredential { return u.userData.Credentials }
+
+// SetWebauthnCredentials implements model.User interface.
+func (u *User) SetWebauthnCredentials(credentials []model.WebauthnCredential) {
+	u.userData.Credentials = credentials
+}
+
+// FederatedIdentities implements model.User interface.
+func (u *User) FederatedIdentities() []model.FederatedIdentity {
+	return model.FederatedIdentitiesFromStrings(u.userData.FederatedIDs)
+}
+
 // PasswordHash implements model.User interface.
 func (u *User) PasswordHash() string { return u.userData.Pswd }
 
@@ -76,6 +97,9 @@ func (u *User) Active() bool { return u.userData.Active }
 // AccessRole implements model.User interface.
 func (u *User) AccessRole() string { return u.userData.AccessRole }
 
+// SetAccessRole implements model.User interface.
+func (u *User) SetAccessRole(role string) { u.userData.AccessRole = role }
+
 // IsAnonymous implements model.User interface.
 func (u *User) IsAnonymous() bool { return u.userData.Anonymous }
 
@@ -50,10 +50,56 @@ func NewUserStorage(db *DB) (model.UserStorage, error) {
 	}); err != nil {
 		return nil, err
 	}
+	if err := dedupeFederatedIDs(s.C); err != nil {
+		return nil, err
+	}
+	if err := s.C.EnsureIndex(mgo.Index{
+		Key:    []string{"federated_ids"},
+		Sparse: true,
+		Unique: true,
+	}); err != nil {
+		return nil, err
+	}
 
 	return us, nil
 }
 
+// dedupeFederatedIDs prepares the users for the unique index of federated IDs.
+// Federated IDs were not looked up by the right field before, so several users may share one.
+// The ID stays with the user created first, and is removed from the rest.
+// Empty arrays are indexed too, so they are removed as well.
+func dedupeFederatedIDs(c *mgo.Collection) error {
+	type duplicate struct {
+		ID    string          `bson:"_id"`
+		Users []bson.ObjectId `bson:"users"`
+	}
+	iter := c.Pipe([]bson.M{
+		{"$match": bson.M{"federated_ids": bson.M{"$exists": true}}},
+		{"$sort": bson.M{"_id": 1}},
+		{"$unwind": "$federated_ids"},
+		{"$group": bson.M{"_id": "$federated_ids", "users": bson.M{"$push": "$_id"}}},
+		{"$match": bson.M{"users.1": bson.M{"$exists": true}}},
+	}).AllowDiskUse().Iter()
+	for d := new(duplicate); iter.Next(d); d = new(duplicate) {
+		for _, userID := range d.Users[1:] {
+			if userID == d.Users[0] {
+				continue
+			}
+			if err := c.UpdateId(userID, bson.M{"$pull": bson.M{"federated_ids": d.ID}}); err != nil && err != mgo.ErrNotFound {
+				iter.Close()
+				return err
+			}
+			log.Printf("Federated ID %s is removed from user %s, it belongs to user %s\n", d.ID, userID.Hex(), d.Users[0].Hex())
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	_, err := c.UpdateAll(bson.M{"federated_ids": bson.M{"$size": 0}}, bson.M{"$unset": bson.M{"federated_ids": ""}})
+	return err
+}
+
 // UserStorage implements user storage interface.
 type UserStorage struct {
 	db *DB
@@ -102,7 +148,7 @@ func (us *UserStorage) UserByFederatedID(provider model.FederatedIdentityProvide
 	sid := string(provider) + ":" + id
 
 	var u userData
-	if err := s.C.Find(bson.M{"federatedIDs": sid}).One(&u); err != nil {
+	if err := s.C.Find(bson.M{"federated_ids": sid}).One(&u); err != nil {
 		return nil, model.ErrUserNotFound
 	}
 	//clear password hash
@@ -229,6 +275,29 @@ func (us *UserStorage) AddUserByPhone(phone, role string) (model.User, error) {
 	return &User{userData: u}, err
 }
 
+// AddUserByEmail registers new user with email.
+func (us *UserStorage) AddUserByEmail(email, role string) (model.User, error) {
+	s := us.db.Session(UsersCollection)
+	defer s.Close()
+
+	email = strings.ToLower(email)
+	u := userData{
+		ID:          bson.NewObjectId(),
+		Username:    email,
+		Email:       email,
+		Active:      true,
+		AccessRole:  role,
+		NumOfLogins: 0,
+	}
+
+	err := s.C.Insert(u)
+	if mgo.IsDup(err) {
+		return nil, model.ErrorUserExists
+	}
+
+	return &User{userData: u}, err
+}
+
 // AddUserByNameAndPassword registers new user.
 func (us *UserStorage) AddUserByNameAndPassword(username, password, role string, isAnonymous bool) (model.User, error) {
 	u := userData{
@@ -265,6 +334,45 @@ func (us *UserStorage) AddUserWithFederatedID(provider model.FederatedIdentityPr
 	return us.AddNewUser(&User{userData: u}, "")
 }
 
+// LinkFederatedID links one more federated identity to the user.
+func (us *UserStorage) LinkFederatedID(userID string, provider model.FederatedIdentityProvider, federatedID string) error {
+	if !bson.IsObjectIdHex(userID) {
+		return model.ErrorWrongDataFormat
+	}
+	s := us.db.Session(UsersCollection)
+	defer s.Close()
+
+	sid := model.FederatedIDString(provider, federatedID)
+	err := s.C.UpdateId(bson.ObjectIdHex(userID), bson.M{"$addToSet": bson.M{"federated_ids": sid}})
+	if mgo.IsDup(err) {
+		return model.ErrorUserExists
+	}
+	if err == mgo.ErrNotFound {
+		return model.ErrUserNotFound
+	}
+	return err
+}
+
+// UnlinkFederatedID unlinks federated identity from the user.
+func (us *UserStorage) UnlinkFederatedID(userID string, provider model.FederatedIdentityProvider, federatedID string) error {
+	if !bson.IsObjectIdHex(userID) {
+		return model.ErrorWrongDataFormat
+	}
+	s := us.db.Session(UsersCollection)
+	defer s.Close()
+
+	sid := model.FederatedIDString(provider, federatedID)
+	// Empty arrays are indexed, so the field is removed with the last ID not to break the unique index.
+	err := s.C.Update(bson.M{"_id": bson.ObjectIdHex(userID), "federated_ids": []string{sid}}, bson.M{"$unset": bson.M{"federated_ids": ""}})
+	if err == mgo.ErrNotFound {
+		err = s.C.Update(bson.M{"_id": bson.ObjectIdHex(userID), "federated_ids": sid}, bson.M{"$pull": bson.M{"federated_ids": sid}})
+	}
+	if err == mgo.ErrNotFound {
+		return model.ErrorNotFound
+	}
+	return err
+}
+
 // UpdateUser updates user in MongoDB storage.
 func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User, error) {
 	if !bson.IsObjectIdHex(userID) {
@@ -294,6 +402,60 @@ func (us *UserStorage) UpdateUser(userID string, newUser model.User) (model.User
 	return &User{userData: ud}, nil
 }
 
+// ConsumeRecoveryCode removes TFA recovery code hash from the user, the code is matched in the update query.
+func (us *UserStorage) ConsumeRecoveryCode(userID, codeHash string) error {
+	if !bson.IsObjectIdHex(userID) {
+		return model.ErrorWrongDataFormat
+	}
+	s := us.db.Session(UsersCollection)
+	defer s.Close()
+
+	err := s.C.Update(
+		bson.M{"_id": bson.ObjectIdHex(userID), "tfa_info.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"tfa_info.recovery_codes": codeHash}},
+	)
+	if err == mgo.ErrNotFound {
+		return model.ErrorNotFound
+	}
+	return err
+}
+
+// SetRecoveryCodes replaces TFA recovery code hashes of the user.
+func (us *UserStorage) SetRecoveryCodes(userID string, codeHashes []string) error {
+	if !bson.IsObjectIdHex(userID) {
+		return model.ErrorWrongDataFormat
+	}
+	s := us.db.Session(UsersCollection)
+	defer s.Close()
+
+	update := bson.M{"$set": bson.M{"tfa_info.recovery_codes": codeHashes, "tfa_info.recovery_codes_issued": true}}
+	if err := s.C.UpdateId(bson.ObjectIdHex(userID), update); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AddTrustedDevice pushes the device to TFA info of the user, expired devices are pulled before.
+func (us *UserStorage) AddTrustedDevice(userID string, device model.TrustedDevice) error {
+	if !bson.IsObjectIdHex(userID) {
+		return model.ErrorWrongDataFormat
+	}
+	s := us.db.Session(UsersCollection)
+	defer s.Close()
+
+	expired := bson.M{"$pull": bson.M{"tfa_info.trusted_devices": bson.M{"expires_at": bson.M{"$lte": time.Now().Unix()}}}}
+	if err := s.C.UpdateId(bson.ObjectIdHex(userID), expired); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.ErrUserNotFound
+		}
+		return err
+	}
+	return s.C.UpdateId(bson.ObjectIdHex(userID), bson.M{"$push": bson.M{"tfa_info.trusted_devices": device}})
+}
+
 // ResetPassword sets new user's password.
 func (us *UserStorage) ResetPassword(id, password string) error {
 	if !bson.IsObjectIdHex(id) {
@@ -15,9 +15,10 @@ const (
 	// verificationCodesExpirationTime specifies time before deleting records.
 	verificationCodesExpirationTime = 5 * time.Minute
 
-	phoneField     = "phone"
-	codeField      = "code"
-	createdAtField = "createdAt"
+	// identifierField keeps its historical name, as codes used to be sent to phone numbers only.
+	identifierField = "phone"
+	codeField       = "code"
+	createdAtField  = "createdAt"
 )
 
 // NewVerificationCodeStorage creates and inits MongoDB verification code storage.
@@ -28,7 +29,7 @@ func NewVerificationCodeStorage(db *DB) (model.VerificationCodeStorage, error) {
 	defer s.Close()
 
 	if err := s.EnsureIndex(mgo.Index{
-		Key:    []string{phoneField},
+		Key:    []string{identifierField},
 		Unique: true,
 	}); err != nil {
 		return nil, err
@@ -56,11 +57,11 @@ type VerificationCodeStorage struct {
 }
 
 // IsVerificationCodeFound checks whether verification code can be found.
-func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string) (bool, error) {
+func (vcs *VerificationCodeStorage) IsVerificationCodeFound(identifier, code string) (bool, error) {
 	s := vcs.db.Session(VerificationCodesCollection)
 	defer s.Close()
 
-	_, err := s.C.Find(bson.M{phoneField: phone, codeField: code}).Apply(mgo.Change{Remove: true}, nil)
+	_, err := s.C.Find(bson.M{identifierField: identifier, codeField: code}).Apply(mgo.Change{Remove: true}, nil)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			return false, nil
@@ -71,15 +72,15 @@ func (vcs *VerificationCodeStorage) IsVerificationCodeFound(phone, code string)
 }
 
 // CreateVerificationCode inserts new verification code to the database.
-func (vcs *VerificationCodeStorage) CreateVerificationCode(phone, code string) error {
+func (vcs *VerificationCodeStorage) CreateVerificationCode(identifier, code string) error {
 	s := vcs.db.Session(VerificationCodesCollection)
 	defer s.Close()
 
-	if _, err := s.C.RemoveAll(bson.M{phoneField: phone}); err != nil {
+	if _, err := s.C.RemoveAll(bson.M{identifierField: identifier}); err != nil {
 		return err
 	}
 
-	err := s.C.Insert(bson.M{phoneField: phone, codeField: code, createdAtField: time.Now()})
+	err := s.C.Insert(bson.M{identifierField: identifier, codeField: code, createdAtField: time.Now()})
 	return err
 }
 
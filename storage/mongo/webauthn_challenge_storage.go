@@ -0,0 +1,87 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/madappgang/identifo/model"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// WebauthnChallengesCollection is a collection name for WebAuthn challenges.
+	WebauthnChallengesCollection = "WebauthnChallenges"
+
+	challengeField = "challenge"
+)
+
+// NewWebauthnChallengeStorage creates and inits MongoDB WebAuthn challenge storage.
+func NewWebauthnChallengeStorage(db *DB) (model.WebauthnChallengeStorage, error) {
+	wcs := &WebauthnChallengeStorage{db: db}
+
+	s := wcs.db.Session(WebauthnChallengesCollection)
+	defer s.Close()
+
+	if err := s.EnsureIndex(mgo.Index{
+		Key:    []string{challengeField},
+		Unique: true,
+	}); err != nil {
+		return nil, err
+	}
+
+	// Let MongoDB remove expired challenges.
+	if err := s.EnsureIndex(mgo.Index{
+		Key:         []string{expiresAtField},
+		ExpireAfter: time.Second,
+	}); err != nil {
+		return nil, err
+	}
+	return wcs, nil
+}
+
+// WebauthnChallengeStorage is a MongoDB WebAuthn challenge storage.
+type WebauthnChallengeStorage struct {
+	db *DB
+}
+
+// webauthnChallenge is a MongoDB representation of model.WebauthnChallenge.
+type webauthnChallenge struct {
+	model.WebauthnChallenge `bson:",inline"`
+	ExpireAt                time.Time `bson:"expiresAt"`
+}
+
+// SaveWebauthnChallenge saves challenge in the database.
+func (wcs *WebauthnChallengeStorage) SaveWebauthnChallenge(challenge model.WebauthnChallenge) error {
+	if len(challenge.Challenge) == 0 {
+		return model.ErrorWrongDataFormat
+	}
+	s := wcs.db.Session(WebauthnChallengesCollection)
+	defer s.Close()
+
+	return s.C.Insert(webauthnChallenge{WebauthnChallenge: challenge, ExpireAt: time.Unix(challenge.ExpiresAt, 0)})
+}
+
+// ConsumeWebauthnChallenge returns challenge and removes it from the database.
+func (wcs *WebauthnChallengeStorage) ConsumeWebauthnChallenge(challenge string) (model.WebauthnChallenge, error) {
+	s := wcs.db.Session(WebauthnChallengesCollection)
+	defer s.Close()
+
+	var wc webauthnChallenge
+	if _, err := s.C.Find(bson.M{challengeField: challenge}).Apply(mgo.Change{Remove: true}, &wc); err != nil {
+		if err == mgo.ErrNotFound {
+			return model.WebauthnChallenge{}, model.ErrorNotFound
+		}
+		return model.WebauthnChallenge{}, err
+	}
+
+	// TTL monitor runs once a minute, so the challenge could still be there.
+	if wc.WebauthnChallenge.Expired() {
+		return model.WebauthnChallenge{}, model.ErrorNotFound
+	}
+	return wc.WebauthnChallenge, nil
+}
+
+// Close closes database connection.
+func (wcs *WebauthnChallengeStorage) Close() {
+	wcs.db.Close()
+}
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+)
+
+type impersonationData struct {
+	AppID  string   `json:"app_id" validate:"required"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// ImpersonateUser issues short-lived access token for the user in the app, so support team can see what the user sees.
+// The token names the admin in "act" claim, and never comes with a refresh token.
+func (ar *Router) ImpersonateUser() http.HandlerFunc {
+	type impersonationResponse struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		d := impersonationData{}
+		if ar.mustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		admin := new(adminLoginData)
+		if ar.getAdminAccountSettings(w, admin) != nil {
+			return
+		}
+
+		user, err := ar.userStorage.UserByID(userID)
+		if err == model.ErrUserNotFound {
+			ar.Error(w, err, http.StatusNotFound, "")
+			return
+		} else if err != nil {
+			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, "")
+			return
+		}
+
+		app, err := ar.appStorage.AppByID(d.AppID)
+		if err != nil {
+			ar.Error(w, fmt.Errorf("Error getting app by ID: %s", err), http.StatusBadRequest, "")
+			return
+		}
+
+		requested, err := ar.userStorage.RequestScopes(userID, d.Scopes)
+		if err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+		// There are no refresh tokens for impersonation, so offline access makes no sense.
+		scopes := make([]string, 0, len(requested))
+		for _, s := range requested {
+			if s != jwtService.OfflineScope {
+				scopes = append(scopes, s)
+			}
+		}
+
+		token, err := ar.tokenService.NewImpersonationToken(user, scopes, app, admin.Login)
+		if err != nil {
+			ar.Error(w, fmt.Errorf("Cannot create impersonation token: %s", err), http.StatusBadRequest, "")
+			return
+		}
+		tokenString, err := ar.tokenService.String(token)
+		if err != nil {
+			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.logger.Printf("Admin %s impersonated user %s in app %s", admin.Login, userID, app.ID())
+		ar.ServeJSON(w, http.StatusOK, &impersonationResponse{AccessToken: tokenString})
+	}
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// GetUserLockout shows failed login attempts of the user and whether they are locked out.
+func (ar *Router) GetUserLockout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		if _, err := ar.userStorage.UserByID(userID); err != nil {
+			if err == model.ErrUserNotFound {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, ar.loginLockout.Status(model.UserLoginAttemptsKey(userID)))
+	}
+}
+
+// ClearUserLockout unlocks the user and forgets their failed login attempts.
+func (ar *Router) ClearUserLockout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		if err := ar.loginLockout.Clear(model.UserLoginAttemptsKey(userID)); err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.logger.Printf("Lockout of user %s is cleared by admin", userID)
+		ar.ServeJSON(w, http.StatusOK, nil)
+	}
+}
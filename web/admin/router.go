@@ -8,6 +8,7 @@ import (
 	"path"
 
 	"github.com/gorilla/mux"
+	jwtService "github.com/madappgang/identifo/jwt/service"
 	"github.com/madappgang/identifo/model"
 	"github.com/rs/cors"
 	"github.com/urfave/negroni"
@@ -25,6 +26,10 @@ type Router struct {
 	userStorage          model.UserStorage
 	configurationStorage model.ConfigurationStorage
 	staticFilesStorage   model.StaticFilesStorage
+	tokenService         jwtService.TokenService
+	tokenStorage         model.TokenStorage
+	tokenBlacklist       model.TokenBlacklist
+	loginLockout         *model.LoginLockout
 	ServerConfigPath     string
 	ServerSettings       *model.ServerSettings
 	newSettings          *model.ServerSettings
@@ -75,6 +80,14 @@ func ServerSettingsOption(settings *model.ServerSettings) func(*Router) error {
 	}
 }
 
+// LoginLockoutOption is for seeing and clearing lockouts after repeated failed login attempts.
+func LoginLockoutOption(loginLockout *model.LoginLockout) func(*Router) error {
+	return func(r *Router) error {
+		r.loginLockout = loginLockout
+		return nil
+	}
+}
+
 // RedirectURLOption sets redirect url value.
 func RedirectURLOption(redirectURL string) func(*Router) error {
 	return func(r *Router) error {
@@ -92,9 +105,10 @@ func PathPrefixOptions(prefix string) func(r *Router) error {
 }
 
 // NewRouter creates and initializes new admin router.
-func NewRouter(logger *log.Logger, sServ model.SessionService, sStor model.SessionStorage, as model.AppStorage, us model.UserStorage, cs model.ConfigurationStorage, sfs model.StaticFilesStorage, options ...func(*Router) error) (model.Router, error) {
+func NewRouter(logger *log.Logger, sServ model.SessionService, sStor model.SessionStorage, as model.AppStorage, us model.UserStorage, cs model.ConfigurationStorage, sfs model.StaticFilesStorage, ts jwtService.TokenService, tstor model.TokenStorage, tb model.TokenBlacklist, options ...func(*Router) error) (model.Router, error) {
 	ar := Router{
 		middleware:           negroni.Classic(),
+		logger:               logger,
 		router:               mux.NewRouter(),
 		sessionService:       sServ,
 		sessionStorage:       sStor,
@@ -102,6 +116,9 @@ func NewRouter(logger *log.Logger, sServ model.SessionService, sStor model.Sessi
 		userStorage:          us,
 		configurationStorage: cs,
 		staticFilesStorage:   sfs,
+		tokenService:         ts,
+		tokenStorage:         tstor,
+		tokenBlacklist:       tb,
 	}
 
 	for _, option := range append(defaultOptions(), options...) {
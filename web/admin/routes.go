@@ -62,6 +62,12 @@ func (ar *Router) initRoutes() {
 	users.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.GetUser()).Methods("GET")
 	users.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.UpdateUser()).Methods("PUT")
 	users.Path("/{id:[a-zA-Z0-9]+}").HandlerFunc(ar.DeleteUser()).Methods("DELETE")
+	users.Path("/{id:[a-zA-Z0-9]+}/impersonate").HandlerFunc(ar.ImpersonateUser()).Methods("POST")
+	users.Path("/{id:[a-zA-Z0-9]+}/sessions").HandlerFunc(ar.FetchUserSessions()).Methods("GET")
+	users.Path("/{id:[a-zA-Z0-9]+}/sessions/{session_id}").HandlerFunc(ar.DeleteUserSession()).Methods("DELETE")
+	users.Path("/{id:[a-zA-Z0-9]+}/logout").HandlerFunc(ar.LogoutUser()).Methods("POST")
+	users.Path("/{id:[a-zA-Z0-9]+}/lockout").HandlerFunc(ar.GetUserLockout()).Methods("GET")
+	users.Path("/{id:[a-zA-Z0-9]+}/lockout").HandlerFunc(ar.ClearUserLockout()).Methods("DELETE")
 
 	ar.router.Path(`/{settings:settings/?}`).Handler(negroni.New(
 		ar.Session(),
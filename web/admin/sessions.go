@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// FetchUserSessions lists where the user is logged in.
+func (ar *Router) FetchUserSessions() http.HandlerFunc {
+	type sessionsResponse struct {
+		Sessions []model.UserSession `json:"sessions"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		sessions, err := ar.tokenStorage.UserSessions(userID)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, &sessionsResponse{Sessions: sessions})
+	}
+}
+
+// DeleteUserSession logs the user out of the session.
+func (ar *Router) DeleteUserSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+		sessionID := getRouteVar("session_id", r)
+
+		tokens, err := ar.tokenStorage.DeleteUserSession(userID, sessionID)
+		if err != nil {
+			if err == model.ErrorNotFound {
+				ar.Error(w, err, http.StatusNotFound, "")
+			} else {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+			return
+		}
+
+		for _, t := range tokens {
+			if err = ar.tokenBlacklist.Add(t); err != nil {
+				ar.logger.Println("Cannot blacklist refresh token of deleted session:", err)
+			}
+		}
+
+		ar.logger.Printf("Session %s of user %s is deleted by admin", sessionID, userID)
+		ar.ServeJSON(w, http.StatusOK, nil)
+	}
+}
+
+// LogoutUser logs the user out of all sessions, all tokens issued to the user before are revoked and trusted devices are forgotten.
+func (ar *Router) LogoutUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := getRouteVar("id", r)
+
+		if err := ar.tokenStorage.RevokeUserTokens(userID); err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		// Devices trusted to skip the second factor must pass it again too.
+		if err := model.ForgetTrustedDevices(ar.userStorage, userID); err != nil && err != model.ErrUserNotFound {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.logger.Printf("User %s is logged out of all sessions by admin", userID)
+		ar.ServeJSON(w, http.StatusOK, nil)
+	}
+}
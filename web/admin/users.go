@@ -122,6 +122,10 @@ func (ar *Router) UpdateUser() http.HandlerFunc {
 		}
 
 		user, err := ar.userStorage.UpdateUser(userID, u)
+		if err == model.ErrorUserExists {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
 		if err != nil {
 			ar.Error(w, ErrorInternalError, http.StatusInternalServerError, "")
 			return
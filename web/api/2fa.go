@@ -7,7 +7,9 @@ import (
 	"path"
 	"time"
 
+	"github.com/gorilla/mux"
 	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
 	"github.com/madappgang/identifo/model"
 	"github.com/madappgang/identifo/web/middleware"
 	"github.com/xlzd/gotp"
@@ -92,6 +94,9 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 	type requestBody struct {
 		TFACode string   `json:"tfa_code"`
 		Scopes  []string `json:"scopes"`
+		// RememberDevice asks to skip the second factor on this device next time, if the app allows it.
+		RememberDevice bool   `json:"remember_device"`
+		DeviceName     string `json:"device_name"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -130,14 +135,63 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 			return
 		}
 
-		totp := gotp.NewDefaultTOTP(user.TFAInfo().Secret)
-		dontNeedVerification := app.DebugTFACode() != "" && d.TFACode == app.DebugTFACode()
-
-		if verified := totp.Verify(d.TFACode, int(time.Now().Unix())); !(verified || dontNeedVerification) {
-			ar.Error(w, ErrorAPIRequestTFACodeInvalid, http.StatusUnauthorized, "", "FinalizeTFA.TOTP_Invalid")
+		userKey, ipKey := ar.loginAttemptsKeys(r, user.ID(), "")
+		if ar.checkLoginLocked(w, userKey, ipKey, "FinalizeTFA.checkLoginLocked") {
 			return
 		}
 
+		tfaInfo := user.TFAInfo()
+		totp := gotp.NewDefaultTOTP(tfaInfo.Secret)
+		dontNeedVerification := app.DebugTFACode() != "" && d.TFACode == app.DebugTFACode()
+		verified := totp.Verify(d.TFACode, int(time.Now().Unix())) || dontNeedVerification
+
+		// Recovery code is accepted in place of the one-time password, and is consumed on use.
+		// Otherwise, give the user recovery codes once the enrollment is completed.
+		var recoveryCodes []string
+		if !verified {
+			err = model.ErrorNotFound
+			if codeHash, ok := model.RecoveryCodeHash(d.TFACode); ok && tfaInfo.HasRecoveryCode(codeHash) {
+				// Storage removes the code only if it is still there, so concurrent requests cannot use it twice.
+				err = ar.userStorage.ConsumeRecoveryCode(userID, codeHash)
+			}
+			switch err {
+			case nil:
+			case model.ErrorNotFound:
+				ar.failLogin(userKey, ipKey, user.ID())
+				ar.Error(w, ErrorAPIRequestTFACodeInvalid, http.StatusUnauthorized, "", "FinalizeTFA.TOTP_Invalid")
+				return
+			default:
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "FinalizeTFA.ConsumeRecoveryCode")
+				return
+			}
+		} else if !tfaInfo.RecoveryCodesIssued {
+			if recoveryCodes, err = tfaInfo.NewRecoveryCodes(); err != nil {
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "FinalizeTFA.NewRecoveryCodes")
+				return
+			}
+			if err = ar.userStorage.SetRecoveryCodes(userID, tfaInfo.RecoveryCodes); err != nil {
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "FinalizeTFA.SetRecoveryCodes")
+				return
+			}
+		}
+
+		var trustedDeviceToken string
+		if d.RememberDevice && app.TrustedDeviceLifespan() > 0 {
+			if trustedDeviceToken, err = ar.trustDevice(user, app, d.DeviceName); err != nil {
+				ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "FinalizeTFA.trustDevice")
+				return
+			}
+		}
+
+		// TFA info is changed in the storage field by field, so that concurrent changes are not lost.
+		// Read it back to show the user what is left.
+		if !verified || recoveryCodes != nil || trustedDeviceToken != "" {
+			if user, err = ar.userStorage.UserByID(userID); err != nil {
+				ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, err.Error(), "FinalizeTFA.UserByID")
+				return
+			}
+		}
+
 		// Issue new access, and, if requested, refresh token, and then invalidate the old one.
 		scopes, err := ar.userStorage.RequestScopes(user.ID(), d.Scopes)
 		if err != nil {
@@ -146,7 +200,7 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 		}
 
 		offline := contains(scopes, jwtService.OfflineScope)
-		accessToken, refreshToken, err := ar.loginUser(user, d.Scopes, app, offline, false)
+		accessToken, refreshToken, err := ar.loginUser(user, d.Scopes, app, offline, false, model.ClientInfoFromRequest(r))
 		if err != nil {
 			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "LoginWithPassword.loginUser")
 			return
@@ -159,16 +213,184 @@ func (ar *Router) FinalizeTFA() http.HandlerFunc {
 
 		user.Sanitize()
 		result := &AuthResponse{
-			AccessToken:  accessToken,
-			RefreshToken: refreshToken,
-			User:         user,
+			AccessToken:        accessToken,
+			RefreshToken:       refreshToken,
+			User:               user,
+			RecoveryCodes:      recoveryCodes,
+			TrustedDeviceToken: trustedDeviceToken,
 		}
 
+		ar.loginLockout.Succeed(userKey)
 		ar.userStorage.UpdateLoginMetadata(user.ID())
 		ar.ServeJSON(w, http.StatusOK, result)
 	}
 }
 
+// trustDevice remembers the device in user's TFA info and issues the token for it.
+func (ar *Router) trustDevice(user model.User, app model.AppData, name string) (string, error) {
+	device, err := model.NewTrustedDevice(app.ID(), name, app.TrustedDeviceLifespan())
+	if err != nil {
+		return "", err
+	}
+	if err = ar.userStorage.AddTrustedDevice(user.ID(), device); err != nil {
+		return "", err
+	}
+
+	token, err := ar.tokenService.NewTrustedDeviceToken(user, app, device)
+	if err != nil {
+		return "", err
+	}
+	return ar.tokenService.String(token)
+}
+
+// isDeviceTrusted checks trusted device token presented on login.
+// The device must still be remembered in user's TFA info, so revoked devices are not trusted anymore.
+func (ar *Router) isDeviceTrusted(tokenString string, user model.User, app model.AppData) bool {
+	if tokenString == "" || app.TrustedDeviceLifespan() <= 0 {
+		return false
+	}
+
+	token, err := ar.tokenService.Parse(tokenString)
+	if err != nil {
+		return false
+	}
+	v := jwtValidator.NewValidator(app.ID(), ar.tokenService.Issuer(), "", jwtService.TrustedDeviceTokenType)
+	if err = v.Validate(token); err != nil || token.UserID() != user.ID() {
+		return false
+	}
+
+	return user.TFAInfo().IsDeviceTrusted(token.Payload()[jwtService.PayloadTrustedDevice], app.ID(), app.TrustedDeviceLifespan())
+}
+
+// RegenerateRecoveryCodes replaces user's TFA recovery codes with a fresh set.
+// Whoever holds the access token must not be able to take over the second factor,
+// so the user confirms the password or the current one-time password.
+func (ar *Router) RegenerateRecoveryCodes() http.HandlerFunc {
+	type requestBody struct {
+		Password string `json:"password"`
+		TFACode  string `json:"tfa_code"`
+	}
+	type recoveryCodesResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := requestBody{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+		if d.Password == "" && d.TFACode == "" {
+			ar.Error(w, ErrorAPIRequestReauthenticationRequired, http.StatusBadRequest, "", "RegenerateRecoveryCodes.empty")
+			return
+		}
+
+		accessTokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte)
+		if !ok {
+			ar.Error(w, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, "Token bytes are not in context.", "RegenerateRecoveryCodes.TokenBytesFromContext")
+			return
+		}
+
+		userID, err := ar.getTokenSubject(string(accessTokenBytes))
+		if err != nil {
+			ar.Error(w, ErrorAPIAppCannotExtractTokenSubject, http.StatusInternalServerError, err.Error(), "RegenerateRecoveryCodes.getTokenSubject")
+			return
+		}
+
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, err.Error(), "RegenerateRecoveryCodes.UserByID")
+			return
+		}
+
+		tfaInfo := user.TFAInfo()
+		if !tfaInfo.IsEnabled {
+			ar.Error(w, ErrorAPIRequestPleaseEnableTFA, http.StatusBadRequest, "", "RegenerateRecoveryCodes.IsEnabled")
+			return
+		}
+
+		userKey, ipKey := ar.loginAttemptsKeys(r, user.ID(), "")
+		if ar.checkLoginLocked(w, userKey, ipKey, "RegenerateRecoveryCodes.checkLoginLocked") {
+			return
+		}
+		if d.TFACode != "" {
+			if tfaInfo.Secret == "" || !gotp.NewDefaultTOTP(tfaInfo.Secret).Verify(d.TFACode, int(time.Now().Unix())) {
+				ar.failLogin(userKey, ipKey, user.ID())
+				ar.Error(w, ErrorAPIRequestTFACodeInvalid, http.StatusUnauthorized, "", "RegenerateRecoveryCodes.TOTP_Invalid")
+				return
+			}
+		} else if _, err = ar.userStorage.UserByNamePassword(user.Username(), d.Password); err != nil {
+			ar.failLogin(userKey, ipKey, user.ID())
+			ar.Error(w, ErrorAPIRequestIncorrectEmailOrPassword, http.StatusUnauthorized, err.Error(), "RegenerateRecoveryCodes.UserByNamePassword")
+			return
+		}
+
+		codes, err := tfaInfo.NewRecoveryCodes()
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "RegenerateRecoveryCodes.NewRecoveryCodes")
+			return
+		}
+		if err = ar.userStorage.SetRecoveryCodes(userID, tfaInfo.RecoveryCodes); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "RegenerateRecoveryCodes.SetRecoveryCodes")
+			return
+		}
+
+		ar.loginLockout.Succeed(userKey)
+		ar.ServeJSON(w, http.StatusOK, &recoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// TrustedDevices lists devices the user skips the second factor on.
+func (ar *Router) TrustedDevices() http.HandlerFunc {
+	type trustedDevicesResponse struct {
+		TrustedDevices []model.TrustedDevice `json:"trusted_devices"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "TrustedDevices.UserByID")
+			return
+		}
+
+		devices := []model.TrustedDevice{}
+		now := time.Now().Unix()
+		for _, d := range user.TFAInfo().TrustedDevices {
+			if d.ExpiresAt > now {
+				devices = append(devices, d)
+			}
+		}
+		ar.ServeJSON(w, http.StatusOK, &trustedDevicesResponse{TrustedDevices: devices})
+	}
+}
+
+// RevokeTrustedDevice makes the second factor required on the device again.
+func (ar *Router) RevokeTrustedDevice() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "RevokeTrustedDevice.UserByID")
+			return
+		}
+
+		tfaInfo := user.TFAInfo()
+		if !tfaInfo.RevokeTrustedDevice(mux.Vars(r)["id"]) {
+			ar.Error(w, ErrorAPITrustedDeviceNotFound, http.StatusNotFound, "", "RevokeTrustedDevice.RevokeTrustedDevice")
+			return
+		}
+		user.SetTFAInfo(tfaInfo)
+
+		if _, err = ar.userStorage.UpdateUser(userID, user); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "RevokeTrustedDevice.UpdateUser")
+			return
+		}
+
+		result := map[string]string{"result": "ok"}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
+
 // RequestDisabledTFA requests link for disabling TFA.
 func (ar *Router) RequestDisabledTFA() http.HandlerFunc {
 	type requestBody struct {
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+	"github.com/madappgang/identifo/web/middleware"
+)
+
+const emailVerificationCodeLength = 6
+
+// RequestEmailCode sends email with verification code.
+// To authenticate, user must have a valid email.
+func (ar *Router) RequestEmailCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.EmailCode {
+			ar.Error(w, ErrorAPIAppEmailCodeLoginNotSupported, http.StatusBadRequest, "Application does not support login via email code", "RequestEmailCode.supportedLoginWays")
+			return
+		}
+
+		var authData EmailLogin
+		if err := json.NewDecoder(r.Body).Decode(&authData); err != nil {
+			ar.Error(w, ErrorAPIRequestBodyInvalid, http.StatusBadRequest, err.Error(), "RequestEmailCode.Unmarshal")
+			return
+		}
+
+		if err := authData.validateEmail(); err != nil {
+			ar.Error(w, ErrorAPIRequestBodyParamsInvalid, http.StatusBadRequest, err.Error(), "RequestEmailCode.validateEmail")
+			return
+		}
+
+		codeKey := model.EmailCodeRequestsKey(authData.Email)
+		ipKey := model.IPLoginAttemptsKey(ar.loginLockout.ClientIP(r))
+		if ar.checkLoginLocked(w, codeKey, ipKey, "RequestEmailCode.checkLoginLocked") {
+			return
+		}
+		// Sent codes are counted as failed attempts until one of them is used, so the requests are throttled.
+		ar.loginLockout.Fail(codeKey, ipKey)
+
+		code := randStringBytes(emailVerificationCodeLength)
+		if err := ar.verificationCodeStorage.CreateVerificationCode(authData.Email, code); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "RequestEmailCode.CreateVerificationCode")
+			return
+		}
+
+		if err := ar.emailService.SendTFAEmail("Login code", authData.Email, code); err != nil {
+			ar.Error(w, ErrorAPIEmailNotSent, http.StatusInternalServerError, fmt.Sprintf("Unable to send email. %s", err), "RequestEmailCode.SendTFAEmail")
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, map[string]string{"message": "Email code is sent"})
+	}
+}
+
+// EmailLogin authenticates user with email and verification code.
+// If user exists - create new session and return token.
+// If user does not exist and the app allows registration - register and then login.
+// If code is invalid - return error.
+func (ar *Router) EmailLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.EmailCode {
+			ar.Error(w, ErrorAPIAppEmailCodeLoginNotSupported, http.StatusBadRequest, "Application does not support login via email code", "EmailLogin.supportedLoginWays")
+			return
+		}
+
+		var authData EmailLogin
+		if err := json.NewDecoder(r.Body).Decode(&authData); err != nil {
+			ar.Error(w, ErrorAPIRequestBodyInvalid, http.StatusBadRequest, err.Error(), "EmailLogin.Unmarshal")
+			return
+		}
+		if err := authData.validateCodeAndEmail(); err != nil {
+			ar.Error(w, ErrorAPIRequestBodyParamsInvalid, http.StatusBadRequest, err.Error(), "EmailLogin.validateCodeAndEmail")
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if app == nil {
+			ar.logger.Println("Error getting App")
+			ar.Error(w, ErrorAPIRequestAppIDInvalid, http.StatusBadRequest, "App is not in context.", "EmailLogin.AppFromContext")
+			return
+		}
+
+		var userID string
+		user, err := ar.userStorage.UserByEmail(authData.Email)
+		if err == nil {
+			userID = user.ID()
+		}
+		userKey, ipKey := ar.loginAttemptsKeys(r, userID, authData.Email)
+		if ar.checkLoginLocked(w, userKey, ipKey, "EmailLogin.checkLoginLocked") {
+			return
+		}
+
+		needVerification := app.DebugTFACode() == "" || authData.Code != app.DebugTFACode()
+		if needVerification { // check verification code
+			if exists, err := ar.verificationCodeStorage.IsVerificationCodeFound(authData.Email, authData.Code); err != nil {
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "EmailLogin.IsVerificationCodeFound.error")
+				return
+			} else if !exists {
+				ar.failLogin(userKey, ipKey, userID)
+				ar.Error(w, ErrorAPIVerificationCodeInvalid, http.StatusUnauthorized, "Invalid email or verification code", "EmailLogin.IsVerificationCodeFound.not_exists")
+				return
+			}
+		}
+
+		if err == model.ErrUserNotFound {
+			if app.RegistrationForbidden() {
+				ar.Error(w, ErrorAPIAppRegistrationForbidden, http.StatusForbidden, "Registration is forbidden in app.", "EmailLogin.RegistrationForbidden")
+				return
+			}
+			user, err = ar.userStorage.AddUserByEmail(authData.Email, app.NewUserDefaultRole())
+		}
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "EmailLogin.UserByEmail")
+			return
+		}
+
+		// Authorize user if the app requires authorization.
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    user.AccessRole(),
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err := ar.Authorizer.Authorize(azi); err != nil {
+			ar.Error(w, ErrorAPIAppAccessDenied, http.StatusForbidden, err.Error(), "EmailLogin.Authorizer")
+			return
+		}
+
+		// Valid code proves that the user owns the email.
+		if !user.EmailVerified() {
+			user.SetEmailVerified(true)
+			if user, err = ar.userStorage.UpdateUser(user.ID(), user); err != nil {
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "EmailLogin.UpdateUser")
+				return
+			}
+		}
+
+		scopes, err := ar.userStorage.RequestScopes(user.ID(), authData.Scopes)
+		if err != nil {
+			ar.Error(w, ErrorAPIRequestScopesForbidden, http.StatusForbidden, err.Error(), "EmailLogin.RequestScopes")
+			return
+		}
+
+		// Check if we should require user to authenticate with 2FA.
+		require2FA, err := ar.check2FA(w, app.TFAStatus(), user.TFAInfo(), false)
+		if err != nil {
+			return
+		}
+
+		offline := contains(scopes, jwtService.OfflineScope)
+		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, require2FA, model.ClientInfoFromRequest(r))
+		if err != nil {
+			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "EmailLogin.loginUser")
+			return
+		}
+
+		// The code has been used, so the user may request new ones.
+		ar.loginLockout.Succeed(model.EmailCodeRequestsKey(authData.Email))
+
+		if require2FA {
+			if err = ar.sendTFACode(w, user, "EmailLogin.sendTFACode"); err != nil {
+				return
+			}
+		} else {
+			// Failed attempts are forgotten once the second factor is verified too.
+			ar.loginLockout.Succeed(userKey)
+			ar.userStorage.UpdateLoginMetadata(user.ID())
+		}
+
+		user.Sanitize()
+		result := AuthResponse{
+			AccessToken:    accessToken,
+			RefreshToken:   refreshToken,
+			User:           user,
+			NeedFurtherTFA: require2FA,
+		}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
+
+// EmailLogin is used to parse input data from the client during email login.
+type EmailLogin struct {
+	Email  string   `json:"email"`
+	Code   string   `json:"code"`
+	Scopes []string `json:"scopes"`
+}
+
+func (l *EmailLogin) validateCodeAndEmail() error {
+	if len(l.Code) == 0 {
+		return errors.New("Verification code is too short or missing. ")
+	}
+	return l.validateEmail()
+}
+
+func (l *EmailLogin) validateEmail() error {
+	l.Email = strings.ToLower(strings.TrimSpace(l.Email))
+	if !model.EmailRegexp.MatchString(l.Email) {
+		return errors.New("Email is not valid. ")
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package api
+
+import (
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/shared"
+)
+
+// sendEmailVerification sends the link to verify user's email address.
+// The link leads to the web router page which consumes the verification token.
+func (ar *Router) sendEmailVerification(user model.User) error {
+	return shared.SendEmailVerification(ar.tokenService, ar.emailService, user, ar.Host, ar.WebRouterPrefix)
+}
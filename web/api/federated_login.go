@@ -18,6 +18,77 @@ type FederatedLoginData struct {
 	RegisterIfNew       bool     `json:"register_if_new,omitempty"`
 	Scopes              []string `json:"scopes,omitempty"`
 	AuthorizationCode   string   `json:"authorization_code,omitempty"` // Specific for Sign In with Apple.
+	IDToken             string   `json:"id_token,omitempty"`           // Specific for Sign In with Google and OpenID Connect providers.
+}
+
+// federatedProvider returns user ID at the identity provider, using the credentials from login data.
+type federatedProvider func(app model.AppData, d FederatedLoginData) (string, error)
+
+// federatedConfigError is when the app lacks settings needed for the identity provider.
+type federatedConfigError MessageID
+
+func (e federatedConfigError) Error() string { return GetMessage(MessageID(e)) }
+
+// builtinFederatedProviders are identity providers supported out of the box.
+func (ar *Router) builtinFederatedProviders() map[model.FederatedIdentityProvider]federatedProvider {
+	return map[model.FederatedIdentityProvider]federatedProvider{
+		model.FacebookIDProvider: func(app model.AppData, d FederatedLoginData) (string, error) {
+			return ar.FacebookUserID(d.AccessToken)
+		},
+		model.AppleIDProvider: func(app model.AppData, d FederatedLoginData) (string, error) {
+			if app.AppleInfo() == nil {
+				return "", federatedConfigError(ErrorAPIAppFederatedProviderEmptyAppleInfo)
+			}
+			return ar.AppleUserID(d.AuthorizationCode, app.AppleInfo())
+		},
+		model.GoogleIDProvider: func(app model.AppData, d FederatedLoginData) (string, error) {
+			if app.GoogleInfo() == nil {
+				return "", federatedConfigError(ErrorAPIAppFederatedProviderEmptyGoogleInfo)
+			}
+			return ar.GoogleUserID(d.IDToken, app.GoogleInfo())
+		},
+	}
+}
+
+// findFederatedProvider finds the identity provider by its name in login request.
+// Generic OpenID Connect providers configured for the app take precedence over built-in ones.
+func (ar *Router) findFederatedProvider(app model.AppData, name string, builtin map[model.FederatedIdentityProvider]federatedProvider) (model.FederatedIdentityProvider, federatedProvider, bool) {
+	for _, p := range app.OIDCProviders() {
+		if p.Key != "" && strings.EqualFold(p.Key, name) {
+			provider := p
+			return provider.FederatedIdentityProvider(), func(app model.AppData, d FederatedLoginData) (string, error) {
+				return ar.OIDCUserID(d.IDToken, provider)
+			}, true
+		}
+	}
+
+	fid := model.FederatedIdentityProvider(strings.ToUpper(name))
+	provider, ok := builtin[fid]
+	return fid, provider, ok
+}
+
+// federatedUserID checks the identity provider credentials from the request, and returns the provider and user ID there.
+// If the check fails, it writes the error response and returns false.
+func (ar *Router) federatedUserID(w http.ResponseWriter, app model.AppData, d FederatedLoginData, builtin map[model.FederatedIdentityProvider]federatedProvider, where string) (model.FederatedIdentityProvider, string, bool) {
+	fid, provider, ok := ar.findFederatedProvider(app, d.FederatedIDProvider, builtin)
+	if !ok {
+		ar.logger.Println("Federated provider is not supported:", d.FederatedIDProvider)
+		ar.Error(w, ErrorAPIAppFederatedProviderNotSupported, http.StatusBadRequest, fmt.Sprintf("UnsupportedProvider: %v", d.FederatedIDProvider), where+".findFederatedProvider")
+		return "", "", false
+	}
+
+	federatedID, err := provider(app, d)
+	if cfgErr, ok := err.(federatedConfigError); ok {
+		ar.logger.Println("App is not configured for federated provider:", d.FederatedIDProvider)
+		ar.Error(w, MessageID(cfgErr), http.StatusBadRequest, cfgErr.Error(), where+".provider_config")
+		return "", "", false
+	}
+	if err != nil {
+		ar.logger.Println("Error getting federated user ID:", err)
+		ar.Error(w, ErrorAPIAppFederatedProviderEmptyUserID, http.StatusBadRequest, err.Error(), where+".switch_providers.err")
+		return "", "", false
+	}
+	return fid, federatedID, true
 }
 
 // FederatedLogin provides login/registration with federated identity.
@@ -28,12 +99,7 @@ type FederatedLoginData struct {
 // If register_if_new presents - function creates new user without username/password,
 // there is a dedicated endpoint to link username/password to federated account.
 func (ar *Router) FederatedLogin() http.HandlerFunc {
-	var federatedProviders = map[string]bool{
-		strings.ToLower(string(model.FacebookIDProvider)): true,
-		strings.ToLower(string(model.AppleIDProvider)):    true,
-		strings.ToLower(string(model.GoogleIDProvider)):   false, //TODO: add later
-		strings.ToLower(string(model.TwitterIDProvider)):  false, //TODO: add later
-	}
+	builtinProviders := ar.builtinFederatedProviders()
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !ar.SupportedLoginWays.Federated {
@@ -46,12 +112,6 @@ func (ar *Router) FederatedLogin() http.HandlerFunc {
 			return
 		}
 
-		if !federatedProviders[strings.ToLower(d.FederatedIDProvider)] {
-			ar.logger.Println("Federated provider is not supported:", d.FederatedIDProvider)
-			ar.Error(w, ErrorAPIAppFederatedProviderNotSupported, http.StatusBadRequest, fmt.Sprintf("UnsupportedProvider: %v", d.FederatedIDProvider), "FederatedLogin.federatedProviders[]")
-			return
-		}
-
 		app := middleware.AppFromContext(r.Context())
 		if app == nil {
 			ar.logger.Println("Error getting App")
@@ -59,28 +119,8 @@ func (ar *Router) FederatedLogin() http.HandlerFunc {
 			return
 		}
 
-		var federatedID string
-		var err error
-
-		fid := model.FederatedIdentityProvider(strings.ToUpper(d.FederatedIDProvider))
-		switch fid {
-		case model.FacebookIDProvider:
-			federatedID, err = ar.FacebookUserID(d.AccessToken)
-		case model.AppleIDProvider:
-			if app.AppleInfo() == nil {
-				ar.logger.Println("Empty apple info")
-				ar.Error(w, ErrorAPIAppFederatedProviderEmptyAppleInfo, http.StatusBadRequest, "App does not have Apple info.", "FederatedLogin.switch_providers_apple")
-				return
-			}
-			federatedID, err = ar.AppleUserID(d.AuthorizationCode, app.AppleInfo())
-		default:
-			ar.Error(w, ErrorAPIAppFederatedProviderNotSupported, http.StatusBadRequest, fmt.Sprintf("UnsupportedProvider: %v", fid), "FederatedLogin.switch_providers_default")
-			return
-		}
-
-		if err != nil {
-			ar.logger.Println("Error getting federated user ID:", err)
-			ar.Error(w, ErrorAPIAppFederatedProviderEmptyUserID, http.StatusBadRequest, err.Error(), "FederatedLogin.switch_providers.err")
+		fid, federatedID, ok := ar.federatedUserID(w, app, d, builtinProviders, "FederatedLogin")
+		if !ok {
 			return
 		}
 
@@ -112,6 +152,11 @@ func (ar *Router) FederatedLogin() http.HandlerFunc {
 			return
 		}
 
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			ar.Error(w, ErrorAPIRequestEmailNotVerified, http.StatusForbidden, "", "FederatedLogin.EmailVerified")
+			return
+		}
+
 		// Request permissions for the user.
 		scopes, err := ar.userStorage.RequestScopes(user.ID(), d.Scopes)
 		if err != nil {
@@ -134,7 +179,7 @@ func (ar *Router) FederatedLogin() http.HandlerFunc {
 		refreshString := ""
 		//requesting offline access ?
 		if contains(scopes, jwtService.OfflineScope) {
-			refresh, err := ar.tokenService.NewRefreshToken(user, scopes, app)
+			refresh, err := ar.tokenService.NewRefreshToken(user, scopes, app, model.ClientInfoFromRequest(r))
 			if err != nil {
 				ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, err.Error(), "FederatedLogin.tokenService_NewRefreshToken")
 				return
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/madappgang/identifo/identity_providers/google"
+	"github.com/madappgang/identifo/model"
+)
+
+// ErrGoogleEmptyUserID is when Google user ID is empty.
+var ErrGoogleEmptyUserID = errors.New("Google user id is not accessible. ")
+
+// GoogleUserID returns Google user ID.
+func (ar *Router) GoogleUserID(idToken string, googleInfo *model.GoogleInfo) (string, error) {
+	gc := google.NewClient(googleInfo)
+	googleProfile, err := gc.MyProfile(idToken)
+	if err != nil {
+		return "", err
+	}
+
+	if len(googleProfile.ID) == 0 {
+		return "", ErrGoogleEmptyUserID
+	}
+	return googleProfile.ID, nil
+}
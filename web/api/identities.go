@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/middleware"
+)
+
+type federatedIdentitiesResponse struct {
+	Identities []model.FederatedIdentity `json:"identities"`
+}
+
+// FederatedIdentities lists federated identities linked to the user.
+func (ar *Router) FederatedIdentities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "FederatedIdentities.UserByID")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, &federatedIdentitiesResponse{Identities: user.FederatedIdentities()})
+	}
+}
+
+// LinkFederatedIdentity links one more federated identity to the user, so the user can log in with it too.
+// The identity provider credentials are sent the same way as for federated login.
+func (ar *Router) LinkFederatedIdentity() http.HandlerFunc {
+	builtinProviders := ar.builtinFederatedProviders()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Federated {
+			ar.Error(w, ErrorAPIAppFederatedLoginNotSupported, http.StatusBadRequest, "Application does not support federated login", "LinkFederatedIdentity.supportedLoginWays")
+			return
+		}
+
+		d := FederatedLoginData{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if app == nil {
+			ar.logger.Println("Error getting App")
+			ar.Error(w, ErrorAPIRequestAppIDInvalid, http.StatusBadRequest, "App id is not specified.", "LinkFederatedIdentity.AppFromContext")
+			return
+		}
+
+		fid, federatedID, ok := ar.federatedUserID(w, app, d, builtinProviders, "LinkFederatedIdentity")
+		if !ok {
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		if err := ar.userStorage.LinkFederatedID(userID, fid, federatedID); err == model.ErrorUserExists {
+			ar.Error(w, ErrorAPIFederatedIdentityLinked, http.StatusConflict, "", "LinkFederatedIdentity.LinkFederatedID")
+			return
+		} else if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "LinkFederatedIdentity.LinkFederatedID")
+			return
+		}
+
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "LinkFederatedIdentity.UserByID")
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, &federatedIdentitiesResponse{Identities: user.FederatedIdentities()})
+	}
+}
+
+// UnlinkFederatedIdentity unlinks federated identity from the user.
+// The last way for the user to log in can't be unlinked. LDAP directory accounts are not unlinked either.
+func (ar *Router) UnlinkFederatedIdentity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := model.FederatedIdentity{
+			Provider: model.FederatedIdentityProvider(strings.ToUpper(mux.Vars(r)["provider"])),
+			ID:       mux.Vars(r)["id"],
+		}
+		if identity.Provider == model.LDAPIDProvider {
+			ar.Error(w, ErrorAPIAppFederatedProviderNotSupported, http.StatusBadRequest, "Directory accounts can't be unlinked", "UnlinkFederatedIdentity.LDAPIDProvider")
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "UnlinkFederatedIdentity.UserByID")
+			return
+		}
+
+		linked, others := false, 0
+		for _, fi := range user.FederatedIdentities() {
+			if fi == identity {
+				linked = true
+			} else {
+				others++
+			}
+		}
+		if !linked {
+			ar.Error(w, ErrorAPIFederatedIdentityNotFound, http.StatusNotFound, "", "UnlinkFederatedIdentity.FederatedIdentities")
+			return
+		}
+		if others == 0 && !ar.canLoginWithoutFederatedIdentity(user) {
+			ar.Error(w, ErrorAPIFederatedIdentityLastLoginMethod, http.StatusBadRequest, "", "UnlinkFederatedIdentity.canLoginWithoutFederatedIdentity")
+			return
+		}
+
+		if err = ar.userStorage.UnlinkFederatedID(userID, identity.Provider, identity.ID); err == model.ErrorNotFound {
+			ar.Error(w, ErrorAPIFederatedIdentityNotFound, http.StatusNotFound, "", "UnlinkFederatedIdentity.UnlinkFederatedID")
+			return
+		} else if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "UnlinkFederatedIdentity.UnlinkFederatedID")
+			return
+		}
+
+		result := map[string]string{"result": "ok"}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
+
+// canLoginWithoutFederatedIdentity checks whether the user has a way to log in supported by the server, other than federated login.
+func (ar *Router) canLoginWithoutFederatedIdentity(user model.User) bool {
+	ways := ar.SupportedLoginWays
+	return (ways.Username && len(user.PasswordHash()) > 0) ||
+		(ways.Phone && len(user.Phone()) > 0) ||
+		((ways.MagicLink || ways.EmailCode) && len(user.Email()) > 0) ||
+		(ways.Webauthn && len(user.WebauthnCredentials()) > 0)
+}
@@ -8,29 +8,25 @@ import (
 	"github.com/madappgang/identifo/model"
 	"github.com/madappgang/identifo/web/authorization"
 	"github.com/madappgang/identifo/web/middleware"
-	"github.com/xlzd/gotp"
+	"github.com/madappgang/identifo/web/shared"
 )
 
-var (
-	errPleaseEnableTFA  = fmt.Errorf("Please enable two-factor authentication to be able to use this app")
-	errPleaseDisableTFA = fmt.Errorf("Please disable two-factor authentication to be able to use this app")
-)
-
-const smsTFACode = "%v is your one-time password!"
-
 // AuthResponse is a response with successful auth data.
 type AuthResponse struct {
-	AccessToken    string     `json:"access_token,omitempty"`
-	RefreshToken   string     `json:"refresh_token,omitempty"`
-	User           model.User `json:"user,omitempty"`
-	NeedFurtherTFA bool       `json:"need_further_tfa,omitempty"`
+	AccessToken        string     `json:"access_token,omitempty"`
+	RefreshToken       string     `json:"refresh_token,omitempty"`
+	User               model.User `json:"user,omitempty"`
+	NeedFurtherTFA     bool       `json:"need_further_tfa,omitempty"`
+	RecoveryCodes      []string   `json:"recovery_codes,omitempty"`
+	TrustedDeviceToken string     `json:"trusted_device_token,omitempty"`
 }
 
 type loginData struct {
-	Username    string   `json:"username,omitempty"`
-	Password    string   `json:"password,omitempty"`
-	DeviceToken string   `json:"device_token,omitempty"`
-	Scopes      []string `json:"scopes,omitempty"`
+	Username           string   `json:"username,omitempty"`
+	Password           string   `json:"password,omitempty"`
+	DeviceToken        string   `json:"device_token,omitempty"`
+	Scopes             []string `json:"scopes,omitempty"`
+	TrustedDeviceToken string   `json:"trusted_device_token,omitempty"`
 }
 
 func (ld *loginData) validate() error {
@@ -63,8 +59,15 @@ func (ar *Router) LoginWithPassword() http.HandlerFunc {
 			return
 		}
 
+		userID, _ := ar.userStorage.IDByName(ld.Username)
+		userKey, ipKey := ar.loginAttemptsKeys(r, userID, ld.Username)
+		if ar.checkLoginLocked(w, userKey, ipKey, "LoginWithPassword.checkLoginLocked") {
+			return
+		}
+
 		user, err := ar.userStorage.UserByNamePassword(ld.Username, ld.Password)
 		if err != nil {
+			ar.failLogin(userKey, ipKey, userID)
 			ar.Error(w, ErrorAPIRequestIncorrectEmailOrPassword, http.StatusUnauthorized, err.Error(), "LoginWithPassword.UserByNamePassword")
 			return
 		}
@@ -94,19 +97,35 @@ func (ar *Router) LoginWithPassword() http.HandlerFunc {
 			return
 		}
 
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			ar.Error(w, ErrorAPIRequestEmailNotVerified, http.StatusForbidden, "", "LoginWithPassword.EmailVerified")
+			return
+		}
+
 		// Check if we should require user to authenticate with 2FA.
-		require2FA, err := ar.check2FA(w, app.TFAStatus(), user.TFAInfo())
+		deviceTrusted := ar.isDeviceTrusted(ld.TrustedDeviceToken, user, app)
+		require2FA, err := ar.check2FA(w, app.TFAStatus(), user.TFAInfo(), deviceTrusted)
 		if err != nil {
 			return
 		}
 
 		offline := contains(scopes, jwtService.OfflineScope)
-		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, require2FA)
+		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, require2FA, model.ClientInfoFromRequest(r))
 		if err != nil {
 			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "LoginWithPassword.loginUser")
 			return
 		}
 
+		if require2FA {
+			if err = ar.sendTFACode(w, user, "LoginWithPassword.sendTFACode"); err != nil {
+				return
+			}
+		} else {
+			// Failed attempts are forgotten once the second factor is verified too.
+			ar.loginLockout.Succeed(userKey)
+			ar.userStorage.UpdateLoginMetadata(user.ID())
+		}
+
 		user.Sanitize()
 		result := AuthResponse{
 			AccessToken:    accessToken,
@@ -114,25 +133,7 @@ func (ar *Router) LoginWithPassword() http.HandlerFunc {
 			User:           user,
 			NeedFurtherTFA: require2FA,
 		}
-
-		if !require2FA {
-			ar.userStorage.UpdateLoginMetadata(user.ID())
-			ar.ServeJSON(w, http.StatusOK, result)
-			return
-		}
-
-		totp := gotp.NewDefaultTOTP(user.TFAInfo().Secret).Now()
-
-		switch ar.tfaType {
-		case model.TFATypeSMS:
-			ar.sendTFACodeInSMS(w, totp, user.Phone())
-			return
-		case model.TFATypeEmail:
-			ar.sendTFACodeOnEmail(w, totp, user.Email())
-			return
-		default:
-			ar.ServeJSON(w, http.StatusOK, result)
-		}
+		ar.ServeJSON(w, http.StatusOK, result)
 	}
 }
 
@@ -147,7 +148,7 @@ func (ar *Router) IsLoggedIn() http.HandlerFunc {
 
 // loginUser creates and returns access token for a user.
 // createRefreshToken boolean param tells if we should issue refresh token as well.
-func (ar *Router) loginUser(user model.User, scopes []string, app model.AppData, createRefreshToken, require2FA bool) (accessTokenString, refreshTokenString string, err error) {
+func (ar *Router) loginUser(user model.User, scopes []string, app model.AppData, createRefreshToken, require2FA bool, client model.ClientInfo) (accessTokenString, refreshTokenString string, err error) {
 	token, err := ar.tokenService.NewAccessToken(user, scopes, app, require2FA)
 	if err != nil {
 		return
@@ -161,7 +162,7 @@ func (ar *Router) loginUser(user model.User, scopes []string, app model.AppData,
 		return
 	}
 
-	refresh, err := ar.tokenService.NewRefreshToken(user, scopes, app)
+	refresh, err := ar.tokenService.NewRefreshToken(user, scopes, app, client)
 	if err != nil {
 		return
 	}
@@ -174,54 +175,35 @@ func (ar *Router) loginUser(user model.User, scopes []string, app model.AppData,
 
 // check2FA checks correspondence between app's TFAstatus and user's TFAInfo,
 // and decides if we require two-factor authentication after all checks are successfully passed.
-func (ar *Router) check2FA(w http.ResponseWriter, appTFAStatus model.TFAStatus, userTFAInfo model.TFAInfo) (bool, error) {
-	if appTFAStatus == model.TFAStatusMandatory && !userTFAInfo.IsEnabled {
-		ar.Error(w, ErrorAPIRequestPleaseEnableTFA, http.StatusBadRequest, errPleaseEnableTFA.Error(), "check2FA.mandatory")
-		return false, errPleaseEnableTFA
-	}
-
-	if appTFAStatus == model.TFAStatusDisabled && userTFAInfo.IsEnabled {
-		ar.Error(w, ErrorAPIRequestPleaseDisableTFA, http.StatusBadRequest, errPleaseDisableTFA.Error(), "check2FA.appDisabled_userEnabled")
-		return false, errPleaseDisableTFA
-	}
-
-	// Request two-factor auth if user enabled it and app supports it.
-	if userTFAInfo.IsEnabled && appTFAStatus != model.TFAStatusDisabled {
-		if userTFAInfo.Secret == "" {
-			// Then admin must have enabled TFA for this user manually.
-			// User must obtain TFA secret, i.e send EnableTFA request.
-			ar.Error(w, ErrorAPIRequestPleaseEnableTFA, http.StatusConflict, errPleaseEnableTFA.Error(), "check2FA.pleaseEnable")
-			return false, errPleaseEnableTFA
-		}
-		return true, nil
-	}
-	return false, nil
+// Second factor is not required on the device the user has chosen to trust.
+func (ar *Router) check2FA(w http.ResponseWriter, appTFAStatus model.TFAStatus, userTFAInfo model.TFAInfo, deviceTrusted bool) (bool, error) {
+	require2FA, err := shared.CheckTFA(appTFAStatus, userTFAInfo, deviceTrusted)
+	switch err {
+	case nil:
+		return require2FA, nil
+	case shared.ErrPleaseEnableTFA:
+		ar.Error(w, ErrorAPIRequestPleaseEnableTFA, http.StatusBadRequest, err.Error(), "check2FA.mandatory")
+	case shared.ErrPleaseDisableTFA:
+		ar.Error(w, ErrorAPIRequestPleaseDisableTFA, http.StatusBadRequest, err.Error(), "check2FA.appDisabled_userEnabled")
+	default:
+		ar.Error(w, ErrorAPIRequestPleaseEnableTFA, http.StatusConflict, err.Error(), "check2FA.pleaseEnable")
+	}
+	return false, err
 }
 
-func (ar *Router) sendTFACodeInSMS(w http.ResponseWriter, phone, totp string) {
-	if phone == "" {
-		ar.Error(w, ErrorAPIRequestPleaseSetPhoneForTFA, http.StatusBadRequest, "", "tfaInSMS.empty_phone")
-		return
-	}
-
-	if err := ar.smsService.SendSMS(phone, fmt.Sprintf(smsTFACode, totp)); err != nil {
-		err = fmt.Errorf("Unable to send sms. %s", err)
-		ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "tfaInSMS.SendSMS")
-		return
-	}
-	ar.ServeJSON(w, http.StatusOK, nil)
-}
-
-func (ar *Router) sendTFACodeOnEmail(w http.ResponseWriter, email, totp string) {
-	if email == "" {
-		ar.Error(w, ErrorAPIRequestPleaseSetEmailForTFA, http.StatusBadRequest, "", "tfaInSMS.empty_email")
-		return
-	}
-
-	if err := ar.emailService.SendTFAEmail("One-time password", email, totp); err != nil {
-		err = fmt.Errorf("Unable to send email. %s", err)
-		ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "tfaInSMS.SendTFAEmail")
-		return
-	}
-	ar.ServeJSON(w, http.StatusOK, nil)
+// sendTFACode sends one-time password to the user who has to pass the second factor.
+// It writes an error to the response if the password cannot be sent.
+func (ar *Router) sendTFACode(w http.ResponseWriter, user model.User, where string) error {
+	err := shared.SendTFACode(ar.tfaType, user, ar.smsService, ar.emailService)
+	switch err {
+	case nil:
+		return nil
+	case shared.ErrPleaseSetPhoneForTFA:
+		ar.Error(w, ErrorAPIRequestPleaseSetPhoneForTFA, http.StatusBadRequest, "", where)
+	case shared.ErrPleaseSetEmailForTFA:
+		ar.Error(w, ErrorAPIRequestPleaseSetEmailForTFA, http.StatusBadRequest, "", where)
+	default:
+		ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, "Unable to send one-time password. "+err.Error(), where)
+	}
+	return err
 }
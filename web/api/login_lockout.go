@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// loginAttemptsKeys returns keys to count failed login attempts of the user, and from the source IP.
+// Names nobody has are counted too, so that lockouts do not tell which users exist.
+func (ar *Router) loginAttemptsKeys(r *http.Request, userID, name string) (userKey, ipKey string) {
+	ipKey = model.IPLoginAttemptsKey(ar.loginLockout.ClientIP(r))
+	if userID != "" {
+		return model.UserLoginAttemptsKey(userID), ipKey
+	}
+	return model.NameLoginAttemptsKey(name), ipKey
+}
+
+// checkLoginLocked writes an error to the response if login is locked for the user or the source IP.
+func (ar *Router) checkLoginLocked(w http.ResponseWriter, userKey, ipKey, where string) bool {
+	lockedFor := ar.loginLockout.LockedFor(userKey, ipKey)
+	if lockedFor == 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(lockedFor.Seconds())))
+	ar.Error(w, ErrorAPILoginLocked, http.StatusTooManyRequests, fmt.Sprintf("Login is locked for %v", lockedFor), where)
+	return true
+}
+
+// failLogin counts failed login attempt and notifies the user if they have just been locked out.
+func (ar *Router) failLogin(userKey, ipKey, userID string) {
+	if !ar.loginLockout.Fail(userKey, ipKey) || userID == "" {
+		return
+	}
+	user, err := ar.userStorage.UserByID(userID)
+	if err != nil {
+		ar.logger.Println("Cannot get locked out user:", err)
+		return
+	}
+	ar.loginLockout.NotifyLockedOut(user, userKey)
+}
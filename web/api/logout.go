@@ -62,6 +62,39 @@ func (ar *Router) Logout() http.HandlerFunc {
 	}
 }
 
+// LogoutAll logs user out of all sessions at once, in case the account has been compromised.
+// All refresh tokens of the user are removed, all tokens issued before are revoked, and trusted devices are forgotten.
+func (ar *Router) LogoutAll() http.HandlerFunc {
+	response := struct {
+		Message string `json:"message"`
+	}{
+		Message: "Done",
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := tokenFromContext(r.Context()).UserID()
+		if err := ar.tokenStorage.RevokeUserTokens(userID); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "LogoutAll.RevokeUserTokens")
+			return
+		}
+		// Devices trusted to skip the second factor must pass it again, or a stolen one would get back in with the password only.
+		if err := model.ForgetTrustedDevices(ar.userStorage, userID); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "LogoutAll.ForgetTrustedDevices")
+			return
+		}
+
+		// Current access token may have been issued within the same second.
+		if accessTokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte); ok {
+			if err := ar.tokenBlacklist.Add(string(accessTokenBytes)); err != nil {
+				ar.logger.Printf("Cannot blacklist access token: %s\n", err)
+			}
+		}
+
+		ar.logger.Printf("User %s logged out of all sessions", userID)
+		ar.ServeJSON(w, http.StatusOK, response)
+	}
+}
+
 func (ar *Router) getTokenSubject(tokenString string) (string, error) {
 	claims := jwt.MapClaims{}
 
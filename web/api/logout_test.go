@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// trustingUserStorage keeps the single user with the trusted device, while the in-memory storage forgets all updates.
+type trustingUserStorage struct {
+	model.UserStorage
+	user model.User
+}
+
+func (s *trustingUserStorage) UserByID(string) (model.User, error) { return s.user, nil }
+
+func (s *trustingUserStorage) UpdateUser(userID string, user model.User) (model.User, error) {
+	s.user = user
+	return user, nil
+}
+
+func TestLogoutAll(t *testing.T) {
+	router, tokenService, app, us := testRouter(t)
+
+	// In-memory storage returns random users, some of them are inactive.
+	user, _ := us.UserByID("user")
+	for !user.Active() {
+		user, _ = us.UserByID("user")
+	}
+	device, err := model.NewTrustedDevice(app.ID(), "Phone", 3600)
+	if err != nil {
+		t.Fatalf("Unable to create trusted device %v", err)
+	}
+	tfaInfo := user.TFAInfo()
+	tfaInfo.AddTrustedDevice(device)
+	user.SetTFAInfo(tfaInfo)
+	storage := &trustingUserStorage{UserStorage: us, user: user}
+	router.(*Router).userStorage = storage
+
+	token, err := tokenService.NewAccessToken(user, []string{}, app, false)
+	if err != nil {
+		t.Fatalf("Unable to create access token %v", err)
+	}
+	accessToken, err := tokenService.String(token)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedRequest("POST", "/me/logout_all", accessToken, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Logout all status = %d, response %s", w.Code, w.Body.String())
+	}
+	if devices := storage.user.TFAInfo().TrustedDevices; len(devices) != 0 {
+		t.Errorf("Trusted devices = %+v, want all forgotten", devices)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedRequest("GET", "/me/sessions", accessToken, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status with the token of the logged out user = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+	"github.com/madappgang/identifo/web/middleware"
+)
+
+// RequestMagicLink sends the passwordless login link to the user's email.
+// The link is short-lived and can be used only once. By default it opens the web router landing page,
+// apps which pass redirect uri exchange the link for tokens themselves.
+func (ar *Router) RequestMagicLink() http.HandlerFunc {
+	type magicLinkRequest struct {
+		Email       string   `json:"email,omitempty"`
+		Scopes      []string `json:"scopes,omitempty"`
+		RedirectURI string   `json:"redirect_uri,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.MagicLink {
+			ar.Error(w, ErrorAPIAppMagicLinkLoginNotSupported, http.StatusBadRequest, "Application does not support login with magic link", "RequestMagicLink.supportedLoginWays")
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if app == nil {
+			ar.logger.Println("Error getting App")
+			ar.Error(w, ErrorAPIRequestAppIDInvalid, http.StatusBadRequest, "App is not in context.", "RequestMagicLink.AppFromContext")
+			return
+		}
+
+		d := magicLinkRequest{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+		if !model.EmailRegexp.MatchString(d.Email) {
+			ar.Error(w, ErrorAPIRequestBodyInvalid, http.StatusBadRequest, "", "RequestMagicLink.emailRegexp_MatchString")
+			return
+		}
+
+		// Links pointing to the app itself must be registered like any other redirect.
+		if d.RedirectURI != "" && !contains(app.RedirectURLs(), d.RedirectURI) {
+			ar.Error(w, ErrorAPIRequestBodyParamsInvalid, http.StatusBadRequest, "Unauthorized redirect uri", "RequestMagicLink.RedirectURLs")
+			return
+		}
+
+		user, err := ar.userStorage.UserByEmail(d.Email)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, "User with this email does not exist", "RequestMagicLink.UserByEmail")
+			return
+		}
+
+		// Do not bother sending the link if the user is not allowed to log in anyway.
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    user.AccessRole(),
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err = ar.Authorizer.Authorize(azi); err != nil {
+			ar.Error(w, ErrorAPIAppAccessDenied, http.StatusForbidden, err.Error(), "RequestMagicLink.Authorizer")
+			return
+		}
+
+		scopes, err := ar.userStorage.RequestScopes(user.ID(), d.Scopes)
+		if err != nil {
+			ar.Error(w, ErrorAPIRequestScopesForbidden, http.StatusBadRequest, err.Error(), "RequestMagicLink.RequestScopes")
+			return
+		}
+
+		token, err := ar.tokenService.NewMagicLinkToken(user, app, scopes)
+		if err != nil {
+			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "RequestMagicLink.NewMagicLinkToken")
+			return
+		}
+
+		tokenString, err := ar.tokenService.String(token)
+		if err != nil {
+			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "RequestMagicLink.tokenService_String")
+			return
+		}
+
+		link, err := ar.magicLinkURL(d.RedirectURI, tokenString)
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "RequestMagicLink.magicLinkURL")
+			return
+		}
+
+		if err = ar.emailService.SendMagicLinkEmail("Login Link", d.Email, link); err != nil {
+			ar.Error(w, ErrorAPIEmailNotSent, http.StatusInternalServerError, "Email sending error: "+err.Error(), "RequestMagicLink.SendMagicLinkEmail")
+			return
+		}
+
+		result := map[string]string{"result": "ok"}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
+
+// magicLinkURL builds the link sent to the user.
+// By default it leads to the web router, apps may handle the link themselves via redirect uri.
+func (ar *Router) magicLinkURL(redirectURI, tokenString string) (string, error) {
+	if redirectURI != "" {
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("token", tokenString)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	host, err := url.Parse(ar.Host)
+	if err != nil {
+		return "", err
+	}
+
+	u := &url.URL{
+		Scheme:   host.Scheme,
+		Host:     host.Host,
+		Path:     path.Join(ar.WebRouterPrefix, "magic_link"),
+		RawQuery: url.Values{"token": []string{tokenString}}.Encode(),
+	}
+	return u.String(), nil
+}
@@ -9,47 +9,62 @@ func GetMessage(id MessageID) string {
 }
 
 var messages = map[MessageID]string{
-	ErrorAPIInternalServerError:                "Internal server error",
-	ErrorAPIUserUnableToCreate:                 "Unable to create use. Try again or contact support team",
-	ErrorAPIVerificationCodeInvalid:            "Sorry, the code you entered is invalid or has expired. Please get a new one.",
-	ErrorAPIUserNotFound:                       "Specified user not found",
-	ErrorAPIUsernameTaken:                      "Username is taken. Try to choose another one",
-	ErrorAPIEmailTaken:                         "Email is taken. Try to choose another one",
-	ErrorAPIInviteTokenServerError:             "Unable to create invite token. Try again or contact support team",
-	ErrorAPIEmailNotSent:                       "Unable to send email. Try again or contact support team",
-	ErrorAPIRequestPasswordWeak:                "Password is not strong enough",
-	ErrorAPIRequestIncorrectEmailOrPassword:    "Incorrect email or password",
-	ErrorAPIRequestScopesForbidden:             "Requested scopes are forbidden",
-	ErrorAPIRequestBodyInvalid:                 "Wrong input data",
-	ErrorAPIRequestBodyParamsInvalid:           "Input data does not pass validation. Please specify valid params",
-	ErrorAPIRequestBodyOldPasswordInvalid:      "Old password is invalid. Please check it again",
-	ErrorAPIRequestBodyEmailInvalid:            "Specified email is invalid or empty",
-	ErrorAPIRequestSignatureInvalid:            "Incorrect or empty request signature",
-	ErrorAPIRequestAppIDInvalid:                "Incorrect or empty application ID",
-	ErrorAPIRequestTokenInvalid:                "Incorrect or empty Bearer token",
-	ErrorAPIRequestTFACodeEmpty:                "Empty two-factor authentication code",
-	ErrorAPIRequestTFACodeInvalid:              "Invalid two-factor authentication code",
-	ErrorAPIRequestTFAAlreadyEnabled:           "Two-factor authentication already enabled",
-	ErrorAPIRequestPleaseEnableTFA:             "Please enable two-factor authenticaton",
-	ErrorAPIRequestPleaseDisableTFA:            "Please disable two-factor authenticaton",
-	ErrorAPIRequestMandatoryTFA:                "Two-factor authentication is mandatory for this app",
-	ErrorAPIRequestDisabledTFA:                 "Two-factor authentication is disabled for this app",
-	ErrorAPIRequestPleaseSetPhoneForTFA:        "Please specify your phone number to be able to receive one-time passwords",
-	ErrorAPIRequestPleaseSetEmailForTFA:        "Please specify your email address to be able to receive one-time passwords",
-	ErrorAPIAppInactive:                        "Requesting app is inactive",
-	ErrorAPIAppRegistrationForbidden:           "Registration in this app is forbidden",
-	ErrorAPIAppResetTokenNotCreated:            "Unable to create reset token",
-	ErrorAPIAppAccessTokenNotCreated:           "Unable to create access token",
-	ErrorAPIAppRefreshTokenNotCreated:          "Unable to create refresh token",
-	ErrorAPIAppCannotExtractTokenSubject:       "Unable to extract Subject claim from token",
-	ErrorAPIAppCannotInitAuthorizer:            "Unable to init internal authorizer",
-	ErrorAPIAppFederatedProviderNotSupported:   "Federated provider is not supported",
-	ErrorAPIAppFederatedProviderEmptyUserID:    "Federated provider returns empty user ID",
-	ErrorAPIAppFederatedProviderEmptyAppleInfo: "Application does not have Apple info",
-	ErrorAPIAppFederatedLoginNotSupported:      "Login with federated identity provider is not supported by app",
-	ErrorAPIAppLoginWithUsernameNotSupported:   "Login with username is not supported by app",
-	ErrorAPIAppPhoneLoginNotSupported:          "Login with phone number is not supported by app",
-	ErrorAPIAppAccessDenied:                    "Access denied",
+	ErrorAPIInternalServerError:                 "Internal server error",
+	ErrorAPIUserUnableToCreate:                  "Unable to create use. Try again or contact support team",
+	ErrorAPIVerificationCodeInvalid:             "Sorry, the code you entered is invalid or has expired. Please get a new one.",
+	ErrorAPIUserNotFound:                        "Specified user not found",
+	ErrorAPIUsernameTaken:                       "Username is taken. Try to choose another one",
+	ErrorAPIEmailTaken:                          "Email is taken. Try to choose another one",
+	ErrorAPIInviteTokenServerError:              "Unable to create invite token. Try again or contact support team",
+	ErrorAPIEmailNotSent:                        "Unable to send email. Try again or contact support team",
+	ErrorAPIRequestPasswordWeak:                 "Password is not strong enough",
+	ErrorAPIRequestIncorrectEmailOrPassword:     "Incorrect email or password",
+	ErrorAPIRequestScopesForbidden:              "Requested scopes are forbidden",
+	ErrorAPIRequestBodyInvalid:                  "Wrong input data",
+	ErrorAPIRequestBodyParamsInvalid:            "Input data does not pass validation. Please specify valid params",
+	ErrorAPIRequestBodyOldPasswordInvalid:       "Old password is invalid. Please check it again",
+	ErrorAPIRequestBodyEmailInvalid:             "Specified email is invalid or empty",
+	ErrorAPIRequestEmailNotVerified:             "Please verify your email address to be able to log in",
+	ErrorAPIRequestSignatureInvalid:             "Incorrect or empty request signature",
+	ErrorAPIRequestAppIDInvalid:                 "Incorrect or empty application ID",
+	ErrorAPIRequestTokenInvalid:                 "Incorrect or empty Bearer token",
+	ErrorAPIRequestTFACodeEmpty:                 "Empty two-factor authentication code",
+	ErrorAPIRequestTFACodeInvalid:               "Invalid two-factor authentication code",
+	ErrorAPIRequestReauthenticationRequired:     "Please confirm it is you with your password or two-factor authentication code",
+	ErrorAPIRequestTFAAlreadyEnabled:            "Two-factor authentication already enabled",
+	ErrorAPIRequestPleaseEnableTFA:              "Please enable two-factor authenticaton",
+	ErrorAPIRequestPleaseDisableTFA:             "Please disable two-factor authenticaton",
+	ErrorAPIRequestMandatoryTFA:                 "Two-factor authentication is mandatory for this app",
+	ErrorAPIRequestDisabledTFA:                  "Two-factor authentication is disabled for this app",
+	ErrorAPIRequestPleaseSetPhoneForTFA:         "Please specify your phone number to be able to receive one-time passwords",
+	ErrorAPIRequestPleaseSetEmailForTFA:         "Please specify your email address to be able to receive one-time passwords",
+	ErrorAPIAppInactive:                         "Requesting app is inactive",
+	ErrorAPIAppRegistrationForbidden:            "Registration in this app is forbidden",
+	ErrorAPIAppResetTokenNotCreated:             "Unable to create reset token",
+	ErrorAPIAppAccessTokenNotCreated:            "Unable to create access token",
+	ErrorAPIAppRefreshTokenNotCreated:           "Unable to create refresh token",
+	ErrorAPIAppCannotExtractTokenSubject:        "Unable to extract Subject claim from token",
+	ErrorAPIAppCannotInitAuthorizer:             "Unable to init internal authorizer",
+	ErrorAPIAppFederatedProviderNotSupported:    "Federated provider is not supported",
+	ErrorAPIAppFederatedProviderEmptyUserID:     "Federated provider returns empty user ID",
+	ErrorAPIAppFederatedProviderEmptyAppleInfo:  "Application does not have Apple info",
+	ErrorAPIAppFederatedProviderEmptyGoogleInfo: "Application does not have Google info",
+	ErrorAPIAppFederatedLoginNotSupported:       "Login with federated identity provider is not supported by app",
+	ErrorAPIAppLoginWithUsernameNotSupported:    "Login with username is not supported by app",
+	ErrorAPIAppPhoneLoginNotSupported:           "Login with phone number is not supported by app",
+	ErrorAPIAppMagicLinkLoginNotSupported:       "Login with magic link is not supported by app",
+	ErrorAPIAppEmailCodeLoginNotSupported:       "Login with email code is not supported by app",
+	ErrorAPIAppWebauthnNotSupported:             "Passkeys are not supported by app",
+	ErrorAPIWebauthnCredentialInvalid:           "Sorry, the passkey is invalid or the request has expired. Please try again.",
+	ErrorAPITrustedDeviceNotFound:               "Trusted device not found",
+	ErrorAPIFederatedIdentityLinked:             "This identity is already linked to another user",
+	ErrorAPIFederatedIdentityNotFound:           "Federated identity not found",
+	ErrorAPIFederatedIdentityLastLoginMethod:    "Unable to unlink the only way to log in. Add another one first",
+	ErrorAPIUserNotAnonymous:                    "User is not anonymous",
+	ErrorAPISessionNotFound:                     "Session not found",
+	ErrorAPILoginLocked:                         "Too many failed login attempts. Please try again later",
+	ErrorAPIAppAccessDenied:                     "Access denied",
+	ErrorAPIImpersonationForbidden:              "The account can't be changed on behalf of the user",
 }
 
 const (
@@ -86,6 +101,8 @@ const (
 	ErrorAPIRequestBodyOldPasswordInvalid = "error.api.request.body.oldpassword.invalid"
 	// ErrorAPIRequestBodyEmailInvalid means that email in request body is corrupted.
 	ErrorAPIRequestBodyEmailInvalid = "error.api.request.body.email.invalid"
+	// ErrorAPIRequestEmailNotVerified means that the app requires verified email to log in.
+	ErrorAPIRequestEmailNotVerified = "error.api.request.email.not_verified"
 	// ErrorAPIRequestSignatureInvalid is a HMAC request signature error.
 	ErrorAPIRequestSignatureInvalid = "error.api.request.signature.invalid"
 	// ErrorAPIRequestAppIDInvalid means that application ID header value is invalid.
@@ -97,6 +114,8 @@ const (
 	ErrorAPIRequestTFACodeEmpty = "error.api.request.2fa_code.empty"
 	// ErrorAPIRequestTFACodeInvalid means that the 2FA code is invalid.
 	ErrorAPIRequestTFACodeInvalid = "error.api.request.2fa_code.invalid"
+	// ErrorAPIRequestReauthenticationRequired means that the user must confirm their password or 2FA code again.
+	ErrorAPIRequestReauthenticationRequired = "error.api.request.reauthentication.required"
 	// ErrorAPIRequestTFAAlreadyEnabled means that 2FA is already enabled for the user.
 	ErrorAPIRequestTFAAlreadyEnabled = "error.api.request.2fa.already_enabled"
 	// ErrorAPIRequestPleaseEnableTFA means that user must request TFA and obtain TFA secret to be able to use the app.
@@ -133,6 +152,8 @@ const (
 	ErrorAPIAppFederatedProviderEmptyUserID = "api.app.federated.provider.empty_user_id"
 	// ErrorAPIAppFederatedProviderEmptyAppleInfo means that application does not have clientID and clientSecret needed for Sign In with Apple.
 	ErrorAPIAppFederatedProviderEmptyAppleInfo = "api.app.federated.provider.empty_apple_info"
+	// ErrorAPIAppFederatedProviderEmptyGoogleInfo means that application does not have clientID needed for Sign In with Google.
+	ErrorAPIAppFederatedProviderEmptyGoogleInfo = "api.app.federated.provider.empty_google_info"
 
 	// ErrorAPIAppFederatedLoginNotSupported means that the app does not support federated login.
 	ErrorAPIAppFederatedLoginNotSupported = "api.app.federated.login.not_supported"
@@ -140,4 +161,28 @@ const (
 	ErrorAPIAppLoginWithUsernameNotSupported = "api.app.username.login.not_supported"
 	// ErrorAPIAppPhoneLoginNotSupported means that the app does not support login by phone number.
 	ErrorAPIAppPhoneLoginNotSupported = "api.app.phone.login.not_supported"
+	// ErrorAPIAppMagicLinkLoginNotSupported means that the app does not support login with magic link.
+	ErrorAPIAppMagicLinkLoginNotSupported = "api.app.magic_link.login.not_supported"
+	// ErrorAPIAppEmailCodeLoginNotSupported means that the app does not support login with code sent by email.
+	ErrorAPIAppEmailCodeLoginNotSupported = "api.app.email_code.login.not_supported"
+	// ErrorAPIAppWebauthnNotSupported means that the app does not support passkeys.
+	ErrorAPIAppWebauthnNotSupported = "api.app.webauthn.not_supported"
+	// ErrorAPIWebauthnCredentialInvalid means that WebAuthn ceremony has failed.
+	ErrorAPIWebauthnCredentialInvalid = "error.api.webauthn.credential.invalid"
+	// ErrorAPITrustedDeviceNotFound is when the user has no trusted device with such ID.
+	ErrorAPITrustedDeviceNotFound = "error.api.trusted_device.not_found"
+	// ErrorAPIFederatedIdentityLinked is when the federated identity belongs to another user.
+	ErrorAPIFederatedIdentityLinked = "error.api.federated_identity.linked"
+	// ErrorAPIFederatedIdentityNotFound is when the user has no such federated identity linked.
+	ErrorAPIFederatedIdentityNotFound = "error.api.federated_identity.not_found"
+	// ErrorAPIFederatedIdentityLastLoginMethod is when unlinking the identity would leave the user with no way to log in.
+	ErrorAPIFederatedIdentityLastLoginMethod = "error.api.federated_identity.last_login_method"
+	// ErrorAPIUserNotAnonymous is when the user to upgrade to full account already has one.
+	ErrorAPIUserNotAnonymous = "error.api.user.not_anonymous"
+	// ErrorAPISessionNotFound is when the user has no session with such ID.
+	ErrorAPISessionNotFound = "error.api.session.not_found"
+	// ErrorAPILoginLocked is when login is locked for a while after repeated failed attempts.
+	ErrorAPILoginLocked = "error.api.login.locked"
+	// ErrorAPIImpersonationForbidden is when the account is to be changed with impersonation token.
+	ErrorAPIImpersonationForbidden = "error.api.impersonation.forbidden"
 )
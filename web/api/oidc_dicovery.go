@@ -9,17 +9,32 @@ import (
 	"net/http"
 	"time"
 
+	jwtService "github.com/madappgang/identifo/jwt/service"
 	"github.com/madappgang/identifo/model"
 )
 
 // OIDCConfiguration describes OIDC configuration.
 // Additional info: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
-// Identifo is not an OIDC provider, that's why we only provide the information for token validation.
+// OAuth 2.0 endpoints are served by the web router, because they need a browser session.
 type OIDCConfiguration struct {
-	Issuer                 string   `json:"issuer"`
-	JwksURI                string   `json:"jwks_uri"`
-	ScopesSupported        []string `json:"scopes_supported"`
-	SupportedIDSigningAlgs []string `json:"id_token_signing_alg_values_supported"`
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	SupportedIDSigningAlgs            []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	BackchannelLogoutSupported        bool     `json:"backchannel_logout_supported"`
 }
 
 type jwk struct {
@@ -58,11 +73,28 @@ type jwk struct {
 func (ar *Router) OIDCConfiguration() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if ar.oidcConfiguration == nil {
+			issuer := ar.tokenService.Issuer()
+			oauthURL := issuer + ar.WebRouterPrefix + "/oauth"
+
 			ar.oidcConfiguration = &OIDCConfiguration{
-				Issuer:                 ar.tokenService.Issuer(),
-				JwksURI:                ar.tokenService.Issuer() + "/.well-known/jwks.json",
-				ScopesSupported:        ar.userStorage.Scopes(),
-				SupportedIDSigningAlgs: []string{ar.tokenService.Algorithm()},
+				Issuer:                            issuer,
+				AuthorizationEndpoint:             oauthURL + "/authorize",
+				TokenEndpoint:                     oauthURL + "/token",
+				UserInfoEndpoint:                  oauthURL + "/userinfo",
+				IntrospectionEndpoint:             oauthURL + "/introspect",
+				RevocationEndpoint:                oauthURL + "/revoke",
+				DeviceAuthorizationEndpoint:       oauthURL + "/device_authorization",
+				EndSessionEndpoint:                oauthURL + "/end_session",
+				JwksURI:                           issuer + "/.well-known/jwks.json",
+				ScopesSupported:                   append([]string{jwtService.OpenIDScope, jwtService.EmailScope, jwtService.PhoneScope, jwtService.ProfileScope}, ar.userStorage.Scopes()...),
+				ResponseTypesSupported:            []string{"code"},
+				GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials", "urn:ietf:params:oauth:grant-type:device_code", "urn:ietf:params:oauth:grant-type:token-exchange"},
+				SubjectTypesSupported:             []string{"public"},
+				SupportedIDSigningAlgs:            []string{ar.tokenService.Algorithm()},
+				TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+				CodeChallengeMethodsSupported:     []string{"S256", "plain"},
+				ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "email", "phone_number", "preferred_username"},
+				BackchannelLogoutSupported:        true,
 			}
 		}
 		ar.ServeJSON(w, http.StatusOK, ar.oidcConfiguration)
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/madappgang/identifo/identity_providers/oidc"
+	"github.com/madappgang/identifo/model"
+)
+
+// ErrOIDCEmptyUserID is when OpenID Connect provider's user ID is empty.
+var ErrOIDCEmptyUserID = errors.New("OpenID Connect user id is not accessible. ")
+
+// OIDCUserID returns user ID at the generic OpenID Connect provider.
+func (ar *Router) OIDCUserID(idToken string, provider model.OIDCProvider) (string, error) {
+	oc := oidc.NewClient(provider)
+	oidcProfile, err := oc.MyProfile(idToken)
+	if err != nil {
+		return "", err
+	}
+
+	if len(oidcProfile.ID) == 0 {
+		return "", ErrOIDCEmptyUserID
+	}
+	return oidcProfile.ID, nil
+}
@@ -77,18 +77,29 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 			return
 		}
 
+		var userID string
+		user, err := ar.userStorage.UserByPhone(authData.PhoneNumber)
+		if err == nil {
+			userID = user.ID()
+		}
+		userKey, ipKey := ar.loginAttemptsKeys(r, userID, authData.PhoneNumber)
+		if ar.checkLoginLocked(w, userKey, ipKey, "PhoneLogin.checkLoginLocked") {
+			return
+		}
+
 		needVerification := app.DebugTFACode() == "" || authData.Code != app.DebugTFACode()
 		if needVerification { // check verification code
 			if exists, err := ar.verificationCodeStorage.IsVerificationCodeFound(authData.PhoneNumber, authData.Code); err != nil {
 				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "PhoneLogin.IsVerificationCodeFound.error")
 				return
 			} else if !exists {
+				ar.failLogin(userKey, ipKey, userID)
 				ar.Error(w, ErrorAPIVerificationCodeInvalid, http.StatusUnauthorized, "Invalid phone or verification code", "PhoneLogin.IsVerificationCodeFound.not_exists")
 				return
 			}
 		}
+		ar.loginLockout.Succeed(userKey)
 
-		user, err := ar.userStorage.UserByPhone(authData.PhoneNumber)
 		if err == model.ErrUserNotFound {
 			user, err = ar.userStorage.AddUserByPhone(authData.PhoneNumber, app.NewUserDefaultRole())
 		}
@@ -109,6 +120,11 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 			return
 		}
 
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			ar.Error(w, ErrorAPIRequestEmailNotVerified, http.StatusForbidden, "", "PhoneLogin.EmailVerified")
+			return
+		}
+
 		scopes, err := ar.userStorage.RequestScopes(user.ID(), authData.Scopes)
 		if err != nil {
 			ar.Error(w, ErrorAPIRequestScopesForbidden, http.StatusForbidden, err.Error(), "PhoneLogin.RequestScopes")
@@ -116,7 +132,7 @@ func (ar *Router) PhoneLogin() http.HandlerFunc {
 		}
 
 		offline := contains(scopes, jwtService.OfflineScope)
-		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, false)
+		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, false, model.ClientInfoFromRequest(r))
 		if err != nil {
 			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "PhoneLogin.loginUser")
 			return
@@ -10,6 +10,7 @@ import (
 
 // RefreshTokens issues new access and, if requsted, refresh token for provided refresh token.
 // After new tokens are issued, the old refresh token gets invalidated (via blacklisting).
+// Apps with refresh token rotation always get new refresh token.
 func (ar *Router) RefreshTokens() http.HandlerFunc {
 	type requestData struct {
 		Scopes []string `json:"scopes,omitempty"`
@@ -35,6 +36,23 @@ func (ar *Router) RefreshTokens() http.HandlerFunc {
 
 		// Get refresh token from context.
 		oldRefreshToken := tokenFromContext(r.Context())
+		oldRefreshTokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte)
+		if !ok || oldRefreshTokenBytes == nil {
+			ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, "Token is empty or invalid.", "RefreshTokens.RawTokenFromContext")
+			return
+		}
+		oldRefreshTokenString := string(oldRefreshTokenBytes)
+
+		if app.RefreshTokenRotation() {
+			if err := jwtService.CheckRefreshTokenFamily(oldRefreshToken, oldRefreshTokenString, app, ar.tokenStorage, ar.logger); err != nil {
+				ar.Error(w, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, err.Error(), "RefreshTokens.CheckRefreshTokenFamily")
+				return
+			}
+		} else if !ar.tokenStorage.HasToken(oldRefreshTokenString) {
+			// Refresh tokens are deleted from the storage when the user logs out of the session.
+			ar.Error(w, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, "Refresh token is revoked", "RefreshTokens.HasToken")
+			return
+		}
 
 		// Issue new access token and stringify it for response.
 		accessToken, err := ar.tokenService.RefreshAccessToken(oldRefreshToken)
@@ -48,22 +66,32 @@ func (ar *Router) RefreshTokens() http.HandlerFunc {
 			return
 		}
 
-		// Stringify old refresh token and issue new one.
-		oldRefreshTokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte)
-		if !ok || oldRefreshTokenBytes == nil {
-			ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, "Token is empty or invalid.", "RefreshTokens.RawTokenFromContext")
-			return
+		var newRefreshTokenString string
+		if app.RefreshTokenRotation() {
+			// Rotated token keeps the scopes, the old one is deleted from the token storage but not blacklisted,
+			// so its reuse could be detected.
+			newRefreshToken, err := ar.tokenService.RotateRefreshToken(oldRefreshToken, model.ClientInfoFromRequest(r))
+			if err != nil {
+				ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, err.Error(), "RefreshTokens.RotateRefreshToken")
+				return
+			}
+			if newRefreshTokenString, err = ar.tokenService.String(newRefreshToken); err != nil {
+				ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, err.Error(), "RefreshTokens.newRefreshTokenString")
+				return
+			}
+			if err = ar.tokenStorage.DeleteToken(oldRefreshTokenString); err != nil {
+				ar.logger.Println("Cannot delete old refresh token from token storage:", err)
+			}
+		} else {
+			newRefreshTokenString, err = ar.issueNewRefreshToken(oldRefreshTokenString, rd.Scopes, app, model.ClientInfoFromRequest(r))
+			if err != nil {
+				ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, err.Error(), "RefreshToken.newRefreshTokenString")
+				return
+			}
+
+			// Invalidate old refresh token - delete it from token storage and add to blacklist.
+			ar.invalidateOldRefreshToken(oldRefreshTokenString)
 		}
-		oldRefreshTokenString := string(oldRefreshTokenBytes)
-
-		newRefreshTokenString, err := ar.issueNewRefreshToken(oldRefreshTokenString, rd.Scopes, app)
-		if err != nil {
-			ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, err.Error(), "RefreshToken.newRefreshTokenString")
-			return
-		}
-
-		// Invalidate old refresh token - delete it from token storage and add to blacklist.
-		ar.invalidateOldRefreshToken(oldRefreshTokenString)
 
 		result := &responseData{
 			AccessToken:  accessTokenString,
@@ -74,7 +102,7 @@ func (ar *Router) RefreshTokens() http.HandlerFunc {
 	}
 }
 
-func (ar *Router) issueNewRefreshToken(oldRefreshTokenString string, scopes []string, app model.AppData) (string, error) {
+func (ar *Router) issueNewRefreshToken(oldRefreshTokenString string, scopes []string, app model.AppData, client model.ClientInfo) (string, error) {
 	if !contains(scopes, jwtService.OfflineScope) { // Don't issue new refresh token if not requested.
 		return "", nil
 	}
@@ -89,7 +117,7 @@ func (ar *Router) issueNewRefreshToken(oldRefreshTokenString string, scopes []st
 		return "", err
 	}
 
-	refreshToken, err := ar.tokenService.NewRefreshToken(user, scopes, app)
+	refreshToken, err := ar.tokenService.NewRefreshToken(user, scopes, app, client)
 	if err != nil {
 		return "", err
 	}
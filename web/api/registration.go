@@ -101,6 +101,19 @@ func (ar *Router) RegisterWithPassword() http.HandlerFunc {
 			return
 		}
 
+		if len(user.Email()) > 0 {
+			if err = ar.sendEmailVerification(user); err != nil {
+				ar.logger.Println("Error sending email verification:", err)
+			}
+		}
+
+		// The user has to verify email first, so there are no tokens yet.
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			user.Sanitize()
+			ar.ServeJSON(w, http.StatusOK, registrationResponse{User: user})
+			return
+		}
+
 		// Do login flow.
 		scopes, err := ar.userStorage.RequestScopes(user.ID(), rd.Scopes)
 		if err != nil {
@@ -123,7 +136,7 @@ func (ar *Router) RegisterWithPassword() http.HandlerFunc {
 		refreshString := ""
 		// Requesting offline access?
 		if contains(scopes, jwtService.OfflineScope) {
-			refresh, err := ar.tokenService.NewRefreshToken(user, scopes, app)
+			refresh, err := ar.tokenService.NewRefreshToken(user, scopes, app, model.ClientInfoFromRequest(r))
 			if err != nil {
 				ar.Error(w, ErrorAPIAppRefreshTokenNotCreated, http.StatusInternalServerError, err.Error(), "RegisterWithPassword.tokenService_NewRefreshToken")
 				return
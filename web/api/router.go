@@ -16,26 +16,28 @@ import (
 
 // Router is a router that handles all API requests.
 type Router struct {
-	middleware              *negroni.Negroni
-	cors                    *cors.Cors
-	logger                  *log.Logger
-	router                  *mux.Router
-	appStorage              model.AppStorage
-	userStorage             model.UserStorage
-	tokenStorage            model.TokenStorage
-	tokenBlacklist          model.TokenBlacklist
-	verificationCodeStorage model.VerificationCodeStorage
-	staticFilesStorage      model.StaticFilesStorage
-	tfaType                 model.TFAType
-	tokenService            jwtService.TokenService
-	smsService              model.SMSService
-	emailService            model.EmailService
-	oidcConfiguration       *OIDCConfiguration
-	jwk                     *jwk
-	Authorizer              *authorization.Authorizer
-	Host                    string
-	SupportedLoginWays      model.LoginWith
-	WebRouterPrefix         string
+	middleware               *negroni.Negroni
+	cors                     *cors.Cors
+	logger                   *log.Logger
+	router                   *mux.Router
+	appStorage               model.AppStorage
+	userStorage              model.UserStorage
+	tokenStorage             model.TokenStorage
+	tokenBlacklist           model.TokenBlacklist
+	verificationCodeStorage  model.VerificationCodeStorage
+	webauthnChallengeStorage model.WebauthnChallengeStorage
+	staticFilesStorage       model.StaticFilesStorage
+	tfaType                  model.TFAType
+	tokenService             jwtService.TokenService
+	smsService               model.SMSService
+	emailService             model.EmailService
+	loginLockout             *model.LoginLockout
+	oidcConfiguration        *OIDCConfiguration
+	jwk                      *jwk
+	Authorizer               *authorization.Authorizer
+	Host                     string
+	SupportedLoginWays       model.LoginWith
+	WebRouterPrefix          string
 }
 
 // ServeHTTP implements identifo.Router interface.
@@ -84,6 +86,14 @@ func TFATypeOption(tfaType model.TFAType) func(*Router) error {
 	}
 }
 
+// LoginLockoutOption is for locking users out after repeated failed login attempts.
+func LoginLockoutOption(loginLockout *model.LoginLockout) func(*Router) error {
+	return func(r *Router) error {
+		r.loginLockout = loginLockout
+		return nil
+	}
+}
+
 // WebRouterPrefixOption sets web prefix host value.
 func WebRouterPrefixOption(prefix string) func(*Router) error {
 	return func(r *Router) error {
@@ -93,20 +103,21 @@ func WebRouterPrefixOption(prefix string) func(*Router) error {
 }
 
 // NewRouter creates and initilizes new router.
-func NewRouter(logger *log.Logger, as model.AppStorage, us model.UserStorage, ts model.TokenStorage, tb model.TokenBlacklist, vcs model.VerificationCodeStorage, sfs model.StaticFilesStorage, tServ jwtService.TokenService, smsServ model.SMSService, emailServ model.EmailService, authorizer *authorization.Authorizer, options ...func(*Router) error) (model.Router, error) {
+func NewRouter(logger *log.Logger, as model.AppStorage, us model.UserStorage, ts model.TokenStorage, tb model.TokenBlacklist, vcs model.VerificationCodeStorage, wcs model.WebauthnChallengeStorage, sfs model.StaticFilesStorage, tServ jwtService.TokenService, smsServ model.SMSService, emailServ model.EmailService, authorizer *authorization.Authorizer, options ...func(*Router) error) (model.Router, error) {
 	ar := Router{
-		middleware:              negroni.Classic(),
-		router:                  mux.NewRouter(),
-		appStorage:              as,
-		userStorage:             us,
-		tokenStorage:            ts,
-		tokenBlacklist:          tb,
-		verificationCodeStorage: vcs,
-		staticFilesStorage:      sfs,
-		tokenService:            tServ,
-		smsService:              smsServ,
-		emailService:            emailServ,
-		Authorizer:              authorizer,
+		middleware:               negroni.Classic(),
+		router:                   mux.NewRouter(),
+		appStorage:               as,
+		userStorage:              us,
+		tokenStorage:             ts,
+		tokenBlacklist:           tb,
+		verificationCodeStorage:  vcs,
+		webauthnChallengeStorage: wcs,
+		staticFilesStorage:       sfs,
+		tokenService:             tServ,
+		smsService:               smsServ,
+		emailService:             emailServ,
+		Authorizer:               authorizer,
 	}
 
 	for _, option := range append(defaultOptions(), options...) {
@@ -25,9 +25,12 @@ func (ar *Router) initRoutes() {
 	auth.Path(`/{login:login/?}`).HandlerFunc(ar.LoginWithPassword()).Methods("POST")
 	auth.Path(`/{request_phone_code:request_phone_code/?}`).HandlerFunc(ar.RequestVerificationCode()).Methods("POST")
 	auth.Path(`/{phone_login:phone_login/?}`).HandlerFunc(ar.PhoneLogin()).Methods("POST")
+	auth.Path(`/{request_email_code:request_email_code/?}`).HandlerFunc(ar.RequestEmailCode()).Methods("POST")
+	auth.Path(`/{email_login:email_login/?}`).HandlerFunc(ar.EmailLogin()).Methods("POST")
 	auth.Path(`/{federated:federated/?}`).HandlerFunc(ar.FederatedLogin()).Methods("POST")
 	auth.Path(`/{register:register/?}`).HandlerFunc(ar.RegisterWithPassword()).Methods("POST")
 	auth.Path(`/{reset_password:reset_password/?}`).HandlerFunc(ar.RequestResetPassword()).Methods("POST")
+	auth.Path(`/{request_magic_link:request_magic_link/?}`).HandlerFunc(ar.RequestMagicLink()).Methods("POST")
 
 	auth.Path(`/{token:token/?}`).Handler(negroni.New(
 		ar.Token(TokenTypeRefresh),
@@ -40,6 +43,7 @@ func (ar *Router) initRoutes() {
 
 	auth.Path(`/{tfa/enable:tfa/enable/?}`).Handler(negroni.New(
 		ar.Token(TokenTypeAccess),
+		ar.DenyImpersonation(),
 		negroni.Wrap(ar.EnableTFA()),
 	)).Methods("PUT")
 	auth.Path(`/{tfa/disable:tfa/disable/?}`).Handler(negroni.New(
@@ -47,13 +51,33 @@ func (ar *Router) initRoutes() {
 	)).Methods("PUT")
 	auth.Path(`/{tfa/finalize:tfa/finalize/?}`).Handler(negroni.New(
 		ar.Token(TokenTypeAccess),
+		ar.DenyImpersonation(),
 		negroni.Wrap(ar.FinalizeTFA()),
 	)).Methods("POST")
+	auth.Path(`/{tfa/recovery_codes:tfa/recovery_codes/?}`).Handler(negroni.New(
+		ar.Token(TokenTypeAccess),
+		ar.DenyImpersonation(),
+		negroni.Wrap(ar.RegenerateRecoveryCodes()),
+	)).Methods("POST")
 	auth.Path(`/{tfa/reset:tfa/reset/?}`).Handler(negroni.New(
 		ar.Token(TokenTypeAccess),
+		ar.DenyImpersonation(),
 		negroni.Wrap(ar.RequestTFAReset()),
 	)).Methods("PUT")
 
+	auth.Path(`/{webauthn/register/begin:webauthn/register/begin/?}`).Handler(negroni.New(
+		ar.Token(TokenTypeAccess),
+		ar.DenyImpersonation(),
+		negroni.Wrap(ar.WebauthnRegisterBegin()),
+	)).Methods("POST")
+	auth.Path(`/{webauthn/register/finish:webauthn/register/finish/?}`).Handler(negroni.New(
+		ar.Token(TokenTypeAccess),
+		ar.DenyImpersonation(),
+		negroni.Wrap(ar.WebauthnRegisterFinish()),
+	)).Methods("POST")
+	auth.Path(`/{webauthn/login/begin:webauthn/login/begin/?}`).HandlerFunc(ar.WebauthnLoginBegin()).Methods("POST")
+	auth.Path(`/{webauthn/login/finish:webauthn/login/finish/?}`).HandlerFunc(ar.WebauthnLoginFinish()).Methods("POST")
+
 	meRouter := mux.NewRouter().PathPrefix("/me").Subrouter()
 	ar.router.PathPrefix("/me").Handler(apiMiddlewares.With(
 		ar.SignatureHandler(),
@@ -61,8 +85,17 @@ func (ar *Router) initRoutes() {
 		negroni.Wrap(meRouter),
 	))
 	meRouter.Path("").HandlerFunc(ar.IsLoggedIn()).Methods("GET")
-	meRouter.Path("").HandlerFunc(ar.UpdateUser()).Methods("PUT")
+	meRouter.Path("").Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.UpdateUser()))).Methods("PUT")
 	meRouter.Path(`/{logout:logout/?}`).HandlerFunc(ar.Logout()).Methods("POST")
+	meRouter.Path(`/{logout_all:logout_all/?}`).Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.LogoutAll()))).Methods("POST")
+	meRouter.Path(`/{trusted_devices:trusted_devices/?}`).HandlerFunc(ar.TrustedDevices()).Methods("GET")
+	meRouter.Path(`/trusted_devices/{id}`).Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.RevokeTrustedDevice()))).Methods("DELETE")
+	meRouter.Path(`/{identities:identities/?}`).HandlerFunc(ar.FederatedIdentities()).Methods("GET")
+	meRouter.Path(`/{identities:identities/?}`).Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.LinkFederatedIdentity()))).Methods("POST")
+	meRouter.Path(`/identities/{provider}/{id}`).Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.UnlinkFederatedIdentity()))).Methods("DELETE")
+	meRouter.Path(`/{upgrade:upgrade/?}`).Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.UpgradeAnonymousUser()))).Methods("POST")
+	meRouter.Path(`/{sessions:sessions/?}`).HandlerFunc(ar.Sessions()).Methods("GET")
+	meRouter.Path(`/sessions/{id}`).Handler(negroni.New(ar.DenyImpersonation(), negroni.Wrap(ar.DeleteSession()))).Methods("DELETE")
 
 	oidc := mux.NewRouter().PathPrefix("/.well-known").Subrouter()
 
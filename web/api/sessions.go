@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/madappgang/identifo/model"
+)
+
+// Sessions lists where the user is logged in, one session per refresh token family.
+func (ar *Router) Sessions() http.HandlerFunc {
+	type sessionsResponse struct {
+		Sessions []model.UserSession `json:"sessions"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := tokenFromContext(r.Context()).UserID()
+		sessions, err := ar.tokenStorage.UserSessions(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "Sessions.UserSessions")
+			return
+		}
+		ar.ServeJSON(w, http.StatusOK, &sessionsResponse{Sessions: sessions})
+	}
+}
+
+// DeleteSession logs the user out of the session, its refresh tokens stop working.
+// Access tokens already issued for the session live until they expire.
+func (ar *Router) DeleteSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := tokenFromContext(r.Context()).UserID()
+		sessionID := mux.Vars(r)["id"]
+
+		tokens, err := ar.tokenStorage.DeleteUserSession(userID, sessionID)
+		if err == model.ErrorNotFound {
+			ar.Error(w, ErrorAPISessionNotFound, http.StatusNotFound, "", "DeleteSession.DeleteUserSession")
+			return
+		} else if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "DeleteSession.DeleteUserSession")
+			return
+		}
+
+		for _, t := range tokens {
+			if err = ar.tokenBlacklist.Add(t); err != nil {
+				ar.logger.Println("Cannot blacklist refresh token of deleted session:", err)
+			}
+		}
+
+		result := map[string]string{"result": "ok"}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+)
+
+func TestDeleteSession(t *testing.T) {
+	router, tokenService, app, us := testRouter(t)
+
+	// In-memory storage returns random users, some of them are inactive.
+	activeUser := func() model.User {
+		u, _ := us.UserByID("user")
+		for !u.Active() {
+			u, _ = us.UserByID("user")
+		}
+		return u
+	}
+	// login issues access token and starts new session, the way login endpoints do.
+	login := func(u model.User) (string, string) {
+		access, err := tokenService.NewAccessToken(u, []string{jwtService.OfflineScope}, app, false)
+		if err != nil {
+			t.Fatalf("Unable to create access token %v", err)
+		}
+		accessString, err := tokenService.String(access)
+		if err != nil {
+			t.Fatalf("Unable to serialize token %v", err)
+		}
+		refresh, err := tokenService.NewRefreshToken(u, []string{jwtService.OfflineScope}, app, model.ClientInfo{DeviceName: "Phone"})
+		if err != nil {
+			t.Fatalf("Unable to create refresh token %v", err)
+		}
+		refreshString, err := tokenService.String(refresh)
+		if err != nil {
+			t.Fatalf("Unable to serialize token %v", err)
+		}
+		if refresh, err = tokenService.Parse(refreshString); err != nil {
+			t.Fatalf("Unable to parse token %v", err)
+		}
+		return accessString, refresh.Payload()[jwtService.PayloadRefreshTokenFamily]
+	}
+	sessions := func(accessToken string) []string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, signedRequest("GET", "/me/sessions", accessToken, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Sessions status = %d, response %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Sessions []model.UserSession `json:"sessions"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unable to parse sessions %v", err)
+		}
+		ids := []string{}
+		for _, s := range resp.Sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	user, other := activeUser(), activeUser()
+	accessToken, session := login(user)
+	_, kept := login(user)
+	otherAccessToken, otherSession := login(other)
+
+	tests := []struct {
+		name       string
+		session    string
+		wantStatus int
+	}{
+		{"own session", session, http.StatusOK},
+		{"deleted session", session, http.StatusNotFound},
+		{"unknown session", "unknown", http.StatusNotFound},
+		{"session of another user", otherSession, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, signedRequest("DELETE", "/me/sessions/"+tt.session, accessToken, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Delete session status = %d, want %d, response %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	if ids := sessions(accessToken); len(ids) != 1 || ids[0] != kept {
+		t.Errorf("Sessions = %v, want only %v left", ids, kept)
+	}
+	if ids := sessions(otherAccessToken); len(ids) != 1 || ids[0] != otherSession {
+		t.Errorf("Sessions of another user = %v, want %v kept", ids, otherSession)
+	}
+}
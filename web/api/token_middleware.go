@@ -54,6 +54,11 @@ func (ar *Router) Token(tokenType string) negroni.HandlerFunc {
 			return
 		}
 
+		if model.TokenRevokedForUser(ar.tokenStorage, token.UserID(), token.IssuedAt()) {
+			ar.Error(rw, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, "", "Token.TokenRevokedForUser")
+			return
+		}
+
 		if strings.Trim(r.RequestURI, "/ ") != "auth/tfa/finalize" {
 			if payload := token.Payload(); payload != nil && payload["tfa_authorized"] == "false" {
 				ar.Error(rw, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, "", "Token.IsTFAuthorized")
@@ -72,3 +77,16 @@ func (ar *Router) Token(tokenType string) negroni.HandlerFunc {
 func tokenFromContext(ctx context.Context) jwt.Token {
 	return ctx.Value(model.TokenContextKey).(jwt.Token)
 }
+
+// DenyImpersonation middleware refuses tokens issued to someone acting on behalf of the user, like support admin.
+// Those who impersonate the user can see the account, but can't change how it is logged in to or protected.
+// Token middleware must go before it.
+func (ar *Router) DenyImpersonation() negroni.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if tokenFromContext(r.Context()).Actor() != nil {
+			ar.Error(rw, ErrorAPIImpersonationForbidden, http.StatusForbidden, "", "DenyImpersonation")
+			return
+		}
+		next.ServeHTTP(rw, r)
+	}
+}
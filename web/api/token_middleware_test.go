@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	configStorageFile "github.com/madappgang/identifo/configuration/storage/file"
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+	staticStoreLocal "github.com/madappgang/identifo/static/storage/local"
+	"github.com/madappgang/identifo/storage/mem"
+)
+
+const (
+	testAppID     = "impersonation-test-app"
+	testAppSecret = "impersonation-test-secret"
+)
+
+// testRouter returns API router with in-memory storages and the token service to issue tokens with.
+func testRouter(t *testing.T) (model.Router, jwtService.TokenService, model.AppData, model.UserStorage) {
+	t.Helper()
+
+	us, err := mem.NewUserStorage()
+	if err != nil {
+		t.Fatalf("Unable to create user storage %v", err)
+	}
+	ts, err := mem.NewTokenStorage()
+	if err != nil {
+		t.Fatalf("Unable to create token storage %v", err)
+	}
+	tb, err := mem.NewTokenBlacklist()
+	if err != nil {
+		t.Fatalf("Unable to create token blacklist %v", err)
+	}
+	vcs, err := mem.NewVerificationCodeStorage()
+	if err != nil {
+		t.Fatalf("Unable to create verification code storage %v", err)
+	}
+	as, err := mem.NewAppStorage()
+	if err != nil {
+		t.Fatalf("Unable to create app storage %v", err)
+	}
+	app := mem.MakeAppData(testAppID, testAppSecret, true, "testName", "testDescription", []string{}, true, []string{}, 0, 0, 0, []string{}, false, false, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+	if _, err = as.CreateApp(&app); err != nil {
+		t.Fatalf("Unable to create app %v", err)
+	}
+
+	configStorage, err := configStorageFile.NewConfigurationStorage(model.ConfigurationStorageSettings{
+		Type: model.ConfigurationStorageTypeFile,
+		KeyStorage: model.KeyStorageSettings{
+			Type:   model.KeyStorageTypeLocal,
+			Folder: "../../jwt",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to init configuration storage. %v", err)
+	}
+	keys, err := configStorage.LoadKeys(ijwt.TokenSignatureAlgorithmES256)
+	if err != nil {
+		t.Fatalf("Unable to load keys. %v", err)
+	}
+	tokenService, err := jwtService.NewJWTokenService(keys, "identifo.madappgang.com", ts, as, us)
+	if err != nil {
+		t.Fatalf("Unable to create token service %v", err)
+	}
+
+	sfs, err := staticStoreLocal.NewStaticFilesStorage(model.StaticFilesStorageSettings{Folder: "../../static"})
+	if err != nil {
+		t.Fatalf("Unable to create static files storage %v", err)
+	}
+
+	router, err := NewRouter(nil, as, us, ts, tb, vcs, nil, sfs, tokenService, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unable to create router %v", err)
+	}
+	return router, tokenService, &app, us
+}
+
+// signedRequest returns API request signed with the app secret and authorized with the token.
+func signedRequest(method, path, token string, body []byte) *http.Request {
+	r := httptest.NewRequest(method, path, bytes.NewReader(body))
+	mac := hmac.New(sha256.New, []byte(testAppSecret))
+	if len(body) == 0 {
+		// Requests without body are signed by their URI.
+		body = []byte(path)
+	}
+	mac.Write(body)
+	r.Header.Set(SignatureHeaderKey, SignatureHeaderValuePrefix+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	r.Header.Set(HeaderKeyAppID, testAppID)
+	r.Header.Set(TokenHeaderKey, "Bearer "+token)
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestDenyImpersonation(t *testing.T) {
+	router, tokenService, app, us := testRouter(t)
+
+	// In-memory storage returns random users, some of them are inactive.
+	u, _ := us.UserByID("user")
+	for !u.Active() {
+		u, _ = us.UserByID("user")
+	}
+
+	impersonation, err := tokenService.NewImpersonationToken(u, []string{}, app, "admin@example.com")
+	if err != nil {
+		t.Fatalf("Unable to create impersonation token %v", err)
+	}
+	impersonationString, err := tokenService.String(impersonation)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+	access, err := tokenService.NewAccessToken(u, []string{}, app, false)
+	if err != nil {
+		t.Fatalf("Unable to create access token %v", err)
+	}
+	accessString, err := tokenService.String(access)
+	if err != nil {
+		t.Fatalf("Unable to serialize token %v", err)
+	}
+
+	body := []byte(`{"provider":"google","access_token":"token"}`)
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		token     string
+		forbidden bool
+	}{
+		{"link identity with impersonation token", "POST", "/me/identities", impersonationString, true},
+		{"link identity with user token", "POST", "/me/identities", accessString, false},
+		{"view identities with impersonation token", "GET", "/me/identities", impersonationString, false},
+		{"update account with impersonation token", "PUT", "/me", impersonationString, true},
+		{"register passkey with impersonation token", "POST", "/auth/webauthn/register/begin", impersonationString, true},
+		{"enable TFA with impersonation token", "PUT", "/auth/tfa/enable", impersonationString, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody := body
+			if tt.method == "GET" {
+				reqBody = nil
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, signedRequest(tt.method, tt.path, tt.token, reqBody))
+
+			var resp struct {
+				Error struct {
+					ID MessageID `json:"id"`
+				} `json:"error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			forbidden := w.Code == http.StatusForbidden && resp.Error.ID == ErrorAPIImpersonationForbidden
+			if forbidden != tt.forbidden {
+				t.Errorf("%s %s status = %d, error = %q, want forbidden %v", tt.method, tt.path, w.Code, resp.Error.ID, tt.forbidden)
+			}
+			// Requests must get past the app, signature and token checks to tell anything.
+			if resp.Error.ID == ErrorAPIRequestSignatureInvalid || resp.Error.ID == ErrorAPIRequestTokenInvalid || resp.Error.ID == ErrorAPIRequestAppIDInvalid {
+				t.Errorf("%s %s is rejected before the impersonation check: %q", tt.method, tt.path, resp.Error.ID)
+			}
+		})
+	}
+}
@@ -74,15 +74,26 @@ func (ar *Router) UpdateUser() http.HandlerFunc {
 
 		if d.updateEmail {
 			user.SetEmail(d.NewEmail)
+			user.SetEmailVerified(false)
 		}
 
 		if d.updateUsername || d.updateEmail {
-			if _, err = ar.userStorage.UpdateUser(userID, user); err != nil {
+			if user, err = ar.userStorage.UpdateUser(userID, user); err == model.ErrorUserExists {
+				ar.Error(w, ErrorAPIUsernameTaken, http.StatusBadRequest, err.Error(), "UpdateUser.UpdateUser")
+				return
+			} else if err != nil {
 				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, "Unable to update username or email. Error:"+err.Error(), "UpdateUser.UpdateUser")
 				return
 			}
 		}
 
+		// New email has to be verified.
+		if d.updateEmail {
+			if err = ar.sendEmailVerification(user); err != nil {
+				ar.logger.Println("Error sending email verification:", err)
+			}
+		}
+
 		// Prepare response.
 		updatedFields := []string{}
 		if d.updateUsername {
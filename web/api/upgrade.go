@@ -0,0 +1,213 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+	"github.com/madappgang/identifo/web/middleware"
+)
+
+// upgradeData is what the anonymous user attaches to become a full one.
+// Username and password go together, federated identity credentials are the same as for federated login.
+type upgradeData struct {
+	Username          string   `json:"username,omitempty"`
+	Password          string   `json:"password,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	Provider          string   `json:"provider,omitempty"`
+	AccessToken       string   `json:"access_token,omitempty"`
+	AuthorizationCode string   `json:"authorization_code,omitempty"`
+	IDToken           string   `json:"id_token,omitempty"`
+	Scopes            []string `json:"scopes,omitempty"`
+}
+
+func (d *upgradeData) validate() error {
+	if len(d.Username) == 0 && len(d.Password) == 0 && len(d.Email) == 0 && len(d.Provider) == 0 {
+		return errors.New("Username and password, email or federated identity should be specified. ")
+	}
+	if len(d.Username) > 0 || len(d.Password) > 0 {
+		usernameLen := len(d.Username)
+		if usernameLen < 6 || usernameLen > 50 {
+			return fmt.Errorf("Incorrect username length %d, expected a number between 6 and 50", usernameLen)
+		}
+		pswdLen := len(d.Password)
+		if pswdLen < 6 || pswdLen > 50 {
+			return fmt.Errorf("Incorrect password length %d, expected a number between 6 and 50", pswdLen)
+		}
+	}
+	if len(d.Email) > 0 && !model.EmailRegexp.MatchString(d.Email) {
+		return errors.New("Email is not valid. ")
+	}
+	return nil
+}
+
+func (d *upgradeData) federatedLoginData() FederatedLoginData {
+	return FederatedLoginData{
+		FederatedIDProvider: d.Provider,
+		AccessToken:         d.AccessToken,
+		AuthorizationCode:   d.AuthorizationCode,
+		IDToken:             d.IDToken,
+		Scopes:              d.Scopes,
+	}
+}
+
+// UpgradeAnonymousUser turns the anonymous user into a full one, by attaching username and password, email or federated identity.
+// The user keeps the same ID, so all data the apps have for the user stays with it.
+// Anonymous access token is revoked, and the new tokens are returned.
+func (ar *Router) UpgradeAnonymousUser() http.HandlerFunc {
+	builtinProviders := ar.builtinFederatedProviders()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		app := middleware.AppFromContext(r.Context())
+		if app == nil {
+			ar.logger.Println("Error getting App")
+			ar.Error(w, ErrorAPIRequestAppIDInvalid, http.StatusBadRequest, "App is not in context.", "UpgradeAnonymousUser.AppFromContext")
+			return
+		}
+
+		if app.RegistrationForbidden() {
+			ar.Error(w, ErrorAPIAppRegistrationForbidden, http.StatusForbidden, "Registration is forbidden in app.", "UpgradeAnonymousUser.RegistrationForbidden")
+			return
+		}
+
+		d := upgradeData{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+		if err := d.validate(); err != nil {
+			ar.Error(w, ErrorAPIRequestBodyParamsInvalid, http.StatusBadRequest, err.Error(), "UpgradeAnonymousUser.validate")
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "UpgradeAnonymousUser.UserByID")
+			return
+		}
+		if !user.IsAnonymous() {
+			ar.Error(w, ErrorAPIUserNotAnonymous, http.StatusBadRequest, "", "UpgradeAnonymousUser.IsAnonymous")
+			return
+		}
+
+		// Check if it makes sense to upgrade the user.
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    app.NewUserDefaultRole(),
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err = ar.Authorizer.Authorize(azi); err != nil {
+			ar.Error(w, ErrorAPIAppAccessDenied, http.StatusForbidden, err.Error(), "UpgradeAnonymousUser.Authorizer")
+			return
+		}
+
+		if len(d.Password) > 0 {
+			if err = model.StrongPswd(d.Password); err != nil {
+				ar.Error(w, ErrorAPIRequestPasswordWeak, http.StatusBadRequest, err.Error(), "UpgradeAnonymousUser.StrongPswd")
+				return
+			}
+			if d.Username != user.Username() && ar.userStorage.UserExists(d.Username) {
+				ar.Error(w, ErrorAPIUsernameTaken, http.StatusBadRequest, "", "UpgradeAnonymousUser.UserExists")
+				return
+			}
+		}
+
+		if len(d.Email) > 0 {
+			if owner, err := ar.userStorage.UserByEmail(d.Email); err == nil && owner.ID() != userID {
+				ar.Error(w, ErrorAPIEmailTaken, http.StatusBadRequest, "", "UpgradeAnonymousUser.UserByEmail")
+				return
+			}
+		}
+
+		// Federated identity goes first, as it is the only thing that may be taken by someone else at the last moment.
+		if len(d.Provider) > 0 {
+			if !ar.SupportedLoginWays.Federated {
+				ar.Error(w, ErrorAPIAppFederatedLoginNotSupported, http.StatusBadRequest, "Application does not support federated login", "UpgradeAnonymousUser.supportedLoginWays")
+				return
+			}
+			fid, federatedID, ok := ar.federatedUserID(w, app, d.federatedLoginData(), builtinProviders, "UpgradeAnonymousUser")
+			if !ok {
+				return
+			}
+			if err = ar.userStorage.LinkFederatedID(userID, fid, federatedID); err == model.ErrorUserExists {
+				ar.Error(w, ErrorAPIFederatedIdentityLinked, http.StatusConflict, "", "UpgradeAnonymousUser.LinkFederatedID")
+				return
+			} else if err != nil {
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "UpgradeAnonymousUser.LinkFederatedID")
+				return
+			}
+		}
+
+		if len(d.Password) > 0 {
+			if err = ar.userStorage.ResetPassword(userID, d.Password); err != nil {
+				ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, "Reset password. Error: "+err.Error(), "UpgradeAnonymousUser.ResetPassword")
+				return
+			}
+		}
+
+		// Refetch user with new password hash and federated identity.
+		if user, err = ar.userStorage.UserByID(userID); err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusUnauthorized, err.Error(), "UpgradeAnonymousUser.RefetchUser")
+			return
+		}
+		if len(d.Username) > 0 {
+			user.SetUsername(d.Username)
+		}
+		if len(d.Email) > 0 && d.Email != user.Email() {
+			user.SetEmail(d.Email)
+			user.SetEmailVerified(false)
+		}
+		user.Deanonimize()
+		user.SetAccessRole(app.NewUserDefaultRole())
+
+		if user, err = ar.userStorage.UpdateUser(userID, user); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, "Unable to upgrade user. Error:"+err.Error(), "UpgradeAnonymousUser.UpdateUser")
+			return
+		}
+
+		// Anonymous access token should not be used anymore.
+		if accessTokenBytes, ok := r.Context().Value(model.TokenRawContextKey).([]byte); ok {
+			if err = ar.tokenBlacklist.Add(string(accessTokenBytes)); err != nil {
+				ar.logger.Printf("Cannot blacklist access token: %s\n", err)
+			}
+		}
+
+		if len(d.Email) > 0 && !user.EmailVerified() {
+			if err = ar.sendEmailVerification(user); err != nil {
+				ar.logger.Println("Error sending email verification:", err)
+			}
+		}
+
+		// The user has to verify email first, so there are no tokens yet.
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			user.Sanitize()
+			ar.ServeJSON(w, http.StatusOK, AuthResponse{User: user})
+			return
+		}
+
+		scopes, err := ar.userStorage.RequestScopes(user.ID(), d.Scopes)
+		if err != nil {
+			ar.Error(w, ErrorAPIRequestScopesForbidden, http.StatusForbidden, err.Error(), "UpgradeAnonymousUser.RequestScopes")
+			return
+		}
+
+		offline := contains(scopes, jwtService.OfflineScope)
+		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, false, model.ClientInfoFromRequest(r))
+		if err != nil {
+			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "UpgradeAnonymousUser.loginUser")
+			return
+		}
+
+		user.Sanitize()
+		result := AuthResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			User:         user,
+		}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
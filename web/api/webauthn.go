@@ -0,0 +1,379 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+	"github.com/madappgang/identifo/web/middleware"
+	"github.com/madappgang/identifo/webauthn"
+)
+
+var (
+	errWebauthnTokenRevoked = fmt.Errorf("Token is revoked")
+	errWebauthnTokenNotTFA  = fmt.Errorf("Token does not require two-factor authentication")
+)
+
+type webauthnOptions struct {
+	PublicKey interface{} `json:"publicKey"`
+}
+
+// WebauthnRegisterBegin starts passkey registration for the logged in user.
+func (ar *Router) WebauthnRegisterBegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Webauthn {
+			ar.Error(w, ErrorAPIAppWebauthnNotSupported, http.StatusBadRequest, "Application does not support passkeys", "WebauthnRegisterBegin.supportedLoginWays")
+			return
+		}
+
+		rp, err := webauthn.NewRelyingParty(ar.Host)
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnRegisterBegin.NewRelyingParty")
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, err.Error(), "WebauthnRegisterBegin.UserByID")
+			return
+		}
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnRegisterBegin.NewChallenge")
+			return
+		}
+		if err = ar.webauthnChallengeStorage.SaveWebauthnChallenge(model.NewWebauthnChallenge(challenge, model.WebauthnCeremonyRegistration, user.ID())); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnRegisterBegin.SaveWebauthnChallenge")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, webauthnOptions{PublicKey: rp.NewCreationOptions(user, challenge)})
+	}
+}
+
+// WebauthnRegisterFinish verifies new passkey and adds it to the logged in user.
+func (ar *Router) WebauthnRegisterFinish() http.HandlerFunc {
+	type registerData struct {
+		webauthn.Credential
+		Name string `json:"name,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Webauthn {
+			ar.Error(w, ErrorAPIAppWebauthnNotSupported, http.StatusBadRequest, "Application does not support passkeys", "WebauthnRegisterFinish.supportedLoginWays")
+			return
+		}
+
+		rp, err := webauthn.NewRelyingParty(ar.Host)
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnRegisterFinish.NewRelyingParty")
+			return
+		}
+
+		d := registerData{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		userID := tokenFromContext(r.Context()).UserID()
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, err.Error(), "WebauthnRegisterFinish.UserByID")
+			return
+		}
+
+		challenge, err := d.Challenge()
+		if err != nil {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, err.Error(), "WebauthnRegisterFinish.Challenge")
+			return
+		}
+		if found, err := ar.consumeWebauthnChallenge(challenge, model.WebauthnCeremonyRegistration, user.ID()); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnRegisterFinish.consumeWebauthnChallenge")
+			return
+		} else if !found {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, "Unknown challenge", "WebauthnRegisterFinish.consumeWebauthnChallenge")
+			return
+		}
+
+		credential, err := rp.VerifyRegistration(&d.Credential, challenge)
+		if err != nil {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, err.Error(), "WebauthnRegisterFinish.VerifyRegistration")
+			return
+		}
+		for _, c := range user.WebauthnCredentials() {
+			if c.ID == credential.ID {
+				ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, "Passkey is already registered", "WebauthnRegisterFinish.alreadyRegistered")
+				return
+			}
+		}
+		credential.Name = d.Name
+
+		user.SetWebauthnCredentials(append(user.WebauthnCredentials(), credential))
+		if _, err = ar.userStorage.UpdateUser(user.ID(), user); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnRegisterFinish.UpdateUser")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, credential)
+	}
+}
+
+// WebauthnLoginBegin starts login with a passkey.
+// Username is optional, without it the user picks one of passkeys stored on the device.
+// Users who have logged in with password and need to pass two-factor authentication present their access token.
+func (ar *Router) WebauthnLoginBegin() http.HandlerFunc {
+	type loginData struct {
+		Username string `json:"username,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Webauthn {
+			ar.Error(w, ErrorAPIAppWebauthnNotSupported, http.StatusBadRequest, "Application does not support passkeys", "WebauthnLoginBegin.supportedLoginWays")
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if app == nil {
+			ar.logger.Println("Error getting App")
+			ar.Error(w, ErrorAPIRequestAppIDInvalid, http.StatusBadRequest, "App is not in context.", "WebauthnLoginBegin.AppFromContext")
+			return
+		}
+
+		rp, err := webauthn.NewRelyingParty(ar.Host)
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnLoginBegin.NewRelyingParty")
+			return
+		}
+
+		d := loginData{}
+		if err = json.NewDecoder(r.Body).Decode(&d); err != nil && err != io.EOF {
+			ar.Error(w, ErrorAPIRequestBodyInvalid, http.StatusBadRequest, err.Error(), "WebauthnLoginBegin.Unmarshal")
+			return
+		}
+
+		userID := ""
+		if tfaToken, _, err := ar.webauthnTFAToken(r, app); err != nil {
+			ar.Error(w, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, err.Error(), "WebauthnLoginBegin.webauthnTFAToken")
+			return
+		} else if tfaToken != nil {
+			userID = tfaToken.UserID()
+		} else if d.Username != "" {
+			if userID, err = ar.userStorage.IDByName(d.Username); err != nil {
+				ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, err.Error(), "WebauthnLoginBegin.IDByName")
+				return
+			}
+		}
+
+		var user model.User
+		if userID != "" {
+			if user, err = ar.userStorage.UserByID(userID); err != nil {
+				ar.Error(w, ErrorAPIUserNotFound, http.StatusBadRequest, err.Error(), "WebauthnLoginBegin.UserByID")
+				return
+			}
+		}
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnLoginBegin.NewChallenge")
+			return
+		}
+		if err = ar.webauthnChallengeStorage.SaveWebauthnChallenge(model.NewWebauthnChallenge(challenge, model.WebauthnCeremonyLogin, "")); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnLoginBegin.SaveWebauthnChallenge")
+			return
+		}
+
+		ar.ServeJSON(w, http.StatusOK, webauthnOptions{PublicKey: rp.NewRequestOptions(user, challenge)})
+	}
+}
+
+// WebauthnLoginFinish verifies the passkey and logs user in.
+// Passkey with user verification, e.g. biometrics or PIN, is multi-factor by itself.
+// Otherwise two-factor authentication is required as usual.
+// With access token of the user who needs to pass two-factor authentication, passkey is used as a second factor.
+func (ar *Router) WebauthnLoginFinish() http.HandlerFunc {
+	type loginData struct {
+		webauthn.Credential
+		Username string   `json:"username,omitempty"`
+		Scopes   []string `json:"scopes,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Webauthn {
+			ar.Error(w, ErrorAPIAppWebauthnNotSupported, http.StatusBadRequest, "Application does not support passkeys", "WebauthnLoginFinish.supportedLoginWays")
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		if app == nil {
+			ar.logger.Println("Error getting App")
+			ar.Error(w, ErrorAPIRequestAppIDInvalid, http.StatusBadRequest, "App is not in context.", "WebauthnLoginFinish.AppFromContext")
+			return
+		}
+
+		rp, err := webauthn.NewRelyingParty(ar.Host)
+		if err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnLoginFinish.NewRelyingParty")
+			return
+		}
+
+		d := loginData{}
+		if ar.MustParseJSON(w, r, &d) != nil {
+			return
+		}
+
+		tfaToken, tfaTokenString, err := ar.webauthnTFAToken(r, app)
+		if err != nil {
+			ar.Error(w, ErrorAPIRequestTokenInvalid, http.StatusBadRequest, err.Error(), "WebauthnLoginFinish.webauthnTFAToken")
+			return
+		}
+
+		challenge, err := d.Challenge()
+		if err != nil {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, err.Error(), "WebauthnLoginFinish.Challenge")
+			return
+		}
+		if found, err := ar.consumeWebauthnChallenge(challenge, model.WebauthnCeremonyLogin, ""); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnLoginFinish.consumeWebauthnChallenge")
+			return
+		} else if !found {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, "Unknown challenge", "WebauthnLoginFinish.consumeWebauthnChallenge")
+			return
+		}
+
+		// Discoverable credentials carry user ID, others are looked up by username.
+		userID := d.UserID()
+		if userID == "" && d.Username != "" {
+			if userID, err = ar.userStorage.IDByName(d.Username); err != nil {
+				ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, err.Error(), "WebauthnLoginFinish.IDByName")
+				return
+			}
+		}
+		if userID == "" && tfaToken != nil {
+			userID = tfaToken.UserID()
+		}
+		if userID == "" || (tfaToken != nil && tfaToken.UserID() != userID) {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, "Unknown user", "WebauthnLoginFinish.userID")
+			return
+		}
+
+		user, err := ar.userStorage.UserByID(userID)
+		if err != nil {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusBadRequest, err.Error(), "WebauthnLoginFinish.UserByID")
+			return
+		}
+
+		credential, userVerified, err := rp.VerifyLogin(&d.Credential, challenge, user.WebauthnCredentials())
+		if err != nil {
+			ar.Error(w, ErrorAPIWebauthnCredentialInvalid, http.StatusUnauthorized, err.Error(), "WebauthnLoginFinish.VerifyLogin")
+			return
+		}
+		user.SetWebauthnCredentials(webauthn.ReplaceCredential(user.WebauthnCredentials(), credential))
+		if user, err = ar.userStorage.UpdateUser(user.ID(), user); err != nil {
+			ar.Error(w, ErrorAPIInternalServerError, http.StatusInternalServerError, err.Error(), "WebauthnLoginFinish.UpdateUser")
+			return
+		}
+
+		require2FA := false
+		if tfaToken == nil {
+			// Authorize user if the app requires authorization.
+			azi := authorization.AuthzInfo{
+				App:         app,
+				UserRole:    user.AccessRole(),
+				ResourceURI: r.RequestURI,
+				Method:      r.Method,
+			}
+			if err = ar.Authorizer.Authorize(azi); err != nil {
+				ar.Error(w, ErrorAPIAppAccessDenied, http.StatusForbidden, err.Error(), "WebauthnLoginFinish.Authorizer")
+				return
+			}
+
+			if app.EmailVerificationRequired() && !user.EmailVerified() {
+				ar.Error(w, ErrorAPIRequestEmailNotVerified, http.StatusForbidden, "Email is not verified", "WebauthnLoginFinish.EmailVerified")
+				return
+			}
+
+			if !userVerified {
+				if require2FA, err = ar.check2FA(w, app.TFAStatus(), user.TFAInfo(), false); err != nil {
+					return
+				}
+			}
+		} else if err = ar.tokenBlacklist.Add(tfaTokenString); err != nil {
+			// Passkey has been used as a second factor, so the old access token is not needed anymore.
+			ar.logger.Printf("Cannot blacklist old access token: %s\n", err)
+		}
+
+		scopes, err := ar.userStorage.RequestScopes(user.ID(), d.Scopes)
+		if err != nil {
+			ar.Error(w, ErrorAPIRequestScopesForbidden, http.StatusForbidden, err.Error(), "WebauthnLoginFinish.RequestScopes")
+			return
+		}
+
+		offline := contains(scopes, jwtService.OfflineScope)
+		accessToken, refreshToken, err := ar.loginUser(user, scopes, app, offline, require2FA, model.ClientInfoFromRequest(r))
+		if err != nil {
+			ar.Error(w, ErrorAPIAppAccessTokenNotCreated, http.StatusInternalServerError, err.Error(), "WebauthnLoginFinish.loginUser")
+			return
+		}
+
+		if require2FA {
+			if err = ar.sendTFACode(w, user, "WebauthnLoginFinish.sendTFACode"); err != nil {
+				return
+			}
+		} else {
+			ar.userStorage.UpdateLoginMetadata(user.ID())
+		}
+
+		user.Sanitize()
+		result := AuthResponse{
+			AccessToken:    accessToken,
+			RefreshToken:   refreshToken,
+			User:           user,
+			NeedFurtherTFA: require2FA,
+		}
+		ar.ServeJSON(w, http.StatusOK, result)
+	}
+}
+
+// webauthnTFAToken returns access token of the user who is in the middle of two-factor authentication, if it is presented.
+func (ar *Router) webauthnTFAToken(r *http.Request, app model.AppData) (ijwt.Token, string, error) {
+	tokenBytes := ijwt.ExtractTokenFromBearerHeader(r.Header.Get(TokenHeaderKey))
+	if tokenBytes == nil {
+		return nil, "", nil
+	}
+	tokenString := string(tokenBytes)
+
+	token, err := ar.tokenService.Parse(tokenString)
+	if err != nil {
+		return nil, "", err
+	}
+	v := jwtValidator.NewValidator(app.ID(), ar.tokenService.Issuer(), "", TokenTypeAccess)
+	if err = v.Validate(token); err != nil {
+		return nil, "", err
+	}
+	if ar.tokenBlacklist.IsBlacklisted(tokenString) {
+		return nil, "", errWebauthnTokenRevoked
+	}
+	if payload := token.Payload(); payload == nil || payload[jwtService.PayloadTFAuthorized] != "false" {
+		return nil, "", errWebauthnTokenNotTFA
+	}
+	return token, tokenString, nil
+}
+
+// consumeWebauthnChallenge checks that the challenge was issued for the ceremony and the user, and removes it, so it cannot be answered again.
+func (ar *Router) consumeWebauthnChallenge(challenge string, ceremony model.WebauthnCeremony, userID string) (bool, error) {
+	wc, err := ar.webauthnChallengeStorage.ConsumeWebauthnChallenge(challenge)
+	if err == model.ErrorNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return wc.Ceremony == ceremony && wc.UserID == userID, nil
+}
@@ -1,6 +1,7 @@
 package html
 
 import (
+	"fmt"
 	"net/http"
 	"path"
 	"time"
@@ -128,10 +129,18 @@ func (ar *Router) ResetTFA() http.HandlerFunc {
 			return
 		}
 
+		userKey, ipKey := ar.loginAttemptsKeys(r, user.ID(), "")
+		if lockedFor := ar.loginLockout.LockedFor(userKey, ipKey); lockedFor > 0 {
+			SetFlash(w, FlashErrorMessageKey, fmt.Sprintf("Too many failed attempts. Please try again in %v", lockedFor))
+			http.Redirect(w, r, path.Join(ar.PathPrefix, r.URL.String()), http.StatusMovedPermanently)
+			return
+		}
+
 		totp := gotp.NewDefaultTOTP(user.TFAInfo().Secret)
 		dontNeedVerification := app.DebugTFACode() != "" && tfaCode == app.DebugTFACode()
 
 		if verified := totp.Verify(tfaCode, int(time.Now().Unix())); !(verified || dontNeedVerification) {
+			ar.failLogin(userKey, ipKey, user.ID())
 			SetFlash(w, FlashErrorMessageKey, "Invalid TFA code")
 			http.Redirect(w, r, path.Join(ar.PathPrefix, r.URL.String()), http.StatusMovedPermanently)
 			return
@@ -143,6 +152,8 @@ func (ar *Router) ResetTFA() http.HandlerFunc {
 			return
 		}
 
+		ar.loginLockout.Succeed(userKey)
+
 		// Invalidate reset token after use.
 		if err := ar.TokenBlacklist.Add(tokenString); err != nil {
 			ar.Logger.Printf("Cannot blacklist reset token after use: %s\n", err)
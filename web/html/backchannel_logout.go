@@ -0,0 +1,116 @@
+package html
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+)
+
+// Back-channel logout delivery, see https://openid.net/specs/openid-connect-backchannel-1_0.html.
+var (
+	backChannelLogoutClient = &http.Client{Timeout: 10 * time.Second}
+	// backChannelLogoutRetryDelays are the pauses before repeated delivery attempts, one per retry.
+	backChannelLogoutRetryDelays = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+)
+
+const backChannelLogoutTokenKey = "logout_token"
+
+// endWebSession logs the user out of the hosted web login.
+// The cookie token is blacklisted, so its copies stop working too,
+// and the apps which have got tokens through the session are notified through back-channel logout.
+func (ar *Router) endWebSession(w http.ResponseWriter, r *http.Request, user model.User) {
+	var appIDs []string
+	if tstr, err := getCookie(r, CookieKeyWebCookieToken); err == nil && tstr != "" {
+		if token, err := ar.TokenService.Parse(tstr); err == nil {
+			appIDs = jwtService.WebSessionApps(token)
+		}
+		if err = ar.TokenBlacklist.Add(tstr); err != nil {
+			ar.Logger.Println("Cannot blacklist web cookie token:", err)
+		}
+	}
+	deleteCookie(w, CookieKeyWebCookieToken)
+
+	if user != nil {
+		ar.backChannelLogout(user, appIDs)
+	}
+}
+
+// backChannelLogout sends signed logout tokens to the listed apps, if they are active and have back-channel logout URI.
+// Delivery happens in the background, so that slow apps do not hold the user up.
+func (ar *Router) backChannelLogout(user model.User, appIDs []string) {
+	notified := make(map[string]bool, len(appIDs))
+	for _, appID := range appIDs {
+		if notified[appID] {
+			continue
+		}
+		notified[appID] = true
+
+		app, err := ar.AppStorage.ActiveAppByID(appID)
+		if err != nil {
+			ar.Logger.Printf("Cannot get app %s for back-channel logout of user %s: %s", appID, user.ID(), err)
+			continue
+		}
+		uri := strings.TrimSpace(app.BackChannelLogoutURI())
+		if uri == "" {
+			continue
+		}
+
+		token, err := ar.TokenService.NewLogoutToken(user, app)
+		if err != nil {
+			ar.Logger.Printf("Cannot create logout token of user %s for app %s: %s", user.ID(), app.ID(), err)
+			continue
+		}
+		tokenString, err := ar.TokenService.String(token)
+		if err != nil {
+			ar.Logger.Printf("Cannot create logout token of user %s for app %s: %s", user.ID(), app.ID(), err)
+			continue
+		}
+
+		go ar.deliverLogoutToken(app.ID(), uri, user.ID(), tokenString)
+	}
+}
+
+// deliverLogoutToken posts the logout token to the app, retrying on network and server errors.
+// Client errors mean the app has rejected the token, so there is no point in retrying them.
+func (ar *Router) deliverLogoutToken(appID, uri, userID, token string) {
+	form := url.Values{backChannelLogoutTokenKey: []string{token}}
+
+	for attempt := 1; ; attempt++ {
+		retryable, err := postLogoutToken(uri, form)
+		if err == nil {
+			ar.Logger.Printf("Back-channel logout of user %s delivered to app %s, attempt %d", userID, appID, attempt)
+			return
+		}
+		if !retryable || attempt > len(backChannelLogoutRetryDelays) {
+			ar.Logger.Printf("Back-channel logout of user %s to app %s failed after %d attempts: %s", userID, appID, attempt, err)
+			return
+		}
+
+		delay := backChannelLogoutRetryDelays[attempt-1]
+		ar.Logger.Printf("Back-channel logout of user %s to app %s failed on attempt %d: %s, retrying in %s", userID, appID, attempt, err, delay)
+		time.Sleep(delay)
+	}
+}
+
+// postLogoutToken makes one delivery attempt and reports if the failed one is worth repeating.
+func postLogoutToken(uri string, form url.Values) (bool, error) {
+	resp, err := backChannelLogoutClient.PostForm(uri, form)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("app responded with status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("app responded with status %d", resp.StatusCode)
+	}
+}
@@ -1,14 +1,23 @@
 package html
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"net/http"
 	"time"
+
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
 )
 
 const (
 	// CookieKeyWebCookieToken cookie key to keep the web cookie token.
 	CookieKeyWebCookieToken = "identifo-user"
+
+	csrfTokenKey = "csrf_token"
 )
 
 func encode(src string) string {
@@ -24,8 +33,9 @@ func decode(src string) (string, error) {
 	return string(b), nil
 }
 
+// setCookie sets the cookie, which is not sent with requests from other sites, except for top-level navigation.
 func setCookie(w http.ResponseWriter, name, value string, maxAge int) {
-	c := &http.Cookie{Name: name, Value: encode(value), MaxAge: maxAge, HttpOnly: true}
+	c := &http.Cookie{Name: name, Value: encode(value), MaxAge: maxAge, HttpOnly: true, SameSite: http.SameSiteLaxMode}
 	http.SetCookie(w, c)
 }
 
@@ -51,3 +61,77 @@ func deleteCookie(w http.ResponseWriter, name string) {
 	c := &http.Cookie{Name: name, Value: "", Expires: time.Unix(0, 0), MaxAge: -1}
 	http.SetCookie(w, c)
 }
+
+// csrfToken derives the token which the form posted on behalf of the logged in user must carry.
+// Other sites cannot read the web cookie token, so they cannot forge the form.
+func csrfToken(webCookieToken, form string) string {
+	sum := sha256.Sum256([]byte(form + ":" + webCookieToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validCSRFToken checks that the posted form carries the CSRF token of the web cookie it comes with.
+func validCSRFToken(r *http.Request, form string) bool {
+	tstr, err := getCookie(r, CookieKeyWebCookieToken)
+	if err != nil || tstr == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.PostFormValue(csrfTokenKey)), []byte(csrfToken(tstr, form))) == 1
+}
+
+// webCookieUser returns the user who is logged in with the web cookie token, and the token itself.
+func (ar *Router) webCookieUser(r *http.Request, tokenValidator jwtValidator.Validator) (model.User, ijwt.Token, error) {
+	tstr, err := getCookie(r, CookieKeyWebCookieToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tstr == "" {
+		return nil, nil, http.ErrNoCookie
+	}
+
+	webCookieToken, err := ar.TokenService.Parse(tstr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = tokenValidator.Validate(webCookieToken); err != nil {
+		return nil, nil, err
+	}
+	if ar.webCookieTokenRevoked(tstr, webCookieToken) {
+		return nil, nil, errors.New("Web cookie token is revoked")
+	}
+
+	user, err := ar.UserStorage.UserByID(webCookieToken.UserID())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, webCookieToken, nil
+}
+
+// joinWebSession records in the web cookie that the app is getting tokens through the web session,
+// so that the app is notified when the session ends. The previous cookie token is blacklisted, as it does not list the app.
+func (ar *Router) joinWebSession(w http.ResponseWriter, r *http.Request, webCookieToken ijwt.Token, app model.AppData) error {
+	token, err := ar.TokenService.JoinWebCookieToken(webCookieToken, app.ID())
+	if err != nil {
+		return err
+	}
+	if token == webCookieToken {
+		return nil
+	}
+	tokenString, err := ar.TokenService.String(token)
+	if err != nil {
+		return err
+	}
+
+	if tstr, err := getCookie(r, CookieKeyWebCookieToken); err == nil && tstr != "" {
+		if err = ar.TokenBlacklist.Add(tstr); err != nil {
+			ar.Logger.Println("Cannot blacklist web cookie token:", err)
+		}
+	}
+	maxAge := ar.TokenService.WebCookieTokenLifespan() - (time.Now().Unix() - token.IssuedAt())
+	setCookie(w, CookieKeyWebCookieToken, tokenString, int(maxAge))
+	return nil
+}
+
+// webCookieTokenRevoked reports if the web cookie token has been revoked on logout, or together with all tokens of the user.
+func (ar *Router) webCookieTokenRevoked(tokenString string, token ijwt.Token) bool {
+	return ar.TokenBlacklist.IsBlacklisted(tokenString) || model.TokenRevokedForUser(ar.TokenStorage, token.UserID(), token.IssuedAt())
+}
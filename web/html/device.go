@@ -0,0 +1,352 @@
+package html
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+)
+
+// Device authorization grant parameters, see https://tools.ietf.org/html/rfc8628.
+const (
+	oauthDeviceCodeKey = "device_code"
+	oauthUserCodeKey   = "user_code"
+
+	oauthGrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	oauthErrorAuthorizationPending = "authorization_pending"
+	oauthErrorSlowDown             = "slow_down"
+	oauthErrorExpiredToken         = "expired_token"
+
+	deviceActionKey     = "action"
+	deviceActionApprove = "approve"
+	// deviceForm is the name of the approval form, which its CSRF token is bound to.
+	deviceForm = "device"
+
+	// userCodeCharset has no vowels to avoid forming words, and no easily confused characters.
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+	// slowDownIncrement is how much the polling interval grows in seconds, every time the device polls too often.
+	slowDownIncrement = 5
+)
+
+// deviceAuthorizationResponse is a device authorization response, see https://tools.ietf.org/html/rfc8628#section-3.2.
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAuthorization starts device authorization flow for devices that cannot host a browser, like TVs and CLIs.
+// The device shows the user code to the user and polls the token endpoint with the device code,
+// while the user approves the request on the verification page.
+func (ar *Router) DeviceAuthorization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Cannot parse request body")
+			return
+		}
+
+		app, _, err := ar.oauthClient(r)
+		if err != nil {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+			return
+		}
+
+		deviceCode, err := randomOAuthCode()
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, "Cannot create device code")
+			return
+		}
+		userCode, err := randomUserCode()
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, "Cannot create user code")
+			return
+		}
+
+		dc := model.DeviceCode{
+			DeviceCode: deviceCode,
+			UserCode:   userCode,
+			AppID:      app.ID(),
+			Scopes:     strings.Fields(r.PostFormValue(oauthScopeKey)),
+			Status:     model.DeviceCodeStatusPending,
+			Interval:   model.DeviceCodePollingInterval,
+			ExpiresAt:  time.Now().Add(model.DeviceCodeLifespan).Unix(),
+		}
+		if err = ar.DeviceCodeStorage.SaveDeviceCode(dc); err != nil {
+			ar.Logger.Printf("Error saving device code: %v", err)
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, "Cannot save device code")
+			return
+		}
+
+		verificationURI := ar.Host + path.Join(ar.PathPrefix, "/device")
+		ar.serveOAuthJSON(w, http.StatusOK, deviceAuthorizationResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                formatUserCode(userCode),
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?" + url.Values{oauthUserCodeKey: []string{formatUserCode(userCode)}}.Encode(),
+			ExpiresIn:               int64(model.DeviceCodeLifespan / time.Second),
+			Interval:                dc.Interval,
+		})
+	}
+}
+
+// exchangeDeviceCode issues tokens for the device once the user has approved the request.
+func (ar *Router) exchangeDeviceCode(w http.ResponseWriter, r *http.Request) {
+	app, _, err := ar.oauthClient(r)
+	if err != nil {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+		return
+	}
+
+	dc, err := ar.DeviceCodeStorage.DeviceCodeByDeviceCode(r.PostFormValue(oauthDeviceCodeKey))
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorExpiredToken, "Device code is invalid or expired")
+		return
+	}
+	if dc.AppID != app.ID() {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Device code was issued to another client")
+		return
+	}
+
+	now := time.Now().Unix()
+	if dc.Status == model.DeviceCodeStatusPending && now-dc.LastPolledAt < dc.Interval {
+		if err = ar.DeviceCodeStorage.UpdateDeviceCodePolling(dc.DeviceCode, now, dc.Interval+slowDownIncrement); err != nil {
+			ar.Logger.Printf("Error saving device code: %v", err)
+		}
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorSlowDown, "Polling too often, interval is increased")
+		return
+	}
+
+	switch dc.Status {
+	case model.DeviceCodeStatusPending:
+		if err = ar.DeviceCodeStorage.UpdateDeviceCodePolling(dc.DeviceCode, now, dc.Interval); err != nil {
+			ar.Logger.Printf("Error saving device code: %v", err)
+		}
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorAuthorizationPending, "User has not approved the request yet")
+		return
+	case model.DeviceCodeStatusDenied:
+		if err = ar.DeviceCodeStorage.DeleteDeviceCode(dc.DeviceCode); err != nil {
+			ar.Logger.Printf("Error deleting device code: %v", err)
+		}
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorAccessDenied, "User has denied the request")
+		return
+	}
+
+	// Device code is approved, it can be used only once, even if the device polls concurrently.
+	if dc, err = ar.DeviceCodeStorage.ConsumeDeviceCode(dc.DeviceCode); err != nil {
+		if err != model.ErrorNotFound {
+			ar.Logger.Printf("Error consuming device code: %v", err)
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, "Cannot use device code")
+			return
+		}
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorExpiredToken, "Device code is invalid or expired")
+		return
+	}
+
+	user, err := ar.UserStorage.UserByID(dc.UserID)
+	if err != nil || !user.Active() {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "User is not eligible to obtain the token")
+		return
+	}
+
+	ar.serveUserTokens(w, r, user, app, dc.Scopes, "", dc.AuthTime)
+}
+
+// DeviceHandler serves the page where the user enters the code shown on the device and approves the request.
+// Users who are not logged in are sent to the login page first.
+func (ar *Router) DeviceHandler() http.HandlerFunc {
+	tmpl, err := ar.staticFilesStorage.ParseTemplate(model.StaticPagesNames.Device)
+	if err != nil {
+		ar.Logger.Fatalln("Cannot parse Device template.", err)
+	}
+	errorPath := path.Join(ar.PathPrefix, "/misconfiguration")
+	tokenValidator := jwtValidator.NewValidator("identifo", ar.TokenService.Issuer(), "", jwtService.WebCookieTokenType)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveTemplate := func(data map[string]interface{}) {
+			data["Prefix"] = ar.PathPrefix
+			if err := tmpl.Execute(w, data); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+		}
+
+		userCode := normalizeUserCode(r.URL.Query().Get(oauthUserCodeKey))
+		if userCode == "" {
+			serveTemplate(map[string]interface{}{})
+			return
+		}
+
+		dc, err := ar.deviceCodeByUserCode(r, userCode)
+		if err != nil {
+			serveTemplate(map[string]interface{}{"Error": err.Error()})
+			return
+		}
+
+		app, err := ar.AppStorage.ActiveAppByID(dc.AppID)
+		if err != nil {
+			ar.Logger.Printf("Error: getting app by id. %s", err)
+			http.Redirect(w, r, errorPath, http.StatusFound)
+			return
+		}
+
+		tstr, err := getCookie(r, CookieKeyWebCookieToken)
+		if _, _, err = ar.webCookieUser(r, tokenValidator); err != nil {
+			scopesJSON, err := json.Marshal(dc.Scopes)
+			if err != nil {
+				http.Redirect(w, r, errorPath, http.StatusFound)
+				return
+			}
+			q := url.Values{}
+			q.Set(oauthUserCodeKey, formatUserCode(userCode))
+
+			loginQuery := url.Values{}
+			loginQuery.Set(FormKeyAppID, app.ID())
+			loginQuery.Set(scopesKey, string(scopesJSON))
+			loginQuery.Set(returnToKey, path.Join(ar.PathPrefix, "/device")+"?"+q.Encode())
+			http.Redirect(w, r, path.Join(ar.PathPrefix, "/login")+"?"+loginQuery.Encode(), http.StatusFound)
+			return
+		}
+
+		serveTemplate(map[string]interface{}{
+			"AppName":   app.Name(),
+			"Scopes":    dc.Scopes,
+			"UserCode":  formatUserCode(userCode),
+			"CSRFToken": csrfToken(tstr, deviceForm),
+		})
+	}
+}
+
+// Device approves or denies the device authorization request on behalf of the logged in user.
+func (ar *Router) Device() http.HandlerFunc {
+	tmpl, err := ar.staticFilesStorage.ParseTemplate(model.StaticPagesNames.Device)
+	if err != nil {
+		ar.Logger.Fatalln("Cannot parse Device template.", err)
+	}
+	tokenValidator := jwtValidator.NewValidator("identifo", ar.TokenService.Issuer(), "", jwtService.WebCookieTokenType)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveTemplate := func(data map[string]interface{}) {
+			data["Prefix"] = ar.PathPrefix
+			if err := tmpl.Execute(w, data); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+		}
+
+		userCode := normalizeUserCode(r.FormValue(oauthUserCodeKey))
+
+		user, webCookieToken, err := ar.webCookieUser(r, tokenValidator)
+		if err != nil || !validCSRFToken(r, deviceForm) {
+			// Let the verification page log the user in, and ask for approval again.
+			http.Redirect(w, r, path.Join(ar.PathPrefix, "/device")+"?"+url.Values{oauthUserCodeKey: []string{userCode}}.Encode(), http.StatusFound)
+			return
+		}
+
+		dc, err := ar.deviceCodeByUserCode(r, userCode)
+		if err != nil {
+			serveTemplate(map[string]interface{}{"Error": err.Error()})
+			return
+		}
+
+		if r.FormValue(deviceActionKey) != deviceActionApprove {
+			dc.Status = model.DeviceCodeStatusDenied
+			if err = ar.DeviceCodeStorage.SaveDeviceCode(dc); err != nil {
+				ar.Logger.Printf("Error saving device code: %v", err)
+				ar.Error(w, err, http.StatusInternalServerError, "")
+				return
+			}
+			serveTemplate(map[string]interface{}{"Result": "Access denied. You can close this page."})
+			return
+		}
+
+		app, err := ar.AppStorage.ActiveAppByID(dc.AppID)
+		if err != nil {
+			serveTemplate(map[string]interface{}{"Error": "The code is invalid or expired"})
+			return
+		}
+
+		scopes, _, err := ar.authorizeUser(r, user, app, dc.Scopes)
+		if err != nil {
+			serveTemplate(map[string]interface{}{
+				"AppName":   app.Name(),
+				"Scopes":    dc.Scopes,
+				"UserCode":  formatUserCode(userCode),
+				"CSRFToken": r.PostFormValue(csrfTokenKey),
+				"Error":     err.Error(),
+			})
+			return
+		}
+
+		dc.Status = model.DeviceCodeStatusApproved
+		dc.UserID = user.ID()
+		dc.Scopes = scopes
+		dc.AuthTime = webCookieToken.IssuedAt()
+		if err = ar.DeviceCodeStorage.SaveDeviceCode(dc); err != nil {
+			ar.Logger.Printf("Error saving device code: %v", err)
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		serveTemplate(map[string]interface{}{"Result": "Your device is connected. You can close this page and return to your device."})
+	}
+}
+
+// deviceCodeByUserCode returns pending device request for the user code.
+// Wrong codes are counted as failed login attempts from the source IP, so that codes cannot be guessed.
+func (ar *Router) deviceCodeByUserCode(r *http.Request, userCode string) (model.DeviceCode, error) {
+	ipKey := ar.ipLoginAttemptsKey(r)
+	if lockedFor := ar.loginLockout.LockedFor("", ipKey); lockedFor > 0 {
+		return model.DeviceCode{}, fmt.Errorf("Too many wrong codes. Please try again in %v", lockedFor)
+	}
+
+	dc, err := ar.DeviceCodeStorage.DeviceCodeByUserCode(userCode)
+	if err != nil || dc.Status != model.DeviceCodeStatusPending {
+		ar.loginLockout.Fail("", ipKey)
+		return model.DeviceCode{}, errors.New("The code is invalid or expired")
+	}
+	return dc, nil
+}
+
+// randomUserCode generates random user code, which is easy to type on another device.
+func randomUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeCharset)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeCharset[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// formatUserCode splits user code into two halves for readability, like BCDF-GHJK.
+func formatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode removes the characters users may type along with the code, see https://tools.ietf.org/html/rfc8628#section-6.1.
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
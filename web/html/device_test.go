@@ -0,0 +1,117 @@
+package html
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/storage/mem"
+)
+
+// approvingDeviceCodeStorage lets the user approve the device code right after the poll has read it.
+type approvingDeviceCodeStorage struct {
+	model.DeviceCodeStorage
+	approve func()
+}
+
+func (s *approvingDeviceCodeStorage) DeviceCodeByDeviceCode(deviceCode string) (model.DeviceCode, error) {
+	dc, err := s.DeviceCodeStorage.DeviceCodeByDeviceCode(deviceCode)
+	if s.approve != nil {
+		s.approve()
+	}
+	return dc, err
+}
+
+func TestDevicePollDoesNotUndoApproval(t *testing.T) {
+	as, err := mem.NewAppStorage()
+	if err != nil {
+		t.Fatalf("Unable to create app storage %v", err)
+	}
+	app := mem.MakeAppData("device-test-app", "", true, "testName", "testDescription", []string{}, true, []string{}, 0, 0, 0, []string{}, false, false, model.TFAStatusDisabled, "", model.NoAuthz, "", "", []string{}, []string{}, "user")
+	if _, err = as.CreateApp(&app); err != nil {
+		t.Fatalf("Unable to create app %v", err)
+	}
+	dcs, err := mem.NewDeviceCodeStorage()
+	if err != nil {
+		t.Fatalf("Unable to create device code storage %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		lastPolledAt int64
+		wantError    string
+		wantInterval int64
+	}{
+		{"poll in time", 0, oauthErrorAuthorizationPending, model.DeviceCodePollingInterval},
+		{"poll too often", time.Now().Unix(), oauthErrorSlowDown, model.DeviceCodePollingInterval + slowDownIncrement},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc := model.DeviceCode{
+				DeviceCode:   "device-code",
+				UserCode:     "BCDFGHJK",
+				AppID:        app.ID(),
+				Status:       model.DeviceCodeStatusPending,
+				Interval:     model.DeviceCodePollingInterval,
+				LastPolledAt: tt.lastPolledAt,
+				ExpiresAt:    time.Now().Add(model.DeviceCodeLifespan).Unix(),
+			}
+			if err := dcs.SaveDeviceCode(dc); err != nil {
+				t.Fatalf("Unable to save device code %v", err)
+			}
+
+			// The user approves the request on the verification page, while the device is polling.
+			storage := &approvingDeviceCodeStorage{DeviceCodeStorage: dcs}
+			storage.approve = func() {
+				var wg sync.WaitGroup
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					approved, err := dcs.DeviceCodeByUserCode(dc.UserCode)
+					if err != nil {
+						t.Errorf("Unable to get device code %v", err)
+						return
+					}
+					approved.Status = model.DeviceCodeStatusApproved
+					approved.UserID = "user"
+					if err = dcs.SaveDeviceCode(approved); err != nil {
+						t.Errorf("Unable to approve device code %v", err)
+					}
+				}()
+				wg.Wait()
+			}
+			ar := &Router{
+				Logger:            log.New(ioutil.Discard, "", 0),
+				AppStorage:        as,
+				DeviceCodeStorage: storage,
+			}
+
+			form := url.Values{oauthClientIDKey: []string{app.ID()}, oauthDeviceCodeKey: []string{dc.DeviceCode}}
+			r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			ar.exchangeDeviceCode(w, r)
+
+			if !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("Poll response = %s, want %s", w.Body.String(), tt.wantError)
+			}
+			got, err := dcs.DeviceCodeByDeviceCode(dc.DeviceCode)
+			if err != nil {
+				t.Fatalf("Unable to get device code %v", err)
+			}
+			if got.Status != model.DeviceCodeStatusApproved || got.UserID != "user" {
+				t.Errorf("Device code status = %v, user = %v after poll, want approval kept", got.Status, got.UserID)
+			}
+			if got.LastPolledAt == 0 || got.Interval != tt.wantInterval {
+				t.Errorf("Last polled at = %v, interval = %v, want poll recorded with interval %v", got.LastPolledAt, got.Interval, tt.wantInterval)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package html
+
+import (
+	"net/http"
+	"strings"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/shared"
+)
+
+// VerifyEmail consumes email verification token and marks user's email as verified.
+func (ar *Router) VerifyEmail() http.HandlerFunc {
+	tmpl, err := ar.staticFilesStorage.ParseTemplate(model.StaticPagesNames.EmailVerificationResult)
+	if err != nil {
+		ar.Logger.Fatalln("Cannot parse EmailVerificationResult template.", err)
+	}
+	tokenValidator := jwtValidator.NewValidator("identifo", ar.TokenService.Issuer(), "", jwtService.EmailVerificationTokenType)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveTemplate := func(errorMessage string) {
+			data := map[string]interface{}{
+				"Error":  errorMessage,
+				"Prefix": ar.PathPrefix,
+			}
+			if err := tmpl.Execute(w, data); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+		}
+		invalidLink := "Looks like your verification link is invalid or has expired."
+
+		token, err := ar.TokenService.Parse(strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			ar.Logger.Printf("Error invalid token: %v", err)
+			serveTemplate(invalidLink)
+			return
+		}
+		if err = tokenValidator.Validate(token); err != nil {
+			ar.Logger.Printf("Error invalid token: %v", err)
+			serveTemplate(invalidLink)
+			return
+		}
+		claims := tokenClaims(token)
+		if claims == nil {
+			serveTemplate(invalidLink)
+			return
+		}
+
+		user, err := ar.UserStorage.UserByID(token.UserID())
+		if err != nil {
+			ar.Logger.Printf("Error: getting UserByID: %v, userID: %v", err, token.UserID())
+			serveTemplate(invalidLink)
+			return
+		}
+
+		// The user has changed email after the link was sent.
+		if !strings.EqualFold(user.Email(), claims.Email) {
+			serveTemplate(invalidLink)
+			return
+		}
+
+		if !user.EmailVerified() {
+			user.SetEmailVerified(true)
+			if _, err = ar.UserStorage.UpdateUser(user.ID(), user); err != nil {
+				ar.Logger.Printf("Error: updating user %v: %v", user.ID(), err)
+				serveTemplate("Server Error")
+				return
+			}
+		}
+		serveTemplate("")
+	}
+}
+
+// sendEmailVerification sends the link to verify user's email address.
+func (ar *Router) sendEmailVerification(user model.User) error {
+	return shared.SendEmailVerification(ar.TokenService, ar.EmailService, user, ar.Host, ar.PathPrefix)
+}
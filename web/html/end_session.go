@@ -0,0 +1,143 @@
+package html
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+)
+
+// RP-initiated logout parameters, see https://openid.net/specs/openid-connect-rpinitiated-1_0.html.
+const (
+	oauthIDTokenHintKey           = "id_token_hint"
+	oauthPostLogoutRedirectURIKey = "post_logout_redirect_uri"
+
+	logoutActionKey    = "action"
+	logoutActionLogout = "logout"
+	// logoutForm is the name of the logout confirmation form, which its CSRF token is bound to.
+	logoutForm = "logout"
+)
+
+// EndSession logs the user out of the hosted web login on behalf of the app, and of all other apps sharing it.
+// Without id_token_hint of the logged in user, anyone could log the user out with a link,
+// so the user has to confirm logout on the page, which posts back here.
+// The user is sent back to post_logout_redirect_uri, which must be one of the app's redirect URLs.
+func (ar *Router) EndSession() http.HandlerFunc {
+	tmpl, err := ar.staticFilesStorage.ParseTemplate(model.StaticPagesNames.Logout)
+	if err != nil {
+		ar.Logger.Fatalln("Cannot parse Logout template.", err)
+	}
+	errorPath := path.Join(ar.PathPrefix, "/misconfiguration")
+	tokenValidator := jwtValidator.NewValidator("identifo", ar.TokenService.Issuer(), "", jwtService.WebCookieTokenType)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveTemplate := func(data map[string]interface{}) {
+			data["Prefix"] = ar.PathPrefix
+			w.Header().Set("Cache-Control", "no-store")
+			if err := tmpl.Execute(w, data); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+		}
+
+		clientID := strings.TrimSpace(r.FormValue(oauthClientIDKey))
+		redirectURI := strings.TrimSpace(r.FormValue(oauthPostLogoutRedirectURIKey))
+		state := r.FormValue(oauthStateKey)
+
+		var hint *ijwt.Claims
+		if hintString := strings.TrimSpace(r.FormValue(oauthIDTokenHintKey)); hintString != "" {
+			var err error
+			if hint, err = ar.idTokenHintClaims(hintString); err != nil {
+				ar.Logger.Printf("Invalid ID token hint: %v", err)
+				http.Redirect(w, r, errorPath, http.StatusFound)
+				return
+			}
+			if clientID == "" {
+				clientID = hint.Audience
+			}
+			if hint.Audience != clientID {
+				ar.Logger.Printf("ID token hint is issued to app %v, not %v", hint.Audience, clientID)
+				http.Redirect(w, r, errorPath, http.StatusFound)
+				return
+			}
+		}
+
+		var app model.AppData
+		if clientID != "" {
+			var err error
+			if app, err = ar.AppStorage.ActiveAppByID(clientID); err != nil {
+				ar.Logger.Printf("Error getting app %v: %v", clientID, err)
+				http.Redirect(w, r, errorPath, http.StatusFound)
+				return
+			}
+		}
+		if redirectURI != "" && (app == nil || !contains(app.RedirectURLs(), redirectURI)) {
+			ar.Logger.Printf("Unauthorized post logout redirect url %v for app %v", redirectURI, clientID)
+			http.Redirect(w, r, errorPath, http.StatusFound)
+			return
+		}
+
+		// The session of another user is not ended by the app that does not know about it.
+		result := "You have been logged out."
+		user, _, err := ar.webCookieUser(r, tokenValidator)
+		switch {
+		case err != nil:
+			deleteCookie(w, CookieKeyWebCookieToken)
+		case hint != nil && hint.Subject != user.ID():
+			ar.Logger.Printf("ID token hint of user %v does not match the web session of user %v", hint.Subject, user.ID())
+		case hint == nil && (r.Method != http.MethodPost || !validCSRFToken(r, logoutForm)):
+			tstr, _ := getCookie(r, CookieKeyWebCookieToken)
+			data := map[string]interface{}{
+				"ClientID":    clientID,
+				"RedirectURI": redirectURI,
+				"State":       state,
+				"CSRFToken":   csrfToken(tstr, logoutForm),
+			}
+			if app != nil {
+				data["AppName"] = app.Name()
+			}
+			serveTemplate(data)
+			return
+		case hint == nil && r.PostFormValue(logoutActionKey) != logoutActionLogout:
+			result = "You are still logged in. You can close this page."
+		default:
+			ar.endWebSession(w, r, user)
+			ar.Logger.Printf("Web session of user %v ended by app %v", user.ID(), clientID)
+		}
+
+		if redirectURI == "" {
+			serveTemplate(map[string]interface{}{"Result": result})
+			return
+		}
+
+		params := url.Values{}
+		if state != "" {
+			params.Set(oauthStateKey, state)
+		}
+		http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+	}
+}
+
+// idTokenHintClaims verifies the ID token issued by us earlier. Expired ones are still good as a hint.
+func (ar *Router) idTokenHintClaims(hint string) (*ijwt.Claims, error) {
+	claims := &ijwt.Claims{}
+	_, err := jwt.ParseWithClaims(hint, claims, func(*jwt.Token) (interface{}, error) {
+		return ar.TokenService.PublicKey(), nil
+	})
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != ar.TokenService.Issuer() || claims.Type != jwtService.IDTokenType {
+		return nil, errors.New("Not an ID token issued by us")
+	}
+	return claims, nil
+}
@@ -9,4 +9,6 @@ func (e Error) Error() string { return string(e) }
 const (
 	// ErrorRegistrationForbidden means that registration is forbidden.
 	ErrorRegistrationForbidden = Error("Registration in this app is forbidden.")
+	// ErrorInvalidClientCredentials means that client app ID or secret is invalid.
+	ErrorInvalidClientCredentials = Error("Invalid client credentials.")
 )
@@ -0,0 +1,118 @@
+package html
+
+import (
+	"net/http"
+	"strings"
+
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+)
+
+// introspectionResponse is a token introspection response, see https://tools.ietf.org/html/rfc7662#section-2.2.
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	// Act is the chain of parties acting on behalf of the subject, for impersonation and exchanged tokens.
+	Act *ijwt.Actor `json:"act,omitempty"`
+}
+
+// Introspect tells resource servers whether the token is active and what it was issued for.
+// The caller must authenticate with its app credentials. Any token that cannot be used anymore,
+// including tokens of disabled users, is reported as inactive without further details.
+func (ar *Router) Introspect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Cannot parse request body")
+			return
+		}
+
+		_, authenticated, err := ar.oauthClient(r)
+		if err != nil {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+			return
+		}
+		if !authenticated {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "Client authentication is required")
+			return
+		}
+
+		tokenString := strings.TrimSpace(r.PostFormValue(oauthTokenKey))
+		if len(tokenString) == 0 {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Token is required")
+			return
+		}
+
+		ar.serveOAuthJSON(w, http.StatusOK, ar.introspect(tokenString))
+	}
+}
+
+// introspect checks the token and returns introspection response for it.
+func (ar *Router) introspect(tokenString string) introspectionResponse {
+	inactive := introspectionResponse{Active: false}
+
+	// Parse validates the signature and the expiration time.
+	token, err := ar.TokenService.Parse(tokenString)
+	if err != nil {
+		return inactive
+	}
+	claims := tokenClaims(token)
+	if claims == nil || claims.Issuer != ar.TokenService.Issuer() {
+		return inactive
+	}
+
+	if ar.TokenBlacklist.IsBlacklisted(tokenString) {
+		return inactive
+	}
+	if model.TokenRevokedForUser(ar.TokenStorage, claims.Subject, claims.IssuedAt) {
+		return inactive
+	}
+
+	switch claims.Type {
+	case jwtService.AccessTokenType:
+		if payload := token.Payload(); payload != nil && payload[jwtService.PayloadTFAuthorized] == "false" {
+			return inactive
+		}
+	case jwtService.RefrestTokenType:
+		// Revoked token families are removed from the storage.
+		if !ar.TokenStorage.HasToken(tokenString) {
+			return inactive
+		}
+	default:
+		// Other tokens are not used to access resources.
+		return inactive
+	}
+
+	app, err := ar.AppStorage.ActiveAppByID(claims.Audience)
+	if err != nil {
+		return inactive
+	}
+
+	// Service apps get tokens on their own behalf, there is no user behind them.
+	if !(app.Type() == model.Service && claims.Subject == app.ID()) {
+		user, err := ar.UserStorage.UserByID(claims.Subject)
+		if err != nil || !user.Active() {
+			return inactive
+		}
+	}
+
+	return introspectionResponse{
+		Active:    true,
+		Scope:     claims.Scopes,
+		ClientID:  app.ID(),
+		TokenType: claims.Type,
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Act:       claims.Actor,
+	}
+}
@@ -2,7 +2,9 @@ package html
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
 
@@ -20,6 +22,7 @@ const (
 	isAnonymousKey = "anonymous"
 	callbackURLKey = "callbackUrl"
 	redirectURIKey = "redirectUri"
+	returnToKey    = "returnTo"
 )
 
 // Login logs user in with email and password.
@@ -31,6 +34,7 @@ func (ar *Router) Login() http.HandlerFunc {
 		password := r.FormValue(passwordKey)
 		scopesJSON := r.FormValue(scopesKey)
 		callbackURL := r.FormValue(callbackURLKey)
+		returnTo := r.FormValue(returnToKey)
 		scopes := []string{}
 		app := middleware.AppFromContext(r.Context())
 
@@ -39,6 +43,9 @@ func (ar *Router) Login() http.HandlerFunc {
 			q.Set(FormKeyAppID, app.ID())
 			q.Set(scopesKey, scopesJSON)
 			q.Set(callbackURLKey, callbackURL)
+			if returnTo != "" {
+				q.Set(returnToKey, returnTo)
+			}
 			r.URL.RawQuery = q.Encode()
 
 			http.Redirect(w, r, path.Join(ar.PathPrefix, r.URL.String()), http.StatusFound)
@@ -50,8 +57,17 @@ func (ar *Router) Login() http.HandlerFunc {
 			return
 		}
 
+		userID, _ := ar.UserStorage.IDByName(username)
+		userKey, ipKey := ar.loginAttemptsKeys(r, userID, username)
+		if lockedFor := ar.loginLockout.LockedFor(userKey, ipKey); lockedFor > 0 {
+			SetFlash(w, FlashErrorMessageKey, fmt.Sprintf("Too many failed login attempts. Please try again in %v", lockedFor))
+			redirectToLogin()
+			return
+		}
+
 		user, err := ar.UserStorage.UserByNamePassword(username, password)
 		if err != nil {
+			ar.failLogin(userKey, ipKey, userID)
 			SetFlash(w, FlashErrorMessageKey, "Invalid Username or Password")
 			redirectToLogin()
 			return
@@ -78,6 +94,12 @@ func (ar *Router) Login() http.HandlerFunc {
 			return
 		}
 
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			SetFlash(w, FlashErrorMessageKey, "Please verify your email address to be able to log in")
+			redirectToLogin()
+			return
+		}
+
 		token, err := ar.TokenService.NewWebCookieToken(user)
 		if err != nil {
 			ar.Logger.Printf("Error creating auth token %v", err)
@@ -92,8 +114,15 @@ func (ar *Router) Login() http.HandlerFunc {
 			return
 		}
 
+		ar.loginLockout.Succeed(userKey)
 		ar.UserStorage.UpdateLoginMetadata(user.ID())
 		setCookie(w, CookieKeyWebCookieToken, tokenString, int(ar.TokenService.WebCookieTokenLifespan()))
+
+		// Get back to the page which has asked user to log in, e.g. OAuth authorization endpoint.
+		if ar.isValidReturnTo(returnTo) {
+			http.Redirect(w, r, returnTo, http.StatusFound)
+			return
+		}
 		redirectToLogin()
 	}
 }
@@ -123,31 +152,42 @@ func (ar *Router) LoginHandler() http.HandlerFunc {
 			return
 		}
 
+		returnTo := strings.TrimSpace(r.URL.Query().Get(returnToKey))
+		if !ar.isValidReturnTo(returnTo) {
+			returnTo = ""
+		}
+
+		// Users who are sent back to one of our pages never reach the callback URL,
+		// so apps without redirect URLs, like TVs and CLIs, can use such pages too.
 		callbackURL := strings.TrimSpace(r.URL.Query().Get(callbackURLKey))
-		if !contains(app.RedirectURLs(), callbackURL) {
+		if returnTo == "" && !contains(app.RedirectURLs(), callbackURL) {
 			ar.Logger.Printf("Unauthorized redirect url %v for app %v", callbackURL, app.ID())
 			http.Redirect(w, r, errorPath, http.StatusFound)
 			return
 		}
 
-		serveTemplate := func() {
-			errorMessage, err := GetFlash(w, r, FlashErrorMessageKey)
-			if err != nil {
-				ar.Error(w, err, http.StatusInternalServerError, "")
-				return
-			}
-
+		serveTemplateWithError := func(errorMessage string) {
 			data := map[string]interface{}{
 				"Error":       errorMessage,
 				"Prefix":      ar.PathPrefix,
 				"Scopes":      scopesJSON,
 				"CallbackURL": callbackURL,
+				"ReturnTo":    returnTo,
 				"AppId":       app.ID(),
+				"Webauthn":    ar.SupportedLoginWays.Webauthn,
 			}
 
-			if err = tmpl.Execute(w, data); err != nil {
+			if err := tmpl.Execute(w, data); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+		}
+		serveTemplate := func() {
+			errorMessage, err := GetFlash(w, r, FlashErrorMessageKey)
+			if err != nil {
 				ar.Error(w, err, http.StatusInternalServerError, "")
+				return
 			}
+			serveTemplateWithError(errorMessage)
 		}
 
 		tstr, err := getCookie(r, CookieKeyWebCookieToken)
@@ -172,6 +212,12 @@ func (ar *Router) LoginHandler() http.HandlerFunc {
 			serveTemplate()
 			return
 		}
+		if ar.webCookieTokenRevoked(tstr, webCookieToken) {
+			ar.Logger.Printf("Error revoked token of user %s", webCookieToken.UserID())
+			deleteCookie(w, CookieKeyWebCookieToken)
+			serveTemplate()
+			return
+		}
 
 		userID := webCookieToken.UserID()
 		user, err := ar.UserStorage.UserByID(userID)
@@ -181,6 +227,11 @@ func (ar *Router) LoginHandler() http.HandlerFunc {
 			return
 		}
 
+		if returnTo != "" {
+			http.Redirect(w, r, returnTo, http.StatusFound)
+			return
+		}
+
 		scopes, err = ar.UserStorage.RequestScopes(userID, scopes)
 		if err != nil {
 			ar.Logger.Printf("Error: invalid scopes %v for userID: %v", scopes, userID)
@@ -188,6 +239,18 @@ func (ar *Router) LoginHandler() http.HandlerFunc {
 			return
 		}
 
+		// The user may have logged in through another app, which does not require verified email.
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			serveTemplateWithError("Please verify your email address to be able to log in")
+			return
+		}
+
+		if err = ar.joinWebSession(w, r, webCookieToken, app); err != nil {
+			ar.Logger.Printf("Error joining app %s to web session: %v", app.ID(), err)
+			serveTemplate()
+			return
+		}
+
 		// TODO: Add TFA support.
 		token, err := ar.TokenService.NewAccessToken(user, scopes, app, false)
 		if err != nil {
@@ -207,3 +270,15 @@ func (ar *Router) LoginHandler() http.HandlerFunc {
 		http.Redirect(w, r, redirectURL, http.StatusFound)
 	}
 }
+
+// isValidReturnTo checks that the user is going to be sent back to one of our own pages.
+func (ar *Router) isValidReturnTo(returnTo string) bool {
+	if returnTo == "" || strings.HasPrefix(returnTo, "//") {
+		return false
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return false
+	}
+	return strings.HasPrefix(u.Path, ar.PathPrefix+"/")
+}
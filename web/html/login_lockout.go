@@ -0,0 +1,35 @@
+package html
+
+import (
+	"net/http"
+
+	"github.com/madappgang/identifo/model"
+)
+
+// loginAttemptsKeys returns keys to count failed login attempts of the user, and from the source IP.
+// Names nobody has are counted too, so that lockouts do not tell which users exist.
+func (ar *Router) loginAttemptsKeys(r *http.Request, userID, name string) (userKey, ipKey string) {
+	ipKey = ar.ipLoginAttemptsKey(r)
+	if userID != "" {
+		return model.UserLoginAttemptsKey(userID), ipKey
+	}
+	return model.NameLoginAttemptsKey(name), ipKey
+}
+
+// ipLoginAttemptsKey returns the key to count failed attempts from the source IP.
+func (ar *Router) ipLoginAttemptsKey(r *http.Request) string {
+	return model.IPLoginAttemptsKey(ar.loginLockout.ClientIP(r))
+}
+
+// failLogin counts failed login attempt and notifies the user if they have just been locked out.
+func (ar *Router) failLogin(userKey, ipKey, userID string) {
+	if !ar.loginLockout.Fail(userKey, ipKey) || userID == "" {
+		return
+	}
+	user, err := ar.UserStorage.UserByID(userID)
+	if err != nil {
+		ar.Logger.Println("Cannot get locked out user:", err)
+		return
+	}
+	ar.loginLockout.NotifyLockedOut(user, userKey)
+}
@@ -6,15 +6,19 @@ import (
 	"path"
 	"strings"
 
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
 	"github.com/madappgang/identifo/web/middleware"
 )
 
-// Logout removes user's session.
+// Logout removes user's session, the apps sharing it are notified through back-channel logout.
 func (ar *Router) Logout() http.HandlerFunc {
 	errorPath := path.Join(ar.PathPrefix, "/misconfiguration")
+	tokenValidator := jwtValidator.NewValidator("identifo", ar.TokenService.Issuer(), "", jwtService.WebCookieTokenType)
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		deleteCookie(w, CookieKeyWebCookieToken)
+		user, _, _ := ar.webCookieUser(r, tokenValidator)
+		ar.endWebSession(w, r, user)
 
 		app := middleware.AppFromContext(r.Context())
 		if app == nil {
@@ -0,0 +1,223 @@
+package html
+
+import (
+	"net/http"
+	"strings"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+	"github.com/madappgang/identifo/web/shared"
+)
+
+const (
+	magicLinkModeKey = "mode"
+	magicLinkModeWeb = "web"
+)
+
+// MagicLink handles the link sent by email.
+// GET only serves the landing page, so mail scanners and link previews cannot use the link up.
+// The landing page posts the link back and logs the user in the hosted web login.
+// Apps which handle the link themselves post it and receive the access and refresh tokens.
+// The link can be used only once.
+func (ar *Router) MagicLink() http.HandlerFunc {
+	tmpl, err := ar.staticFilesStorage.ParseTemplate(model.StaticPagesNames.MagicLink)
+	if err != nil {
+		ar.Logger.Fatalln("Cannot parse MagicLink template.", err)
+	}
+
+	type authResponse struct {
+		AccessToken    string     `json:"access_token,omitempty"`
+		RefreshToken   string     `json:"refresh_token,omitempty"`
+		User           model.User `json:"user,omitempty"`
+		NeedFurtherTFA bool       `json:"need_further_tfa,omitempty"`
+	}
+	invalidLink := "Magic link is invalid or has expired"
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// People following the link get pages, apps get JSON.
+		webSession := r.Method == http.MethodGet || r.PostFormValue(magicLinkModeKey) == magicLinkModeWeb
+
+		serveTemplate := func(data map[string]interface{}) {
+			data["Prefix"] = ar.PathPrefix
+			if err := tmpl.Execute(w, data); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+			}
+		}
+		fail := func(status int, code, description string) {
+			if webSession {
+				w.WriteHeader(status)
+				serveTemplate(map[string]interface{}{"Error": description})
+				return
+			}
+			ar.oauthError(w, status, code, description)
+		}
+
+		if !ar.SupportedLoginWays.MagicLink {
+			fail(http.StatusBadRequest, "unsupported_grant_type", "Login with magic link is not supported")
+			return
+		}
+
+		tokenString := strings.TrimSpace(r.FormValue("token"))
+		if tokenString == "" {
+			fail(http.StatusBadRequest, "invalid_request", "Missing token")
+			return
+		}
+		if ar.TokenBlacklist.IsBlacklisted(tokenString) {
+			fail(http.StatusBadRequest, "invalid_grant", "Magic link has already been used")
+			return
+		}
+
+		token, err := ar.TokenService.Parse(tokenString)
+		if err != nil {
+			fail(http.StatusBadRequest, "invalid_grant", invalidLink)
+			return
+		}
+		claims := tokenClaims(token)
+		if claims == nil {
+			fail(http.StatusBadRequest, "invalid_grant", invalidLink)
+			return
+		}
+
+		app, err := ar.AppStorage.ActiveAppByID(claims.Audience)
+		if err != nil {
+			fail(http.StatusBadRequest, "invalid_grant", invalidLink)
+			return
+		}
+		tokenValidator := jwtValidator.NewValidator(app.ID(), ar.TokenService.Issuer(), "", jwtService.MagicLinkTokenType)
+		if err = tokenValidator.Validate(token); err != nil {
+			fail(http.StatusBadRequest, "invalid_grant", invalidLink)
+			return
+		}
+
+		user, err := ar.UserStorage.UserByID(token.UserID())
+		if err != nil {
+			fail(http.StatusBadRequest, "invalid_grant", invalidLink)
+			return
+		}
+		// The user has changed email after the link was sent.
+		if !strings.EqualFold(user.Email(), claims.Email) {
+			fail(http.StatusBadRequest, "invalid_grant", invalidLink)
+			return
+		}
+
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    user.AccessRole(),
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err = ar.Authorizer.Authorize(azi); err != nil {
+			fail(http.StatusForbidden, "access_denied", err.Error())
+			return
+		}
+
+		require2FA, err := shared.CheckTFA(app.TFAStatus(), user.TFAInfo(), false)
+		if err != nil {
+			fail(http.StatusBadRequest, "access_denied", err.Error())
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			serveTemplate(map[string]interface{}{
+				"AppName": app.Name(),
+				"Email":   user.Email(),
+				"Token":   tokenString,
+			})
+			return
+		}
+
+		// Hosted web login cannot ask for the second factor, the app has to handle such links.
+		if webSession && require2FA {
+			fail(http.StatusForbidden, "access_denied", "Please open the link in "+app.Name()+" to finish two-factor authentication")
+			return
+		}
+
+		// Make the link single-use before anything is issued.
+		if err = ar.TokenBlacklist.Add(tokenString); err != nil {
+			ar.Logger.Printf("Error blacklisting magic link token: %v", err)
+			fail(http.StatusInternalServerError, "server_error", "Unable to use magic link")
+			return
+		}
+
+		// Following the link proves that the user owns the email.
+		if !user.EmailVerified() {
+			user.SetEmailVerified(true)
+			if user, err = ar.UserStorage.UpdateUser(user.ID(), user); err != nil {
+				ar.Logger.Printf("Error: updating user %v: %v", token.UserID(), err)
+				fail(http.StatusInternalServerError, "server_error", "Unable to update user")
+				return
+			}
+		}
+
+		if webSession {
+			if err = ar.startMagicLinkWebSession(w, user); err != nil {
+				ar.Logger.Printf("Error starting web session for user %v: %v", user.ID(), err)
+				fail(http.StatusInternalServerError, "server_error", "Unable to log in")
+				return
+			}
+			serveTemplate(map[string]interface{}{
+				"Result": "You are signed in. You can close this page and return to " + app.Name() + ".",
+			})
+			return
+		}
+
+		scopes := strings.Fields(claims.Scopes)
+		accessToken, err := ar.TokenService.NewAccessToken(user, scopes, app, require2FA)
+		if err != nil {
+			fail(http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		accessTokenString, err := ar.TokenService.String(accessToken)
+		if err != nil {
+			fail(http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		refreshTokenString := ""
+		if contains(scopes, jwtService.OfflineScope) && app.Offline() && !require2FA {
+			refreshToken, err := ar.TokenService.NewRefreshToken(user, scopes, app, model.ClientInfoFromRequest(r))
+			if err != nil {
+				fail(http.StatusInternalServerError, "server_error", err.Error())
+				return
+			}
+			if refreshTokenString, err = ar.TokenService.String(refreshToken); err != nil {
+				fail(http.StatusInternalServerError, "server_error", err.Error())
+				return
+			}
+		}
+
+		if require2FA {
+			if err = shared.SendTFACode(ar.tfaType, user, ar.SMSService, ar.EmailService); err != nil {
+				ar.Logger.Printf("Error sending one-time password to user %v: %v", user.ID(), err)
+			}
+		} else {
+			ar.UserStorage.UpdateLoginMetadata(user.ID())
+		}
+
+		user.Sanitize()
+		ar.serveOAuthJSON(w, http.StatusOK, authResponse{
+			AccessToken:    accessTokenString,
+			RefreshToken:   refreshTokenString,
+			User:           user,
+			NeedFurtherTFA: require2FA,
+		})
+	}
+}
+
+// startMagicLinkWebSession logs the user who has followed the link in the hosted web login.
+func (ar *Router) startMagicLinkWebSession(w http.ResponseWriter, user model.User) error {
+	token, err := ar.TokenService.NewWebCookieToken(user)
+	if err != nil {
+		return err
+	}
+	tokenString, err := ar.TokenService.String(token)
+	if err != nil {
+		return err
+	}
+
+	ar.UserStorage.UpdateLoginMetadata(user.ID())
+	setCookie(w, CookieKeyWebCookieToken, tokenString, int(ar.TokenService.WebCookieTokenLifespan()))
+	return nil
+}
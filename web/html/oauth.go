@@ -0,0 +1,580 @@
+package html
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+)
+
+// OAuth 2.0 request parameters, see https://tools.ietf.org/html/rfc6749.
+const (
+	oauthClientIDKey            = "client_id"
+	oauthClientSecretKey        = "client_secret"
+	oauthRedirectURIKey         = "redirect_uri"
+	oauthResponseTypeKey        = "response_type"
+	oauthScopeKey               = "scope"
+	oauthStateKey               = "state"
+	oauthCodeKey                = "code"
+	oauthGrantTypeKey           = "grant_type"
+	oauthRefreshTokenKey        = "refresh_token"
+	oauthCodeChallengeKey       = "code_challenge"
+	oauthCodeChallengeMethodKey = "code_challenge_method"
+	oauthCodeVerifierKey        = "code_verifier"
+	oauthNonceKey               = "nonce"
+	oauthTokenKey               = "token"
+
+	oauthResponseTypeCode = "code"
+
+	oauthGrantTypeAuthorizationCode = "authorization_code"
+	oauthGrantTypeRefreshToken      = "refresh_token"
+	oauthGrantTypeClientCredentials = "client_credentials"
+
+	pkceMethodS256  = "S256"
+	pkceMethodPlain = "plain"
+)
+
+// OAuth 2.0 error codes, see https://tools.ietf.org/html/rfc6749#section-4.1.2.1 and https://tools.ietf.org/html/rfc6749#section-5.2.
+const (
+	oauthErrorInvalidRequest          = "invalid_request"
+	oauthErrorInvalidClient           = "invalid_client"
+	oauthErrorInvalidGrant            = "invalid_grant"
+	oauthErrorUnauthorizedClient      = "unauthorized_client"
+	oauthErrorUnsupportedGrantType    = "unsupported_grant_type"
+	oauthErrorUnsupportedResponseType = "unsupported_response_type"
+	oauthErrorInvalidScope            = "invalid_scope"
+	oauthErrorAccessDenied            = "access_denied"
+	oauthErrorServerError             = "server_error"
+)
+
+// oauthTokenResponse is a successful access token response, see https://tools.ietf.org/html/rfc6749#section-5.1.
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// IssuedTokenType is the type of token issued in token exchange, see https://tools.ietf.org/html/rfc8693#section-2.2.1.
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// Authorize handles OAuth 2.0 authorization requests (authorization code grant).
+// Users who are not logged in are sent to the login page and get back here once they are authenticated.
+func (ar *Router) Authorize() http.HandlerFunc {
+	errorPath := path.Join(ar.PathPrefix, "/misconfiguration")
+	tokenValidator := jwtValidator.NewValidator("identifo", ar.TokenService.Issuer(), "", jwtService.WebCookieTokenType)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		redirectURI := strings.TrimSpace(q.Get(oauthRedirectURIKey))
+		state := q.Get(oauthStateKey)
+
+		app, err := ar.AppStorage.ActiveAppByID(strings.TrimSpace(q.Get(oauthClientIDKey)))
+		if err != nil {
+			ar.Logger.Printf("Error: getting app by id. %s", err)
+			http.Redirect(w, r, errorPath, http.StatusFound)
+			return
+		}
+
+		// We must not redirect the user agent to the untrusted URI, even with an error.
+		if !contains(app.RedirectURLs(), redirectURI) {
+			ar.Logger.Printf("Unauthorized redirect url %v for app %v", redirectURI, app.ID())
+			http.Redirect(w, r, errorPath, http.StatusFound)
+			return
+		}
+
+		redirectWithError := func(code, description string) {
+			params := url.Values{}
+			params.Set("error", code)
+			params.Set("error_description", description)
+			if state != "" {
+				params.Set(oauthStateKey, state)
+			}
+			http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+		}
+
+		if q.Get(oauthResponseTypeKey) != oauthResponseTypeCode {
+			redirectWithError(oauthErrorUnsupportedResponseType, "Only code response type is supported")
+			return
+		}
+
+		codeChallenge := q.Get(oauthCodeChallengeKey)
+		codeChallengeMethod := q.Get(oauthCodeChallengeMethodKey)
+		if codeChallenge == "" && pkceRequired(app) {
+			redirectWithError(oauthErrorInvalidRequest, "PKCE code challenge is required for this app")
+			return
+		}
+		if codeChallenge != "" && codeChallengeMethod == "" {
+			codeChallengeMethod = pkceMethodPlain
+		}
+		if codeChallenge != "" && codeChallengeMethod != pkceMethodS256 && codeChallengeMethod != pkceMethodPlain {
+			redirectWithError(oauthErrorInvalidRequest, "Unsupported code challenge method")
+			return
+		}
+		// Plain challenge is the verifier itself, so it does not protect public clients from intercepted requests.
+		if codeChallengeMethod == pkceMethodPlain && pkceRequired(app) {
+			redirectWithError(oauthErrorInvalidRequest, "S256 code challenge method is required for this app")
+			return
+		}
+
+		scopes := strings.Fields(q.Get(oauthScopeKey))
+
+		user, webCookieToken, err := ar.webCookieUser(r, tokenValidator)
+		if err != nil {
+			// The user has to log in first, then the login page sends them back here.
+			scopesJSON, err := json.Marshal(scopes)
+			if err != nil {
+				redirectWithError(oauthErrorServerError, "Cannot encode scopes")
+				return
+			}
+			loginQuery := url.Values{}
+			loginQuery.Set(FormKeyAppID, app.ID())
+			loginQuery.Set(scopesKey, string(scopesJSON))
+			loginQuery.Set(callbackURLKey, redirectURI)
+			loginQuery.Set(returnToKey, path.Join(ar.PathPrefix, r.URL.Path)+"?"+r.URL.RawQuery)
+			http.Redirect(w, r, path.Join(ar.PathPrefix, "/login")+"?"+loginQuery.Encode(), http.StatusFound)
+			return
+		}
+
+		scopes, errorCode, err := ar.authorizeUser(r, user, app, scopes)
+		if err != nil {
+			redirectWithError(errorCode, err.Error())
+			return
+		}
+
+		if err = ar.joinWebSession(w, r, webCookieToken, app); err != nil {
+			ar.Logger.Printf("Error joining app %s to web session: %v", app.ID(), err)
+			redirectWithError(oauthErrorServerError, "Cannot update web session")
+			return
+		}
+
+		code, err := randomOAuthCode()
+		if err != nil {
+			ar.Logger.Printf("Error generating authorization code: %v", err)
+			redirectWithError(oauthErrorServerError, "Cannot create authorization code")
+			return
+		}
+
+		ac := model.AuthorizationCode{
+			Code:                code,
+			AppID:               app.ID(),
+			UserID:              user.ID(),
+			RedirectURI:         redirectURI,
+			Scopes:              scopes,
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: codeChallengeMethod,
+			Nonce:               q.Get(oauthNonceKey),
+			AuthTime:            webCookieToken.IssuedAt(),
+			ExpiresAt:           time.Now().Add(model.AuthorizationCodeLifespan).Unix(),
+		}
+		if err = ar.AuthorizationCodeStorage.SaveAuthorizationCode(ac); err != nil {
+			ar.Logger.Printf("Error saving authorization code: %v", err)
+			redirectWithError(oauthErrorServerError, "Cannot save authorization code")
+			return
+		}
+
+		params := url.Values{}
+		params.Set(oauthCodeKey, code)
+		if state != "" {
+			params.Set(oauthStateKey, state)
+		}
+		http.Redirect(w, r, appendQuery(redirectURI, params), http.StatusFound)
+	}
+}
+
+// authorizeUser checks that the user is allowed to grant the app access to the requested scopes.
+// It returns granted scopes, or OAuth 2.0 error code with the error.
+func (ar *Router) authorizeUser(r *http.Request, user model.User, app model.AppData, scopes []string) ([]string, string, error) {
+	scopes, err := ar.UserStorage.RequestScopes(user.ID(), scopes)
+	if err != nil {
+		ar.Logger.Printf("Error: invalid scopes %v for userID: %v", scopes, user.ID())
+		return nil, oauthErrorInvalidScope, errors.New("User is not allowed to access requested scopes")
+	}
+
+	// Authorize user if the app requires authorization.
+	azi := authorization.AuthzInfo{
+		App:         app,
+		UserRole:    user.AccessRole(),
+		ResourceURI: r.RequestURI,
+		Method:      r.Method,
+	}
+	if err = ar.Authorizer.Authorize(azi); err != nil {
+		return nil, oauthErrorAccessDenied, err
+	}
+
+	if app.TFAStatus() == model.TFAStatusMandatory && !user.TFAInfo().IsEnabled {
+		return nil, oauthErrorAccessDenied, errors.New("Please enable two-factor authentication to be able to use this app")
+	}
+	if app.EmailVerificationRequired() && !user.EmailVerified() {
+		return nil, oauthErrorAccessDenied, errors.New("Please verify your email address to be able to log in")
+	}
+	return scopes, "", nil
+}
+
+// Token is an OAuth 2.0 token endpoint.
+func (ar *Router) Token() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Cannot parse request body")
+			return
+		}
+
+		switch grantType := r.PostFormValue(oauthGrantTypeKey); grantType {
+		case oauthGrantTypeAuthorizationCode:
+			ar.exchangeAuthorizationCode(w, r)
+		case oauthGrantTypeRefreshToken:
+			ar.exchangeRefreshToken(w, r)
+		case oauthGrantTypeClientCredentials:
+			ar.exchangeClientCredentials(w, r)
+		case oauthGrantTypeDeviceCode:
+			ar.exchangeDeviceCode(w, r)
+		case oauthGrantTypeTokenExchange:
+			ar.exchangeToken(w, r)
+		default:
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorUnsupportedGrantType, "Unsupported grant type "+grantType)
+		}
+	}
+}
+
+// exchangeAuthorizationCode exchanges authorization code for tokens.
+func (ar *Router) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request) {
+	app, authenticated, err := ar.oauthClient(r)
+	if err != nil {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+		return
+	}
+
+	ac, err := ar.AuthorizationCodeStorage.ConsumeAuthorizationCode(r.PostFormValue(oauthCodeKey))
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Authorization code is invalid or expired")
+		return
+	}
+	if ac.AppID != app.ID() || ac.RedirectURI != r.PostFormValue(oauthRedirectURIKey) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Authorization code was issued to another client or redirect URI")
+		return
+	}
+
+	// Public clients prove they are the ones who have started the flow with PKCE,
+	// confidential clients may use their secret instead.
+	if ac.CodeChallenge != "" {
+		if !verifyCodeChallenge(ac.CodeChallenge, ac.CodeChallengeMethod, r.PostFormValue(oauthCodeVerifierKey)) {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Code verifier does not match code challenge")
+			return
+		}
+	} else if !authenticated {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "Client authentication is required")
+		return
+	}
+
+	user, err := ar.UserStorage.UserByID(ac.UserID)
+	if err != nil || !user.Active() {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "User is not eligible to obtain the token")
+		return
+	}
+
+	ar.serveUserTokens(w, r, user, app, ac.Scopes, ac.Nonce, ac.AuthTime)
+}
+
+// serveUserTokens issues tokens for the user who has authorized the app and writes the token response.
+// ID token is issued for openid scope, refresh token is issued for offline scope.
+func (ar *Router) serveUserTokens(w http.ResponseWriter, r *http.Request, user model.User, app model.AppData, scopes []string, nonce string, authTime int64) {
+	// As with API login, users with enabled TFA get a token that has to be authorized with the one-time password.
+	requireTFA := user.TFAInfo().IsEnabled && app.TFAStatus() != model.TFAStatusDisabled
+
+	accessToken, err := ar.TokenService.NewAccessToken(user, scopes, app, requireTFA)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	accessTokenString, err := ar.TokenService.String(accessToken)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	resp := oauthTokenResponse{
+		AccessToken: accessTokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   accessTokenLifespan(app),
+		Scope:       strings.Join(scopes, " "),
+	}
+
+	if contains(scopes, jwtService.OpenIDScope) {
+		idToken, err := ar.TokenService.NewIDToken(user, scopes, app, nonce, authTime, accessTokenString)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+		if resp.IDToken, err = ar.TokenService.String(idToken); err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+	}
+
+	if contains(scopes, jwtService.OfflineScope) && app.Offline() && !requireTFA {
+		refreshToken, err := ar.TokenService.NewRefreshToken(user, scopes, app, model.ClientInfoFromRequest(r))
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+		if resp.RefreshToken, err = ar.TokenService.String(refreshToken); err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+	}
+
+	ar.serveOAuthJSON(w, http.StatusOK, resp)
+}
+
+// exchangeRefreshToken issues new access token for the refresh token.
+func (ar *Router) exchangeRefreshToken(w http.ResponseWriter, r *http.Request) {
+	app, _, err := ar.oauthClient(r)
+	if err != nil {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+		return
+	}
+
+	refreshTokenString := r.PostFormValue(oauthRefreshTokenKey)
+	if ar.TokenBlacklist.IsBlacklisted(refreshTokenString) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Refresh token is invalid or revoked")
+		return
+	}
+
+	refreshToken, err := ar.TokenService.Parse(refreshTokenString)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	v := jwtValidator.NewValidator(app.ID(), ar.TokenService.Issuer(), "", jwtService.RefrestTokenType)
+	if err = v.Validate(refreshToken); err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	if model.TokenRevokedForUser(ar.TokenStorage, refreshToken.UserID(), refreshToken.IssuedAt()) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Refresh token is invalid or revoked")
+		return
+	}
+
+	if app.RefreshTokenRotation() {
+		if err = jwtService.CheckRefreshTokenFamily(refreshToken, refreshTokenString, app, ar.TokenStorage, ar.Logger); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+			return
+		}
+	} else if !ar.TokenStorage.HasToken(refreshTokenString) {
+		// Refresh tokens are deleted from the storage when the user logs out of the session.
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Refresh token is invalid or revoked")
+		return
+	}
+
+	accessToken, err := ar.TokenService.RefreshAccessToken(refreshToken)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	accessTokenString, err := ar.TokenService.String(accessToken)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	resp := oauthTokenResponse{
+		AccessToken: accessTokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   accessTokenLifespan(app),
+	}
+
+	// With rotation the client gets new refresh token, and the old one stops working.
+	if app.RefreshTokenRotation() {
+		newRefreshToken, err := ar.TokenService.RotateRefreshToken(refreshToken, model.ClientInfoFromRequest(r))
+		if err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+			return
+		}
+		if resp.RefreshToken, err = ar.TokenService.String(newRefreshToken); err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+			return
+		}
+		if err = ar.TokenStorage.DeleteToken(refreshTokenString); err != nil {
+			ar.Logger.Println("Cannot delete old refresh token from token storage:", err)
+		}
+	} else if err = ar.TokenStorage.TouchToken(refreshTokenString, model.ClientInfoFromRequest(r)); err != nil && err != model.ErrorNotFound {
+		ar.Logger.Println("Cannot update refresh token session:", err)
+	}
+
+	ar.serveOAuthJSON(w, http.StatusOK, resp)
+}
+
+// exchangeClientCredentials issues access token for the service app itself.
+// Requested scopes must be allowed for the app, if none are requested, all allowed scopes are granted.
+func (ar *Router) exchangeClientCredentials(w http.ResponseWriter, r *http.Request) {
+	app, authenticated, err := ar.oauthClient(r)
+	if err != nil {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+		return
+	}
+	if !authenticated {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "Client authentication is required")
+		return
+	}
+	if app.Type() != model.Service {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorUnauthorizedClient, "Only service apps can use client credentials grant")
+		return
+	}
+
+	scopes := strings.Fields(r.PostFormValue(oauthScopeKey))
+	if len(scopes) == 0 {
+		scopes = app.Scopes()
+	}
+	// Empty list of app scopes means no limitations.
+	if len(app.Scopes()) > 0 {
+		for _, s := range scopes {
+			if !contains(app.Scopes(), s) {
+				ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidScope, "Scope "+s+" is not allowed for the app")
+				return
+			}
+		}
+	}
+
+	accessToken, err := ar.TokenService.NewAppAccessToken(app, scopes)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	accessTokenString, err := ar.TokenService.String(accessToken)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	// No refresh token here, the app can always get a new access token with its credentials.
+	ar.serveOAuthJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken: accessTokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   accessTokenLifespan(app),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+// oauthClient returns the client app.
+// Client credentials are taken either from the basic auth header or from the request body.
+// The second return value tells whether the client has been authenticated with its secret.
+func (ar *Router) oauthClient(r *http.Request) (model.AppData, bool, error) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostFormValue(oauthClientIDKey)
+		clientSecret = r.PostFormValue(oauthClientSecretKey)
+	}
+
+	app, err := ar.AppStorage.ActiveAppByID(strings.TrimSpace(clientID))
+	if err != nil {
+		return nil, false, err
+	}
+	if clientSecret == "" {
+		return app, false, nil
+	}
+	if app.Secret() == "" || subtle.ConstantTimeCompare([]byte(app.Secret()), []byte(clientSecret)) != 1 {
+		return nil, false, ErrorInvalidClientCredentials
+	}
+	return app, true, nil
+}
+
+// oauthError writes an OAuth 2.0 error response.
+func (ar *Router) oauthError(w http.ResponseWriter, code int, errorCode, description string) {
+	ar.Logger.Printf("oauth error: %s, %s (code=%d)\n", errorCode, description, code)
+
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="identifo"`)
+	}
+	ar.serveOAuthJSON(w, code, map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
+
+// serveOAuthJSON sends JSON response which must not be cached.
+func (ar *Router) serveOAuthJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		ar.Error(w, err, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(code)
+	if _, err = w.Write(data); err != nil {
+		ar.Logger.Printf("error writing http response: %s", err)
+	}
+}
+
+// pkceRequired tells if the app must use PKCE with S256 challenge.
+// Web, iOS and Android apps cannot keep their secrets, so they must prove the code possession.
+func pkceRequired(app model.AppData) bool {
+	switch app.Type() {
+	case model.Web, model.IOS, model.Android:
+		return true
+	}
+	return false
+}
+
+// verifyCodeChallenge checks the code verifier against the code challenge, see https://tools.ietf.org/html/rfc7636#section-4.6.
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if l := len(verifier); l < 43 || l > 128 {
+		return false
+	}
+
+	switch method {
+	case pkceMethodS256:
+		hash := sha256.Sum256([]byte(verifier))
+		return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(hash[:])), []byte(challenge)) == 1
+	case pkceMethodPlain:
+		return subtle.ConstantTimeCompare([]byte(verifier), []byte(challenge)) == 1
+	}
+	return false
+}
+
+// randomOAuthCode generates random string for authorization codes.
+func randomOAuthCode() (string, error) {
+	code := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, code); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// accessTokenLifespan returns access token lifespan in seconds.
+func accessTokenLifespan(app model.AppData) int64 {
+	if lifespan := app.TokenLifespan(); lifespan != 0 {
+		return lifespan
+	}
+	return jwtService.TokenLifespan
+}
+
+// appendQuery adds query parameters to the URI, keeping the existing ones.
+func appendQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
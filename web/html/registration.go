@@ -111,6 +111,19 @@ func (ar *Router) Register() http.HandlerFunc {
 			return
 		}
 
+		if len(user.Email()) > 0 {
+			if err = ar.sendEmailVerification(user); err != nil {
+				ar.Logger.Printf("Error sending email verification: %v", err)
+			}
+		}
+
+		// The user is not logged in until the email is verified.
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			SetFlash(w, FlashErrorMessageKey, "Please follow the link we have sent to your email to verify it")
+			redirectToLogin()
+			return
+		}
+
 		// Do login flow.
 		scopes, err = ar.UserStorage.RequestScopes(user.ID(), scopes)
 		if err != nil {
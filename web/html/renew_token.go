@@ -72,6 +72,12 @@ func (ar *Router) RenewToken() http.HandlerFunc {
 			serveTemplate("not authorized", "", redirectURI)
 			return
 		}
+		if ar.webCookieTokenRevoked(tstr, webCookieToken) {
+			ar.Logger.Printf("Error revoked token of user %s", webCookieToken.UserID())
+			deleteCookie(w, CookieKeyWebCookieToken)
+			serveTemplate("not authorized", "", redirectURI)
+			return
+		}
 
 		userID := webCookieToken.UserID()
 
@@ -99,6 +105,17 @@ func (ar *Router) RenewToken() http.HandlerFunc {
 			return
 		}
 
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			serveTemplate("email is not verified", "", redirectURI)
+			return
+		}
+
+		if err = ar.joinWebSession(w, r, webCookieToken, app); err != nil {
+			ar.Logger.Printf("Error joining app %s to web session: %v", app.ID(), err)
+			serveTemplate("server error", "", redirectURI)
+			return
+		}
+
 		token, err := ar.TokenService.NewAccessToken(user, scopes, app, false)
 		if err != nil {
 			ar.Logger.Printf("Error creating token: %v", err)
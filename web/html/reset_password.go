@@ -32,6 +32,23 @@ func (ar *Router) ResetPassword() http.HandlerFunc {
 			return
 		}
 
+		// Whoever knew the old password should not stay logged in.
+		// The apps are known from the sessions, which go away together with the tokens.
+		sessions, err := ar.TokenStorage.UserSessions(token.UserID())
+		if err != nil {
+			ar.Logger.Println("Error getting user sessions before password reset logout. ", err)
+		}
+		if err = ar.TokenStorage.RevokeUserTokens(token.UserID()); err != nil {
+			ar.Logger.Println("Error revoking user tokens after password reset. ", err)
+		}
+		if user, err := ar.UserStorage.UserByID(token.UserID()); err == nil {
+			appIDs := make([]string, 0, len(sessions))
+			for _, s := range sessions {
+				appIDs = append(appIDs, s.AppID)
+			}
+			ar.backChannelLogout(user, appIDs)
+		}
+
 		successPath := path.Join(ar.PathPrefix, "password/reset/success")
 		http.Redirect(w, r, successPath, http.StatusMovedPermanently)
 	}
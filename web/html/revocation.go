@@ -0,0 +1,92 @@
+package html
+
+import (
+	"net/http"
+	"strings"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+)
+
+// Token type hints, see https://tools.ietf.org/html/rfc7009#section-2.1.
+const (
+	oauthTokenTypeHintKey          = "token_type_hint"
+	oauthTokenTypeHintAccessToken  = "access_token"
+	oauthTokenTypeHintRefreshToken = "refresh_token"
+
+	oauthErrorUnsupportedTokenType = "unsupported_token_type"
+)
+
+// Revoke revokes access or refresh token issued to the calling app.
+// Unlike /me/logout, it does not need a valid access token, so apps can revoke refresh tokens
+// after their access tokens have expired.
+// Invalid and already expired tokens are reported as revoked, as RFC 7009 requires.
+func (ar *Router) Revoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Cannot parse request body")
+			return
+		}
+
+		app, authenticated, err := ar.oauthClient(r)
+		if err != nil {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+			return
+		}
+		if !authenticated {
+			ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "Client authentication is required")
+			return
+		}
+
+		tokenString := strings.TrimSpace(r.PostFormValue(oauthTokenKey))
+		if len(tokenString) == 0 {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Token is required")
+			return
+		}
+
+		switch hint := r.PostFormValue(oauthTokenTypeHintKey); hint {
+		case "", oauthTokenTypeHintAccessToken, oauthTokenTypeHintRefreshToken:
+		default:
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorUnsupportedTokenType, "Unsupported token type hint "+hint)
+			return
+		}
+
+		// The hint only helps to look the token up, our tokens know their own type.
+		token, err := ar.TokenService.Parse(tokenString)
+		if err != nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		claims := tokenClaims(token)
+		if claims == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if claims.Audience != app.ID() {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorUnauthorizedClient, "Token was issued to another app")
+			return
+		}
+
+		switch claims.Type {
+		case jwtService.RefrestTokenType:
+			if err = ar.TokenStorage.DeleteToken(tokenString); err != nil {
+				ar.Logger.Printf("Cannot delete refresh token: %s", err)
+				ar.oauthError(w, http.StatusServiceUnavailable, oauthErrorServerError, "Cannot revoke refresh token")
+				return
+			}
+			if err = ar.TokenBlacklist.Add(tokenString); err != nil {
+				ar.Logger.Printf("Cannot blacklist refresh token: %s", err)
+			}
+		case jwtService.AccessTokenType:
+			if err = ar.TokenBlacklist.Add(tokenString); err != nil {
+				ar.Logger.Printf("Cannot blacklist access token: %s", err)
+				ar.oauthError(w, http.StatusServiceUnavailable, oauthErrorServerError, "Cannot revoke access token")
+				return
+			}
+		default:
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorUnsupportedTokenType, "Token of type "+claims.Type+" cannot be revoked")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
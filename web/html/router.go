@@ -17,21 +17,28 @@ import (
 
 // Router handles incoming http connections.
 type Router struct {
-	Middleware         *negroni.Negroni
-	Logger             *log.Logger
-	Router             *mux.Router
-	AppStorage         model.AppStorage
-	UserStorage        model.UserStorage
-	TokenStorage       model.TokenStorage
-	TokenBlacklist     model.TokenBlacklist
-	TokenService       jwtService.TokenService
-	SMSService         model.SMSService
-	EmailService       model.EmailService
-	staticFilesStorage model.StaticFilesStorage
-	Authorizer         *authorization.Authorizer
-	PathPrefix         string
-	Host               string
-	cors               *cors.Cors
+	Middleware               *negroni.Negroni
+	Logger                   *log.Logger
+	Router                   *mux.Router
+	AppStorage               model.AppStorage
+	UserStorage              model.UserStorage
+	TokenStorage             model.TokenStorage
+	TokenBlacklist           model.TokenBlacklist
+	AuthorizationCodeStorage model.AuthorizationCodeStorage
+	DeviceCodeStorage        model.DeviceCodeStorage
+	VerificationCodeStorage  model.VerificationCodeStorage
+	WebauthnChallengeStorage model.WebauthnChallengeStorage
+	TokenService             jwtService.TokenService
+	SMSService               model.SMSService
+	EmailService             model.EmailService
+	staticFilesStorage       model.StaticFilesStorage
+	Authorizer               *authorization.Authorizer
+	PathPrefix               string
+	Host                     string
+	SupportedLoginWays       model.LoginWith
+	tfaType                  model.TFAType
+	loginLockout             *model.LoginLockout
+	cors                     *cors.Cors
 }
 
 func defaultOptions() []func(*Router) error {
@@ -66,20 +73,48 @@ func CorsOption(corsOptions *model.CorsOptions) func(*Router) error {
 	}
 }
 
+// SupportedLoginWaysOption is for setting supported ways of logging in into the app.
+func SupportedLoginWaysOption(loginWays model.LoginWith) func(*Router) error {
+	return func(r *Router) error {
+		r.SupportedLoginWays = loginWays
+		return nil
+	}
+}
+
+// TFATypeOption is for setting two-factor authentication type.
+func TFATypeOption(tfaType model.TFAType) func(*Router) error {
+	return func(r *Router) error {
+		r.tfaType = tfaType
+		return nil
+	}
+}
+
+// LoginLockoutOption is for locking users out after repeated failed login attempts.
+func LoginLockoutOption(loginLockout *model.LoginLockout) func(*Router) error {
+	return func(r *Router) error {
+		r.loginLockout = loginLockout
+		return nil
+	}
+}
+
 // NewRouter creates and initializes new router.
-func NewRouter(logger *log.Logger, as model.AppStorage, us model.UserStorage, sfs model.StaticFilesStorage, ts model.TokenStorage, tb model.TokenBlacklist, tServ jwtService.TokenService, smsServ model.SMSService, emailServ model.EmailService, authorizer *authorization.Authorizer, options ...func(*Router) error) (model.Router, error) {
+func NewRouter(logger *log.Logger, as model.AppStorage, us model.UserStorage, sfs model.StaticFilesStorage, ts model.TokenStorage, tb model.TokenBlacklist, acs model.AuthorizationCodeStorage, dcs model.DeviceCodeStorage, vcs model.VerificationCodeStorage, wcs model.WebauthnChallengeStorage, tServ jwtService.TokenService, smsServ model.SMSService, emailServ model.EmailService, authorizer *authorization.Authorizer, options ...func(*Router) error) (model.Router, error) {
 	ar := Router{
-		Middleware:         negroni.Classic(),
-		Router:             mux.NewRouter(),
-		AppStorage:         as,
-		UserStorage:        us,
-		TokenStorage:       ts,
-		TokenBlacklist:     tb,
-		TokenService:       tServ,
-		SMSService:         smsServ,
-		EmailService:       emailServ,
-		staticFilesStorage: sfs,
-		Authorizer:         authorizer,
+		Middleware:               negroni.Classic(),
+		Router:                   mux.NewRouter(),
+		AppStorage:               as,
+		UserStorage:              us,
+		TokenStorage:             ts,
+		TokenBlacklist:           tb,
+		AuthorizationCodeStorage: acs,
+		DeviceCodeStorage:        dcs,
+		VerificationCodeStorage:  vcs,
+		WebauthnChallengeStorage: wcs,
+		TokenService:             tServ,
+		SMSService:               smsServ,
+		EmailService:             emailServ,
+		staticFilesStorage:       sfs,
+		Authorizer:               authorizer,
 	}
 
 	for _, option := range append(defaultOptions(), options...) {
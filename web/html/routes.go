@@ -62,8 +62,33 @@ func (ar *Router) initRoutes() {
 		ar.AppID(),
 		negroni.WrapFunc(ar.RegistrationHandler()),
 	)).Methods("GET")
+	ar.Router.Path(`/webauthn/login/{begin:begin/?}`).Handler(negroni.New(
+		ar.AppID(),
+		negroni.WrapFunc(ar.WebauthnLoginBegin()),
+	)).Methods("POST")
+	ar.Router.Path(`/webauthn/login/{finish:finish/?}`).Handler(negroni.New(
+		ar.AppID(),
+		negroni.WrapFunc(ar.WebauthnLoginFinish()),
+	)).Methods("POST")
+
+	ar.Router.HandleFunc(`/saml/{appId}/{metadata:metadata/?}`, ar.SAMLMetadata()).Methods("GET")
+	ar.Router.HandleFunc(`/saml/{appId}/{login:login/?}`, ar.SAMLLogin()).Methods("GET")
+	ar.Router.HandleFunc(`/saml/{appId}/{acs:acs/?}`, ar.SAMLAssertionConsumer()).Methods("POST")
 
 	ar.Router.HandleFunc(`/token/{renew:renew/?}`, ar.RenewToken()).Methods("GET")
+	ar.Router.HandleFunc(`/email/{verify:verify/?}`, ar.VerifyEmail()).Methods("GET")
+	ar.Router.HandleFunc(`/{magic_link:magic_link/?}`, ar.MagicLink()).Methods("GET", "POST")
+
+	ar.Router.HandleFunc(`/oauth/{authorize:authorize/?}`, ar.Authorize()).Methods("GET")
+	ar.Router.HandleFunc(`/oauth/{token:token/?}`, ar.Token()).Methods("POST")
+	ar.Router.HandleFunc(`/oauth/{userinfo:userinfo/?}`, ar.UserInfo()).Methods("GET", "POST")
+	ar.Router.HandleFunc(`/oauth/{introspect:introspect/?}`, ar.Introspect()).Methods("POST")
+	ar.Router.HandleFunc(`/oauth/{revoke:revoke/?}`, ar.Revoke()).Methods("POST")
+	ar.Router.HandleFunc(`/oauth/{device_authorization:device_authorization/?}`, ar.DeviceAuthorization()).Methods("POST")
+	ar.Router.HandleFunc(`/oauth/{end_session:end_session/?}`, ar.EndSession()).Methods("GET", "POST")
+	ar.Router.HandleFunc(`/{device:device/?}`, ar.DeviceHandler()).Methods("GET")
+	ar.Router.HandleFunc(`/{device:device/?}`, ar.Device()).Methods("POST")
+
 	ar.Router.Path(`/{logout:logout/?}`).Handler(negroni.New(
 		ar.AppID(),
 		negroni.WrapFunc(ar.Logout()),
@@ -0,0 +1,251 @@
+package html
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/madappgang/identifo/identity_providers/saml"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+)
+
+const (
+	// samlRequestPrefix is a prefix authentication request IDs are kept under in verification code storage.
+	samlRequestPrefix = "saml_request:"
+	// samlAssertionPrefix is a prefix IDs of used assertions are blacklisted with.
+	samlAssertionPrefix = "saml_assertion:"
+	samlResponseKey     = "SAMLResponse"
+	relayStateKey       = "RelayState"
+	redirectURIQueryKey = "redirect_uri"
+)
+
+// SAMLMetadata serves service provider metadata of the app, to register Identifo with the app's identity provider.
+func (ar *Router) SAMLMetadata() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, sp, err := ar.samlServiceProvider(mux.Vars(r)["appId"])
+		if err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/samlmetadata+xml")
+		if _, err = w.Write(sp.Metadata()); err != nil {
+			ar.Logger.Printf("Error writing SAML metadata: %v", err)
+		}
+	}
+}
+
+// SAMLLogin starts login initiated by Identifo, sending the user to the identity provider.
+// After login the user is redirected to redirect_uri, that must be one of app redirect URLs or one of our own pages.
+// If it is not set, the first app redirect URL is used.
+func (ar *Router) SAMLLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app, sp, err := ar.samlServiceProvider(mux.Vars(r)["appId"])
+		if err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+
+		redirectURI := strings.TrimSpace(r.URL.Query().Get(redirectURIQueryKey))
+		if redirectURI == "" {
+			redirectURI = ar.samlDefaultRedirect(app)
+		}
+		if !ar.isValidSAMLRedirect(app, redirectURI) {
+			ar.Error(w, fmt.Errorf("Unauthorized redirect uri %s", redirectURI), http.StatusBadRequest, "")
+			return
+		}
+
+		requestID, err := saml.NewRequestID()
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		// Remember the request, so the response to it is accepted once, and only with the same relay state.
+		if err = ar.VerificationCodeStorage.CreateVerificationCode(samlRequestPrefix+requestID, redirectURI); err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		requestURL, err := sp.AuthnRequestURL(requestID, redirectURI)
+		if err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+		http.Redirect(w, r, requestURL, http.StatusFound)
+	}
+}
+
+// SAMLAssertionConsumer is Assertion Consumer Service the identity provider posts its responses to.
+// It logs in the user with NameID of the verified assertion as a federated ID, registering new users if the app allows registration.
+// Responses to requests we have not sent are accepted only if the app allows login initiated at the identity provider.
+func (ar *Router) SAMLAssertionConsumer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app, sp, err := ar.samlServiceProvider(mux.Vars(r)["appId"])
+		if err != nil {
+			ar.Error(w, err, http.StatusBadRequest, "")
+			return
+		}
+		samlInfo := app.SAMLInfo()
+
+		assertion, err := sp.ParseResponse(r.PostFormValue(samlResponseKey), time.Now())
+		if err != nil {
+			ar.Error(w, err, http.StatusForbidden, "Invalid SAML response")
+			return
+		}
+
+		relayState := r.PostFormValue(relayStateKey)
+		redirectURI := relayState
+		if assertion.InResponseTo != "" {
+			if found, err := ar.VerificationCodeStorage.IsVerificationCodeFound(samlRequestPrefix+assertion.InResponseTo, relayState); err != nil {
+				ar.Error(w, err, http.StatusInternalServerError, "")
+				return
+			} else if !found {
+				ar.Error(w, fmt.Errorf("SAML response to unknown request"), http.StatusForbidden, "Invalid SAML response")
+				return
+			}
+		} else {
+			if !samlInfo.IdPInitiatedAllowed {
+				ar.Error(w, fmt.Errorf("Login initiated at identity provider is not allowed for app %s", app.ID()), http.StatusForbidden, "")
+				return
+			}
+			// Relay state of unsolicited responses is not ours, so it is used only if it is allowed.
+			if !ar.isValidSAMLRedirect(app, redirectURI) {
+				redirectURI = ar.samlDefaultRedirect(app)
+			}
+		}
+		if redirectURI == "" {
+			ar.Error(w, fmt.Errorf("App %s has no redirect URLs", app.ID()), http.StatusBadRequest, "")
+			return
+		}
+
+		// Assertion can be used only once.
+		if ar.TokenBlacklist.IsBlacklisted(samlAssertionPrefix + assertion.ID) {
+			ar.Error(w, fmt.Errorf("SAML assertion %s has already been used", assertion.ID), http.StatusForbidden, "Invalid SAML response")
+			return
+		}
+		if err = ar.TokenBlacklist.Add(samlAssertionPrefix + assertion.ID); err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		user, err := ar.samlUser(app, samlInfo, assertion)
+		if err != nil {
+			ar.Error(w, err, http.StatusForbidden, "")
+			return
+		}
+
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    user.AccessRole(),
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err = ar.Authorizer.Authorize(azi); err != nil {
+			ar.Error(w, err, http.StatusForbidden, "")
+			return
+		}
+
+		token, err := ar.TokenService.NewWebCookieToken(user)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+		tokenString, err := ar.TokenService.String(token)
+		if err != nil {
+			ar.Error(w, err, http.StatusInternalServerError, "")
+			return
+		}
+
+		ar.UserStorage.UpdateLoginMetadata(user.ID())
+		setCookie(w, CookieKeyWebCookieToken, tokenString, int(ar.TokenService.WebCookieTokenLifespan()))
+		http.Redirect(w, r, redirectURI, http.StatusFound)
+	}
+}
+
+// samlServiceProvider returns the app and Identifo as SAML service provider for it.
+func (ar *Router) samlServiceProvider(appID string) (model.AppData, *saml.ServiceProvider, error) {
+	if !ar.SupportedLoginWays.Federated {
+		return nil, nil, fmt.Errorf("Federated login is not supported")
+	}
+	app, err := ar.AppStorage.ActiveAppByID(appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	samlInfo := app.SAMLInfo()
+	if samlInfo == nil || samlInfo.Key == "" {
+		return nil, nil, fmt.Errorf("SAML login is not enabled for app %s", appID)
+	}
+
+	idp, err := saml.ParseMetadata([]byte(samlInfo.IdPMetadata))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	baseURL := strings.TrimSuffix(ar.Host, "/") + path.Join(ar.PathPrefix, "saml", app.ID())
+	return app, &saml.ServiceProvider{
+		EntityID: baseURL + "/metadata",
+		ACSURL:   baseURL + "/acs",
+		IdP:      idp,
+	}, nil
+}
+
+// samlUser finds the user by NameID, or registers the new one.
+// Email of the new user is taken from the assertion, if nobody else uses it.
+func (ar *Router) samlUser(app model.AppData, samlInfo *model.SAMLInfo, assertion *saml.Assertion) (model.User, error) {
+	fid := samlInfo.FederatedIdentityProvider()
+
+	user, err := ar.UserStorage.UserByFederatedID(fid, assertion.NameID)
+	if err == nil {
+		return user, nil
+	}
+	if err != model.ErrUserNotFound {
+		return nil, err
+	}
+	if app.RegistrationForbidden() {
+		return nil, fmt.Errorf("Registration in app %s is forbidden", app.ID())
+	}
+
+	if user, err = ar.UserStorage.AddUserWithFederatedID(fid, assertion.NameID, app.NewUserDefaultRole()); err != nil {
+		return nil, err
+	}
+
+	email := ""
+	if samlInfo.EmailAttribute != "" {
+		email = strings.TrimSpace(assertion.Attribute(samlInfo.EmailAttribute))
+	} else if assertion.NameIDFormat == saml.EmailNameIDFormat {
+		email = assertion.NameID
+	}
+	if !model.EmailRegexp.MatchString(email) {
+		return user, nil
+	}
+	if _, err = ar.UserStorage.UserByEmail(email); err != model.ErrUserNotFound {
+		return user, nil
+	}
+
+	// The app trusts its identity provider, so the email is verified there.
+	user.SetEmail(email)
+	user.SetEmailVerified(true)
+	if updated, err := ar.UserStorage.UpdateUser(user.ID(), user); err != nil {
+		ar.Logger.Printf("Error setting email of SAML user %s: %v", user.ID(), err)
+	} else {
+		user = updated
+	}
+	return user, nil
+}
+
+// samlDefaultRedirect returns the first redirect URL of the app.
+func (ar *Router) samlDefaultRedirect(app model.AppData) string {
+	if urls := app.RedirectURLs(); len(urls) > 0 {
+		return urls[0]
+	}
+	return ""
+}
+
+// isValidSAMLRedirect checks the user is sent either to one of app redirect URLs, or back to one of our own pages.
+func (ar *Router) isValidSAMLRedirect(app model.AppData, redirectURI string) bool {
+	return redirectURI != "" && (contains(app.RedirectURLs(), redirectURI) || ar.isValidReturnTo(redirectURI))
+}
@@ -0,0 +1,158 @@
+package html
+
+import (
+	"net/http"
+	"strings"
+
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+)
+
+// Token exchange grant parameters, see https://tools.ietf.org/html/rfc8693.
+const (
+	oauthSubjectTokenKey       = "subject_token"
+	oauthSubjectTokenTypeKey   = "subject_token_type"
+	oauthActorTokenKey         = "actor_token"
+	oauthRequestedTokenTypeKey = "requested_token_type"
+	oauthAudienceKey           = "audience"
+	oauthTokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+
+	oauthGrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	oauthErrorInvalidTarget = "invalid_target"
+)
+
+// exchangeToken issues access token for another app on behalf of the user, in exchange for the user's access token.
+// The subject token must be issued to the requesting app, and the app must be allowed to exchange tokens for the target one.
+// Scopes can only be narrowed, and the requesting app is added to "act" claim of the new token.
+func (ar *Router) exchangeToken(w http.ResponseWriter, r *http.Request) {
+	app, authenticated, err := ar.oauthClient(r)
+	if err != nil {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, err.Error())
+		return
+	}
+	if !authenticated {
+		ar.oauthError(w, http.StatusUnauthorized, oauthErrorInvalidClient, "Client authentication is required")
+		return
+	}
+
+	if r.PostFormValue(oauthSubjectTokenTypeKey) != oauthTokenTypeAccessToken {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Only access tokens can be exchanged")
+		return
+	}
+	if tt := r.PostFormValue(oauthRequestedTokenTypeKey); tt != "" && tt != oauthTokenTypeAccessToken {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Only access tokens can be issued")
+		return
+	}
+	if r.PostFormValue(oauthActorTokenKey) != "" {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidRequest, "Actor tokens are not supported, the client is the actor")
+		return
+	}
+
+	audience := strings.TrimSpace(r.PostFormValue(oauthAudienceKey))
+	if !contains(app.TokenExchangeAudiences(), audience) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidTarget, "The app is not allowed to exchange tokens for audience "+audience)
+		return
+	}
+	target, err := ar.AppStorage.ActiveAppByID(audience)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidTarget, "Audience app is not found or inactive")
+		return
+	}
+
+	subjectTokenString := strings.TrimSpace(r.PostFormValue(oauthSubjectTokenKey))
+	if ar.TokenBlacklist.IsBlacklisted(subjectTokenString) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Subject token is invalid or revoked")
+		return
+	}
+	subjectToken, err := ar.TokenService.Parse(subjectTokenString)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	v := jwtValidator.NewValidator(app.ID(), ar.TokenService.Issuer(), "", jwtService.AccessTokenType)
+	if err = v.Validate(subjectToken); err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	if model.TokenRevokedForUser(ar.TokenStorage, subjectToken.UserID(), subjectToken.IssuedAt()) {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Subject token is invalid or revoked")
+		return
+	}
+	if subjectToken.Payload()[jwtService.PayloadTFAuthorized] == "false" {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "Subject token is not authorized with the second factor")
+		return
+	}
+
+	user, err := ar.UserStorage.UserByID(subjectToken.UserID())
+	if err != nil || !user.Active() {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, "User is not eligible to obtain the token")
+		return
+	}
+
+	// Scopes can only be narrowed down, there are no refresh tokens for exchanged ones.
+	// If none are requested, the subject token scopes allowed for the audience app are granted.
+	subjectScopes := strings.Fields(tokenClaims(subjectToken).Scopes)
+	scopes := strings.Fields(r.PostFormValue(oauthScopeKey))
+	requested := len(scopes) > 0
+	if !requested {
+		scopes = subjectScopes
+	}
+	granted := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if s == jwtService.OfflineScope {
+			continue
+		}
+		// Empty list of app scopes means no limitations.
+		allowed := len(target.Scopes()) == 0 || contains(target.Scopes(), s)
+		if !requested {
+			if allowed {
+				granted = append(granted, s)
+			}
+			continue
+		}
+		if !contains(subjectScopes, s) {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidScope, "Scope "+s+" is not granted to the subject token")
+			return
+		}
+		if !allowed {
+			ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidScope, "Scope "+s+" is not allowed for the audience app")
+			return
+		}
+		granted = append(granted, s)
+	}
+
+	granted, errorCode, err := ar.authorizeUser(r, user, target, granted)
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, errorCode, err.Error())
+		return
+	}
+
+	accessToken, err := ar.TokenService.ExchangeAccessToken(subjectToken, user, granted, target, app.ID())
+	if err != nil {
+		ar.oauthError(w, http.StatusBadRequest, oauthErrorInvalidGrant, err.Error())
+		return
+	}
+	accessTokenString, err := ar.TokenService.String(accessToken)
+	if err != nil {
+		ar.oauthError(w, http.StatusInternalServerError, oauthErrorServerError, err.Error())
+		return
+	}
+
+	// Exchanged token does not outlive the subject token.
+	expiresIn := accessTokenLifespan(target)
+	if left := tokenClaims(subjectToken).ExpiresAt - ijwt.TimeFunc().Unix(); left < expiresIn {
+		expiresIn = left
+	}
+
+	ar.Logger.Printf("App %s exchanged token of user %s for app %s", app.ID(), user.ID(), target.ID())
+	ar.serveOAuthJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken:     accessTokenString,
+		IssuedTokenType: oauthTokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       expiresIn,
+		Scope:           strings.Join(granted, " "),
+	})
+}
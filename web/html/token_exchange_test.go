@@ -0,0 +1,165 @@
+package html
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	configStorageFile "github.com/madappgang/identifo/configuration/storage/file"
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/storage/mem"
+)
+
+// sameUserStorage always returns the same user, while the in-memory storage makes up a new one every time.
+type sameUserStorage struct {
+	model.UserStorage
+	user model.User
+}
+
+func (s sameUserStorage) UserByID(string) (model.User, error) { return s.user, nil }
+
+func TestExchangeTokenAudience(t *testing.T) {
+	as, err := mem.NewAppStorage()
+	if err != nil {
+		t.Fatalf("Unable to create app storage %v", err)
+	}
+	// The gateway may exchange tokens for the service and for the app nobody has created.
+	if err = as.ImportJSON([]byte(`[
+		{"id": "gateway", "secret": "gateway-secret", "active": true, "token_exchange_audiences": ["service", "missing"]},
+		{"id": "service", "secret": "service-secret", "active": true},
+		{"id": "other", "secret": "other-secret", "active": true}
+	]`)); err != nil {
+		t.Fatalf("Unable to import apps %v", err)
+	}
+	gateway, err := as.AppByID("gateway")
+	if err != nil {
+		t.Fatalf("Unable to get app %v", err)
+	}
+	service, err := as.AppByID("service")
+	if err != nil {
+		t.Fatalf("Unable to get app %v", err)
+	}
+
+	us, err := mem.NewUserStorage()
+	if err != nil {
+		t.Fatalf("Unable to create user storage %v", err)
+	}
+	user, _ := us.UserByID("user")
+	for !user.Active() {
+		user, _ = us.UserByID("user")
+	}
+	us = sameUserStorage{UserStorage: us, user: user}
+	ts, err := mem.NewTokenStorage()
+	if err != nil {
+		t.Fatalf("Unable to create token storage %v", err)
+	}
+	tb, err := mem.NewTokenBlacklist()
+	if err != nil {
+		t.Fatalf("Unable to create token blacklist %v", err)
+	}
+
+	configStorage, err := configStorageFile.NewConfigurationStorage(model.ConfigurationStorageSettings{
+		Type: model.ConfigurationStorageTypeFile,
+		KeyStorage: model.KeyStorageSettings{
+			Type:   model.KeyStorageTypeLocal,
+			Folder: "../../jwt",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to init configuration storage. %v", err)
+	}
+	keys, err := configStorage.LoadKeys(ijwt.TokenSignatureAlgorithmES256)
+	if err != nil {
+		t.Fatalf("Unable to load keys. %v", err)
+	}
+	tokenService, err := jwtService.NewJWTokenService(keys, "identifo.madappgang.com", ts, as, us)
+	if err != nil {
+		t.Fatalf("Unable to create token service %v", err)
+	}
+
+	subjectToken := func(app model.AppData) string {
+		token, err := tokenService.NewAccessToken(user, []string{}, app, false)
+		if err != nil {
+			t.Fatalf("Unable to create token %v", err)
+		}
+		tokenString, err := tokenService.String(token)
+		if err != nil {
+			t.Fatalf("Unable to serialize token %v", err)
+		}
+		return tokenString
+	}
+
+	ar := &Router{
+		Logger:         log.New(ioutil.Discard, "", 0),
+		AppStorage:     as,
+		UserStorage:    us,
+		TokenStorage:   ts,
+		TokenBlacklist: tb,
+		TokenService:   tokenService,
+	}
+
+	tests := []struct {
+		name         string
+		secret       string
+		audience     string
+		subjectToken string
+		wantStatus   int
+		wantError    string
+	}{
+		{"allowed audience", "gateway-secret", "service", subjectToken(gateway), http.StatusOK, ""},
+		{"audience not allowed for the app", "gateway-secret", "other", subjectToken(gateway), http.StatusBadRequest, oauthErrorInvalidTarget},
+		{"no audience", "gateway-secret", "", subjectToken(gateway), http.StatusBadRequest, oauthErrorInvalidTarget},
+		{"allowed audience app does not exist", "gateway-secret", "missing", subjectToken(gateway), http.StatusBadRequest, oauthErrorInvalidTarget},
+		{"subject token of another app", "gateway-secret", "service", subjectToken(service), http.StatusBadRequest, oauthErrorInvalidGrant},
+		{"public client", "", "service", subjectToken(gateway), http.StatusUnauthorized, oauthErrorInvalidClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{
+				oauthGrantTypeKey:        []string{oauthGrantTypeTokenExchange},
+				oauthClientIDKey:         []string{"gateway"},
+				oauthClientSecretKey:     []string{tt.secret},
+				oauthSubjectTokenKey:     []string{tt.subjectToken},
+				oauthSubjectTokenTypeKey: []string{oauthTokenTypeAccessToken},
+				oauthAudienceKey:         []string{tt.audience},
+			}
+			r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			ar.exchangeToken(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Status = %v, want %v, response %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			resp := map[string]interface{}{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Unable to parse response %v", err)
+			}
+			if tt.wantError != "" {
+				if resp["error"] != tt.wantError {
+					t.Errorf("Error = %v, want %v", resp["error"], tt.wantError)
+				}
+				return
+			}
+
+			token, err := tokenService.Parse(resp["access_token"].(string))
+			if err != nil {
+				t.Fatalf("Unable to parse exchanged token %v", err)
+			}
+			claims := token.(*ijwt.JWToken).JWT.Claims.(*ijwt.Claims)
+			if claims.Audience != "service" || claims.Subject != user.ID() {
+				t.Errorf("Exchanged token audience = %v, subject = %v, want service and %v", claims.Audience, claims.Subject, user.ID())
+			}
+			if claims.Actor == nil || claims.Actor.Subject != "gateway" {
+				t.Errorf("Exchanged token actor = %+v, want gateway", claims.Actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package html
+
+import (
+	"net/http"
+	"strings"
+
+	ijwt "github.com/madappgang/identifo/jwt"
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	jwtValidator "github.com/madappgang/identifo/jwt/validator"
+	"github.com/madappgang/identifo/model"
+)
+
+// userInfoResponse is an OpenID Connect UserInfo response, see https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse.
+type userInfoResponse struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email,omitempty"`
+	PhoneNumber       string `json:"phone_number,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// UserInfo returns claims about the user who owns the access token.
+// Claims are released according to the scopes the access token has been issued with.
+func (ar *Router) UserInfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenBytes := ijwt.ExtractTokenFromBearerHeader(r.Header.Get("Authorization"))
+		if tokenBytes == nil {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_request", "Access token is missing")
+			return
+		}
+		tokenString := string(tokenBytes)
+
+		token, err := ar.TokenService.Parse(tokenString)
+		if err != nil {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", err.Error())
+			return
+		}
+		claims := tokenClaims(token)
+		if claims == nil {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "Access token is invalid")
+			return
+		}
+
+		app, err := ar.AppStorage.ActiveAppByID(claims.Audience)
+		if err != nil {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "Access token was issued to unknown app")
+			return
+		}
+		v := jwtValidator.NewValidator(app.ID(), ar.TokenService.Issuer(), "", jwtService.AccessTokenType)
+		if err = v.Validate(token); err != nil {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", err.Error())
+			return
+		}
+		if ar.TokenBlacklist.IsBlacklisted(tokenString) {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "Access token is revoked")
+			return
+		}
+		if model.TokenRevokedForUser(ar.TokenStorage, claims.Subject, claims.IssuedAt) {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "Access token is revoked")
+			return
+		}
+		if payload := token.Payload(); payload != nil && payload[jwtService.PayloadTFAuthorized] == "false" {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "Access token is not authorized with two-factor authentication")
+			return
+		}
+
+		scopes := strings.Fields(claims.Scopes)
+		if !contains(scopes, jwtService.OpenIDScope) {
+			ar.bearerError(w, http.StatusForbidden, "insufficient_scope", "Access token has no openid scope")
+			return
+		}
+
+		user, err := ar.UserStorage.UserByID(claims.Subject)
+		if err != nil || !user.Active() {
+			ar.bearerError(w, http.StatusUnauthorized, "invalid_token", "User is not eligible to get user info")
+			return
+		}
+
+		resp := userInfoResponse{Subject: user.ID()}
+		if contains(scopes, jwtService.EmailScope) {
+			resp.Email = user.Email()
+		}
+		if contains(scopes, jwtService.PhoneScope) {
+			resp.PhoneNumber = user.Phone()
+		}
+		if contains(scopes, jwtService.ProfileScope) {
+			resp.PreferredUsername = user.Username()
+		}
+		ar.serveOAuthJSON(w, http.StatusOK, resp)
+	}
+}
+
+// bearerError writes an error response for requests with bearer token, see https://tools.ietf.org/html/rfc6750#section-3.
+func (ar *Router) bearerError(w http.ResponseWriter, code int, errorCode, description string) {
+	ar.Logger.Printf("bearer token error: %s, %s (code=%d)\n", errorCode, description, code)
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="identifo", error="`+errorCode+`"`)
+	ar.serveOAuthJSON(w, code, map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
+
+// tokenClaims returns claims of the parsed token, or nil if the token has no Identifo claims.
+func tokenClaims(t ijwt.Token) *ijwt.Claims {
+	token, ok := t.(*ijwt.JWToken)
+	if !ok || token.JWT == nil {
+		return nil
+	}
+	claims, _ := token.JWT.Claims.(*ijwt.Claims)
+	return claims
+}
@@ -0,0 +1,166 @@
+package html
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/madappgang/identifo/model"
+	"github.com/madappgang/identifo/web/authorization"
+	"github.com/madappgang/identifo/web/middleware"
+	"github.com/madappgang/identifo/webauthn"
+)
+
+const (
+	credentialKey = "credential"
+)
+
+// WebauthnLoginBegin starts login with a passkey on the login page.
+func (ar *Router) WebauthnLoginBegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Webauthn {
+			ar.oauthError(w, http.StatusBadRequest, "invalid_request", "Passkeys are not supported")
+			return
+		}
+
+		rp, err := webauthn.NewRelyingParty(ar.Host)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		var user model.User
+		if username := strings.TrimSpace(r.FormValue(usernameKey)); username != "" {
+			userID, err := ar.UserStorage.IDByName(username)
+			if err == nil {
+				user, err = ar.UserStorage.UserByID(userID)
+			}
+			if err != nil {
+				ar.oauthError(w, http.StatusBadRequest, "invalid_request", "Invalid Username")
+				return
+			}
+		}
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		if err = ar.WebauthnChallengeStorage.SaveWebauthnChallenge(model.NewWebauthnChallenge(challenge, model.WebauthnCeremonyLogin, "")); err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		ar.serveOAuthJSON(w, http.StatusOK, map[string]interface{}{"publicKey": rp.NewRequestOptions(user, challenge)})
+	}
+}
+
+// WebauthnLoginFinish verifies the passkey and logs user in, like password login does.
+// Instead of redirect, it returns the page to go next, as the ceremony is performed by the script.
+func (ar *Router) WebauthnLoginFinish() http.HandlerFunc {
+	invalidPasskey := "Invalid Passkey"
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ar.SupportedLoginWays.Webauthn {
+			ar.oauthError(w, http.StatusBadRequest, "invalid_request", "Passkeys are not supported")
+			return
+		}
+
+		app := middleware.AppFromContext(r.Context())
+		rp, err := webauthn.NewRelyingParty(ar.Host)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		credential := webauthn.Credential{}
+		if err = json.Unmarshal([]byte(r.FormValue(credentialKey)), &credential); err != nil {
+			ar.oauthError(w, http.StatusBadRequest, "invalid_request", invalidPasskey)
+			return
+		}
+
+		challenge, err := credential.Challenge()
+		if err != nil {
+			ar.oauthError(w, http.StatusBadRequest, "invalid_request", invalidPasskey)
+			return
+		}
+		// Challenge is consumed whether the passkey is verified or not, so it cannot be answered again.
+		if wc, err := ar.WebauthnChallengeStorage.ConsumeWebauthnChallenge(challenge); err == model.ErrorNotFound || (err == nil && wc.Ceremony != model.WebauthnCeremonyLogin) {
+			ar.oauthError(w, http.StatusBadRequest, "invalid_request", invalidPasskey)
+			return
+		} else if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		// Discoverable credentials carry user ID, others are looked up by username.
+		userID := credential.UserID()
+		if userID == "" {
+			if userID, err = ar.UserStorage.IDByName(strings.TrimSpace(r.FormValue(usernameKey))); err != nil {
+				ar.oauthError(w, http.StatusBadRequest, "invalid_request", invalidPasskey)
+				return
+			}
+		}
+		user, err := ar.UserStorage.UserByID(userID)
+		if err != nil {
+			ar.oauthError(w, http.StatusBadRequest, "invalid_request", invalidPasskey)
+			return
+		}
+
+		verified, _, err := rp.VerifyLogin(&credential, challenge, user.WebauthnCredentials())
+		if err != nil {
+			ar.Logger.Printf("Error verifying passkey of user %v: %v", userID, err)
+			ar.oauthError(w, http.StatusUnauthorized, "access_denied", invalidPasskey)
+			return
+		}
+		user.SetWebauthnCredentials(webauthn.ReplaceCredential(user.WebauthnCredentials(), verified))
+		if _, err = ar.UserStorage.UpdateUser(user.ID(), user); err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		// Authorize user if the app requires authorization.
+		azi := authorization.AuthzInfo{
+			App:         app,
+			UserRole:    user.AccessRole(),
+			ResourceURI: r.RequestURI,
+			Method:      r.Method,
+		}
+		if err = ar.Authorizer.Authorize(azi); err != nil {
+			ar.oauthError(w, http.StatusForbidden, "access_denied", err.Error())
+			return
+		}
+
+		if app.EmailVerificationRequired() && !user.EmailVerified() {
+			ar.oauthError(w, http.StatusForbidden, "access_denied", "Please verify your email address to be able to log in")
+			return
+		}
+
+		token, err := ar.TokenService.NewWebCookieToken(user)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		tokenString, err := ar.TokenService.String(token)
+		if err != nil {
+			ar.oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+
+		ar.UserStorage.UpdateLoginMetadata(user.ID())
+		setCookie(w, CookieKeyWebCookieToken, tokenString, int(ar.TokenService.WebCookieTokenLifespan()))
+
+		// Login page itself sends the user to the callback URL, as the user is logged in now.
+		redirect := r.FormValue(returnToKey)
+		if !ar.isValidReturnTo(redirect) {
+			q := url.Values{}
+			q.Set(FormKeyAppID, app.ID())
+			q.Set(scopesKey, r.FormValue(scopesKey))
+			q.Set(callbackURLKey, r.FormValue(callbackURLKey))
+			redirect = path.Join(ar.PathPrefix, "/login") + "?" + q.Encode()
+		}
+		ar.serveOAuthJSON(w, http.StatusOK, map[string]string{"redirect": redirect})
+	}
+}
@@ -15,23 +15,26 @@ import (
 
 // RouterSetting contains settings for root http router.
 type RouterSetting struct {
-	AppStorage              model.AppStorage
-	UserStorage             model.UserStorage
-	TokenStorage            model.TokenStorage
-	TokenBlacklist          model.TokenBlacklist
-	VerificationCodeStorage model.VerificationCodeStorage
-	TokenService            jwtService.TokenService
-	SMSService              model.SMSService
-	EmailService            model.EmailService
-	SessionService          model.SessionService
-	SessionStorage          model.SessionStorage
-	StaticFilesStorage      model.StaticFilesStorage
-	ConfigurationStorage    model.ConfigurationStorage
-	Logger                  *log.Logger
-	ServeAdminPanel         bool
-	APIRouterSettings       []func(*api.Router) error
-	WebRouterSettings       []func(*html.Router) error
-	AdminRouterSettings     []func(*admin.Router) error
+	AppStorage               model.AppStorage
+	UserStorage              model.UserStorage
+	TokenStorage             model.TokenStorage
+	TokenBlacklist           model.TokenBlacklist
+	VerificationCodeStorage  model.VerificationCodeStorage
+	AuthorizationCodeStorage model.AuthorizationCodeStorage
+	DeviceCodeStorage        model.DeviceCodeStorage
+	WebauthnChallengeStorage model.WebauthnChallengeStorage
+	TokenService             jwtService.TokenService
+	SMSService               model.SMSService
+	EmailService             model.EmailService
+	SessionService           model.SessionService
+	SessionStorage           model.SessionStorage
+	StaticFilesStorage       model.StaticFilesStorage
+	ConfigurationStorage     model.ConfigurationStorage
+	Logger                   *log.Logger
+	ServeAdminPanel          bool
+	APIRouterSettings        []func(*api.Router) error
+	WebRouterSettings        []func(*html.Router) error
+	AdminRouterSettings      []func(*admin.Router) error
 }
 
 // NewRouter creates and inits root http router.
@@ -47,6 +50,7 @@ func NewRouter(settings RouterSetting) (model.Router, error) {
 		settings.TokenStorage,
 		settings.TokenBlacklist,
 		settings.VerificationCodeStorage,
+		settings.WebauthnChallengeStorage,
 		settings.StaticFilesStorage,
 		settings.TokenService,
 		settings.SMSService,
@@ -65,6 +69,10 @@ func NewRouter(settings RouterSetting) (model.Router, error) {
 		settings.StaticFilesStorage,
 		settings.TokenStorage,
 		settings.TokenBlacklist,
+		settings.AuthorizationCodeStorage,
+		settings.DeviceCodeStorage,
+		settings.VerificationCodeStorage,
+		settings.WebauthnChallengeStorage,
 		settings.TokenService,
 		settings.SMSService,
 		settings.EmailService,
@@ -84,6 +92,9 @@ func NewRouter(settings RouterSetting) (model.Router, error) {
 			settings.UserStorage,
 			settings.ConfigurationStorage,
 			settings.StaticFilesStorage,
+			settings.TokenService,
+			settings.TokenStorage,
+			settings.TokenBlacklist,
 			settings.AdminRouterSettings...,
 		)
 		if err != nil {
@@ -0,0 +1,37 @@
+// Package shared holds the logic which API and HTML routers must agree on.
+package shared
+
+import (
+	"net/url"
+	"path"
+
+	jwtService "github.com/madappgang/identifo/jwt/service"
+	"github.com/madappgang/identifo/model"
+)
+
+// SendEmailVerification sends the link to verify user's email address.
+// The link leads to the page of the web router with webPrefix, which consumes the verification token.
+func SendEmailVerification(tokenService jwtService.TokenService, emailService model.EmailService, user model.User, host, webPrefix string) error {
+	token, err := tokenService.NewEmailVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	tokenString, err := tokenService.String(token)
+	if err != nil {
+		return err
+	}
+
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return err
+	}
+
+	u := &url.URL{
+		Scheme:   hostURL.Scheme,
+		Host:     hostURL.Host,
+		Path:     path.Join(webPrefix, "email/verify"),
+		RawQuery: url.Values{"token": []string{tokenString}}.Encode(),
+	}
+	return emailService.SendVerifyEmail("Verify Email", user.Email(), u.String())
+}
@@ -0,0 +1,67 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/madappgang/identifo/model"
+	"github.com/xlzd/gotp"
+)
+
+const smsTFACode = "%v is your one-time password!"
+
+var (
+	// ErrPleaseEnableTFA means that the app requires two-factor authentication, but user has not enabled it.
+	ErrPleaseEnableTFA = errors.New("Please enable two-factor authentication to be able to use this app")
+	// ErrPleaseFinishEnablingTFA means that admin has enabled two-factor authentication for the user, but user has not obtained TFA secret yet.
+	ErrPleaseFinishEnablingTFA = errors.New("Please enable two-factor authentication to be able to use this app")
+	// ErrPleaseDisableTFA means that the app does not support two-factor authentication, but user has enabled it.
+	ErrPleaseDisableTFA = errors.New("Please disable two-factor authentication to be able to use this app")
+	// ErrPleaseSetPhoneForTFA means that one-time password cannot be sent, because user has no phone number.
+	ErrPleaseSetPhoneForTFA = errors.New("Please specify your phone number to be able to receive one-time passwords")
+	// ErrPleaseSetEmailForTFA means that one-time password cannot be sent, because user has no email.
+	ErrPleaseSetEmailForTFA = errors.New("Please specify your email address to be able to receive one-time passwords")
+)
+
+// CheckTFA checks correspondence between app's TFA status and user's TFA info,
+// and decides if we require two-factor authentication after all checks are successfully passed.
+// Second factor is not required on the device the user has chosen to trust.
+func CheckTFA(appTFAStatus model.TFAStatus, userTFAInfo model.TFAInfo, deviceTrusted bool) (bool, error) {
+	if appTFAStatus == model.TFAStatusMandatory && !userTFAInfo.IsEnabled {
+		return false, ErrPleaseEnableTFA
+	}
+	if appTFAStatus == model.TFAStatusDisabled && userTFAInfo.IsEnabled {
+		return false, ErrPleaseDisableTFA
+	}
+
+	// Request two-factor auth if user enabled it and app supports it.
+	if userTFAInfo.IsEnabled && appTFAStatus != model.TFAStatusDisabled {
+		if userTFAInfo.Secret == "" {
+			// Then admin must have enabled TFA for this user manually.
+			// User must obtain TFA secret, i.e send EnableTFA request.
+			return false, ErrPleaseFinishEnablingTFA
+		}
+		return !deviceTrusted, nil
+	}
+	return false, nil
+}
+
+// SendTFACode sends one-time password to the user, if the server is configured to deliver it by SMS or email.
+// It must be called before the user is sanitized, since the password is generated from TFA secret.
+func SendTFACode(tfaType model.TFAType, user model.User, smsService model.SMSService, emailService model.EmailService) error {
+	totp := gotp.NewDefaultTOTP(user.TFAInfo().Secret).Now()
+
+	switch tfaType {
+	case model.TFATypeSMS:
+		if user.Phone() == "" {
+			return ErrPleaseSetPhoneForTFA
+		}
+		return smsService.SendSMS(user.Phone(), fmt.Sprintf(smsTFACode, totp))
+	case model.TFATypeEmail:
+		if user.Email() == "" {
+			return ErrPleaseSetEmailForTFA
+		}
+		return emailService.SendTFAEmail("One-time password", user.Email(), totp)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrInvalidCBOR is returned when data is not a valid CBOR of the subset used by authenticators.
+var ErrInvalidCBOR = errors.New("webauthn: invalid CBOR data")
+
+// maxCBORDepth limits nesting, authenticator data is never nested deeply.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR data item and returns it along with the number of bytes consumed.
+// Authenticators use canonical CBOR, so indefinite-length items are not supported.
+// Maps are decoded into map[interface{}]interface{}, integers into int64.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, int, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, 0, ErrInvalidCBOR
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	val, n, err := cborArgument(data, info)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0: // Unsigned integer.
+		if val > math.MaxInt64 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return int64(val), n, nil
+	case 1: // Negative integer.
+		if val > math.MaxInt64 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return -1 - int64(val), n, nil
+	case 2, 3: // Byte and text strings.
+		if val > uint64(len(data)-n) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		end := n + int(val)
+		if major == 2 {
+			b := make([]byte, val)
+			copy(b, data[n:end])
+			return b, end, nil
+		}
+		return string(data[n:end]), end, nil
+	case 4: // Array.
+		if val > uint64(len(data)) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		arr := make([]interface{}, 0, val)
+		for i := uint64(0); i < val; i++ {
+			item, m, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, item)
+			n += m
+		}
+		return arr, n, nil
+	case 5: // Map.
+		if val > uint64(len(data)) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		m := make(map[interface{}]interface{}, val)
+		for i := uint64(0); i < val; i++ {
+			key, k, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += k
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, ErrInvalidCBOR
+			}
+
+			value, v, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += v
+			m[key] = value
+		}
+		return m, n, nil
+	case 6: // Tag, the tagged item is returned as is.
+		item, m, err := decodeCBORItem(data[n:], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return item, n + m, nil
+	default: // Simple values and floats.
+		switch info {
+		case 20:
+			return false, n, nil
+		case 21:
+			return true, n, nil
+		case 22, 23:
+			return nil, n, nil
+		case 25:
+			return float64(float16ToFloat32(uint16(val))), n, nil
+		case 26:
+			return float64(math.Float32frombits(uint32(val))), n, nil
+		case 27:
+			return math.Float64frombits(val), n, nil
+		}
+		return nil, 0, ErrInvalidCBOR
+	}
+}
+
+// cborArgument reads the argument of the data item head, returns it with the length of the head.
+func cborArgument(data []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info == 24 && len(data) >= 2:
+		return uint64(data[1]), 2, nil
+	case info == 25 && len(data) >= 3:
+		return uint64(binary.BigEndian.Uint16(data[1:3])), 3, nil
+	case info == 26 && len(data) >= 5:
+		return uint64(binary.BigEndian.Uint32(data[1:5])), 5, nil
+	case info == 27 && len(data) >= 9:
+		return binary.BigEndian.Uint64(data[1:9]), 9, nil
+	}
+	return 0, 0, ErrInvalidCBOR
+}
+
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+
+	switch exp {
+	case 0:
+		f := float32(frac) / 1024 * float32(math.Pow(2, -14))
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+}
@@ -0,0 +1,180 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cborMap keeps map entries in order, so encoded fixtures are canonical like the ones of authenticators.
+type cborMap []cborPair
+
+type cborPair struct {
+	key, value interface{}
+}
+
+// encodeCBOR encodes test fixtures, only the types authenticators use are supported.
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case cborMap:
+		b := cborHead(5, uint64(len(v)))
+		for _, p := range v {
+			b = append(b, encodeCBOR(p.key)...)
+			b = append(b, encodeCBOR(p.value)...)
+		}
+		return b
+	}
+	panic("unsupported CBOR fixture type")
+}
+
+func cborHead(major byte, val uint64) []byte {
+	switch {
+	case val < 24:
+		return []byte{major<<5 | byte(val)}
+	case val <= 0xff:
+		return []byte{major<<5 | 24, byte(val)}
+	case val <= 0xffff:
+		b := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(val))
+		return b
+	}
+	b := []byte{major<<5 | 26, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(val))
+	return b
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid hex fixture %s: %v", s, err)
+	}
+	return b
+}
+
+// Examples are taken from RFC 8949, Appendix A.
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"40", []byte{}},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"62225c", "\"\\"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a0", map[interface{}]interface{}{}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f93c00", float64(1)},
+		{"f97bff", float64(65504)},
+		{"fa47c35000", float64(100000)},
+		{"fb3ff199999999999a", 1.1},
+		{"c11a514b67b0", int64(1363896240)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			data := mustHex(t, tt.hex)
+			got, n, err := decodeCBOR(data)
+			if err != nil {
+				t.Fatalf("decodeCBOR() error = %v", err)
+			}
+			if n != len(data) {
+				t.Errorf("decodeCBOR() consumed %d bytes, want %d", n, len(data))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORConsumesFirstItem(t *testing.T) {
+	got, n, err := decodeCBOR(mustHex(t, "83010203ffff"))
+	if err != nil {
+		t.Fatalf("decodeCBOR() error = %v", err)
+	}
+	if n != 4 || !reflect.DeepEqual(got, []interface{}{int64(1), int64(2), int64(3)}) {
+		t.Errorf("decodeCBOR() = %#v, %d", got, n)
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"empty", ""},
+		{"truncated argument", "19"},
+		{"truncated 64-bit argument", "1b000000e8d4a510"},
+		{"reserved additional information", "1c"},
+		{"unsigned integer overflow", "1bffffffffffffffff"},
+		{"negative integer overflow", "3bffffffffffffffff"},
+		{"truncated byte string", "44010203"},
+		{"truncated text string", "64494554"},
+		{"byte string longer than data", "5bffffffffffffffff01"},
+		{"indefinite length byte string", "5f42010243030405ff"},
+		{"indefinite length array", "9f0102ff"},
+		{"truncated array", "830102"},
+		{"array longer than data", "9bffffffffffffffff"},
+		{"map without value", "a101"},
+		{"map with array key", "a1800102"},
+		{"map longer than data", "bbffffffffffffffff"},
+		{"tag without item", "c1"},
+		{"break outside indefinite item", "ff"},
+		{"reserved simple value", "fc"},
+		{"too deep", strings.Repeat("81", maxCBORDepth+1) + "00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, err := decodeCBOR(mustHex(t, tt.hex)); err != ErrInvalidCBOR {
+				t.Errorf("decodeCBOR() = %#v, %v, want %v", got, err, ErrInvalidCBOR)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORTruncated(t *testing.T) {
+	// Every prefix of a valid item must be rejected rather than decoded partially.
+	data := encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", []byte(strings.Repeat("a", 300))},
+	})
+	for i := 0; i < len(data); i++ {
+		if _, _, err := decodeCBOR(data[:i]); err != ErrInvalidCBOR {
+			t.Fatalf("decodeCBOR() of %d bytes out of %d error = %v, want %v", i, len(data), err, ErrInvalidCBOR)
+		}
+	}
+	if _, n, err := decodeCBOR(data); err != nil || n != len(data) {
+		t.Fatalf("decodeCBOR() = %d, %v", n, err)
+	}
+}
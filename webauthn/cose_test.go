@@ -0,0 +1,188 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// testKey is a credential key pair as an authenticator keeps it.
+type testKey struct {
+	alg  int64
+	cose []byte
+	sign func(data []byte) []byte
+}
+
+func newES256Key(t *testing.T) testKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate ECDSA key: %v", err)
+	}
+	return testKey{
+		alg: AlgES256,
+		cose: encodeCBOR(cborMap{
+			{coseKeyType, coseKeyTypeEC2},
+			{coseAlgorithm, AlgES256},
+			{coseCurve, coseCurveP256},
+			{coseX, padded(priv.X, 32)},
+			{coseY, padded(priv.Y, 32)},
+		}),
+		sign: func(data []byte) []byte {
+			hash := sha256.Sum256(data)
+			r, s, err := ecdsa.Sign(rand.Reader, priv, hash[:])
+			if err != nil {
+				t.Fatalf("Unable to sign: %v", err)
+			}
+			sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+			if err != nil {
+				t.Fatalf("Unable to marshal signature: %v", err)
+			}
+			return sig
+		},
+	}
+}
+
+func newEdDSAKey(t *testing.T) testKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate Ed25519 key: %v", err)
+	}
+	return testKey{
+		alg: AlgEdDSA,
+		cose: encodeCBOR(cborMap{
+			{coseKeyType, coseKeyTypeOKP},
+			{coseAlgorithm, AlgEdDSA},
+			{coseCurve, coseCurveEd25519},
+			{coseX, []byte(pub)},
+		}),
+		sign: func(data []byte) []byte {
+			return ed25519.Sign(priv, data)
+		},
+	}
+}
+
+func newRS256Key(t *testing.T) testKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate RSA key: %v", err)
+	}
+	return testKey{
+		alg: AlgRS256,
+		cose: encodeCBOR(cborMap{
+			{coseKeyType, coseKeyTypeRSA},
+			{coseAlgorithm, AlgRS256},
+			{coseRSAN, priv.N.Bytes()},
+			{coseRSAE, big.NewInt(int64(priv.E)).Bytes()},
+		}),
+		sign: func(data []byte) []byte {
+			hash := sha256.Sum256(data)
+			sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hash[:])
+			if err != nil {
+				t.Fatalf("Unable to sign: %v", err)
+			}
+			return sig
+		},
+	}
+}
+
+func padded(n *big.Int, size int) []byte {
+	b := make([]byte, size)
+	nb := n.Bytes()
+	copy(b[size-len(nb):], nb)
+	return b
+}
+
+func TestParsePublicKeyAndVerify(t *testing.T) {
+	keys := map[string]testKey{
+		"ES256": newES256Key(t),
+		"EdDSA": newEdDSAKey(t),
+		"RS256": newRS256Key(t),
+	}
+	data := []byte("authenticator data and client data hash")
+
+	for name, k := range keys {
+		t.Run(name, func(t *testing.T) {
+			pk, err := parsePublicKey(k.cose)
+			if err != nil {
+				t.Fatalf("parsePublicKey() error = %v", err)
+			}
+			if pk.alg != k.alg {
+				t.Errorf("parsePublicKey() alg = %v, want %v", pk.alg, k.alg)
+			}
+
+			sig := k.sign(data)
+			if err = pk.verify(data, sig); err != nil {
+				t.Errorf("verify() error = %v", err)
+			}
+			if err = pk.verify([]byte("other data"), sig); err != ErrInvalidSignature {
+				t.Errorf("verify() of other data error = %v, want %v", err, ErrInvalidSignature)
+			}
+
+			tampered := append([]byte{}, sig...)
+			tampered[len(tampered)-1] ^= 0xff
+			if err = pk.verify(data, tampered); err != ErrInvalidSignature {
+				t.Errorf("verify() of tampered signature error = %v, want %v", err, ErrInvalidSignature)
+			}
+			if err = pk.verify(data, nil); err != ErrInvalidSignature {
+				t.Errorf("verify() of empty signature error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	// Signature made by another key of the same type.
+	other := newES256Key(t)
+	pk, err := parsePublicKey(keys["ES256"].cose)
+	if err != nil {
+		t.Fatalf("parsePublicKey() error = %v", err)
+	}
+	if err = pk.verify(data, other.sign(data)); err != ErrInvalidSignature {
+		t.Errorf("verify() with another key error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParsePublicKeyInvalid(t *testing.T) {
+	x := make([]byte, 32)
+	x[31] = 1
+	y := make([]byte, 32)
+	y[31] = 2
+	ed := make([]byte, ed25519.PublicKeySize)
+	rsaN := make([]byte, 256)
+	rsaN[0] = 0xc1
+
+	tests := []struct {
+		name string
+		cose []byte
+		want error
+	}{
+		{"truncated CBOR", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}})[:2], ErrInvalidCBOR},
+		{"not a map", encodeCBOR("public key"), ErrUnsupportedKey},
+		{"unknown algorithm", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, -35}, {coseCurve, coseCurveP256}, {coseX, x}, {coseY, y}}), ErrUnsupportedKey},
+		{"algorithm of another key type", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeOKP}, {coseAlgorithm, AlgES256}, {coseCurve, coseCurveEd25519}, {coseX, ed}}), ErrUnsupportedKey},
+		{"EC2 on another curve", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, 2}, {coseX, x}, {coseY, y}}), ErrUnsupportedKey},
+		{"EC2 short coordinate", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, coseCurveP256}, {coseX, x[1:]}, {coseY, y}}), ErrUnsupportedKey},
+		{"EC2 point not on curve", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, coseCurveP256}, {coseX, x}, {coseY, y}}), ErrUnsupportedKey},
+		{"OKP on another curve", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeOKP}, {coseAlgorithm, AlgEdDSA}, {coseCurve, 4}, {coseX, ed}}), ErrUnsupportedKey},
+		{"OKP short key", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeOKP}, {coseAlgorithm, AlgEdDSA}, {coseCurve, coseCurveEd25519}, {coseX, ed[1:]}}), ErrUnsupportedKey},
+		{"RSA short modulus", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseRSAN, rsaN[:128]}, {coseRSAE, []byte{1, 0, 1}}}), ErrUnsupportedKey},
+		{"RSA without exponent", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseRSAN, rsaN}}), ErrUnsupportedKey},
+		{"RSA long exponent", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseRSAN, rsaN}, {coseRSAE, []byte{1, 0, 0, 0, 1}}}), ErrUnsupportedKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePublicKey(tt.cose); err != tt.want {
+				t.Errorf("parsePublicKey() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
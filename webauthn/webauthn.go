@@ -0,0 +1,416 @@
+// Package webauthn implements server side of WebAuthn registration and authentication ceremonies.
+// Attestation statements are not verified, as the server does not restrict authenticator models,
+// so credentials are created with "none" attestation conveyance preference.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/madappgang/identifo/model"
+)
+
+const (
+	// ChallengeLifespan is how long the ceremony challenge stays valid.
+	ChallengeLifespan = 5 * time.Minute
+	// Timeout is a ceremony timeout hint for the client, in milliseconds.
+	Timeout = 60000
+
+	challengeRandomLength   = 32
+	credentialTypePublicKey = "public-key"
+
+	ceremonyTypeCreate = "webauthn.create"
+	ceremonyTypeGet    = "webauthn.get"
+
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+)
+
+var (
+	// ErrInvalidCredential is returned when the credential sent by the client cannot be parsed.
+	ErrInvalidCredential = errors.New("webauthn: invalid credential")
+	// ErrChallengeMismatch is returned when the client has signed another or expired challenge.
+	ErrChallengeMismatch = errors.New("webauthn: challenge mismatch")
+	// ErrOriginMismatch is returned when the ceremony was performed on another site.
+	ErrOriginMismatch = errors.New("webauthn: origin mismatch")
+	// ErrRelyingPartyMismatch is returned when the credential is scoped to another relying party.
+	ErrRelyingPartyMismatch = errors.New("webauthn: relying party mismatch")
+	// ErrUserNotPresent is returned when the authenticator has not checked the user presence.
+	ErrUserNotPresent = errors.New("webauthn: user is not present")
+	// ErrCredentialNotFound is returned when the credential is not registered by the user.
+	ErrCredentialNotFound = errors.New("webauthn: credential not found")
+	// ErrCredentialCloned is returned when the signature counter goes backwards.
+	ErrCredentialCloned = errors.New("webauthn: signature counter mismatch, credential might be cloned")
+)
+
+var b64 = base64.RawURLEncoding
+
+// RelyingParty is a WebAuthn relying party, i.e. the server the credentials are scoped to.
+type RelyingParty struct {
+	// ID is a domain name of the relying party.
+	ID   string
+	Name string
+	// Origin is the origin ceremonies are performed on, e.g. https://example.com.
+	Origin string
+}
+
+// NewRelyingParty creates relying party for the server running on the host, e.g. https://example.com.
+func NewRelyingParty(host string) (RelyingParty, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return RelyingParty{}, err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return RelyingParty{}, fmt.Errorf("webauthn: invalid host %s", host)
+	}
+	return RelyingParty{
+		ID:     u.Hostname(),
+		Name:   u.Hostname(),
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+// PublicKeyCredentialDescriptor identifies the credential.
+type PublicKeyCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// CredentialParameters describe the type of credential to be created.
+type CredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CreationOptions are options for navigator.credentials.create(), binary values are base64url encoded.
+type CreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []CredentialParameters          `json:"pubKeyCredParams"`
+	Timeout                int                             `json:"timeout"`
+	Attestation            string                          `json:"attestation"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+}
+
+// RequestOptions are options for navigator.credentials.get(), binary values are base64url encoded.
+type RequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	RPID             string                          `json:"rpId"`
+	Timeout          int                             `json:"timeout"`
+	UserVerification string                          `json:"userVerification"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+}
+
+// Credential is a public key credential sent by the client, binary values are base64url encoded.
+type Credential struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject,omitempty"`
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// NewChallenge generates new ceremony challenge.
+// Challenge starts with its expiration time, so it can be checked regardless of the storage it is kept in.
+func NewChallenge() (string, error) {
+	b := make([]byte, 8+challengeRandomLength)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(ChallengeLifespan).Unix()))
+	if _, err := rand.Read(b[8:]); err != nil {
+		return "", err
+	}
+	return b64.EncodeToString(b), nil
+}
+
+// ChallengeExpired tells whether the challenge is malformed or expired.
+func ChallengeExpired(challenge string) bool {
+	b, err := decode(challenge)
+	if err != nil || len(b) != 8+challengeRandomLength {
+		return true
+	}
+	return time.Now().Unix() > int64(binary.BigEndian.Uint64(b))
+}
+
+// NewCreationOptions creates options for the registration ceremony.
+func (rp RelyingParty) NewCreationOptions(user model.User, challenge string) CreationOptions {
+	opts := CreationOptions{Challenge: challenge, Timeout: Timeout, Attestation: "none"}
+	opts.RP.ID = rp.ID
+	opts.RP.Name = rp.Name
+
+	name := user.Username()
+	if name == "" {
+		name = user.Email()
+	}
+	opts.User.ID = b64.EncodeToString([]byte(user.ID()))
+	opts.User.Name = name
+	opts.User.DisplayName = name
+
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, CredentialParameters{Type: credentialTypePublicKey, Alg: alg})
+	}
+	opts.ExcludeCredentials = descriptors(user.WebauthnCredentials())
+	opts.AuthenticatorSelection.ResidentKey = "preferred"
+	opts.AuthenticatorSelection.UserVerification = "preferred"
+	return opts
+}
+
+// NewRequestOptions creates options for the authentication ceremony.
+// User is optional, without it the client is offered to choose any of discoverable credentials.
+func (rp RelyingParty) NewRequestOptions(user model.User, challenge string) RequestOptions {
+	opts := RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          Timeout,
+		UserVerification: "preferred",
+		AllowCredentials: []PublicKeyCredentialDescriptor{},
+	}
+	if user != nil {
+		opts.AllowCredentials = descriptors(user.WebauthnCredentials())
+	}
+	return opts
+}
+
+// Challenge returns the challenge signed by the client, so it can be looked up in the storage.
+func (c *Credential) Challenge() (string, error) {
+	cd, err := c.clientData()
+	if err != nil {
+		return "", err
+	}
+	return cd.Challenge, nil
+}
+
+// UserID returns the user handle of discoverable credential, if any.
+func (c *Credential) UserID() string {
+	b, err := decode(c.Response.UserHandle)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// VerifyRegistration verifies the response to the registration ceremony and returns the new credential.
+func (rp RelyingParty) VerifyRegistration(c *Credential, challenge string) (model.WebauthnCredential, error) {
+	if err := rp.verifyClientData(c, ceremonyTypeCreate, challenge); err != nil {
+		return model.WebauthnCredential{}, err
+	}
+
+	attestation, err := decode(c.Response.AttestationObject)
+	if err != nil {
+		return model.WebauthnCredential{}, ErrInvalidCredential
+	}
+	decoded, _, err := decodeCBOR(attestation)
+	if err != nil {
+		return model.WebauthnCredential{}, err
+	}
+	attestationObject, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return model.WebauthnCredential{}, ErrInvalidCredential
+	}
+	authData, ok := attestationObject["authData"].([]byte)
+	if !ok {
+		return model.WebauthnCredential{}, ErrInvalidCredential
+	}
+
+	flags, signCount, err := rp.verifyAuthenticatorData(authData)
+	if err != nil {
+		return model.WebauthnCredential{}, err
+	}
+	if flags&flagAttestedCredential == 0 || len(authData) < 55 {
+		return model.WebauthnCredential{}, ErrInvalidCredential
+	}
+
+	// Attested credential data: AAGUID (16), credential ID length (2), credential ID, public key.
+	idLen := int(binary.BigEndian.Uint16(authData[53:55]))
+	if len(authData) < 55+idLen {
+		return model.WebauthnCredential{}, ErrInvalidCredential
+	}
+	credentialID := authData[55 : 55+idLen]
+	if rawID, err := decode(c.RawID); err != nil || !bytes.Equal(rawID, credentialID) {
+		return model.WebauthnCredential{}, ErrInvalidCredential
+	}
+
+	_, keyLen, err := decodeCBOR(authData[55+idLen:])
+	if err != nil {
+		return model.WebauthnCredential{}, err
+	}
+	coseKey := authData[55+idLen : 55+idLen+keyLen]
+	if _, err = parsePublicKey(coseKey); err != nil {
+		return model.WebauthnCredential{}, err
+	}
+
+	return model.WebauthnCredential{
+		ID:        b64.EncodeToString(credentialID),
+		PublicKey: coseKey,
+		SignCount: signCount,
+		CreatedAt: time.Now().Unix(),
+	}, nil
+}
+
+// VerifyLogin verifies the response to the authentication ceremony against user's credentials.
+// It returns the credential with updated signature counter and whether the user has been verified by the authenticator,
+// e.g. with biometrics or PIN. Such login is multi-factor by itself.
+func (rp RelyingParty) VerifyLogin(c *Credential, challenge string, credentials []model.WebauthnCredential) (model.WebauthnCredential, bool, error) {
+	if err := rp.verifyClientData(c, ceremonyTypeGet, challenge); err != nil {
+		return model.WebauthnCredential{}, false, err
+	}
+
+	rawID, err := decode(c.RawID)
+	if err != nil {
+		return model.WebauthnCredential{}, false, ErrInvalidCredential
+	}
+	id := b64.EncodeToString(rawID)
+
+	var credential *model.WebauthnCredential
+	for i := range credentials {
+		if credentials[i].ID == id {
+			credential = &credentials[i]
+			break
+		}
+	}
+	if credential == nil {
+		return model.WebauthnCredential{}, false, ErrCredentialNotFound
+	}
+
+	authData, err := decode(c.Response.AuthenticatorData)
+	if err != nil {
+		return model.WebauthnCredential{}, false, ErrInvalidCredential
+	}
+	flags, signCount, err := rp.verifyAuthenticatorData(authData)
+	if err != nil {
+		return model.WebauthnCredential{}, false, err
+	}
+
+	signature, err := decode(c.Response.Signature)
+	if err != nil {
+		return model.WebauthnCredential{}, false, ErrInvalidCredential
+	}
+	clientDataJSON, err := decode(c.Response.ClientDataJSON)
+	if err != nil {
+		return model.WebauthnCredential{}, false, ErrInvalidCredential
+	}
+	pk, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return model.WebauthnCredential{}, false, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if err = pk.verify(signed, signature); err != nil {
+		return model.WebauthnCredential{}, false, err
+	}
+
+	// Authenticators which do not support counters always send zero.
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return model.WebauthnCredential{}, false, ErrCredentialCloned
+	}
+
+	updated := *credential
+	updated.SignCount = signCount
+	updated.LastUsedAt = time.Now().Unix()
+	return updated, flags&flagUserVerified != 0, nil
+}
+
+// ReplaceCredential returns credentials with the one having the same ID replaced.
+func ReplaceCredential(credentials []model.WebauthnCredential, credential model.WebauthnCredential) []model.WebauthnCredential {
+	res := make([]model.WebauthnCredential, 0, len(credentials))
+	for _, c := range credentials {
+		if c.ID == credential.ID {
+			c = credential
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+func (rp RelyingParty) verifyClientData(c *Credential, ceremonyType, challenge string) error {
+	if c.Type != credentialTypePublicKey {
+		return ErrInvalidCredential
+	}
+	cd, err := c.clientData()
+	if err != nil {
+		return err
+	}
+	if cd.Type != ceremonyType {
+		return ErrInvalidCredential
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 || ChallengeExpired(challenge) {
+		return ErrChallengeMismatch
+	}
+	if strings.TrimSuffix(cd.Origin, "/") != strings.TrimSuffix(rp.Origin, "/") {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+// verifyAuthenticatorData checks the relying party and user presence, returns flags and signature counter.
+func (rp RelyingParty) verifyAuthenticatorData(authData []byte) (byte, uint32, error) {
+	if len(authData) < 37 {
+		return 0, 0, ErrInvalidCredential
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(authData[:32], rpIDHash[:]) != 1 {
+		return 0, 0, ErrRelyingPartyMismatch
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 {
+		return 0, 0, ErrUserNotPresent
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+func (c *Credential) clientData() (*clientData, error) {
+	raw, err := decode(c.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	cd := new(clientData)
+	if err = json.Unmarshal(raw, cd); err != nil {
+		return nil, ErrInvalidCredential
+	}
+	return cd, nil
+}
+
+func descriptors(credentials []model.WebauthnCredential) []PublicKeyCredentialDescriptor {
+	res := make([]PublicKeyCredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		res = append(res, PublicKeyCredentialDescriptor{Type: credentialTypePublicKey, ID: c.ID})
+	}
+	return res
+}
+
+// decode decodes base64url value, padded or not.
+func decode(s string) ([]byte, error) {
+	return b64.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,351 @@
+package webauthn
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/madappgang/identifo/model"
+)
+
+var testRP = RelyingParty{ID: "example.com", Name: "example.com", Origin: "https://example.com"}
+
+// authenticatorData builds authenticator data as described in WebAuthn, section 6.1.
+func authenticatorData(rpID string, flags byte, signCount uint32, credentialID, coseKey []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:37], signCount)
+	if flags&flagAttestedCredential == 0 {
+		return data
+	}
+
+	data = append(data, make([]byte, 16)...) // AAGUID of "none" attestation.
+	data = append(data, byte(len(credentialID)>>8), byte(len(credentialID)))
+	data = append(data, credentialID...)
+	return append(data, coseKey...)
+}
+
+func clientDataJSON(t *testing.T, ceremonyType, challenge, origin string) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]interface{}{
+		"type":        ceremonyType,
+		"challenge":   challenge,
+		"origin":      origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatalf("Unable to marshal client data: %v", err)
+	}
+	return b64.EncodeToString(b)
+}
+
+func newTestChallenge(t *testing.T) string {
+	t.Helper()
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	return challenge
+}
+
+func newCredentialID(t *testing.T) []byte {
+	t.Helper()
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatalf("Unable to generate credential ID: %v", err)
+	}
+	return id
+}
+
+func TestChallengeExpired(t *testing.T) {
+	if ChallengeExpired(newTestChallenge(t)) {
+		t.Error("ChallengeExpired() of new challenge = true")
+	}
+
+	expired := make([]byte, 8+challengeRandomLength)
+	binary.BigEndian.PutUint64(expired, uint64(time.Now().Add(-time.Second).Unix()))
+	for name, challenge := range map[string]string{
+		"expired":   b64.EncodeToString(expired),
+		"too short": b64.EncodeToString(expired[:8]),
+		"not b64":   "not base64!",
+		"empty":     "",
+	} {
+		if !ChallengeExpired(challenge) {
+			t.Errorf("ChallengeExpired() of %s challenge = false", name)
+		}
+	}
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	key := newES256Key(t)
+	credentialID := newCredentialID(t)
+	challenge := newTestChallenge(t)
+
+	attestationObject := func(authData []byte) string {
+		return b64.EncodeToString(encodeCBOR(cborMap{
+			{"fmt", "none"},
+			{"attStmt", cborMap{}},
+			{"authData", authData},
+		}))
+	}
+	validAuthData := authenticatorData(testRP.ID, flagUserPresent|flagUserVerified|flagAttestedCredential, 0, credentialID, key.cose)
+
+	newCredential := func() *Credential {
+		c := &Credential{
+			ID:    b64.EncodeToString(credentialID),
+			RawID: b64.EncodeToString(credentialID),
+			Type:  credentialTypePublicKey,
+		}
+		c.Response.ClientDataJSON = clientDataJSON(t, ceremonyTypeCreate, challenge, testRP.Origin)
+		c.Response.AttestationObject = attestationObject(validAuthData)
+		return c
+	}
+
+	c := newCredential()
+	// Browsers may send padded base64.
+	c.Response.AttestationObject = base64.URLEncoding.EncodeToString(encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", validAuthData},
+	}))
+	got, err := testRP.VerifyRegistration(c, challenge)
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error = %v", err)
+	}
+	if got.ID != b64.EncodeToString(credentialID) || string(got.PublicKey) != string(key.cose) || got.SignCount != 0 {
+		t.Errorf("VerifyRegistration() = %+v", got)
+	}
+
+	otherChallenge := newTestChallenge(t)
+	tests := []struct {
+		name   string
+		modify func(c *Credential)
+		want   error
+	}{
+		{"wrong credential type", func(c *Credential) { c.Type = "password" }, ErrInvalidCredential},
+		{"malformed client data", func(c *Credential) { c.Response.ClientDataJSON = b64.EncodeToString([]byte("{")) }, ErrInvalidCredential},
+		{"login ceremony", func(c *Credential) {
+			c.Response.ClientDataJSON = clientDataJSON(t, ceremonyTypeGet, challenge, testRP.Origin)
+		}, ErrInvalidCredential},
+		{"another challenge", func(c *Credential) {
+			c.Response.ClientDataJSON = clientDataJSON(t, ceremonyTypeCreate, otherChallenge, testRP.Origin)
+		}, ErrChallengeMismatch},
+		{"wrong origin", func(c *Credential) {
+			c.Response.ClientDataJSON = clientDataJSON(t, ceremonyTypeCreate, challenge, "https://evil.example.com")
+		}, ErrOriginMismatch},
+		{"wrong origin scheme", func(c *Credential) {
+			c.Response.ClientDataJSON = clientDataJSON(t, ceremonyTypeCreate, challenge, "http://example.com")
+		}, ErrOriginMismatch},
+		{"malformed attestation object", func(c *Credential) {
+			c.Response.AttestationObject = b64.EncodeToString([]byte{0xa3, 0x63})
+		}, ErrInvalidCBOR},
+		{"truncated attestation object", func(c *Credential) {
+			b, _ := decode(attestationObject(validAuthData))
+			c.Response.AttestationObject = b64.EncodeToString(b[:len(b)-10])
+		}, ErrInvalidCBOR},
+		{"attestation object is not a map", func(c *Credential) {
+			c.Response.AttestationObject = b64.EncodeToString(encodeCBOR(validAuthData))
+		}, ErrInvalidCredential},
+		{"no authenticator data", func(c *Credential) {
+			c.Response.AttestationObject = b64.EncodeToString(encodeCBOR(cborMap{{"fmt", "none"}}))
+		}, ErrInvalidCredential},
+		{"wrong RP ID hash", func(c *Credential) {
+			c.Response.AttestationObject = attestationObject(authenticatorData("evil.com", flagUserPresent|flagAttestedCredential, 0, credentialID, key.cose))
+		}, ErrRelyingPartyMismatch},
+		{"user not present", func(c *Credential) {
+			c.Response.AttestationObject = attestationObject(authenticatorData(testRP.ID, flagAttestedCredential, 0, credentialID, key.cose))
+		}, ErrUserNotPresent},
+		{"no attested credential", func(c *Credential) {
+			c.Response.AttestationObject = attestationObject(authenticatorData(testRP.ID, flagUserPresent, 0, credentialID, key.cose))
+		}, ErrInvalidCredential},
+		{"truncated authenticator data", func(c *Credential) {
+			c.Response.AttestationObject = attestationObject(validAuthData[:36])
+		}, ErrInvalidCredential},
+		{"truncated credential ID", func(c *Credential) {
+			c.Response.AttestationObject = attestationObject(validAuthData[:55+len(credentialID)-1])
+		}, ErrInvalidCredential},
+		{"truncated public key", func(c *Credential) {
+			c.Response.AttestationObject = attestationObject(validAuthData[:len(validAuthData)-1])
+		}, ErrInvalidCBOR},
+		{"unsupported public key", func(c *Credential) {
+			cose := encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, -35}})
+			c.Response.AttestationObject = attestationObject(authenticatorData(testRP.ID, flagUserPresent|flagAttestedCredential, 0, credentialID, cose))
+		}, ErrUnsupportedKey},
+		{"raw ID of another credential", func(c *Credential) { c.RawID = b64.EncodeToString(newCredentialID(t)) }, ErrInvalidCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCredential()
+			tt.modify(c)
+			if _, err := testRP.VerifyRegistration(c, challenge); err != tt.want {
+				t.Errorf("VerifyRegistration() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	expired := make([]byte, 8+challengeRandomLength)
+	binary.BigEndian.PutUint64(expired, uint64(time.Now().Add(-time.Second).Unix()))
+	expiredChallenge := b64.EncodeToString(expired)
+	c = newCredential()
+	c.Response.ClientDataJSON = clientDataJSON(t, ceremonyTypeCreate, expiredChallenge, testRP.Origin)
+	if _, err = testRP.VerifyRegistration(c, expiredChallenge); err != ErrChallengeMismatch {
+		t.Errorf("VerifyRegistration() with expired challenge error = %v, want %v", err, ErrChallengeMismatch)
+	}
+}
+
+// assertion is the data the authenticator signs on login.
+type assertion struct {
+	rpID      string
+	flags     byte
+	signCount uint32
+	ceremony  string
+	origin    string
+	challenge string
+}
+
+func (a assertion) credential(t *testing.T, key testKey, credentialID []byte) *Credential {
+	t.Helper()
+	authData := authenticatorData(a.rpID, a.flags, a.signCount, nil, nil)
+	cdJSON := clientDataJSON(t, a.ceremony, a.challenge, a.origin)
+	raw, _ := decode(cdJSON)
+	clientDataHash := sha256.Sum256(raw)
+
+	c := &Credential{
+		ID:    b64.EncodeToString(credentialID),
+		RawID: b64.EncodeToString(credentialID),
+		Type:  credentialTypePublicKey,
+	}
+	c.Response.ClientDataJSON = cdJSON
+	c.Response.AuthenticatorData = b64.EncodeToString(authData)
+	c.Response.Signature = b64.EncodeToString(key.sign(append(authData, clientDataHash[:]...)))
+	return c
+}
+
+func TestVerifyLogin(t *testing.T) {
+	keys := map[string]testKey{
+		"ES256": newES256Key(t),
+		"EdDSA": newEdDSAKey(t),
+		"RS256": newRS256Key(t),
+	}
+
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			credentialID := newCredentialID(t)
+			challenge := newTestChallenge(t)
+			stored := []model.WebauthnCredential{
+				{ID: b64.EncodeToString(newCredentialID(t)), PublicKey: newEdDSAKey(t).cose, SignCount: 3},
+				{ID: b64.EncodeToString(credentialID), PublicKey: key.cose, SignCount: 10},
+			}
+			valid := assertion{
+				rpID:      testRP.ID,
+				flags:     flagUserPresent | flagUserVerified,
+				signCount: 11,
+				ceremony:  ceremonyTypeGet,
+				origin:    testRP.Origin,
+				challenge: challenge,
+			}
+
+			got, verified, err := testRP.VerifyLogin(valid.credential(t, key, credentialID), challenge, stored)
+			if err != nil {
+				t.Fatalf("VerifyLogin() error = %v", err)
+			}
+			if !verified {
+				t.Error("VerifyLogin() user verified = false")
+			}
+			if got.ID != stored[1].ID || got.SignCount != 11 || got.LastUsedAt == 0 {
+				t.Errorf("VerifyLogin() = %+v", got)
+			}
+
+			presentOnly := valid
+			presentOnly.flags = flagUserPresent
+			if _, verified, err = testRP.VerifyLogin(presentOnly.credential(t, key, credentialID), challenge, stored); err != nil || verified {
+				t.Errorf("VerifyLogin() without user verification = %v, %v", verified, err)
+			}
+
+			// Authenticators without counters always send zero.
+			noCounter := valid
+			noCounter.signCount = 0
+			zeroStored := []model.WebauthnCredential{{ID: stored[1].ID, PublicKey: key.cose}}
+			if _, _, err = testRP.VerifyLogin(noCounter.credential(t, key, credentialID), challenge, zeroStored); err != nil {
+				t.Errorf("VerifyLogin() without counter error = %v", err)
+			}
+
+			tests := []struct {
+				name   string
+				modify func(a *assertion)
+				cred   func(c *Credential)
+				want   error
+			}{
+				{name: "counter goes backwards", modify: func(a *assertion) { a.signCount = 5 }, want: ErrCredentialCloned},
+				{name: "counter does not change", modify: func(a *assertion) { a.signCount = 10 }, want: ErrCredentialCloned},
+				{name: "counter reset to zero", modify: func(a *assertion) { a.signCount = 0 }, want: ErrCredentialCloned},
+				{name: "wrong RP ID hash", modify: func(a *assertion) { a.rpID = "evil.com" }, want: ErrRelyingPartyMismatch},
+				{name: "wrong origin", modify: func(a *assertion) { a.origin = "https://evil.com" }, want: ErrOriginMismatch},
+				{name: "registration ceremony", modify: func(a *assertion) { a.ceremony = ceremonyTypeCreate }, want: ErrInvalidCredential},
+				{name: "another challenge", modify: func(a *assertion) { a.challenge = newTestChallenge(t) }, want: ErrChallengeMismatch},
+				{name: "user not present", modify: func(a *assertion) { a.flags = flagUserVerified }, want: ErrUserNotPresent},
+				{name: "bad signature", cred: func(c *Credential) {
+					sig, _ := decode(c.Response.Signature)
+					sig[len(sig)-1] ^= 0xff
+					c.Response.Signature = b64.EncodeToString(sig)
+				}, want: ErrInvalidSignature},
+				{name: "signature of other data", cred: func(c *Credential) {
+					c.Response.Signature = b64.EncodeToString(key.sign([]byte("other data")))
+				}, want: ErrInvalidSignature},
+				{name: "signature made by another key", cred: func(c *Credential) {
+					authData, _ := decode(c.Response.AuthenticatorData)
+					raw, _ := decode(c.Response.ClientDataJSON)
+					hash := sha256.Sum256(raw)
+					c.Response.Signature = b64.EncodeToString(newES256Key(t).sign(append(authData, hash[:]...)))
+				}, want: ErrInvalidSignature},
+				{name: "tampered authenticator data", cred: func(c *Credential) {
+					authData, _ := decode(c.Response.AuthenticatorData)
+					authData[36]++
+					c.Response.AuthenticatorData = b64.EncodeToString(authData)
+				}, want: ErrInvalidSignature},
+				{name: "truncated authenticator data", cred: func(c *Credential) {
+					authData, _ := decode(c.Response.AuthenticatorData)
+					c.Response.AuthenticatorData = b64.EncodeToString(authData[:36])
+				}, want: ErrInvalidCredential},
+				{name: "unknown credential", cred: func(c *Credential) {
+					c.RawID = b64.EncodeToString(newCredentialID(t))
+				}, want: ErrCredentialNotFound},
+				{name: "malformed raw ID", cred: func(c *Credential) { c.RawID = "not base64!" }, want: ErrInvalidCredential},
+				{name: "malformed signature", cred: func(c *Credential) { c.Response.Signature = "not base64!" }, want: ErrInvalidCredential},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					a := valid
+					if tt.modify != nil {
+						tt.modify(&a)
+					}
+					c := a.credential(t, key, credentialID)
+					if tt.cred != nil {
+						tt.cred(c)
+					}
+					if _, _, err := testRP.VerifyLogin(c, challenge, stored); err != tt.want {
+						t.Errorf("VerifyLogin() error = %v, want %v", err, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestNewRelyingParty(t *testing.T) {
+	rp, err := NewRelyingParty("https://login.example.com:8443/web")
+	if err != nil {
+		t.Fatalf("NewRelyingParty() error = %v", err)
+	}
+	if rp.ID != "login.example.com" || rp.Origin != "https://login.example.com:8443" {
+		t.Errorf("NewRelyingParty() = %+v", rp)
+	}
+	if _, err = NewRelyingParty("login.example.com"); err == nil {
+		t.Error("NewRelyingParty() accepts host without scheme")
+	}
+}